@@ -0,0 +1,165 @@
+// MPC Artwork downloads, verifies and caches the images of the videos. The images are decoded and encoded again
+// so only the pixels are saved, and they are stored by the hash of their content with resized variants
+//
+package mpcartwork
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"image"
+	_ "image/gif"
+	"image/jpeg"
+	_ "image/png"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const (
+	// MaxImageSize is the max size of the downloaded images
+	MaxImageSize = 10 << 20
+	// MaxPixels is the max width by height of the images, it stops the small files that decode to huge images
+	MaxPixels = 40 << 20
+	// DownloadTimeout is how long a download can take
+	DownloadTimeout = 30 * time.Second
+	// Quality of the JPEG images of the cache
+	Quality = 90
+)
+
+// imageTypes are the content types and the formats of the images that can be decoded
+var imageTypes = map[string]string{
+	"image/jpeg": "jpeg",
+	"image/png":  "png",
+	"image/gif":  "gif",
+}
+
+var client = &http.Client{Timeout: DownloadTimeout}
+
+// Download gets an image from a URL, the response must be an image of a supported type smaller than the max size
+//
+func Download(ctx context.Context, url string) (img image.Image, err error) {
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return
+	}
+
+	response, err := client.Do(request)
+	if err != nil {
+		return
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return nil, errors.New("artwork_download_failed: " + response.Status)
+	}
+
+	contentType, _, err := mime.ParseMediaType(response.Header.Get("Content-Type"))
+	if err != nil || imageTypes[contentType] == "" {
+		return nil, errors.New("artwork_content_type_invalid")
+	}
+
+	if response.ContentLength > MaxImageSize {
+		return nil, errors.New("artwork_too_large")
+	}
+
+	data, err := ioutil.ReadAll(io.LimitReader(response.Body, MaxImageSize+1))
+	if err != nil {
+		return
+	}
+
+	if len(data) > MaxImageSize {
+		return nil, errors.New("artwork_too_large")
+	}
+
+	return Decode(data)
+}
+
+// DownloadFile downloads an image and saves it as a JPEG file
+//
+func DownloadFile(url string, path string) error {
+	img, err := Download(context.Background(), url)
+	if err != nil {
+		return err
+	}
+
+	var data bytes.Buffer
+
+	err = Encode(&data, img)
+	if err != nil {
+		return err
+	}
+
+	return writeFile(path, data.Bytes())
+}
+
+// Decode checks the format and the size of an image before it decodes it
+//
+func Decode(data []byte) (image.Image, error) {
+	config, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, errors.New("artwork_not_image")
+	}
+
+	if !supportedFormat(format) {
+		return nil, errors.New("artwork_format_invalid")
+	}
+
+	if config.Width <= 0 || config.Height <= 0 || config.Width*config.Height > MaxPixels {
+		return nil, errors.New("artwork_too_large")
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, errors.New("artwork_not_image")
+	}
+
+	return img, nil
+}
+
+// Encode writes the image as a JPEG, the metadata and the data after the image of the original file are not kept
+//
+func Encode(w io.Writer, img image.Image) error {
+	return jpeg.Encode(w, img, &jpeg.Options{Quality: Quality})
+}
+
+func supportedFormat(format string) bool {
+	for _, supported := range imageTypes {
+		if supported == format {
+			return true
+		}
+	}
+
+	return false
+}
+
+// writeFile writes the file in a temporary file that is renamed, the readers never see a file that is being written
+//
+func writeFile(path string, data []byte) error {
+	err := os.MkdirAll(filepath.Dir(path), 0700)
+	if err != nil {
+		return err
+	}
+
+	temp, err := ioutil.TempFile(filepath.Dir(path), ".artwork-")
+	if err != nil {
+		return err
+	}
+
+	_, err = temp.Write(data)
+
+	if closeErr := temp.Close(); err == nil {
+		err = closeErr
+	}
+
+	if err != nil {
+		os.Remove(temp.Name())
+		return err
+	}
+
+	return os.Rename(temp.Name(), path)
+}
@@ -0,0 +1,215 @@
+package mpcartwork
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+)
+
+func testImage(width int, height int) image.Image {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			img.Set(x, y, color.RGBA{R: uint8(x), G: uint8(y), B: 128, A: 255})
+		}
+	}
+
+	return img
+}
+
+func pngData(t *testing.T, img image.Image) []byte {
+	var data bytes.Buffer
+
+	err := png.Encode(&data, img)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return data.Bytes()
+}
+
+func TestDownload(t *testing.T) {
+	imageData := pngData(t, testImage(40, 30))
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/image.png":
+			w.Header().Set("Content-Type", "image/png")
+			w.Write(imageData)
+		case "/page.html":
+			w.Header().Set("Content-Type", "text/html")
+			w.Write(imageData)
+		case "/fake.jpg":
+			w.Header().Set("Content-Type", "image/jpeg")
+			w.Write([]byte("<?php echo 'not an image'; ?>"))
+		case "/large.jpg":
+			w.Header().Set("Content-Type", "image/jpeg")
+			w.Write(make([]byte, MaxImageSize+1))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	img, err := Download(context.Background(), server.URL+"/image.png")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if img.Bounds().Dx() != 40 || img.Bounds().Dy() != 30 {
+		t.Error("wrong image size", img.Bounds())
+	}
+
+	for path, expected := range map[string]string{
+		"/page.html":   "artwork_content_type_invalid",
+		"/fake.jpg":    "artwork_not_image",
+		"/large.jpg":   "artwork_too_large",
+		"/missing.jpg": "artwork_download_failed: 404 Not Found",
+	} {
+		_, err := Download(context.Background(), server.URL+path)
+		if err == nil || err.Error() != expected {
+			t.Error(path, "should fail with", expected, err)
+		}
+	}
+
+	target := t.TempDir() + "/thumb.jpg"
+
+	err = DownloadFile(server.URL+"/image.png", target)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	file, err := os.Open(target)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	if _, err := jpeg.DecodeConfig(file); err != nil {
+		t.Error("the image should be saved as JPEG", err)
+	}
+}
+
+func TestStore(t *testing.T) {
+	store := &Store{Path: t.TempDir()}
+
+	data := append(pngData(t, testImage(800, 600)), []byte("payload after the image")...)
+
+	hash, err := store.Import(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	again, err := store.Import(pngData(t, testImage(800, 600)))
+	if err != nil || again != hash {
+		t.Error("the same image should have the same hash", hash, again, err)
+	}
+
+	original, err := store.File(hash, VariantOriginal)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	content, _ := os.ReadFile(original)
+	if bytes.Contains(content, []byte("payload")) {
+		t.Error("the data after the image should not be saved")
+	}
+
+	for variant, expected := range map[string][2]int{VariantThumb: {240, 180}, VariantCard: {480, 360}, VariantBackdrop: {800, 600}} {
+		path, err := store.File(hash, variant)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		file, err := os.Open(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		config, err := jpeg.DecodeConfig(file)
+		file.Close()
+
+		if err != nil || config.Width != expected[0] || config.Height != expected[1] {
+			t.Error(variant, "should fit in its size", config.Width, config.Height, err)
+		}
+	}
+
+	// the variants that are missing are created again
+	thumbPath, _ := store.File(hash, VariantThumb)
+	os.Remove(thumbPath)
+
+	if _, err := store.File(hash, VariantThumb); err != nil || !fileExists(thumbPath) {
+		t.Error("the missing variant should be created", err)
+	}
+
+	if _, err := store.File("../../etc/passwd", VariantOriginal); err == nil {
+		t.Error("invalid hashes should be rejected")
+	}
+
+	if _, err := store.File(hash, "huge"); err == nil {
+		t.Error("unknown variants should be rejected")
+	}
+
+	err = store.Link("video-id", hash, "https://example.com/poster.jpg")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if linked, ok := store.Lookup("video-id", "https://example.com/poster.jpg"); !ok || linked != hash {
+		t.Error("the key should be linked to the image", linked)
+	}
+
+	if _, ok := store.Lookup("video-id", "https://example.com/new-poster.jpg"); ok {
+		t.Error("the image of another source should not be found")
+	}
+
+	if _, ok := store.Lookup("other-id", ""); ok {
+		t.Error("keys without image should not be found")
+	}
+
+	if err := store.Link("../video", hash, ""); err == nil {
+		t.Error("keys with paths should be rejected")
+	}
+}
+
+func TestFitSize(t *testing.T) {
+	tests := []struct {
+		width, height       int
+		fitWidth, fitHeight int
+	}{
+		{1920, 1080, 320, 180},
+		{1080, 1920, 101, 180},
+		{100, 50, 100, 50},
+		{4000, 10, 320, 1},
+	}
+
+	for _, test := range tests {
+		width, height := FitSize(test.width, test.height, Variants[VariantThumb])
+
+		if width != test.fitWidth || height != test.fitHeight {
+			t.Error(test.width, test.height, "should fit in", width, height)
+		}
+	}
+
+	resized := Resize(testImage(64, 64), Size{Width: 2, Height: 2})
+
+	if resized.Bounds().Dx() != 2 || resized.Bounds().Dy() != 2 {
+		t.Fatal("wrong resized size", resized.Bounds())
+	}
+
+	// every pixel is the average of a 32x32 block where the red channel goes from 0 to 31 or from 32 to 63
+	r, _, _, _ := resized.At(0, 0).RGBA()
+	r2, _, _, _ := resized.At(1, 0).RGBA()
+
+	if r>>8 != 15 || r2>>8 != 47 {
+		t.Error("the pixels should be averaged", r>>8, r2>>8)
+	}
+}
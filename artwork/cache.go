@@ -0,0 +1,78 @@
+package mpcartwork
+
+import (
+	"github.com/jempe/encdec"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Cache saves the resized images on disk, the images of the encrypted videos are saved encrypted with the key
+type Cache struct {
+	Path string
+	Key  []byte
+}
+
+// Load gets a cached image and the time when it was saved
+//
+func (cache *Cache) Load(name string, encrypted bool) (data []byte, modTime time.Time, err error) {
+	path, err := cache.path(name, encrypted)
+	if err != nil {
+		return
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return
+	}
+
+	data, err = ioutil.ReadFile(path)
+	if err != nil {
+		return
+	}
+
+	if encrypted {
+		data, err = encdec.Decrypt(data, cache.Key)
+	}
+
+	return data, info.ModTime(), err
+}
+
+// Save writes an image in the cache
+//
+func (cache *Cache) Save(name string, data []byte, encrypted bool) (err error) {
+	path, err := cache.path(name, encrypted)
+	if err != nil {
+		return
+	}
+
+	if encrypted {
+		data, err = encdec.Encrypt(data, cache.Key)
+		if err != nil {
+			return
+		}
+	}
+
+	return writeFile(path, data)
+}
+
+// path gets the path of an image, the encrypted images have the .enc extension like the files of the library
+//
+func (cache *Cache) path(name string, encrypted bool) (string, error) {
+	if strings.Contains(name, "..") || strings.ContainsAny(name, `\`) || strings.HasPrefix(name, "/") {
+		return "", errors.New("cache_name_invalid")
+	}
+
+	if encrypted {
+		if len(cache.Key) == 0 {
+			return "", errors.New("cache_key_empty")
+		}
+
+		name += ".enc"
+	}
+
+	return filepath.Join(cache.Path, filepath.FromSlash(name)), nil
+}
@@ -0,0 +1,79 @@
+package mpcartwork
+
+import (
+	"bytes"
+	"image"
+	"io"
+	"os"
+	"testing"
+)
+
+func TestCache(t *testing.T) {
+	cache := &Cache{Path: t.TempDir(), Key: []byte("ThisisAT3stKey12")}
+
+	err := cache.Save("thumbs/video_w320.jpg", []byte("plain image"), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = cache.Save("thumbs/secret_w320.jpg", []byte("secret image"), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	encrypted, err := os.ReadFile(cache.Path + "/thumbs/secret_w320.jpg.enc")
+	if err != nil || bytes.Contains(encrypted, []byte("secret image")) {
+		t.Error("the images of the encrypted videos should be encrypted", err)
+	}
+
+	data, modTime, err := cache.Load("thumbs/secret_w320.jpg", true)
+	if err != nil || string(data) != "secret image" || modTime.IsZero() {
+		t.Error("the encrypted image should be decrypted", string(data), err)
+	}
+
+	data, _, err = cache.Load("thumbs/video_w320.jpg", false)
+	if err != nil || string(data) != "plain image" {
+		t.Error("wrong cached image", string(data), err)
+	}
+
+	if _, _, err := cache.Load("thumbs/missing.jpg", false); err == nil {
+		t.Error("missing images should fail")
+	}
+
+	if err := cache.Save("../outside.jpg", []byte("image"), false); err == nil {
+		t.Error("names outside the cache should be rejected")
+	}
+
+	if err := (&Cache{Path: cache.Path}).Save("thumbs/other.jpg", []byte("image"), true); err == nil {
+		t.Error("encrypted images should not be saved without key")
+	}
+}
+
+func TestNegotiateEncoder(t *testing.T) {
+	defer func(registered []Encoder) {
+		encoders = registered
+	}(append([]Encoder{}, encoders...))
+
+	browser := "image/avif,image/webp,image/apng,image/*,*/*;q=0.8"
+
+	if encoder := NegotiateEncoder(browser); encoder.ContentType != "image/jpeg" {
+		t.Error("JPEG should be used when there is no WebP encoder", encoder.ContentType)
+	}
+
+	webp := Encoder{ContentType: "image/webp", Extension: ".webp", Encode: func(w io.Writer, img image.Image) error {
+		_, err := w.Write([]byte("webp"))
+		return err
+	}}
+
+	RegisterEncoder(webp)
+
+	if encoder := NegotiateEncoder(browser); encoder.ContentType != "image/webp" || encoder.Extension != ".webp" {
+		t.Error("WebP should be used when the client accepts it", encoder.ContentType)
+	}
+
+	for _, accept := range []string{"", "*/*", "image/*", "image/webp;q=0", "image/jpeg"} {
+		if encoder := NegotiateEncoder(accept); encoder.ContentType != "image/jpeg" {
+			t.Error(accept, "should get JPEG", encoder.ContentType)
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package mpcartwork
+
+import (
+	"image"
+	"io"
+	"mime"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Encoder writes images in a format that the browsers can show
+type Encoder struct {
+	ContentType string
+	// Extension is the extension of the cached files of the format
+	Extension string
+	Encode    func(w io.Writer, img image.Image) error
+}
+
+// JPEG is the encoder of the images when the clients don't accept other formats
+var JPEG = Encoder{ContentType: "image/jpeg", Extension: ".jpg", Encode: Encode}
+
+var (
+	encoders      []Encoder
+	encodersMutex sync.RWMutex
+)
+
+// RegisterEncoder adds an encoder that is preferred to JPEG when the clients accept it, like a WebP encoder.
+// The standard library has no WebP encoder, so WebP is only served when one is registered
+//
+func RegisterEncoder(encoder Encoder) {
+	encodersMutex.Lock()
+	defer encodersMutex.Unlock()
+
+	for index, registered := range encoders {
+		if registered.ContentType == encoder.ContentType {
+			encoders[index] = encoder
+			return
+		}
+	}
+
+	encoders = append(encoders, encoder)
+}
+
+// NegotiateEncoder gets the first registered encoder that the Accept header allows, JPEG is used when
+// the header doesn't allow any of them
+//
+func NegotiateEncoder(accept string) Encoder {
+	accepted := acceptedTypes(accept)
+
+	encodersMutex.RLock()
+	defer encodersMutex.RUnlock()
+
+	for _, encoder := range encoders {
+		if accepted[encoder.ContentType] {
+			return encoder
+		}
+	}
+
+	return JPEG
+}
+
+// acceptedTypes gets the types of the Accept header that have a quality above zero,
+// the wildcards are skipped because every browser sends them
+//
+func acceptedTypes(accept string) map[string]bool {
+	accepted := make(map[string]bool)
+
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil || strings.Contains(mediaType, "*") {
+			continue
+		}
+
+		if quality, ok := params["q"]; ok {
+			if value, err := strconv.ParseFloat(quality, 64); err != nil || value <= 0 {
+				continue
+			}
+		}
+
+		accepted[mediaType] = true
+	}
+
+	return accepted
+}
@@ -0,0 +1,84 @@
+package mpcartwork
+
+import (
+	"image"
+	"image/draw"
+)
+
+// Resize scales the image to fit in the size, every pixel is the average of the pixels that it covers.
+// The images that already fit are copied without changes
+//
+func Resize(img image.Image, size Size) image.Image {
+	bounds := img.Bounds()
+	width, height := FitSize(bounds.Dx(), bounds.Dy(), size)
+
+	source := image.NewRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(source, source.Bounds(), img, bounds.Min, draw.Src)
+
+	if width == bounds.Dx() && height == bounds.Dy() {
+		return source
+	}
+
+	resized := image.NewRGBA(image.Rect(0, 0, width, height))
+
+	for y := 0; y < height; y++ {
+		y0 := y * bounds.Dy() / height
+		y1 := maxInt((y+1)*bounds.Dy()/height, y0+1)
+
+		for x := 0; x < width; x++ {
+			x0 := x * bounds.Dx() / width
+			x1 := maxInt((x+1)*bounds.Dx()/width, x0+1)
+
+			var r, g, b, a, count int
+
+			for sy := y0; sy < y1; sy++ {
+				offset := source.PixOffset(x0, sy)
+
+				for sx := x0; sx < x1; sx++ {
+					r += int(source.Pix[offset])
+					g += int(source.Pix[offset+1])
+					b += int(source.Pix[offset+2])
+					a += int(source.Pix[offset+3])
+					offset += 4
+					count++
+				}
+			}
+
+			offset := resized.PixOffset(x, y)
+			resized.Pix[offset] = uint8(r / count)
+			resized.Pix[offset+1] = uint8(g / count)
+			resized.Pix[offset+2] = uint8(b / count)
+			resized.Pix[offset+3] = uint8(a / count)
+		}
+	}
+
+	return resized
+}
+
+// FitSize gets the dimensions of an image scaled to fit in the size, the images are not enlarged.
+// A size without height only limits the width
+//
+func FitSize(width int, height int, size Size) (int, int) {
+	if size.Height == 0 {
+		size.Height = height
+	}
+
+	if width <= size.Width && height <= size.Height {
+		return width, height
+	}
+
+	// the side that is further from the box sets the scale
+	if width*size.Height > height*size.Width {
+		return size.Width, maxInt(height*size.Width/width, 1)
+	}
+
+	return maxInt(width*size.Height/height, 1), size.Height
+}
+
+func maxInt(a int, b int) int {
+	if a > b {
+		return a
+	}
+
+	return b
+}
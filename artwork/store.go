@@ -0,0 +1,197 @@
+package mpcartwork
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"image"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// variants of the images
+const (
+	VariantOriginal = "original"
+	VariantThumb    = "thumb"
+	VariantCard     = "card"
+	VariantBackdrop = "backdrop"
+)
+
+// Size is the box where a variant fits, the images keep their aspect ratio and they are not enlarged
+type Size struct {
+	Width  int `json:"width"`
+	Height int `json:"height"`
+}
+
+// Variants are the sizes of the resized images
+var Variants = map[string]Size{
+	VariantThumb:    {Width: 320, Height: 180},
+	VariantCard:     {Width: 640, Height: 360},
+	VariantBackdrop: {Width: 1920, Height: 1080},
+}
+
+// Store is a cache of images saved by the hash of their content, the same image is saved once
+// even when many videos use it. The videos are linked to the images by a key, like the video ID
+type Store struct {
+	Path string
+}
+
+// Save encodes the image and saves it with its variants, it returns the hash of the image
+//
+func (store *Store) Save(img image.Image) (hash string, err error) {
+	var data bytes.Buffer
+
+	err = Encode(&data, img)
+	if err != nil {
+		return
+	}
+
+	sum := sha256.Sum256(data.Bytes())
+	hash = hex.EncodeToString(sum[:])
+
+	originalPath := store.path(hash, VariantOriginal)
+
+	if _, err = os.Stat(originalPath); err == nil {
+		return hash, nil
+	}
+
+	err = writeFile(originalPath, data.Bytes())
+	if err != nil {
+		return
+	}
+
+	for variant, size := range Variants {
+		err = store.saveVariant(img, hash, variant, size)
+		if err != nil {
+			return
+		}
+	}
+
+	return hash, nil
+}
+
+// Import verifies the data of an image file and saves it
+//
+func (store *Store) Import(data []byte) (hash string, err error) {
+	img, err := Decode(data)
+	if err != nil {
+		return
+	}
+
+	return store.Save(img)
+}
+
+// Link saves the hash of the image of a key with the source of the image, like its URL or the time of its file
+//
+func (store *Store) Link(key string, hash string, source string) error {
+	if !validKey(key) || !validHash(hash) {
+		return errors.New("artwork_key_invalid")
+	}
+
+	return writeFile(filepath.Join(store.Path, "refs", key), []byte(hash+"\n"+source))
+}
+
+// Lookup gets the hash of the image of a key, it's not found when the image was linked from another source
+//
+func (store *Store) Lookup(key string, source string) (hash string, ok bool) {
+	if !validKey(key) {
+		return
+	}
+
+	data, err := ioutil.ReadFile(filepath.Join(store.Path, "refs", key))
+	if err != nil {
+		return
+	}
+
+	ref := strings.SplitN(string(data), "\n", 2)
+	if len(ref) != 2 || ref[1] != source {
+		return
+	}
+
+	hash = strings.TrimSpace(ref[0])
+
+	return hash, validHash(hash) && fileExists(store.path(hash, VariantOriginal))
+}
+
+// File gets the path of a variant of an image, the variants that are missing are created from the original
+//
+func (store *Store) File(hash string, variant string) (path string, err error) {
+	if !validHash(hash) {
+		return "", errors.New("artwork_hash_invalid")
+	}
+
+	size, ok := Variants[variant]
+	if variant != VariantOriginal && !ok {
+		return "", errors.New("artwork_variant_invalid")
+	}
+
+	path = store.path(hash, variant)
+
+	if fileExists(path) {
+		return path, nil
+	}
+
+	data, err := ioutil.ReadFile(store.path(hash, VariantOriginal))
+	if err != nil {
+		return "", errors.New("artwork_not_exists")
+	}
+
+	if variant == VariantOriginal {
+		return path, nil
+	}
+
+	img, err := Decode(data)
+	if err != nil {
+		return
+	}
+
+	return path, store.saveVariant(img, hash, variant, size)
+}
+
+func (store *Store) saveVariant(img image.Image, hash string, variant string, size Size) error {
+	var data bytes.Buffer
+
+	err := Encode(&data, Resize(img, size))
+	if err != nil {
+		return err
+	}
+
+	return writeFile(store.path(hash, variant), data.Bytes())
+}
+
+// path gets the path of a variant, the images are split in folders by the first two characters of the hash
+//
+func (store *Store) path(hash string, variant string) string {
+	name := hash + ".jpg"
+
+	if variant != VariantOriginal {
+		name = hash + "_" + variant + ".jpg"
+	}
+
+	return filepath.Join(store.Path, hash[:2], name)
+}
+
+func validHash(hash string) bool {
+	if len(hash) != sha256.Size*2 {
+		return false
+	}
+
+	_, err := hex.DecodeString(hash)
+
+	return err == nil
+}
+
+// validKey checks that the key can be used as a file name
+//
+func validKey(key string) bool {
+	return key != "" && key != "." && key != ".." && !strings.ContainsAny(key, `/\`)
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+
+	return err == nil
+}
@@ -6,6 +6,7 @@ import (
 	"encoding/base64"
 	"errors"
 	"fmt"
+	"sync"
 	jwt "github.com/dgrijalva/jwt-go"
 )
 
@@ -13,6 +14,10 @@ type Auth struct {
 	Key            []byte
 	Storage        *mpcstorage.Storage
 	Authorizations []AuthData
+	// MediaKeyGeneration is the generation of the key of the signed media URLs, it is saved in the settings
+	MediaKeyGeneration int
+
+	mediaMutex sync.RWMutex
 }
 
 type AuthData struct {
@@ -100,6 +105,10 @@ func (auth *Auth) ValidateToken(tokenString string) (sessionId string, err error
 		}
 	})
 
+	if err != nil {
+		return
+	}
+
 	if claims, ok := token.Claims.(jwt.MapClaims); ok && token.Valid {
 		return claims["sessionID"].(string), nil
 	}
@@ -0,0 +1,154 @@
+package mpcauth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// operations that a signed media URL can allow
+const (
+	OperationStream     = "stream"
+	OperationThumb      = "thumb"
+	OperationScreenshot = "screenshot"
+)
+
+// MediaOperations are all the operations of the signed media URLs
+var MediaOperations = []string{OperationStream, OperationThumb, OperationScreenshot}
+
+// MediaClaims are the data of a signed media URL, the short JSON keys keep the URLs short
+type MediaClaims struct {
+	Video      string   `json:"v"`
+	User       string   `json:"u,omitempty"`
+	Operations []string `json:"o"`
+	Expires    int64    `json:"e"`
+	Generation int      `json:"g"`
+}
+
+// Allows checks if the claims allow the operation
+//
+func (claims MediaClaims) Allows(operation string) bool {
+	for _, allowed := range claims.Operations {
+		if allowed == operation {
+			return true
+		}
+	}
+
+	return false
+}
+
+// SignMedia signs the claims with the current media key, the signature is the base64 claims and the base64 HMAC
+// separated by a dot
+//
+func (auth *Auth) SignMedia(claims MediaClaims) (signature string, err error) {
+	if claims.Video == "" || len(claims.Operations) == 0 {
+		return "", errors.New("media_claims_invalid")
+	}
+
+	for _, operation := range claims.Operations {
+		if !(MediaClaims{Operations: MediaOperations}).Allows(operation) {
+			return "", errors.New("media_operation_invalid")
+		}
+	}
+
+	claims.Generation = auth.CurrentMediaKeyGeneration()
+
+	data, err := json.Marshal(claims)
+	if err != nil {
+		return
+	}
+
+	payload := base64.RawURLEncoding.EncodeToString(data)
+
+	return payload + "." + base64.RawURLEncoding.EncodeToString(auth.mediaMAC(claims.Generation, payload)), nil
+}
+
+// ValidateMediaSignature checks the signature and returns its claims, the signatures of the previous
+// key generations and the expired signatures are rejected
+//
+func (auth *Auth) ValidateMediaSignature(signature string) (claims MediaClaims, err error) {
+	parts := strings.Split(signature, ".")
+	if len(parts) != 2 {
+		return claims, errors.New("media_signature_invalid")
+	}
+
+	mac, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return claims, errors.New("media_signature_invalid")
+	}
+
+	data, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return claims, errors.New("media_signature_invalid")
+	}
+
+	err = json.Unmarshal(data, &claims)
+	if err != nil {
+		return claims, errors.New("media_signature_invalid")
+	}
+
+	if claims.Generation != auth.CurrentMediaKeyGeneration() {
+		return claims, errors.New("media_signature_revoked")
+	}
+
+	if !hmac.Equal(mac, auth.mediaMAC(claims.Generation, parts[0])) {
+		return claims, errors.New("media_signature_invalid")
+	}
+
+	if time.Now().Unix() > claims.Expires {
+		return claims, errors.New("media_signature_expired")
+	}
+
+	return claims, nil
+}
+
+// CurrentMediaKeyGeneration gets the generation of the key that signs the media URLs
+//
+func (auth *Auth) CurrentMediaKeyGeneration() int {
+	auth.mediaMutex.RLock()
+	defer auth.mediaMutex.RUnlock()
+
+	return auth.MediaKeyGeneration
+}
+
+// RotateMediaKey changes the key of the media URLs and saves its generation in the settings,
+// all the signed URLs stop working
+//
+func (auth *Auth) RotateMediaKey() (generation int, err error) {
+	auth.mediaMutex.Lock()
+	defer auth.mediaMutex.Unlock()
+
+	settings, err := auth.Storage.GetSettings()
+	if err != nil {
+		return
+	}
+
+	settings.MediaKeyGeneration = auth.MediaKeyGeneration + 1
+
+	err = auth.Storage.SaveSettings(settings)
+	if err != nil {
+		return
+	}
+
+	auth.MediaKeyGeneration = settings.MediaKeyGeneration
+
+	return auth.MediaKeyGeneration, nil
+}
+
+// mediaMAC signs the payload with the media key of the generation, the media key is derived from the HMAC key
+// so the session tokens don't change when it is rotated
+//
+func (auth *Auth) mediaMAC(generation int, payload string) []byte {
+	keyMAC := hmac.New(sha256.New, auth.Key)
+	keyMAC.Write([]byte("media:" + strconv.Itoa(generation)))
+
+	mac := hmac.New(sha256.New, keyMAC.Sum(nil))
+	mac.Write([]byte(payload))
+
+	return mac.Sum(nil)
+}
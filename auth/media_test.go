@@ -0,0 +1,102 @@
+package mpcauth
+
+import (
+	"github.com/jempe/mpc/storage"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestAuth(t *testing.T) *Auth {
+	storage := &mpcstorage.Storage{Path: t.TempDir()}
+
+	err := storage.InitDb()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() {
+		storage.Db.Close()
+	})
+
+	return &Auth{Key: []byte("test-key"), Storage: storage}
+}
+
+func TestMediaSignature(t *testing.T) {
+	auth := newTestAuth(t)
+
+	claims := MediaClaims{Video: "abc", User: "user-1", Operations: []string{OperationStream}, Expires: time.Now().Add(time.Hour).Unix()}
+
+	signature, err := auth.SignMedia(claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	validated, err := auth.ValidateMediaSignature(signature)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if validated.Video != "abc" || validated.User != "user-1" || !validated.Allows(OperationStream) || validated.Allows(OperationThumb) {
+		t.Error("the claims should be kept in the signature", validated)
+	}
+
+	// a signature of other claims with the same MAC is rejected
+	other, _ := auth.SignMedia(MediaClaims{Video: "xyz", Operations: []string{OperationStream}, Expires: claims.Expires})
+	tampered := strings.Split(other, ".")[0] + "." + strings.Split(signature, ".")[1]
+
+	if _, err := auth.ValidateMediaSignature(tampered); err == nil || err.Error() != "media_signature_invalid" {
+		t.Error("tampered signatures should be rejected", err)
+	}
+
+	if _, err := (&Auth{Key: []byte("other-key")}).ValidateMediaSignature(signature); err == nil {
+		t.Error("signatures of other keys should be rejected")
+	}
+
+	expired, _ := auth.SignMedia(MediaClaims{Video: "abc", Operations: []string{OperationThumb}, Expires: time.Now().Add(-time.Minute).Unix()})
+
+	if _, err := auth.ValidateMediaSignature(expired); err == nil || err.Error() != "media_signature_expired" {
+		t.Error("expired signatures should be rejected", err)
+	}
+
+	if _, err := auth.SignMedia(MediaClaims{Video: "abc", Operations: []string{"delete"}, Expires: claims.Expires}); err == nil {
+		t.Error("unknown operations should not be signed")
+	}
+}
+
+func TestRotateMediaKey(t *testing.T) {
+	auth := newTestAuth(t)
+
+	signature, err := auth.SignMedia(MediaClaims{Video: "abc", Operations: []string{OperationStream}, Expires: time.Now().Add(time.Hour).Unix()})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	generation, err := auth.RotateMediaKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if generation != 1 {
+		t.Error("the generation should be increased", generation)
+	}
+
+	if _, err := auth.ValidateMediaSignature(signature); err == nil || err.Error() != "media_signature_revoked" {
+		t.Error("the signatures of the previous key should be revoked", err)
+	}
+
+	settings, err := auth.Storage.GetSettings()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if settings.MediaKeyGeneration != 1 {
+		t.Error("the generation should be saved in the settings", settings.MediaKeyGeneration)
+	}
+
+	signature, _ = auth.SignMedia(MediaClaims{Video: "abc", Operations: []string{OperationStream}, Expires: time.Now().Add(time.Hour).Unix()})
+
+	if _, err := auth.ValidateMediaSignature(signature); err != nil {
+		t.Error("the signatures of the new key should be valid", err)
+	}
+}
@@ -0,0 +1,466 @@
+package mpccast
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"log"
+	"net"
+	"sync"
+	"time"
+)
+
+// DefaultMediaReceiver is the app of the receivers that plays media URLs
+const DefaultMediaReceiver = "CC1AD845"
+
+const (
+	senderID   = "sender-0"
+	receiverID = "receiver-0"
+)
+
+// timeouts of the connection, the receivers close the connections that don't answer the pings
+const (
+	dialTimeout     = 10 * time.Second
+	requestTimeout  = 10 * time.Second
+	heartbeatPeriod = 5 * time.Second
+	readTimeout     = 30 * time.Second
+)
+
+// Media is the video that the receiver loads
+type Media struct {
+	URL         string
+	ContentType string
+	Title       string
+	ImageURL    string
+	// Duration is the length of the video in seconds
+	Duration float64
+	// Position is where the playback starts in seconds
+	Position float64
+	// Expires is when the URL stops working, zero if it doesn't expire
+	Expires time.Time
+}
+
+// MediaStatus is the playback state reported by the media receiver
+type MediaStatus struct {
+	MediaSessionID int     `json:"mediaSessionId"`
+	PlayerState    string  `json:"playerState"`
+	CurrentTime    float64 `json:"currentTime"`
+	IdleReason     string  `json:"idleReason,omitempty"`
+	Volume         struct {
+		Level float64 `json:"level"`
+		Muted bool    `json:"muted"`
+	} `json:"volume"`
+	Media *struct {
+		Duration float64 `json:"duration"`
+	} `json:"media,omitempty"`
+}
+
+// payload is the JSON payload of the messages, the fields that are used depend on the type
+type payload struct {
+	Type      string          `json:"type"`
+	RequestID int             `json:"requestId,omitempty"`
+	Reason    string          `json:"reason,omitempty"`
+	Status    json.RawMessage `json:"status,omitempty"`
+}
+
+type receiverStatus struct {
+	Applications []struct {
+		AppID       string `json:"appId"`
+		SessionID   string `json:"sessionId"`
+		TransportID string `json:"transportId"`
+	} `json:"applications"`
+}
+
+// Client is a Cast v2 connection with a receiver
+type Client struct {
+	conn net.Conn
+
+	writeMutex sync.Mutex
+	mutex      sync.Mutex
+	requestID  int
+	pending    map[int]chan payload
+
+	transportID    string
+	sessionID      string
+	mediaSessionID int
+
+	statuses  chan MediaStatus
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// Dial connects to the receiver, the receivers use certificates of the devices that are not signed by a public CA
+// so the certificate is not verified
+//
+func Dial(address string) (*Client, error) {
+	conn, err := tls.DialWithDialer(&net.Dialer{Timeout: dialTimeout}, "tcp", address, &tls.Config{InsecureSkipVerify: true})
+	if err != nil {
+		return nil, err
+	}
+
+	return newClient(conn)
+}
+
+// newClient starts the Cast v2 session on the connection
+//
+func newClient(conn net.Conn) (*Client, error) {
+	client := &Client{
+		conn:     conn,
+		pending:  make(map[int]chan payload),
+		statuses: make(chan MediaStatus, 16),
+		done:     make(chan struct{}),
+	}
+
+	err := client.send(namespaceConnection, receiverID, map[string]interface{}{"type": "CONNECT"})
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	go client.read()
+	go client.heartbeat()
+
+	return client, nil
+}
+
+// Launch starts the app on the receiver and connects to it
+//
+func (client *Client) Launch(appID string) error {
+	response, err := client.request(namespaceReceiver, receiverID, map[string]interface{}{"type": "LAUNCH", "appId": appID})
+	if err != nil {
+		return err
+	}
+
+	var status receiverStatus
+
+	err = json.Unmarshal(response.Status, &status)
+	if err != nil {
+		return err
+	}
+
+	for _, app := range status.Applications {
+		if app.AppID == appID {
+			client.mutex.Lock()
+			client.transportID = app.TransportID
+			client.sessionID = app.SessionID
+			client.mutex.Unlock()
+
+			return client.send(namespaceConnection, app.TransportID, map[string]interface{}{"type": "CONNECT"})
+		}
+	}
+
+	return errors.New("cast_app_not_launched")
+}
+
+// Load plays the media in the app
+//
+func (client *Client) Load(media Media) error {
+	metadata := map[string]interface{}{"metadataType": 0, "title": media.Title}
+
+	if media.ImageURL != "" {
+		metadata["images"] = []map[string]string{{"url": media.ImageURL}}
+	}
+
+	mediaInfo := map[string]interface{}{
+		"contentId":   media.URL,
+		"contentType": media.ContentType,
+		"streamType":  "BUFFERED",
+		"metadata":    metadata,
+	}
+
+	if media.Duration > 0 {
+		mediaInfo["duration"] = media.Duration
+	}
+
+	response, err := client.mediaRequest(map[string]interface{}{"type": "LOAD", "media": mediaInfo, "autoplay": true, "currentTime": media.Position})
+	if err != nil {
+		return err
+	}
+
+	statuses, err := parseMediaStatuses(response.Status)
+	if err != nil {
+		return err
+	}
+
+	if len(statuses) == 0 {
+		return errors.New("cast_media_not_loaded")
+	}
+
+	client.mutex.Lock()
+	client.mediaSessionID = statuses[0].MediaSessionID
+	client.mutex.Unlock()
+
+	return nil
+}
+
+// Play resumes the media
+//
+func (client *Client) Play() error {
+	return client.mediaCommand(map[string]interface{}{"type": "PLAY"})
+}
+
+// Pause pauses the media
+//
+func (client *Client) Pause() error {
+	return client.mediaCommand(map[string]interface{}{"type": "PAUSE"})
+}
+
+// Seek moves the media to the position in seconds
+//
+func (client *Client) Seek(position float64) error {
+	return client.mediaCommand(map[string]interface{}{"type": "SEEK", "currentTime": position})
+}
+
+// Stop stops the media
+//
+func (client *Client) Stop() error {
+	return client.mediaCommand(map[string]interface{}{"type": "STOP"})
+}
+
+// SetVolume changes the volume of the receiver, the level goes from 0 to 1
+//
+func (client *Client) SetVolume(level float64) error {
+	_, err := client.request(namespaceReceiver, receiverID, map[string]interface{}{"type": "SET_VOLUME", "volume": map[string]interface{}{"level": level}})
+
+	return err
+}
+
+// StopApp closes the app on the receiver
+//
+func (client *Client) StopApp() error {
+	client.mutex.Lock()
+	sessionID := client.sessionID
+	client.mutex.Unlock()
+
+	if sessionID == "" {
+		return errors.New("cast_app_not_launched")
+	}
+
+	_, err := client.request(namespaceReceiver, receiverID, map[string]interface{}{"type": "STOP", "sessionId": sessionID})
+
+	return err
+}
+
+// Statuses gets the media statuses that the receiver sends when the playback changes
+//
+func (client *Client) Statuses() <-chan MediaStatus {
+	return client.statuses
+}
+
+// Done is closed when the connection is closed
+//
+func (client *Client) Done() <-chan struct{} {
+	return client.done
+}
+
+// Close closes the connection with the receiver, the app keeps playing
+//
+func (client *Client) Close() error {
+	client.closeOnce.Do(func() {
+		client.mutex.Lock()
+		transportID := client.transportID
+		client.mutex.Unlock()
+
+		if transportID != "" {
+			client.send(namespaceConnection, transportID, map[string]interface{}{"type": "CLOSE"})
+		}
+
+		client.send(namespaceConnection, receiverID, map[string]interface{}{"type": "CLOSE"})
+
+		close(client.done)
+		client.conn.Close()
+	})
+
+	return nil
+}
+
+// mediaCommand sends a command for the loaded media
+//
+func (client *Client) mediaCommand(command map[string]interface{}) error {
+	client.mutex.Lock()
+	mediaSessionID := client.mediaSessionID
+	client.mutex.Unlock()
+
+	if mediaSessionID == 0 {
+		return errors.New("cast_media_not_loaded")
+	}
+
+	command["mediaSessionId"] = mediaSessionID
+
+	_, err := client.mediaRequest(command)
+
+	return err
+}
+
+// mediaRequest sends a request to the media namespace of the app
+//
+func (client *Client) mediaRequest(request map[string]interface{}) (payload, error) {
+	client.mutex.Lock()
+	transportID := client.transportID
+	client.mutex.Unlock()
+
+	if transportID == "" {
+		return payload{}, errors.New("cast_app_not_launched")
+	}
+
+	return client.request(namespaceMedia, transportID, request)
+}
+
+// request sends a request with a new request ID and waits for the response with the same ID
+//
+func (client *Client) request(namespace string, destination string, request map[string]interface{}) (payload, error) {
+	response := make(chan payload, 1)
+
+	client.mutex.Lock()
+	client.requestID++
+	requestID := client.requestID
+	client.pending[requestID] = response
+	client.mutex.Unlock()
+
+	defer func() {
+		client.mutex.Lock()
+		delete(client.pending, requestID)
+		client.mutex.Unlock()
+	}()
+
+	request["requestId"] = requestID
+
+	err := client.send(namespace, destination, request)
+	if err != nil {
+		return payload{}, err
+	}
+
+	select {
+	case answer := <-response:
+		switch answer.Type {
+		case "LAUNCH_ERROR", "LOAD_FAILED", "LOAD_CANCELLED", "INVALID_REQUEST", "INVALID_PLAYER_STATE":
+			log.Println("Cast:", answer.Type, answer.Reason)
+			return answer, errors.New("cast_request_failed")
+		}
+
+		return answer, nil
+	case <-time.After(requestTimeout):
+		return payload{}, errors.New("cast_request_timeout")
+	case <-client.done:
+		return payload{}, errors.New("cast_connection_closed")
+	}
+}
+
+// send writes a message from the sender to the destination
+//
+func (client *Client) send(namespace string, destination string, data interface{}) error {
+	jsonData, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	client.writeMutex.Lock()
+	defer client.writeMutex.Unlock()
+
+	client.conn.SetWriteDeadline(time.Now().Add(requestTimeout))
+
+	return writeCastMessage(client.conn, castMessage{SourceID: senderID, DestinationID: destination, Namespace: namespace, Payload: string(jsonData)})
+}
+
+// read gets the messages of the receiver until the connection is closed,
+// it answers the pings and sends the responses to the pending requests
+//
+func (client *Client) read() {
+	defer client.Close()
+
+	for {
+		client.conn.SetReadDeadline(time.Now().Add(readTimeout))
+
+		msg, err := readCastMessage(client.conn)
+		if err != nil {
+			return
+		}
+
+		var answer payload
+
+		err = json.Unmarshal([]byte(msg.Payload), &answer)
+		if err != nil {
+			continue
+		}
+
+		switch {
+		case msg.Namespace == namespaceHeartbeat && answer.Type == "PING":
+			client.send(namespaceHeartbeat, msg.SourceID, map[string]interface{}{"type": "PONG"})
+			continue
+		case msg.Namespace == namespaceConnection && answer.Type == "CLOSE":
+			// the app was closed on the receiver
+			return
+		case msg.Namespace == namespaceMedia && answer.Type == "MEDIA_STATUS":
+			statuses, err := parseMediaStatuses(answer.Status)
+			if err == nil {
+				for _, status := range statuses {
+					client.updateStatus(status)
+				}
+			}
+		}
+
+		client.mutex.Lock()
+		response, ok := client.pending[answer.RequestID]
+		client.mutex.Unlock()
+
+		if ok && answer.RequestID != 0 {
+			response <- answer
+		}
+	}
+}
+
+// updateStatus keeps the media session of the status and sends it to the statuses channel,
+// the oldest status is dropped when nobody reads them
+//
+func (client *Client) updateStatus(status MediaStatus) {
+	client.mutex.Lock()
+	if status.MediaSessionID != 0 {
+		client.mediaSessionID = status.MediaSessionID
+	}
+	client.mutex.Unlock()
+
+	for {
+		select {
+		case client.statuses <- status:
+			return
+		default:
+		}
+
+		select {
+		case <-client.statuses:
+		default:
+		}
+	}
+}
+
+// heartbeat pings the receiver so it keeps the connection open
+//
+func (client *Client) heartbeat() {
+	ticker := time.NewTicker(heartbeatPeriod)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			err := client.send(namespaceHeartbeat, receiverID, map[string]interface{}{"type": "PING"})
+			if err != nil {
+				client.Close()
+				return
+			}
+		case <-client.done:
+			return
+		}
+	}
+}
+
+// parseMediaStatuses decodes the statuses of a MEDIA_STATUS message
+//
+func parseMediaStatuses(data json.RawMessage) (statuses []MediaStatus, err error) {
+	if len(data) == 0 {
+		return
+	}
+
+	err = json.Unmarshal(data, &statuses)
+
+	return
+}
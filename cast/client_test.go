@@ -0,0 +1,365 @@
+package mpccast
+
+import (
+	"github.com/jempe/mpc/remote"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeReceiver is a local stand-in of a cast device that runs the default media receiver
+type fakeReceiver struct {
+	listener net.Listener
+	commands chan map[string]interface{}
+	mutex    sync.Mutex
+	conns    []net.Conn
+}
+
+// newFakeReceiver starts a TLS receiver with the self signed certificate of httptest
+//
+func newFakeReceiver(t *testing.T) *fakeReceiver {
+	certServer := httptest.NewUnstartedServer(nil)
+	certServer.StartTLS()
+	config := &tls.Config{Certificates: certServer.TLS.Certificates}
+	certServer.Close()
+
+	listener, err := tls.Listen("tcp", "127.0.0.1:0", config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	receiver := &fakeReceiver{listener: listener, commands: make(chan map[string]interface{}, 32)}
+
+	t.Cleanup(func() {
+		listener.Close()
+
+		receiver.mutex.Lock()
+		for _, conn := range receiver.conns {
+			conn.Close()
+		}
+		receiver.mutex.Unlock()
+	})
+
+	go receiver.accept()
+
+	return receiver
+}
+
+func (receiver *fakeReceiver) accept() {
+	for {
+		conn, err := receiver.listener.Accept()
+		if err != nil {
+			return
+		}
+
+		receiver.mutex.Lock()
+		receiver.conns = append(receiver.conns, conn)
+		receiver.mutex.Unlock()
+
+		go receiver.serve(conn)
+	}
+}
+
+// serve answers the requests of the sender like the default media receiver
+//
+func (receiver *fakeReceiver) serve(conn net.Conn) {
+	var writeMutex sync.Mutex
+
+	reply := func(msg castMessage, data map[string]interface{}) {
+		jsonData, _ := json.Marshal(data)
+
+		writeMutex.Lock()
+		defer writeMutex.Unlock()
+
+		writeCastMessage(conn, castMessage{SourceID: msg.DestinationID, DestinationID: msg.SourceID, Namespace: msg.Namespace, Payload: string(jsonData)})
+	}
+
+	mediaStatus := func(msg castMessage, requestID interface{}, playerState string, position float64, idleReason string) {
+		reply(msg, map[string]interface{}{
+			"type":      "MEDIA_STATUS",
+			"requestId": requestID,
+			"status": []map[string]interface{}{{
+				"mediaSessionId": 7,
+				"playerState":    playerState,
+				"currentTime":    position,
+				"idleReason":     idleReason,
+				"volume":         map[string]interface{}{"level": 0.5},
+				"media":          map[string]interface{}{"duration": 120},
+			}},
+		})
+	}
+
+	for {
+		msg, err := readCastMessage(conn)
+		if err != nil {
+			return
+		}
+
+		var command map[string]interface{}
+
+		json.Unmarshal([]byte(msg.Payload), &command)
+
+		command["namespace"] = msg.Namespace
+		command["destination"] = msg.DestinationID
+
+		receiver.commands <- command
+
+		switch command["type"] {
+		case "PING":
+			reply(msg, map[string]interface{}{"type": "PONG"})
+		case "LAUNCH":
+			reply(msg, map[string]interface{}{
+				"type":      "RECEIVER_STATUS",
+				"requestId": command["requestId"],
+				"status": map[string]interface{}{
+					"applications": []map[string]interface{}{{"appId": command["appId"], "sessionId": "session-1", "transportId": "web-1"}},
+				},
+			})
+		case "SET_VOLUME", "STOP":
+			reply(msg, map[string]interface{}{"type": "RECEIVER_STATUS", "requestId": command["requestId"], "status": map[string]interface{}{}})
+		case "LOAD", "PLAY":
+			mediaStatus(msg, command["requestId"], "PLAYING", 0, "")
+		case "PAUSE":
+			mediaStatus(msg, command["requestId"], "PAUSED", 10, "")
+		case "SEEK":
+			mediaStatus(msg, command["requestId"], "PLAYING", command["currentTime"].(float64), "")
+
+			// the video ends after a seek to the end
+			if command["currentTime"].(float64) >= 120 {
+				mediaStatus(msg, 0, "IDLE", 120, "FINISHED")
+			}
+		}
+	}
+}
+
+// nextCommand waits for a command of the type, other commands like the pings are skipped
+//
+func (receiver *fakeReceiver) nextCommand(t *testing.T, commandType string) map[string]interface{} {
+	timeout := time.After(2 * time.Second)
+
+	for {
+		select {
+		case command := <-receiver.commands:
+			if command["type"] == commandType {
+				return command
+			}
+		case <-timeout:
+			t.Fatal("the receiver did not get", commandType)
+			return nil
+		}
+	}
+}
+
+func TestClient(t *testing.T) {
+	receiver := newFakeReceiver(t)
+
+	client, err := Dial(receiver.listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	err = client.Launch(DefaultMediaReceiver)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if connect := receiver.nextCommand(t, "CONNECT"); connect["destination"] != receiverID {
+		t.Error("the sender should connect to the receiver first", connect)
+	}
+
+	if launch := receiver.nextCommand(t, "LAUNCH"); launch["appId"] != DefaultMediaReceiver {
+		t.Error("the default media receiver should be launched", launch)
+	}
+
+	if connect := receiver.nextCommand(t, "CONNECT"); connect["destination"] != "web-1" {
+		t.Error("the sender should connect to the app", connect)
+	}
+
+	err = client.Pause()
+	if err == nil {
+		t.Error("commands should fail before the media is loaded")
+	}
+
+	err = client.Load(Media{URL: "http://127.0.0.1:3000/videos/abc.mp4?token=xyz", ContentType: "video/mp4", Title: "Movie"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	load := receiver.nextCommand(t, "LOAD")
+	media := load["media"].(map[string]interface{})
+
+	if media["contentId"] != "http://127.0.0.1:3000/videos/abc.mp4?token=xyz" || load["destination"] != "web-1" {
+		t.Error("the media should be loaded in the app", load)
+	}
+
+	err = client.Seek(30)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if seek := receiver.nextCommand(t, "SEEK"); seek["currentTime"] != float64(30) || seek["mediaSessionId"] != float64(7) {
+		t.Error("seek should have the position and the media session", seek)
+	}
+}
+
+func TestSession(t *testing.T) {
+	receiver := newFakeReceiver(t)
+
+	hub := mpcremote.NewRemote()
+	go hub.Run()
+	defer hub.Shutdown(context.Background())
+
+	identity := mpcremote.Identity{User: "user", Admin: true}
+
+	client, err := Dial(receiver.listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = client.Launch(DefaultMediaReceiver)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	player := hub.ConnectLocal(identity, "cast-1", "", "TV")
+
+	session := NewSession("cast-1", Receiver{Name: "TV"}, "user", client, player, func(videoID string) (Media, error) {
+		return Media{URL: "http://127.0.0.1:3000/videos/" + videoID + ".mp4", ContentType: "video/mp4"}, nil
+	})
+
+	err = session.Play("first")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	go session.Run()
+
+	remote := hub.ConnectLocal(identity, "", "remote-1", "")
+	defer remote.Close()
+
+	nextMessage := func(messageType string) mpcremote.Message {
+		timeout := time.After(2 * time.Second)
+
+		for {
+			select {
+			case msg := <-remote.Messages():
+				if msg.Type == messageType {
+					return msg
+				}
+			case <-timeout:
+				t.Fatal("the remote did not get", messageType)
+				return mpcremote.Message{}
+			}
+		}
+	}
+
+	remote.Send(mpcremote.Message{Type: mpcremote.TypePair, PlayerID: "cast-1"})
+	nextMessage(mpcremote.TypePaired)
+
+	remote.Send(mpcremote.Message{Type: mpcremote.TypePause})
+	receiver.nextCommand(t, "PAUSE")
+
+	// the state of the load can arrive before the state of the pause
+	state := nextMessage(mpcremote.TypeState)
+	if !state.State.Paused {
+		state = nextMessage(mpcremote.TypeState)
+	}
+
+	if state.State.Video != "first" || !state.State.Paused || state.State.Position != 10 || state.State.Duration != 120 {
+		t.Error("the remote should get the state of the receiver", state.State)
+	}
+
+	position := 45.0
+	remote.Send(mpcremote.Message{Type: mpcremote.TypeSeek, Position: &position})
+
+	if seek := receiver.nextCommand(t, "SEEK"); seek["currentTime"] != position {
+		t.Error("seek should be sent to the receiver", seek)
+	}
+
+	// the queue plays the next video when the receiver finishes the current one
+	remote.Send(mpcremote.Message{Type: mpcremote.TypeQueue, Queue: &mpcremote.QueueChange{Op: mpcremote.QueueAdd, Videos: []string{"first", "second"}}})
+	remote.Send(mpcremote.Message{Type: mpcremote.TypeQueue, Queue: &mpcremote.QueueChange{Op: mpcremote.QueuePlay, Index: 0}})
+
+	receiver.nextCommand(t, "LOAD")
+
+	end := 120.0
+	remote.Send(mpcremote.Message{Type: mpcremote.TypeSeek, Position: &end})
+
+	load := receiver.nextCommand(t, "LOAD")
+	if load["media"].(map[string]interface{})["contentId"] != "http://127.0.0.1:3000/videos/second.mp4" {
+		t.Error("the next video of the queue should be loaded", load)
+	}
+
+	session.Stop()
+	receiver.nextCommand(t, "STOP")
+}
+
+func TestSessionRenewsExpiredMedia(t *testing.T) {
+	receiver := newFakeReceiver(t)
+
+	hub := mpcremote.NewRemote()
+	go hub.Run()
+	defer hub.Shutdown(context.Background())
+
+	identity := mpcremote.Identity{User: "user", Admin: true}
+
+	client, err := Dial(receiver.listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = client.Launch(DefaultMediaReceiver)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	player := hub.ConnectLocal(identity, "cast-1", "", "TV")
+
+	signatures := 0
+
+	// every media expires right away so the resume needs a new URL
+	session := NewSession("cast-1", Receiver{Name: "TV"}, "user", client, player, func(videoID string) (Media, error) {
+		signatures++
+		return Media{URL: fmt.Sprintf("http://127.0.0.1:3000/videos/%s.mp4?sig=%d", videoID, signatures), ContentType: "video/mp4", Expires: time.Now().Add(-time.Second)}, nil
+	})
+
+	err = session.Play("first")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	receiver.nextCommand(t, "LOAD")
+
+	go session.Run()
+	defer session.Stop()
+
+	remote := hub.ConnectLocal(identity, "", "remote-1", "")
+	defer remote.Close()
+
+	remote.Send(mpcremote.Message{Type: mpcremote.TypePair, PlayerID: "cast-1"})
+	remote.Send(mpcremote.Message{Type: mpcremote.TypePause})
+	receiver.nextCommand(t, "PAUSE")
+
+	// the position of the pause is reported before the resume
+	for timeout := time.Now().Add(2 * time.Second); session.State().Position != 10; {
+		if time.Now().After(timeout) {
+			t.Fatal("the receiver did not report the pause", session.State())
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	remote.Send(mpcremote.Message{Type: mpcremote.TypePlay})
+
+	load := receiver.nextCommand(t, "LOAD")
+	if load["media"].(map[string]interface{})["contentId"] != "http://127.0.0.1:3000/videos/first.mp4?sig=2" || load["currentTime"] != float64(10) {
+		t.Error("the expired media should be loaded again with a new URL at the same position", load)
+	}
+}
@@ -0,0 +1,324 @@
+package mpccast
+
+import (
+	"encoding/binary"
+	"errors"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// mdnsAddress is the multicast group of the mDNS queries
+const mdnsAddress = "224.0.0.251:5353"
+
+// castService is the mDNS service of the cast receivers
+const castService = "_googlecast._tcp.local"
+
+// DNS record types used by the discovery
+const (
+	typeA   = 1
+	typePTR = 12
+	typeTXT = 16
+	typeSRV = 33
+)
+
+// Receiver is a cast receiver of the LAN
+type Receiver struct {
+	ID    string `json:"id"`
+	Name  string `json:"name"`
+	Model string `json:"model"`
+	Host  string `json:"host"`
+	Port  int    `json:"port"`
+}
+
+// dnsRecord is a resource record of a mDNS response
+type dnsRecord struct {
+	Name   string
+	Type   uint16
+	Target string
+	Port   int
+	IP     net.IP
+	Text   map[string]string
+}
+
+// Address gets the host and port of the receiver
+//
+func (receiver Receiver) Address() string {
+	return net.JoinHostPort(receiver.Host, strconv.Itoa(receiver.Port))
+}
+
+// Discover sends a mDNS query for the cast receivers and collects the answers until the timeout
+//
+func Discover(timeout time.Duration) ([]Receiver, error) {
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{})
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	group, err := net.ResolveUDPAddr("udp4", mdnsAddress)
+	if err != nil {
+		return nil, err
+	}
+
+	return discover(conn, group, timeout)
+}
+
+// discover sends the query to the address and reads the answers, the tests use a local responder
+//
+func discover(conn net.PacketConn, address net.Addr, timeout time.Duration) ([]Receiver, error) {
+	_, err := conn.WriteTo(buildQuery(castService, typePTR), address)
+	if err != nil {
+		return nil, err
+	}
+
+	conn.SetReadDeadline(time.Now().Add(timeout))
+
+	var records []dnsRecord
+
+	buffer := make([]byte, 9000)
+
+	for {
+		n, _, err := conn.ReadFrom(buffer)
+		if err != nil {
+			// the timeout ends the discovery
+			break
+		}
+
+		response, err := parseResponse(buffer[:n])
+		if err == nil {
+			records = append(records, response...)
+		}
+	}
+
+	return receiversFromRecords(records), nil
+}
+
+// receiversFromRecords joins the PTR, SRV, TXT and A records of the receivers
+//
+func receiversFromRecords(records []dnsRecord) (receivers []Receiver) {
+	receivers = []Receiver{}
+
+	find := func(name string, recordType uint16) (dnsRecord, bool) {
+		for _, record := range records {
+			if record.Type == recordType && strings.EqualFold(record.Name, name) {
+				return record, true
+			}
+		}
+
+		return dnsRecord{}, false
+	}
+
+	found := make(map[string]bool)
+
+	for _, record := range records {
+		if record.Type != typePTR || !strings.EqualFold(record.Name, castService) || found[record.Target] {
+			continue
+		}
+
+		service, ok := find(record.Target, typeSRV)
+		if !ok {
+			continue
+		}
+
+		address, ok := find(service.Target, typeA)
+		if !ok {
+			continue
+		}
+
+		found[record.Target] = true
+
+		receiver := Receiver{
+			ID:   strings.TrimSuffix(record.Target, "."+castService),
+			Name: strings.TrimSuffix(record.Target, "."+castService),
+			Host: address.IP.String(),
+			Port: service.Port,
+		}
+
+		if text, ok := find(record.Target, typeTXT); ok {
+			if text.Text["id"] != "" {
+				receiver.ID = text.Text["id"]
+			}
+
+			if text.Text["fn"] != "" {
+				receiver.Name = text.Text["fn"]
+			}
+
+			receiver.Model = text.Text["md"]
+		}
+
+		receivers = append(receivers, receiver)
+	}
+
+	sort.Slice(receivers, func(i, j int) bool {
+		return receivers[i].Name < receivers[j].Name
+	})
+
+	return
+}
+
+// buildQuery builds a DNS query of one question
+//
+func buildQuery(name string, recordType uint16) []byte {
+	// the ID and flags are 0 in mDNS queries and there is one question
+	query := []byte{0, 0, 0, 0, 0, 1, 0, 0, 0, 0, 0, 0}
+	query = appendName(query, name)
+
+	question := make([]byte, 4)
+	binary.BigEndian.PutUint16(question, recordType)
+	binary.BigEndian.PutUint16(question[2:], 1)
+
+	return append(query, question...)
+}
+
+// appendName appends a domain name as labels
+//
+func appendName(data []byte, name string) []byte {
+	for _, label := range strings.Split(strings.Trim(name, "."), ".") {
+		data = append(data, byte(len(label)))
+		data = append(data, label...)
+	}
+
+	return append(data, 0)
+}
+
+// parseResponse decodes the records of a DNS response
+//
+func parseResponse(data []byte) (records []dnsRecord, err error) {
+	if len(data) < 12 {
+		return nil, errors.New("dns_message_invalid")
+	}
+
+	questions := int(binary.BigEndian.Uint16(data[4:]))
+	total := int(binary.BigEndian.Uint16(data[6:])) + int(binary.BigEndian.Uint16(data[8:])) + int(binary.BigEndian.Uint16(data[10:]))
+
+	offset := 12
+
+	for i := 0; i < questions; i++ {
+		_, offset, err = readName(data, offset)
+		if err != nil {
+			return
+		}
+
+		offset += 4
+	}
+
+	for i := 0; i < total; i++ {
+		var record dnsRecord
+
+		record.Name, offset, err = readName(data, offset)
+		if err != nil {
+			return
+		}
+
+		if offset+10 > len(data) {
+			return nil, errors.New("dns_message_invalid")
+		}
+
+		record.Type = binary.BigEndian.Uint16(data[offset:])
+		length := int(binary.BigEndian.Uint16(data[offset+8:]))
+		offset += 10
+
+		if offset+length > len(data) {
+			return nil, errors.New("dns_message_invalid")
+		}
+
+		rdata := data[offset : offset+length]
+
+		switch record.Type {
+		case typePTR:
+			record.Target, _, err = readName(data, offset)
+		case typeSRV:
+			if length < 7 {
+				return nil, errors.New("dns_message_invalid")
+			}
+
+			record.Port = int(binary.BigEndian.Uint16(rdata[4:]))
+			record.Target, _, err = readName(data, offset+6)
+		case typeA:
+			if length == 4 {
+				record.IP = net.IP(append([]byte{}, rdata...))
+			}
+		case typeTXT:
+			record.Text = parseText(rdata)
+		}
+
+		if err != nil {
+			return
+		}
+
+		records = append(records, record)
+		offset += length
+	}
+
+	return
+}
+
+// readName reads a domain name that can be compressed with pointers to previous names
+//
+func readName(data []byte, offset int) (name string, next int, err error) {
+	var labels []string
+
+	next = -1
+
+	for jumps := 0; jumps < 16; {
+		if offset >= len(data) {
+			return "", 0, errors.New("dns_name_invalid")
+		}
+
+		length := int(data[offset])
+
+		switch {
+		case length == 0:
+			if next < 0 {
+				next = offset + 1
+			}
+
+			return strings.Join(labels, "."), next, nil
+		case length&0xC0 == 0xC0:
+			if offset+1 >= len(data) {
+				return "", 0, errors.New("dns_name_invalid")
+			}
+
+			if next < 0 {
+				next = offset + 2
+			}
+
+			offset = int(binary.BigEndian.Uint16(data[offset:]) & 0x3FFF)
+			jumps++
+		default:
+			if offset+1+length > len(data) {
+				return "", 0, errors.New("dns_name_invalid")
+			}
+
+			labels = append(labels, string(data[offset+1:offset+1+length]))
+			offset += 1 + length
+		}
+	}
+
+	return "", 0, errors.New("dns_name_invalid")
+}
+
+// parseText decodes the key=value strings of a TXT record
+//
+func parseText(data []byte) map[string]string {
+	text := make(map[string]string)
+
+	for len(data) > 0 {
+		length := int(data[0])
+		if 1+length > len(data) {
+			break
+		}
+
+		parts := strings.SplitN(string(data[1:1+length]), "=", 2)
+		if len(parts) == 2 {
+			text[parts[0]] = parts[1]
+		}
+
+		data = data[1+length:]
+	}
+
+	return text
+}
@@ -0,0 +1,118 @@
+package mpccast
+
+import (
+	"encoding/binary"
+	"net"
+	"testing"
+	"time"
+)
+
+// appendRecord appends a resource record with its data
+//
+func appendRecord(data []byte, name string, recordType uint16, rdata []byte) []byte {
+	data = appendName(data, name)
+
+	header := make([]byte, 10)
+	binary.BigEndian.PutUint16(header, recordType)
+	binary.BigEndian.PutUint16(header[2:], 1)
+	binary.BigEndian.PutUint32(header[4:], 120)
+	binary.BigEndian.PutUint16(header[8:], uint16(len(rdata)))
+
+	return append(append(data, header...), rdata...)
+}
+
+// castResponse builds the answer of a receiver, the SRV target uses a compressed name
+//
+func castResponse() []byte {
+	response := []byte{0, 0, 0x84, 0, 0, 0, 0, 1, 0, 0, 0, 3}
+
+	instance := "Chromecast-1234." + castService
+
+	response = appendRecord(response, castService, typePTR, appendName(nil, instance))
+
+	text := []byte{}
+	for _, value := range []string{"id=1234abcd", "md=Chromecast", "fn=Living Room TV"} {
+		text = append(append(text, byte(len(value))), value...)
+	}
+
+	response = appendRecord(response, instance, typeTXT, text)
+
+	// the host name points to the label "local" of the PTR record
+	localOffset := 12 + len(appendName(nil, castService)) - len("local") - 2
+	srv := []byte{0, 0, 0, 0, 0x1F, 0x49, 7}
+	srv = append(srv, "tv-host"...)
+	srv = append(srv, 0xC0|byte(localOffset>>8), byte(localOffset))
+
+	response = appendRecord(response, instance, typeSRV, srv)
+	response = appendRecord(response, "tv-host.local", typeA, []byte{192, 168, 1, 20})
+
+	return response
+}
+
+func TestParseResponse(t *testing.T) {
+	records, err := parseResponse(castResponse())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	receivers := receiversFromRecords(records)
+
+	if len(receivers) != 1 {
+		t.Fatal("one receiver expected", records)
+	}
+
+	expected := Receiver{ID: "1234abcd", Name: "Living Room TV", Model: "Chromecast", Host: "192.168.1.20", Port: 8009}
+
+	if receivers[0] != expected {
+		t.Error("wrong receiver", receivers[0])
+	}
+
+	if receivers[0].Address() != "192.168.1.20:8009" {
+		t.Error("wrong address", receivers[0].Address())
+	}
+
+	_, err = parseResponse(castResponse()[:40])
+	if err == nil {
+		t.Error("truncated responses should fail")
+	}
+}
+
+func TestDiscover(t *testing.T) {
+	responder, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer responder.Close()
+
+	// the fake responder answers the PTR queries of the cast service
+	go func() {
+		buffer := make([]byte, 1500)
+
+		n, addr, err := responder.ReadFrom(buffer)
+		if err != nil {
+			return
+		}
+
+		name, offset, err := readName(buffer[:n], 12)
+		if err != nil || name != castService || binary.BigEndian.Uint16(buffer[offset:]) != typePTR {
+			return
+		}
+
+		responder.WriteTo(castResponse(), addr)
+	}()
+
+	conn, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	receivers, err := discover(conn, responder.LocalAddr(), 300*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(receivers) != 1 || receivers[0].Name != "Living Room TV" {
+		t.Error("the receiver of the responder should be discovered", receivers)
+	}
+}
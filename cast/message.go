@@ -0,0 +1,164 @@
+package mpccast
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+// namespaces of the Cast v2 messages
+const (
+	namespaceConnection = "urn:x-cast:com.google.cast.tp.connection"
+	namespaceHeartbeat  = "urn:x-cast:com.google.cast.tp.heartbeat"
+	namespaceReceiver   = "urn:x-cast:com.google.cast.receiver"
+	namespaceMedia      = "urn:x-cast:com.google.cast.media"
+)
+
+// maxMessageSize is the biggest message that the receivers send
+const maxMessageSize = 64 * 1024
+
+// castMessage is the protobuf message of the Cast v2 protocol, only the JSON payloads are used
+type castMessage struct {
+	SourceID      string
+	DestinationID string
+	Namespace     string
+	Payload       string
+}
+
+// protobuf field numbers of the cast message
+const (
+	fieldProtocolVersion = 1
+	fieldSourceID        = 2
+	fieldDestinationID   = 3
+	fieldNamespace       = 4
+	fieldPayloadType     = 5
+	fieldPayloadUTF8     = 6
+	fieldPayloadBinary   = 7
+)
+
+// marshal encodes the message in the protobuf wire format, the protocol version and the string payload type are 0
+//
+func (msg castMessage) marshal() []byte {
+	var data []byte
+
+	data = appendVarintField(data, fieldProtocolVersion, 0)
+	data = appendStringField(data, fieldSourceID, msg.SourceID)
+	data = appendStringField(data, fieldDestinationID, msg.DestinationID)
+	data = appendStringField(data, fieldNamespace, msg.Namespace)
+	data = appendVarintField(data, fieldPayloadType, 0)
+	data = appendStringField(data, fieldPayloadUTF8, msg.Payload)
+
+	return data
+}
+
+// unmarshalCastMessage decodes a message in the protobuf wire format, unknown fields are skipped
+//
+func unmarshalCastMessage(data []byte) (msg castMessage, err error) {
+	for len(data) > 0 {
+		key, n := binary.Uvarint(data)
+		if n <= 0 {
+			return msg, errors.New("cast_message_invalid")
+		}
+
+		data = data[n:]
+
+		field := key >> 3
+
+		switch key & 7 {
+		case 0:
+			_, n = binary.Uvarint(data)
+			if n <= 0 {
+				return msg, errors.New("cast_message_invalid")
+			}
+
+			data = data[n:]
+		case 2:
+			length, n := binary.Uvarint(data)
+			if n <= 0 || length > uint64(len(data)-n) {
+				return msg, errors.New("cast_message_invalid")
+			}
+
+			value := string(data[n : n+int(length)])
+			data = data[n+int(length):]
+
+			switch field {
+			case fieldSourceID:
+				msg.SourceID = value
+			case fieldDestinationID:
+				msg.DestinationID = value
+			case fieldNamespace:
+				msg.Namespace = value
+			case fieldPayloadUTF8:
+				msg.Payload = value
+			}
+		default:
+			return msg, errors.New("cast_message_invalid")
+		}
+	}
+
+	return
+}
+
+// writeCastMessage writes the message with its length before it
+//
+func writeCastMessage(w io.Writer, msg castMessage) error {
+	data := msg.marshal()
+
+	frame := make([]byte, 4, 4+len(data))
+	binary.BigEndian.PutUint32(frame, uint32(len(data)))
+
+	_, err := w.Write(append(frame, data...))
+
+	return err
+}
+
+// readCastMessage reads the length and the message
+//
+func readCastMessage(r io.Reader) (msg castMessage, err error) {
+	header := make([]byte, 4)
+
+	_, err = io.ReadFull(r, header)
+	if err != nil {
+		return
+	}
+
+	length := binary.BigEndian.Uint32(header)
+	if length > maxMessageSize {
+		return msg, errors.New("cast_message_too_big")
+	}
+
+	data := make([]byte, length)
+
+	_, err = io.ReadFull(r, data)
+	if err != nil {
+		return
+	}
+
+	return unmarshalCastMessage(data)
+}
+
+// appendVarintField appends a varint field
+//
+func appendVarintField(data []byte, field int, value uint64) []byte {
+	data = appendUvarint(data, uint64(field<<3))
+
+	return appendUvarint(data, value)
+}
+
+// appendStringField appends a length delimited field
+//
+func appendStringField(data []byte, field int, value string) []byte {
+	data = appendUvarint(data, uint64(field<<3|2))
+	data = appendUvarint(data, uint64(len(value)))
+
+	return append(data, value...)
+}
+
+// appendUvarint appends a number in the varint format
+//
+func appendUvarint(data []byte, value uint64) []byte {
+	buffer := make([]byte, binary.MaxVarintLen64)
+	n := binary.PutUvarint(buffer, value)
+
+	return append(data, buffer[:n]...)
+}
@@ -0,0 +1,182 @@
+package mpccast
+
+import (
+	"github.com/jempe/mpc/remote"
+	"log"
+	"sync"
+	"time"
+)
+
+// Session plays videos on a receiver, it is connected to the remote as a player so the remotes control it
+// with the same play, pause and seek messages as the web players
+type Session struct {
+	ID       string
+	Receiver Receiver
+	// Owner is the user that started the session, the URLs of the media are signed for this user
+	Owner string
+	// MediaFor gets the media of a video with an URL that the receiver can open
+	MediaFor func(videoID string) (Media, error)
+
+	client  *Client
+	player  *mpcremote.LocalConn
+	state   mpcremote.PlayerState
+	expires time.Time
+	mutex   sync.Mutex
+}
+
+// NewSession creates the session of a receiver that already runs the media app
+//
+func NewSession(id string, receiver Receiver, owner string, client *Client, player *mpcremote.LocalConn, mediaFor func(videoID string) (Media, error)) *Session {
+	return &Session{
+		ID:       id,
+		Receiver: receiver,
+		Owner:    owner,
+		MediaFor: mediaFor,
+		client:   client,
+		player:   player,
+		state:    mpcremote.PlayerState{Paused: true, Volume: 1},
+	}
+}
+
+// Play loads a video on the receiver
+//
+func (session *Session) Play(videoID string) error {
+	return session.load(videoID, 0)
+}
+
+// load gets the media of the video with a new URL and loads it on the receiver at the position
+//
+func (session *Session) load(videoID string, position float64) error {
+	media, err := session.MediaFor(videoID)
+	if err != nil {
+		return err
+	}
+
+	media.Position = position
+
+	err = session.client.Load(media)
+	if err != nil {
+		return err
+	}
+
+	session.mutex.Lock()
+	session.state = mpcremote.PlayerState{Video: videoID, Position: position, Duration: media.Duration, Volume: session.state.Volume}
+	session.expires = media.Expires
+	session.mutex.Unlock()
+
+	return nil
+}
+
+// resume plays the media, if the URL expired while the media was paused the video is loaded again
+// with a new URL at the same position
+//
+func (session *Session) resume() error {
+	session.mutex.Lock()
+	state := session.state
+	expired := !session.expires.IsZero() && time.Now().After(session.expires)
+	session.mutex.Unlock()
+
+	if !expired || state.Video == "" {
+		return session.client.Play()
+	}
+
+	return session.load(state.Video, state.Position)
+}
+
+// Run maps the messages of the remote to cast commands and reports the statuses of the receiver
+// until the receiver or the remote closes the connection
+//
+func (session *Session) Run() {
+	defer session.Close()
+
+	for {
+		select {
+		case msg := <-session.player.Messages():
+			err := session.handle(msg)
+			if err != nil {
+				log.Println("Cast:", msg.Type, err)
+				session.player.Send(mpcremote.Message{Type: mpcremote.TypeError, Error: err.Error()})
+			}
+		case status := <-session.client.Statuses():
+			session.report(status)
+		case <-session.player.Done():
+			return
+		case <-session.client.Done():
+			return
+		}
+	}
+}
+
+// Close disconnects the session from the receiver and from the remote
+//
+func (session *Session) Close() {
+	session.client.Close()
+	session.player.Close()
+}
+
+// Stop closes the media app on the receiver and the session
+//
+func (session *Session) Stop() {
+	err := session.client.StopApp()
+	if err != nil {
+		log.Println("Cast:", err)
+	}
+
+	session.Close()
+}
+
+// handle runs the command of a remote on the receiver, the messages that are not commands are ignored
+//
+func (session *Session) handle(msg mpcremote.Message) error {
+	switch msg.Type {
+	case mpcremote.TypePlay:
+		return session.resume()
+	case mpcremote.TypePause:
+		return session.client.Pause()
+	case mpcremote.TypeSeek:
+		return session.client.Seek(*msg.Position)
+	case mpcremote.TypeVolume:
+		return session.client.SetVolume(*msg.Volume)
+	case mpcremote.TypePlayVideo:
+		return session.Play(msg.Video)
+	}
+
+	return nil
+}
+
+// report sends the state of the receiver to the remote, the end of a video plays the next video of the queue
+//
+func (session *Session) report(status MediaStatus) {
+	session.mutex.Lock()
+
+	state := session.state
+	state.Position = status.CurrentTime
+	state.Paused = status.PlayerState != "PLAYING" && status.PlayerState != "BUFFERING"
+	state.Volume = status.Volume.Level
+
+	if status.Media != nil && status.Media.Duration > 0 {
+		state.Duration = status.Media.Duration
+	}
+
+	session.state = state
+	session.mutex.Unlock()
+
+	if state.Video == "" {
+		return
+	}
+
+	session.player.Send(mpcremote.Message{Type: mpcremote.TypeState, State: &state})
+
+	if status.PlayerState == "IDLE" && status.IdleReason == "FINISHED" {
+		session.player.Send(mpcremote.Message{Type: mpcremote.TypeEnded, Video: state.Video})
+	}
+}
+
+// State gets the last state reported by the receiver
+//
+func (session *Session) State() mpcremote.PlayerState {
+	session.mutex.Lock()
+	defer session.mutex.Unlock()
+
+	return session.state
+}
@@ -2,9 +2,11 @@ package main
 
 import (
 	"github.com/jempe/encdec"
+	"github.com/jempe/mpc/config"
 	"github.com/jempe/mpc/storage"
+	"errors"
+	"flag"
 	"fmt"
-	"github.com/spf13/viper"
 	"io/ioutil"
 	"os"
 	"path/filepath"
@@ -14,16 +16,23 @@ import (
 var key string
 
 func main() {
-	configPath := "/home/mpc/.mpc/"
-	target := os.Args[2]
+	config, _, err := mpcconfig.Parse("config", "keyFile")
+	exitErr(err)
 
-	viper.SetConfigName("config")
-	viper.AddConfigPath(configPath)
-	viper.ReadInConfig()
+	if config.Key == "" {
+		exitErr(errors.New("the key is not set, set it in " + config.File() + ", MPC_KEY or MPC_KEY_FILE"))
+	}
+
+	if flag.NArg() < 2 {
+		fmt.Println("Please enter the file that you want to decrypt and the target folder")
+		return
+	}
 
-	key = viper.GetString("key")
+	configPath := config.ConfigPath
+	key = config.Key
 
-	source := os.Args[1]
+	source := flag.Arg(0)
+	target := flag.Arg(1)
 
 	if !encdec.Exists(source) {
 		fmt.Println("source file", source, "doesn't exist")
@@ -106,3 +115,11 @@ func checkErr(err error) {
 		panic(err)
 	}
 }
+
+// exitErr shows the error and stops the command
+func exitErr(err error) {
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+}
@@ -2,11 +2,13 @@ package main
 
 import (
 	"github.com/jempe/encdec"
+	"github.com/jempe/mpc/config"
 	"github.com/jempe/mpc/library"
 	"github.com/jempe/mpc/storage"
 	"github.com/jempe/mpc/utils"
+	"errors"
+	"flag"
 	"fmt"
-	"github.com/spf13/viper"
 	"io/ioutil"
 	"os"
 	"path/filepath"
@@ -17,16 +19,38 @@ import (
 var key string
 
 func main() {
-	configPath := "/home/mpc/.mpc/"
-	target := "/home/mpc/Videos/"
+	config, _, err := mpcconfig.Parse("config", "library", "keyFile", "ffmpeg", "ffprobe")
+	exitErr(err)
 
-	viper.SetConfigName("config")
-	viper.AddConfigPath(configPath)
-	viper.ReadInConfig()
+	if config.Key == "" {
+		exitErr(errors.New("the key is not set, set it in " + config.File() + ", MPC_KEY or MPC_KEY_FILE"))
+	}
+
+	if flag.NArg() < 1 {
+		fmt.Println("Please enter the file that you want to encrypt")
+		return
+	}
+
+	configPath := config.ConfigPath
+	key = config.Key
+
+	storage := &mpcstorage.Storage{Path: configPath}
+
+	err = storage.InitDb()
+	checkErr(err)
+	defer storage.Db.Close()
 
-	key = viper.GetString("key")
+	// the videos are encrypted in the library folder of the server when the library is not set
+	target := config.LibraryPath
 
-	source := os.Args[1]
+	if target == "" {
+		settings, err := storage.GetSettings()
+		checkErr(err)
+
+		target = settings.LibraryPath
+	}
+
+	source := flag.Arg(0)
 
 	if !encdec.Exists(source) {
 		fmt.Println("source file", source, "doesn't exist")
@@ -66,19 +90,11 @@ func main() {
 
 						encryptThumbnail(source+".jpg", target+md5Sum+"_thumb.enc")
 
-						// check if video has JSON file with data
-						videoJSON := strings.Replace(source, sourceExtension, ".json", 1)
-
-						videoJSONData, err := mpclibrary.GetJSONData(videoJSON)
+						// read the metadata of the sidecar files and the container tags of the video
+						thisVideo := mpclibrary.ReadMetadata(source)
 
 						var videos []mpclibrary.Video
 
-						thisVideo := mpclibrary.Video{}
-
-						if err == nil {
-							thisVideo = videoJSONData
-						}
-
 						thisVideo.Extension = strings.Replace(sourceExtension, ".", "", 1)
 						thisVideo.File = encryptedName
 						thisVideo.Path = target
@@ -95,11 +111,6 @@ func main() {
 
 						videos = append(videos, thisVideo)
 
-						storage := &mpcstorage.Storage{Path: configPath}
-
-						err = storage.InitDb()
-						checkErr(err)
-
 						err = storage.InsertVideos(videos)
 						checkErr(err)
 
@@ -179,3 +190,11 @@ func checkErr(err error) {
 		panic(err)
 	}
 }
+
+// exitErr shows the error and stops the command
+func exitErr(err error) {
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+}
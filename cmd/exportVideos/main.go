@@ -0,0 +1,111 @@
+package main
+
+import (
+	"github.com/jempe/mpc/auth"
+	"github.com/jempe/mpc/config"
+	"github.com/jempe/mpc/export"
+	"github.com/jempe/mpc/library"
+	"github.com/jempe/mpc/storage"
+	"github.com/jempe/mpc/utils"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+var (
+	format     = flag.String("format", mpcexport.FormatM3U8, "Playlist format: m3u8 or xspf")
+	nfo        = flag.Bool("nfo", false, "Write Kodi NFO files next to the selected videos instead of a playlist")
+	output     = flag.String("out", "", "Playlist file, the playlist is written to the standard output when it is empty")
+	baseURL    = flag.String("url", "", "URL of the MPC server, like http://192.168.1.10:3000, it's found with the port of the config when it's empty")
+	ttl        = flag.Duration("ttl", 24*time.Hour, "Validity of the signed URLs")
+	playlistID = flag.String("playlist", "", "Export the playlist with this ID")
+	collection = flag.Int("collection", 0, "Export the collection with this ID")
+	actor      = flag.Int("actor", 0, "Export the videos of the actor with this ID")
+	category   = flag.String("category", "", "Filter the videos by category")
+	title      = flag.String("title", "", "Filter the videos by title")
+	tag        = flag.String("tag", "", "Filter the videos by tag")
+	sortBy     = flag.String("sort", "", "Order of the filtered videos")
+)
+
+func main() {
+	config, _, err := mpcconfig.Parse("config")
+	checkErr(err)
+
+	if *baseURL == "" {
+		*baseURL = config.URL(mpcutils.GetLocalIP())
+	}
+
+	storage := &mpcstorage.Storage{Path: config.ConfigPath}
+
+	err = storage.InitDb()
+	checkErr(err)
+
+	err = storage.GetAllVideos()
+	checkErr(err)
+
+	settings, err := storage.GetSettings()
+	checkErr(err)
+
+	selection := mpcexport.Selection{Playlist: *playlistID, Collection: *collection, Actor: *actor, Sort: *sortBy}
+	selection.Filter.Category = *category
+	selection.Filter.Title = *title
+	selection.Filter.Tag = *tag
+
+	playlistTitle, videos, err := mpcexport.Select(storage, selection)
+	checkErr(err)
+
+	if *nfo {
+		library := &mpclibrary.Library{Path: settings.LibraryPath, Settings: settings}
+
+		for _, video := range videos {
+			err = library.SaveNFOData(video)
+			if err != nil {
+				fmt.Fprintln(os.Stderr, video.Title, err)
+				continue
+			}
+
+			fmt.Fprintln(os.Stderr, "Saved", library.NFODataPath(video))
+		}
+
+		return
+	}
+
+	if settings.HMACkey == nil {
+		checkErr(errors.New("the HMAC key doesn't exist, start the MPC server first"))
+	}
+
+	// the URLs are signed like the URLs of the export endpoints, rotating the media key revokes them
+	auth := &mpcauth.Auth{Key: settings.HMACkey, MediaKeyGeneration: settings.MediaKeyGeneration}
+
+	expires := time.Now().Add(*ttl).Unix()
+
+	playlist, err := mpcexport.NewPlaylist(playlistTitle, videos, *baseURL, func(videoID string) (string, error) {
+		return auth.SignMedia(mpcauth.MediaClaims{Video: videoID, Operations: []string{mpcauth.OperationStream, mpcauth.OperationThumb}, Expires: expires})
+	})
+	checkErr(err)
+
+	var writer io.Writer = os.Stdout
+
+	if *output != "" {
+		file, err := os.Create(*output)
+		checkErr(err)
+		defer file.Close()
+
+		writer = file
+	}
+
+	err = mpcexport.Write(writer, *format, playlist)
+	checkErr(err)
+
+	fmt.Fprintln(os.Stderr, "Exported", len(playlist.Tracks), "videos")
+}
+
+func checkErr(err error) {
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
@@ -1,18 +1,27 @@
 package main
 
 import (
+	"github.com/jempe/mpc/artwork"
+	"github.com/jempe/mpc/config"
 	"github.com/jempe/mpc/library"
 	"github.com/jempe/mpc/storage"
 	"github.com/jempe/mpc/utils"
+	"flag"
 	"fmt"
 	"os"
 	"path/filepath"
 )
 
 func main() {
-	if len(os.Args) > 1 {
-		source := os.Args[1]
-		configPath := mpcutils.ConfigFolder()
+	config, _, err := mpcconfig.Parse("config", "ffmpeg", "ffprobe")
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	if flag.NArg() > 0 {
+		source := flag.Arg(0)
+		configPath := config.ConfigPath
 
 		storage := &mpcstorage.Storage{Path: configPath}
 
@@ -51,7 +60,7 @@ func main() {
 						printErr(err)
 					} else {
 						if videoData.ImgURL != "" {
-							err = mpcutils.DownloadImage(videoData.ImgURL, targetScreenshot)
+							err = mpcartwork.DownloadFile(videoData.ImgURL, targetScreenshot)
 							printErr(err)
 						}
 					}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"github.com/jempe/mpc/config"
+	"github.com/jempe/mpc/fsck"
+	"github.com/jempe/mpc/library"
+	"github.com/jempe/mpc/storage"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+var (
+	checksums = flag.Bool("checksums", true, "Compare the MD5 sum of the video files with the DB, every file is read")
+	repair    = flag.String("repair", "", "Repair the issues of these kinds separated by commas, or all of them with all")
+	apply     = flag.Bool("apply", false, "Apply the repairs, without it the repairs are only shown")
+)
+
+func main() {
+	config, _, err := mpcconfig.Parse("config", "ffprobe")
+	checkErr(err)
+
+	storage := &mpcstorage.Storage{Path: config.ConfigPath}
+
+	err = storage.InitDb()
+	checkErr(err)
+	defer storage.Db.Close()
+
+	settings, err := storage.GetSettings()
+	checkErr(err)
+
+	library := &mpclibrary.Library{Path: settings.LibraryPath, Settings: settings}
+
+	checker := &mpcfsck.Checker{Storage: storage, Library: library, Checksums: *checksums, User: "mpcfsck"}
+
+	report, err := checker.Check()
+	checkErr(err)
+
+	fmt.Println("Checked", report.Videos, "videos and", report.Files, "files in", report.Finished.Sub(report.Started).Round(time.Millisecond))
+
+	kinds := report.ByKind()
+
+	for _, kind := range mpcfsck.Kinds {
+		fmt.Printf("\n%s: %d\n", kind, len(kinds[kind]))
+
+		for _, issue := range kinds[kind] {
+			fmt.Println(" ", issueName(issue), "-", issue.Detail, "["+issue.Repair+"]")
+		}
+	}
+
+	if *repair == "" {
+		return
+	}
+
+	var selected []string
+
+	if *repair != "all" {
+		for _, kind := range strings.Split(*repair, ",") {
+			selected = append(selected, strings.TrimSpace(kind))
+		}
+	}
+
+	if *apply {
+		fmt.Println("\nRepairs:")
+	} else {
+		fmt.Println("\nRepairs (dry run, use -apply to apply them):")
+	}
+
+	failed := false
+
+	for _, result := range checker.Repair(mpcfsck.Filter(report.Issues, selected), !*apply) {
+		status := "ok"
+
+		if result.Error != "" {
+			status = result.Error
+			failed = true
+		}
+
+		fmt.Println(" ", result.Issue.Repair, issueName(result.Issue), "-", status)
+	}
+
+	if failed {
+		os.Exit(1)
+	}
+}
+
+// issueName gets the file or the video of the issue
+func issueName(issue mpcfsck.Issue) string {
+	if issue.Path != "" {
+		return issue.Path
+	}
+
+	return issue.VideoID
+}
+
+func checkErr(err error) {
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
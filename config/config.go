@@ -0,0 +1,473 @@
+// MPC Config loads the settings of the server and the command line tools. Every setting has a default that is
+// changed by config.json of the config folder, then by an MPC_ environment variable and then by a flag, and the
+// config remembers where every effective value came from
+//
+package mpcconfig
+
+import (
+	"github.com/jempe/mpc/utils"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// FileName is the name of the config file in the config folder
+const FileName = "config.json"
+
+// kinds of the sources of the settings
+const (
+	SourceDefault = "default"
+	SourceFile    = "file"
+	SourceEnv     = "env"
+	SourceFlag    = "flag"
+	// SourceKeyFile is the source of the key when it's read from keyFile
+	SourceKeyFile = "keyFile"
+)
+
+// Config has the effective settings
+type Config struct {
+	// ConfigPath is the folder of the DB, the config file and the caches, it can't be set in the config file
+	ConfigPath string
+	// Address is the IP or the host name where the server listens, it listens in all the interfaces when it's empty
+	Address string
+	Port    int
+	// TLSCert and TLSKey are the PEM files of the certificate, the server uses HTTPS when they are set
+	TLSCert string
+	TLSKey  string
+	// LibraryPath is the videos folder, the server saves it in the DB settings when the DB has no library
+	LibraryPath string
+	// Key encrypts the videos and the images, it's read from KeyFile when it's not set
+	Key     string
+	KeyFile string
+	FFmpeg  string
+	FFprobe string
+	// Workers is the number of videos that the bulk file actions of the server process in parallel
+	Workers int
+	// SessionTTL is the validity of the login sessions
+	SessionTTL time.Duration
+	// AdminName, AdminEmail and AdminPassword are the admin user that is created when the server starts
+	AdminName     string
+	AdminEmail    string
+	AdminPassword string
+	DLNA          bool
+	// AllowedOrigins are the web origins besides the server that can connect to the remote, like https://remote.example.com
+	AllowedOrigins []string
+
+	sources   map[string]Source
+	flags     map[string]string
+	lookupEnv func(key string) (string, bool)
+}
+
+// Source is where the effective value of a setting came from
+type Source struct {
+	Kind string `json:"kind"`
+	// Detail is the config file, the environment variable or the flag
+	Detail string `json:"detail,omitempty"`
+}
+
+// setting describes a setting, its name in the config file, its environment variable and its flag
+type setting struct {
+	Name string
+	Env  string
+	// Flag is empty for the secrets, they are not shown in the process list
+	Flag    string
+	Default string
+	Usage   string
+	Secret  bool
+	// NotInFile is set for the settings that are needed to find the config file
+	NotInFile bool
+	Bool      bool
+
+	set func(config *Config, value string) error
+	get func(config *Config) string
+}
+
+// New creates a config, the settings are loaded by Load
+//
+func New() *Config {
+	return &Config{sources: make(map[string]Source), flags: make(map[string]string), lookupEnv: os.LookupEnv}
+}
+
+// definitions are the settings in the order they are printed
+//
+func definitions() []setting {
+	return []setting{
+		stringSetting(setting{Name: "config", Env: "MPC_CONFIG", Flag: "config", Default: mpcutils.ConfigFolder(), NotInFile: true, Usage: "Define the path of config folder"}, func(config *Config) *string { return &config.ConfigPath }),
+		stringSetting(setting{Name: "address", Env: "MPC_ADDRESS", Flag: "address", Usage: "IP or host name where the server listens, all the interfaces when it's empty"}, func(config *Config) *string { return &config.Address }),
+		intSetting(setting{Name: "port", Env: "MPC_PORT", Flag: "port", Default: "3000", Usage: "Port of the server"}, func(config *Config) *int { return &config.Port }),
+		stringSetting(setting{Name: "tlsCert", Env: "MPC_TLS_CERT", Flag: "tls-cert", Usage: "PEM certificate file, the server uses HTTPS when it's set"}, func(config *Config) *string { return &config.TLSCert }),
+		stringSetting(setting{Name: "tlsKey", Env: "MPC_TLS_KEY", Flag: "tls-key", Usage: "PEM private key file of the certificate"}, func(config *Config) *string { return &config.TLSKey }),
+		stringSetting(setting{Name: "library", Env: "MPC_LIBRARY", Flag: "path", Usage: "Define the path of your videos folder"}, func(config *Config) *string { return &config.LibraryPath }),
+		stringSetting(setting{Name: "key", Env: "MPC_KEY", Secret: true, Usage: "Key of the encrypted videos, 16, 24 or 32 bytes"}, func(config *Config) *string { return &config.Key }),
+		stringSetting(setting{Name: "keyFile", Env: "MPC_KEY_FILE", Flag: "key-file", Usage: "File with the key of the encrypted videos"}, func(config *Config) *string { return &config.KeyFile }),
+		stringSetting(setting{Name: "ffmpeg", Env: "MPC_FFMPEG", Flag: "ffmpeg", Default: "ffmpeg", Usage: "Path of the ffmpeg binary"}, func(config *Config) *string { return &config.FFmpeg }),
+		stringSetting(setting{Name: "ffprobe", Env: "MPC_FFPROBE", Flag: "ffprobe", Default: "ffprobe", Usage: "Path of the ffprobe binary"}, func(config *Config) *string { return &config.FFprobe }),
+		intSetting(setting{Name: "workers", Env: "MPC_WORKERS", Flag: "workers", Default: "2", Usage: "Number of videos that the bulk file actions process in parallel"}, func(config *Config) *int { return &config.Workers }),
+		durationSetting(setting{Name: "sessionTTL", Env: "MPC_SESSION_TTL", Flag: "session-ttl", Default: "1h", Usage: "Validity of the login sessions"}, func(config *Config) *time.Duration { return &config.SessionTTL }),
+		stringSetting(setting{Name: "adminName", Env: "MPC_ADMIN_NAME", Flag: "admin-name", Default: "Admin", Usage: "Name of the admin user"}, func(config *Config) *string { return &config.AdminName }),
+		stringSetting(setting{Name: "adminEmail", Env: "MPC_ADMIN_EMAIL", Flag: "admin-email", Default: DefaultAdminEmail, Usage: "Email of the admin user"}, func(config *Config) *string { return &config.AdminEmail }),
+		stringSetting(setting{Name: "adminPassword", Env: "MPC_ADMIN_PASSWORD", Default: DefaultAdminPassword, Secret: true, Usage: "Password of the admin user"}, func(config *Config) *string { return &config.AdminPassword }),
+		boolSetting(setting{Name: "dlna", Env: "MPC_DLNA", Flag: "dlna", Default: "false", Usage: "Share the videos that are not encrypted with the TVs of the LAN"}, func(config *Config) *bool { return &config.DLNA }),
+		listSetting(setting{Name: "allowedOrigins", Env: "MPC_ALLOWED_ORIGINS", Flag: "allowed-origins", Usage: "Origins separated by commas that can connect to the remote besides the server"}, func(config *Config) *[]string { return &config.AllowedOrigins }),
+	}
+}
+
+// stringSetting sets and gets a string field of the config
+//
+func stringSetting(definition setting, field func(config *Config) *string) setting {
+	definition.set = func(config *Config, value string) error {
+		*field(config) = value
+		return nil
+	}
+
+	definition.get = func(config *Config) string {
+		return *field(config)
+	}
+
+	return definition
+}
+
+// listSetting sets and gets a list field of the config, the values are separated by commas
+//
+func listSetting(definition setting, field func(config *Config) *[]string) setting {
+	definition.set = func(config *Config, value string) error {
+		var list []string
+
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				list = append(list, item)
+			}
+		}
+
+		*field(config) = list
+
+		return nil
+	}
+
+	definition.get = func(config *Config) string {
+		return strings.Join(*field(config), ",")
+	}
+
+	return definition
+}
+
+// intSetting sets and gets an integer field of the config
+//
+func intSetting(definition setting, field func(config *Config) *int) setting {
+	definition.set = func(config *Config, value string) error {
+		number, err := strconv.Atoi(strings.TrimSpace(value))
+		if err != nil {
+			return fmt.Errorf("%q is not a number", value)
+		}
+
+		*field(config) = number
+
+		return nil
+	}
+
+	definition.get = func(config *Config) string {
+		return strconv.Itoa(*field(config))
+	}
+
+	return definition
+}
+
+// boolSetting sets and gets a boolean field of the config
+//
+func boolSetting(definition setting, field func(config *Config) *bool) setting {
+	definition.Bool = true
+
+	definition.set = func(config *Config, value string) error {
+		boolean, err := strconv.ParseBool(strings.TrimSpace(value))
+		if err != nil {
+			return fmt.Errorf("%q is not true or false", value)
+		}
+
+		*field(config) = boolean
+
+		return nil
+	}
+
+	definition.get = func(config *Config) string {
+		return strconv.FormatBool(*field(config))
+	}
+
+	return definition
+}
+
+// durationSetting sets and gets a duration field of the config, like 90m or 12h
+//
+func durationSetting(definition setting, field func(config *Config) *time.Duration) setting {
+	definition.set = func(config *Config, value string) error {
+		duration, err := time.ParseDuration(strings.TrimSpace(value))
+		if err != nil {
+			return fmt.Errorf("%q is not a duration like 90m or 12h", value)
+		}
+
+		*field(config) = duration
+
+		return nil
+	}
+
+	definition.get = func(config *Config) string {
+		return field(config).String()
+	}
+
+	return definition
+}
+
+// flagValue is the flag of a setting, the value is applied by Load after the config file and the environment
+type flagValue struct {
+	config     *Config
+	definition setting
+}
+
+func (value *flagValue) String() string {
+	if value.config == nil {
+		return ""
+	}
+
+	if raw, ok := value.config.flags[value.definition.Name]; ok {
+		return raw
+	}
+
+	return value.definition.Default
+}
+
+func (value *flagValue) Set(raw string) error {
+	// the value is checked when the flag is parsed so the error shows the usage
+	err := value.definition.set(&Config{}, raw)
+	if err != nil {
+		return err
+	}
+
+	value.config.flags[value.definition.Name] = raw
+
+	return nil
+}
+
+func (value *flagValue) IsBoolFlag() bool {
+	return value.definition.Bool
+}
+
+// Flags defines the flags of the settings with these names in the flag set, or of all the settings when there are
+// no names. The secrets don't have flags
+//
+func (config *Config) Flags(set *flag.FlagSet, names ...string) {
+	for _, definition := range definitions() {
+		if definition.Flag == "" || (len(names) > 0 && !contains(names, definition.Name)) {
+			continue
+		}
+
+		set.Var(&flagValue{config: config, definition: definition}, definition.Flag, definition.Usage+" ("+definition.Env+")")
+	}
+}
+
+// Parse defines the flags of the settings with these names in the command line, parses it and loads and validates
+// the config, the command line tools use it
+//
+func Parse(names ...string) (config *Config, warnings []string, err error) {
+	config = New()
+	config.Flags(flag.CommandLine, names...)
+	flag.Parse()
+
+	err = config.Load()
+	if err != nil {
+		return
+	}
+
+	warnings, err = config.Validate()
+	if err != nil {
+		return
+	}
+
+	config.UseTools()
+
+	return
+}
+
+// Load applies the defaults, the config file, the environment variables and the flags. The key is read from the
+// key file when it's not set
+//
+func (config *Config) Load() error {
+	var problems Errors
+
+	definitions := definitions()
+
+	// the config folder is found first, the config file is in it
+	for _, definition := range definitions {
+		config.apply(definition, definition.Default, Source{Kind: SourceDefault}, &problems)
+
+		if definition.NotInFile {
+			config.override(definition, &problems)
+		}
+	}
+
+	fileValues, err := config.readFile()
+	if err != nil {
+		return err
+	}
+
+	file := config.File()
+
+	for name, value := range fileValues {
+		definition, ok := lookup(definitions, name)
+
+		if !ok {
+			problems = append(problems, fmt.Sprintf("unknown setting %q in %s", name, file))
+		} else if definition.NotInFile {
+			problems = append(problems, fmt.Sprintf("%s can't be set in %s", name, file))
+		} else {
+			config.apply(definition, value, Source{Kind: SourceFile, Detail: file}, &problems)
+		}
+	}
+
+	for _, definition := range definitions {
+		if !definition.NotInFile {
+			config.override(definition, &problems)
+		}
+	}
+
+	if config.Key == "" && config.KeyFile != "" {
+		data, err := ioutil.ReadFile(config.KeyFile)
+		if err != nil {
+			problems = append(problems, "keyFile: "+err.Error())
+		} else {
+			config.Key = strings.TrimRight(string(data), "\r\n")
+			config.sources["key"] = Source{Kind: SourceKeyFile, Detail: config.KeyFile}
+		}
+	}
+
+	if len(problems) > 0 {
+		return problems
+	}
+
+	return nil
+}
+
+// apply sets the value of a setting and its source
+//
+func (config *Config) apply(definition setting, value string, source Source, problems *Errors) {
+	err := definition.set(config, value)
+	if err != nil {
+		*problems = append(*problems, fmt.Sprintf("%s from %s: %s", definition.Name, source, err))
+		return
+	}
+
+	config.sources[definition.Name] = source
+}
+
+// override applies the environment variable and then the flag of a setting
+//
+func (config *Config) override(definition setting, problems *Errors) {
+	if value, ok := config.lookupEnv(definition.Env); ok {
+		config.apply(definition, value, Source{Kind: SourceEnv, Detail: definition.Env}, problems)
+	}
+
+	if value, ok := config.flags[definition.Name]; ok {
+		config.apply(definition, value, Source{Kind: SourceFlag, Detail: "-" + definition.Flag}, problems)
+	}
+}
+
+// readFile reads the values of the config file, the file is optional
+//
+func (config *Config) readFile() (values map[string]string, err error) {
+	values = make(map[string]string)
+
+	data, err := ioutil.ReadFile(config.File())
+	if os.IsNotExist(err) {
+		return values, nil
+	}
+
+	if err != nil {
+		return
+	}
+
+	var raw map[string]interface{}
+
+	decoder := json.NewDecoder(strings.NewReader(string(data)))
+	decoder.UseNumber()
+
+	err = decoder.Decode(&raw)
+	if err != nil {
+		return values, fmt.Errorf("%s: %s", config.File(), err)
+	}
+
+	for name, value := range raw {
+		switch value := value.(type) {
+		case string:
+			values[name] = value
+		case json.Number:
+			values[name] = value.String()
+		case bool:
+			values[name] = strconv.FormatBool(value)
+		case []interface{}:
+			// the lists are joined like the lists of the environment and the flags
+			var items []string
+
+			for _, item := range value {
+				text, ok := item.(string)
+				if !ok || strings.Contains(text, ",") {
+					return values, fmt.Errorf("%s: %s must be a list of strings without commas", config.File(), name)
+				}
+
+				items = append(items, text)
+			}
+
+			values[name] = strings.Join(items, ",")
+		default:
+			return values, fmt.Errorf("%s: %s must be a string, a number, a boolean or a list", config.File(), name)
+		}
+	}
+
+	return
+}
+
+// File gets the path of the config file
+//
+func (config *Config) File() string {
+	return strings.TrimSuffix(config.ConfigPath, "/") + "/" + FileName
+}
+
+// Source gets where the effective value of a setting came from
+//
+func (config *Config) Source(name string) Source {
+	return config.sources[name]
+}
+
+// String shows the kind and the detail of the source, like env MPC_PORT
+//
+func (source Source) String() string {
+	if source.Detail == "" {
+		return source.Kind
+	}
+
+	return source.Kind + " " + source.Detail
+}
+
+// lookup finds the setting with the name
+//
+func lookup(definitions []setting, name string) (setting, bool) {
+	for _, definition := range definitions {
+		if definition.Name == name {
+			return definition, true
+		}
+	}
+
+	return setting{}, false
+}
+
+func contains(names []string, name string) bool {
+	for _, item := range names {
+		if item == name {
+			return true
+		}
+	}
+
+	return false
+}
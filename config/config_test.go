@@ -0,0 +1,194 @@
+package mpcconfig
+
+import (
+	"bytes"
+	"flag"
+	"io/ioutil"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestConfig(t *testing.T, file string, env map[string]string, args ...string) *Config {
+	configPath := t.TempDir()
+
+	if file != "" {
+		err := ioutil.WriteFile(configPath+"/"+FileName, []byte(file), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	env["MPC_CONFIG"] = configPath
+
+	config := New()
+	config.lookupEnv = func(key string) (string, bool) {
+		value, ok := env[key]
+		return value, ok
+	}
+
+	flags := flag.NewFlagSet("test", flag.ContinueOnError)
+	config.Flags(flags)
+
+	err := flags.Parse(args)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return config
+}
+
+func TestLoadLayers(t *testing.T) {
+	file := `{"port": 8080, "workers": 4, "sessionTTL": "12h", "adminEmail": "admin@example.com", "dlna": true, "key": "0123456789abcdef"}`
+	env := map[string]string{"MPC_PORT": "9090", "MPC_WORKERS": "6"}
+
+	config := newTestConfig(t, file, env, "-workers", "8", "-dlna=false")
+
+	err := config.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if config.Port != 9090 || config.Source("port") != (Source{Kind: SourceEnv, Detail: "MPC_PORT"}) {
+		t.Error("the environment should override the file", config.Port, config.Source("port"))
+	}
+
+	if config.Workers != 8 || config.Source("workers") != (Source{Kind: SourceFlag, Detail: "-workers"}) {
+		t.Error("the flag should override the environment", config.Workers, config.Source("workers"))
+	}
+
+	if config.DLNA || config.Source("dlna").Kind != SourceFlag {
+		t.Error("the bool flag should be applied", config.DLNA)
+	}
+
+	if config.SessionTTL != 12*time.Hour || config.Source("sessionTTL") != (Source{Kind: SourceFile, Detail: config.File()}) {
+		t.Error("the file should override the default", config.SessionTTL, config.Source("sessionTTL"))
+	}
+
+	if config.FFmpeg != "ffmpeg" || config.Source("ffmpeg").Kind != SourceDefault {
+		t.Error("the default should be used", config.FFmpeg, config.Source("ffmpeg"))
+	}
+
+	if config.Source("config").Kind != SourceEnv || config.ListenAddress() != ":9090" || config.URL("10.0.0.2") != "http://10.0.0.2:9090" {
+		t.Error("wrong config folder or address", config.ConfigPath, config.ListenAddress())
+	}
+
+	_, err = config.Validate()
+	if err != nil {
+		t.Error(err)
+	}
+
+	var output bytes.Buffer
+
+	err = config.Print(&output)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(output.String(), "env MPC_PORT") || !strings.Contains(output.String(), "flag -workers") || strings.Contains(output.String(), "0123456789abcdef") {
+		t.Error("the sources should be printed and the key should be hidden", output.String())
+	}
+}
+
+func TestAllowedOrigins(t *testing.T) {
+	config := newTestConfig(t, `{"allowedOrigins": ["https://remote.example.com", "http://10.0.0.5:8080"]}`, map[string]string{})
+
+	err := config.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(config.AllowedOrigins) != 2 || config.AllowedOrigins[1] != "http://10.0.0.5:8080" {
+		t.Error("the list should be read from the file", config.AllowedOrigins)
+	}
+
+	config = newTestConfig(t, "", map[string]string{"MPC_ALLOWED_ORIGINS": "https://remote.example.com, remote.example.com"})
+
+	err = config.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = config.Validate(); err == nil || !strings.Contains(err.Error(), `allowedOrigins "remote.example.com"`) {
+		t.Error("the origins need a scheme and a host", err)
+	}
+}
+
+func TestKeyFile(t *testing.T) {
+	keyFile := t.TempDir() + "/key"
+
+	err := ioutil.WriteFile(keyFile, []byte("0123456789abcdef0123456789abcdef\n"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	config := newTestConfig(t, "", map[string]string{}, "-key-file", keyFile)
+
+	err = config.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if config.Key != "0123456789abcdef0123456789abcdef" || config.Source("key") != (Source{Kind: SourceKeyFile, Detail: keyFile}) {
+		t.Error("the key should be read from the key file", config.Key, config.Source("key"))
+	}
+
+	_, err = config.Validate()
+	if err != nil {
+		t.Error(err)
+	}
+
+	config = newTestConfig(t, `{"key": "0123456789abcdef"}`, map[string]string{"MPC_KEY_FILE": keyFile})
+
+	err = config.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = config.Validate(); err == nil || !strings.Contains(err.Error(), "key and keyFile") {
+		t.Error("the key and the key file can't be set together", err)
+	}
+}
+
+func TestInvalidSettings(t *testing.T) {
+	config := newTestConfig(t, `{"port": "http", "config": "/tmp", "color": "blue"}`, map[string]string{"MPC_SESSION_TTL": "forever"})
+
+	err := config.Load()
+
+	problems, ok := err.(Errors)
+	if !ok || len(problems) != 4 {
+		t.Fatal("the invalid values and the unknown settings should be errors", err)
+	}
+
+	file := `{"port": 70000, "workers": 0, "tlsCert": "cert.pem", "key": "short", "ffmpeg": "/missing/ffmpeg", "adminEmail": "admin", "adminPassword": "1234", "library": "/missing/videos"}`
+
+	config = newTestConfig(t, file, map[string]string{})
+
+	err = config.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = config.Validate()
+
+	problems, ok = err.(Errors)
+	if !ok || len(problems) != 8 {
+		t.Error("every invalid setting should be reported", err)
+	}
+}
+
+func TestFlagErrors(t *testing.T) {
+	config := New()
+
+	flags := flag.NewFlagSet("test", flag.ContinueOnError)
+	flags.SetOutput(ioutil.Discard)
+	config.Flags(flags, "port")
+
+	if flags.Lookup("workers") != nil || flags.Lookup("key") != nil {
+		t.Error("only the port flag should be defined")
+	}
+
+	if err := flags.Parse([]string{"-port", "http"}); err == nil {
+		t.Error("the invalid flag value should be rejected")
+	}
+}
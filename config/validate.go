@@ -0,0 +1,171 @@
+package mpcconfig
+
+import (
+	"github.com/jempe/mpc/utils"
+	"crypto/tls"
+	"fmt"
+	"github.com/asaskevich/govalidator"
+	"io"
+	"net"
+	"net/url"
+	"os/exec"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// DefaultAdminEmail and DefaultAdminPassword are the admin user of a new server, the password must be changed
+const (
+	DefaultAdminEmail    = "test@jempe.org"
+	DefaultAdminPassword = "test1234"
+)
+
+// MaxWorkers is the max number of workers
+const MaxWorkers = 64
+
+// Errors are the problems of the settings, they are shown together
+type Errors []string
+
+func (problems Errors) Error() string {
+	return "invalid config: " + strings.Join(problems, "; ")
+}
+
+// Validate checks the effective settings, the warnings are settings that work but limit the server
+//
+func (config *Config) Validate() (warnings []string, err error) {
+	var problems Errors
+
+	if config.ConfigPath == "" {
+		problems = append(problems, "config can't be empty")
+	}
+
+	if config.Address != "" && net.ParseIP(config.Address) == nil && !govalidator.IsDNSName(config.Address) {
+		problems = append(problems, fmt.Sprintf("address %q is not an IP or a host name", config.Address))
+	}
+
+	if config.Port < 1 || config.Port > 65535 {
+		problems = append(problems, fmt.Sprintf("port %d must be between 1 and 65535", config.Port))
+	}
+
+	if (config.TLSCert == "") != (config.TLSKey == "") {
+		problems = append(problems, "tlsCert and tlsKey must be set together")
+	} else if config.TLS() {
+		if _, err := tls.LoadX509KeyPair(config.TLSCert, config.TLSKey); err != nil {
+			problems = append(problems, "tlsCert and tlsKey: "+err.Error())
+		}
+	}
+
+	if config.LibraryPath != "" && !mpcutils.IsDir(config.LibraryPath) {
+		problems = append(problems, fmt.Sprintf("library %q is not a folder", config.LibraryPath))
+	}
+
+	if config.KeyFile != "" && config.sources["key"].Kind != SourceKeyFile {
+		problems = append(problems, "key and keyFile can't be set together")
+	}
+
+	switch len(config.Key) {
+	case 0:
+		warnings = append(warnings, "there is no key, the encrypted videos can't be played")
+	case 16, 24, 32:
+	default:
+		problems = append(problems, fmt.Sprintf("key from %s has %d bytes, it must have 16, 24 or 32 bytes", config.sources["key"], len(config.Key)))
+	}
+
+	for _, binary := range []struct{ name, path string }{{"ffmpeg", config.FFmpeg}, {"ffprobe", config.FFprobe}} {
+		if _, err := exec.LookPath(binary.path); err != nil {
+			// the default binaries are optional, the screenshots and the video information need them
+			if config.sources[binary.name].Kind == SourceDefault {
+				warnings = append(warnings, binary.name+" was not found, the screenshots and the video information are not available")
+			} else {
+				problems = append(problems, binary.name+": "+err.Error())
+			}
+		}
+	}
+
+	if config.Workers < 1 || config.Workers > MaxWorkers {
+		problems = append(problems, fmt.Sprintf("workers %d must be between 1 and %d", config.Workers, MaxWorkers))
+	}
+
+	if config.SessionTTL < time.Minute {
+		problems = append(problems, fmt.Sprintf("sessionTTL %s must be at least 1m", config.SessionTTL))
+	}
+
+	if config.AdminName == "" {
+		problems = append(problems, "adminName can't be empty")
+	}
+
+	if !govalidator.IsEmail(config.AdminEmail) {
+		problems = append(problems, fmt.Sprintf("adminEmail %q is not an email", config.AdminEmail))
+	}
+
+	if len(config.AdminPassword) < 6 {
+		problems = append(problems, "adminPassword must have at least 6 characters")
+	} else if config.AdminPassword == DefaultAdminPassword {
+		warnings = append(warnings, "the admin password is the default one, change it with MPC_ADMIN_PASSWORD or "+FileName)
+	}
+
+	for _, origin := range config.AllowedOrigins {
+		if originURL, err := url.Parse(origin); err != nil || originURL.Scheme == "" || originURL.Host == "" || strings.Trim(originURL.Path, "/") != "" {
+			problems = append(problems, fmt.Sprintf("allowedOrigins %q is not an origin like https://remote.example.com", origin))
+		}
+	}
+
+	if len(problems) > 0 {
+		err = problems
+	}
+
+	return
+}
+
+// TLS checks if the server uses HTTPS
+//
+func (config *Config) TLS() bool {
+	return config.TLSCert != "" && config.TLSKey != ""
+}
+
+// ListenAddress gets the address of the http server, like :3000
+//
+func (config *Config) ListenAddress() string {
+	return net.JoinHostPort(config.Address, strconv.Itoa(config.Port))
+}
+
+// URL gets the URL of the server with the host, like http://192.168.1.10:3000
+//
+func (config *Config) URL(host string) string {
+	scheme := "http"
+	if config.TLS() {
+		scheme = "https"
+	}
+
+	return scheme + "://" + net.JoinHostPort(host, strconv.Itoa(config.Port))
+}
+
+// UseTools sets the ffmpeg and ffprobe binaries of the utils package
+//
+func (config *Config) UseTools() {
+	mpcutils.FFmpegPath = config.FFmpeg
+	mpcutils.FFprobePath = config.FFprobe
+}
+
+// Print writes every setting with its effective value and its source, the secrets are hidden
+//
+func (config *Config) Print(w io.Writer) error {
+	table := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+
+	fmt.Fprintln(table, "SETTING\tVALUE\tSOURCE")
+
+	for _, definition := range definitions() {
+		value := definition.get(config)
+
+		if definition.Secret && value != "" {
+			value = "********"
+		} else if value == "" {
+			value = "-"
+		}
+
+		fmt.Fprintf(table, "%s\t%s\t%s\n", definition.Name, value, config.sources[definition.Name])
+	}
+
+	return table.Flush()
+}
@@ -0,0 +1,632 @@
+package mpcdlna
+
+import (
+	"github.com/jempe/mpc/library"
+	"github.com/jempe/mpc/storage"
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// IDs of the containers of the content directory, the actors, categories and playlists use their type and ID, like actor/3
+const (
+	rootID       = "0"
+	allVideosID  = "all"
+	actorsID     = "actors"
+	categoriesID = "categories"
+	playlistsID  = "playlists"
+)
+
+// object is a container or a video of the content directory
+type object struct {
+	ID       string
+	ParentID string
+	Title    string
+	Children int
+	Video    *mpclibrary.Video
+}
+
+type soapEnvelope struct {
+	Body struct {
+		Action soapAction `xml:",any"`
+	} `xml:"Body"`
+}
+
+type soapAction struct {
+	XMLName        xml.Name
+	ObjectID       string
+	BrowseFlag     string
+	StartingIndex  int
+	RequestedCount int
+}
+
+type soapArgument struct {
+	Name  string
+	Value string
+}
+
+type didlLite struct {
+	XMLName    xml.Name        `xml:"DIDL-Lite"`
+	Xmlns      string          `xml:"xmlns,attr"`
+	XmlnsDC    string          `xml:"xmlns:dc,attr"`
+	XmlnsUPnP  string          `xml:"xmlns:upnp,attr"`
+	XmlnsDLNA  string          `xml:"xmlns:dlna,attr"`
+	Containers []didlContainer `xml:"container"`
+	Items      []didlItem      `xml:"item"`
+}
+
+type didlContainer struct {
+	ID         string `xml:"id,attr"`
+	ParentID   string `xml:"parentID,attr"`
+	Restricted int    `xml:"restricted,attr"`
+	ChildCount int    `xml:"childCount,attr"`
+	Title      string `xml:"dc:title"`
+	Class      string `xml:"upnp:class"`
+}
+
+type didlItem struct {
+	ID          string        `xml:"id,attr"`
+	ParentID    string        `xml:"parentID,attr"`
+	Restricted  int           `xml:"restricted,attr"`
+	Title       string        `xml:"dc:title"`
+	Class       string        `xml:"upnp:class"`
+	Date        string        `xml:"dc:date,omitempty"`
+	Description string        `xml:"dc:description,omitempty"`
+	Actors      []string      `xml:"upnp:actor"`
+	Genres      []string      `xml:"upnp:genre"`
+	AlbumArt    *didlAlbumArt `xml:"upnp:albumArtURI"`
+	Resource    didlResource  `xml:"res"`
+}
+
+type didlAlbumArt struct {
+	ProfileID string `xml:"dlna:profileID,attr"`
+	URL       string `xml:",chardata"`
+}
+
+type didlResource struct {
+	ProtocolInfo string `xml:"protocolInfo,attr"`
+	Duration     string `xml:"duration,attr,omitempty"`
+	Size         int64  `xml:"size,attr,omitempty"`
+	Resolution   string `xml:"resolution,attr,omitempty"`
+	URL          string `xml:",chardata"`
+}
+
+// contentDirectoryHandler answers the SOAP actions of the content directory
+//
+func (server *MediaServer) contentDirectoryHandler(w http.ResponseWriter, r *http.Request) {
+	action, ok := readSOAPAction(w, r)
+	if !ok {
+		return
+	}
+
+	switch action.XMLName.Local {
+	case "Browse":
+		server.browse(w, action)
+	case "GetSearchCapabilities":
+		writeSOAPResponse(w, contentDirectoryType, "GetSearchCapabilities", []soapArgument{{"SearchCaps", ""}})
+	case "GetSortCapabilities":
+		writeSOAPResponse(w, contentDirectoryType, "GetSortCapabilities", []soapArgument{{"SortCaps", ""}})
+	case "GetSystemUpdateID":
+		writeSOAPResponse(w, contentDirectoryType, "GetSystemUpdateID", []soapArgument{{"Id", strconv.Itoa(server.updateID())}})
+	default:
+		writeSOAPError(w, 401, "Invalid Action")
+	}
+}
+
+// connectionManagerHandler answers the SOAP actions of the connection manager, the server only sends videos
+//
+func (server *MediaServer) connectionManagerHandler(w http.ResponseWriter, r *http.Request) {
+	action, ok := readSOAPAction(w, r)
+	if !ok {
+		return
+	}
+
+	switch action.XMLName.Local {
+	case "GetProtocolInfo":
+		writeSOAPResponse(w, connectionManagerType, "GetProtocolInfo", []soapArgument{
+			{"Source", protocolInfo("video/mp4") + "," + protocolInfo("video/ogg") + "," + "http-get:*:image/jpeg:*"},
+			{"Sink", ""},
+		})
+	case "GetCurrentConnectionIDs":
+		writeSOAPResponse(w, connectionManagerType, "GetCurrentConnectionIDs", []soapArgument{{"ConnectionIDs", "0"}})
+	case "GetCurrentConnectionInfo":
+		writeSOAPResponse(w, connectionManagerType, "GetCurrentConnectionInfo", []soapArgument{
+			{"RcsID", "-1"},
+			{"AVTransportID", "-1"},
+			{"ProtocolInfo", ""},
+			{"PeerConnectionManager", ""},
+			{"PeerConnectionID", "-1"},
+			{"Direction", "Output"},
+			{"Status", "OK"},
+		})
+	default:
+		writeSOAPError(w, 401, "Invalid Action")
+	}
+}
+
+// browse answers the Browse action with the metadata of an object or the page of its children
+//
+func (server *MediaServer) browse(w http.ResponseWriter, action soapAction) {
+	var objects []object
+	var total int
+
+	switch action.BrowseFlag {
+	case "BrowseMetadata":
+		metadata, err := server.metadata(action.ObjectID)
+		if err != nil {
+			writeSOAPError(w, 701, "No such object")
+			return
+		}
+
+		objects = []object{metadata}
+		total = 1
+	case "BrowseDirectChildren":
+		_, children, err := server.container(action.ObjectID)
+		if err != nil {
+			writeSOAPError(w, 701, "No such object")
+			return
+		}
+
+		total = len(children)
+
+		if action.StartingIndex < 0 || action.RequestedCount < 0 {
+			writeSOAPError(w, 402, "Invalid Args")
+			return
+		}
+
+		// a requested count of 0 asks for all the children
+		last := total
+		if action.RequestedCount > 0 && action.StartingIndex+action.RequestedCount < total {
+			last = action.StartingIndex + action.RequestedCount
+		}
+
+		if action.StartingIndex < last {
+			objects = children[action.StartingIndex:last]
+		}
+	default:
+		writeSOAPError(w, 402, "Invalid Args")
+		return
+	}
+
+	result, err := server.didl(objects)
+	if err != nil {
+		writeSOAPError(w, 720, "Cannot process the request")
+		return
+	}
+
+	writeSOAPResponse(w, contentDirectoryType, "Browse", []soapArgument{
+		{"Result", result},
+		{"NumberReturned", strconv.Itoa(len(objects))},
+		{"TotalMatches", strconv.Itoa(total)},
+		{"UpdateID", strconv.Itoa(server.updateID())},
+	})
+}
+
+// metadata gets a container or a video by its object ID
+//
+func (server *MediaServer) metadata(objectID string) (object, error) {
+	if strings.HasPrefix(objectID, "video/") {
+		video, ok := server.sharedVideo(strings.TrimPrefix(objectID, "video/"))
+		if !ok {
+			return object{}, errors.New("object_not_exists")
+		}
+
+		return videoObject(allVideosID, video), nil
+	}
+
+	container, children, err := server.container(objectID)
+	container.Children = len(children)
+
+	return container, err
+}
+
+// container gets a container and its children, the containers of the children don't have the number of children
+//
+func (server *MediaServer) container(objectID string) (container object, children []object, err error) {
+	kind, id := splitObjectID(objectID)
+
+	switch kind {
+	case rootID:
+		container = object{ID: rootID, ParentID: "-1", Title: server.Name}
+
+		for _, childID := range []string{allVideosID, actorsID, categoriesID, playlistsID} {
+			child, err := server.metadata(childID)
+			if err != nil {
+				return container, nil, err
+			}
+
+			children = append(children, child)
+		}
+	case allVideosID:
+		container = object{ID: allVideosID, ParentID: rootID, Title: "All Videos"}
+		children = videoObjects(allVideosID, server.sharedVideos(func(video mpcstorage.Video) bool { return true }))
+	case actorsID:
+		container = object{ID: actorsID, ParentID: rootID, Title: "Actors"}
+
+		counts := make(map[int]int)
+
+		for _, video := range server.sharedVideos(func(video mpcstorage.Video) bool { return true }) {
+			for _, actor := range video.Actors {
+				counts[actor.ID]++
+			}
+		}
+
+		for actorID, actor := range server.Storage.CachedActors() {
+			if counts[actorID] > 0 {
+				children = append(children, object{ID: "actor/" + strconv.Itoa(actorID), ParentID: actorsID, Title: actor.Name, Children: counts[actorID]})
+			}
+		}
+
+		sortObjects(children)
+	case "actor":
+		actor, _ := server.Storage.GetActorByID(id)
+		if actor.Name == "" {
+			return container, nil, errors.New("object_not_exists")
+		}
+
+		container = object{ID: objectID, ParentID: actorsID, Title: actor.Name}
+		children = videoObjects(objectID, server.sharedVideos(func(video mpcstorage.Video) bool {
+			return containsInt(video.Actors, id)
+		}))
+	case categoriesID:
+		container = object{ID: categoriesID, ParentID: rootID, Title: "Categories"}
+		children = server.categoryObjects(0, categoriesID)
+	case "category":
+		category, _ := server.Storage.GetCategoryByID(id)
+		if category.Name == "" {
+			return container, nil, errors.New("object_not_exists")
+		}
+
+		parentID := categoriesID
+		if category.Parent != 0 {
+			parentID = "category/" + strconv.Itoa(category.Parent)
+		}
+
+		container = object{ID: objectID, ParentID: parentID, Title: category.Name}
+
+		// the subcategories go before the videos of the category
+		children = append(server.categoryObjects(id, objectID), videoObjects(objectID, server.sharedVideos(func(video mpcstorage.Video) bool {
+			return containsInt(video.Categories, id)
+		}))...)
+	case playlistsID:
+		container = object{ID: playlistsID, ParentID: rootID, Title: "Playlists"}
+
+		collections, err := server.Storage.GetCollections()
+		if err != nil {
+			return container, nil, err
+		}
+
+		for _, collection := range collections {
+			collectionID := "collection/" + strconv.Itoa(collection.ID)
+			children = append(children, object{ID: collectionID, ParentID: playlistsID, Title: collection.Name, Children: len(server.videosByIDs(collection.Videos))})
+		}
+
+		// the TVs can't log in so only the playlists that are shared with everybody are listed
+		playlists, err := server.Storage.GetPlaylists("")
+		if err != nil {
+			return container, nil, err
+		}
+
+		for _, playlist := range playlists {
+			if playlist.Shared {
+				children = append(children, object{ID: "playlist/" + playlist.ID, ParentID: playlistsID, Title: playlist.Name, Children: len(server.videosByIDs(playlist.Videos))})
+			}
+		}
+	case "collection":
+		collection, err := server.Storage.GetCollection(id)
+		if err != nil {
+			return container, nil, errors.New("object_not_exists")
+		}
+
+		container = object{ID: objectID, ParentID: playlistsID, Title: collection.Name}
+		children = videoObjects(objectID, server.videosByIDs(collection.Videos))
+	case "playlist":
+		playlist, err := server.Storage.GetPlaylist(strings.TrimPrefix(objectID, "playlist/"))
+		if err != nil || !playlist.Shared {
+			return container, nil, errors.New("object_not_exists")
+		}
+
+		container = object{ID: objectID, ParentID: playlistsID, Title: playlist.Name}
+		children = videoObjects(objectID, server.videosByIDs(playlist.Videos))
+	default:
+		return container, nil, errors.New("object_not_exists")
+	}
+
+	return
+}
+
+// categoryObjects gets the containers of the subcategories of a category, 0 gets the top categories
+//
+func (server *MediaServer) categoryObjects(parent int, parentID string) (children []object) {
+	for _, category := range server.Storage.GetCategoryList() {
+		if category.Parent != parent {
+			continue
+		}
+
+		videos := server.sharedVideos(func(video mpcstorage.Video) bool {
+			return containsInt(video.Categories, category.ID)
+		})
+
+		children = append(children, object{ID: "category/" + strconv.Itoa(category.ID), ParentID: parentID, Title: category.Name, Children: len(category.Children) + len(videos)})
+	}
+
+	return
+}
+
+// sharedVideos gets the videos that are not encrypted and pass the filter sorted by title
+//
+func (server *MediaServer) sharedVideos(filter func(video mpcstorage.Video) bool) (videos mpclibrary.Videos) {
+	for _, dbVideo := range server.Storage.CachedVideos() {
+		if dbVideo.Encrypted || !filter(dbVideo) {
+			continue
+		}
+
+		video, err := server.Storage.GetVideoByID(dbVideo.ID)
+		if err == nil {
+			videos = append(videos, video)
+		}
+	}
+
+	sort.Sort(mpclibrary.ByNaturalTitle{Videos: videos})
+
+	return
+}
+
+// sharedVideo gets a video when it is not encrypted
+//
+func (server *MediaServer) sharedVideo(videoID string) (video mpclibrary.Video, ok bool) {
+	dbVideo, ok := server.Storage.CachedVideo(videoID)
+	if !ok || dbVideo.Encrypted {
+		return video, false
+	}
+
+	video, err := server.Storage.GetVideoByID(videoID)
+
+	return video, err == nil
+}
+
+// videosByIDs gets the videos of a playlist in the same order, encrypted videos are skipped
+//
+func (server *MediaServer) videosByIDs(videoIDs []string) (videos mpclibrary.Videos) {
+	for _, videoID := range videoIDs {
+		if video, ok := server.sharedVideo(videoID); ok {
+			videos = append(videos, video)
+		}
+	}
+
+	return
+}
+
+// updateID changes when videos are added or removed so the TVs reload the folders
+//
+func (server *MediaServer) updateID() int {
+	return server.Storage.CountVideos()
+}
+
+// didl encodes the objects in the DIDL-Lite format
+//
+func (server *MediaServer) didl(objects []object) (string, error) {
+	result := didlLite{
+		Xmlns:     "urn:schemas-upnp-org:metadata-1-0/DIDL-Lite/",
+		XmlnsDC:   "http://purl.org/dc/elements/1.1/",
+		XmlnsUPnP: "urn:schemas-upnp-org:metadata-1-0/upnp/",
+		XmlnsDLNA: "urn:schemas-dlna-org:metadata-1-0/",
+	}
+
+	for _, item := range objects {
+		if item.Video == nil {
+			result.Containers = append(result.Containers, didlContainer{
+				ID:         item.ID,
+				ParentID:   item.ParentID,
+				Restricted: 1,
+				ChildCount: item.Children,
+				Title:      item.Title,
+				Class:      "object.container.storageFolder",
+			})
+
+			continue
+		}
+
+		result.Items = append(result.Items, server.didlItem(item))
+	}
+
+	data, err := xml.Marshal(result)
+
+	return string(data), err
+}
+
+// didlItem gets the DIDL-Lite item of a video with the URLs of the file and the thumb
+//
+func (server *MediaServer) didlItem(item object) didlItem {
+	video := item.Video
+
+	didlVideo := didlItem{
+		ID:          item.ID,
+		ParentID:    item.ParentID,
+		Restricted:  1,
+		Title:       video.Title,
+		Class:       "object.item.videoItem",
+		Description: video.Description,
+		Resource: didlResource{
+			ProtocolInfo: protocolInfo(videoMimeType(*video)),
+			URL:          server.videoURL(video.ID),
+		},
+	}
+
+	if !video.PubDate.IsZero() {
+		didlVideo.Date = video.PubDate.Format("2006-01-02")
+	}
+
+	for _, actor := range video.Actors {
+		didlVideo.Actors = append(didlVideo.Actors, actor.Name)
+	}
+
+	for _, category := range video.Categories {
+		didlVideo.Genres = append(didlVideo.Genres, category.Name)
+	}
+
+	if video.Duration > 0 {
+		didlVideo.Resource.Duration = fmt.Sprintf("%d:%02d:%02d.000", video.Duration/3600, video.Duration%3600/60, video.Duration%60)
+	}
+
+	if video.Width > 0 && video.Height > 0 {
+		didlVideo.Resource.Resolution = fmt.Sprintf("%dx%d", video.Width, video.Height)
+	}
+
+	videoPath := server.LibraryPath + "/" + video.File
+
+	if info, err := os.Stat(videoPath); err == nil {
+		didlVideo.Resource.Size = info.Size()
+	}
+
+	// the thumbs handler downloads the image of the video when the thumb doesn't exist
+	if _, err := os.Stat(videoPath + ".jpg"); err == nil || video.ImgURL != "" {
+		didlVideo.AlbumArt = &didlAlbumArt{ProfileID: "JPEG_TN", URL: server.thumbURL(video.ID)}
+	}
+
+	return didlVideo
+}
+
+// videoURL gets the URL of the video file, it is served by the same handler as the web player
+//
+func (server *MediaServer) videoURL(videoID string) string {
+	return server.BaseURL + "/videos/" + videoID + ".mp4" + server.signature(videoID)
+}
+
+// thumbURL gets the URL of the thumb of the video
+//
+func (server *MediaServer) thumbURL(videoID string) string {
+	return server.BaseURL + "/videos/thumbs/" + videoID + ".jpg" + server.signature(videoID)
+}
+
+// signature gets the query of the signed URLs of a video
+//
+func (server *MediaServer) signature(videoID string) string {
+	if server.Sign == nil {
+		return ""
+	}
+
+	return "?sig=" + url.QueryEscape(server.Sign(videoID))
+}
+
+// videoObjects gets the objects of the videos of a container
+//
+func videoObjects(parentID string, videos mpclibrary.Videos) (objects []object) {
+	for _, video := range videos {
+		objects = append(objects, videoObject(parentID, video))
+	}
+
+	return
+}
+
+// videoObject gets the object of a video
+//
+func videoObject(parentID string, video mpclibrary.Video) object {
+	return object{ID: "video/" + video.ID, ParentID: parentID, Title: video.Title, Video: &video}
+}
+
+// videoMimeType gets the mime type of the video file
+//
+func videoMimeType(video mpclibrary.Video) string {
+	if video.Extension == "ogv" {
+		return "video/ogg"
+	}
+
+	return "video/mp4"
+}
+
+// protocolInfo gets the DLNA protocol info of a mime type
+//
+func protocolInfo(mimeType string) string {
+	return "http-get:*:" + mimeType + ":" + contentFeatures
+}
+
+// splitObjectID gets the type and the ID of an object like category/3
+//
+func splitObjectID(objectID string) (kind string, id int) {
+	parts := strings.SplitN(objectID, "/", 2)
+	if len(parts) == 2 {
+		id, _ = strconv.Atoi(parts[1])
+	}
+
+	return parts[0], id
+}
+
+// sortObjects sorts the objects by title
+//
+func sortObjects(objects []object) {
+	sort.Slice(objects, func(i, j int) bool {
+		return mpclibrary.NaturalLess(strings.ToLower(objects[i].Title), strings.ToLower(objects[j].Title))
+	})
+}
+
+// containsInt checks if the list has the value
+//
+func containsInt(list []int, value int) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+
+	return false
+}
+
+// readSOAPAction decodes the action of a SOAP request
+//
+func readSOAPAction(w http.ResponseWriter, r *http.Request) (action soapAction, ok bool) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Invalid Request", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var envelope soapEnvelope
+
+	err := xml.NewDecoder(r.Body).Decode(&envelope)
+	if err != nil || envelope.Body.Action.XMLName.Local == "" {
+		writeSOAPError(w, 401, "Invalid Action")
+		return
+	}
+
+	return envelope.Body.Action, true
+}
+
+// writeSOAPResponse writes the response of an action with its output arguments in order
+//
+func writeSOAPResponse(w http.ResponseWriter, serviceType string, action string, arguments []soapArgument) {
+	var body bytes.Buffer
+
+	body.WriteString(xml.Header)
+	body.WriteString(`<s:Envelope xmlns:s="http://schemas.xmlsoap.org/soap/envelope/" s:encodingStyle="http://schemas.xmlsoap.org/soap/encoding/"><s:Body>`)
+	body.WriteString(`<u:` + action + `Response xmlns:u="` + serviceType + `">`)
+
+	for _, argument := range arguments {
+		body.WriteString("<" + argument.Name + ">")
+		xml.EscapeText(&body, []byte(argument.Value))
+		body.WriteString("</" + argument.Name + ">")
+	}
+
+	body.WriteString(`</u:` + action + `Response></s:Body></s:Envelope>`)
+
+	w.Header().Set("EXT", "")
+	writeXML(w, body.String())
+}
+
+// writeSOAPError writes a UPnP error
+//
+func writeSOAPError(w http.ResponseWriter, code int, description string) {
+	w.Header().Set("Content-Type", `text/xml; charset="utf-8"`)
+	w.WriteHeader(http.StatusInternalServerError)
+
+	fmt.Fprintf(w, `%s<s:Envelope xmlns:s="http://schemas.xmlsoap.org/soap/envelope/" s:encodingStyle="http://schemas.xmlsoap.org/soap/encoding/"><s:Body>`+
+		`<s:Fault><faultcode>s:Client</faultcode><faultstring>UPnPError</faultstring><detail>`+
+		`<UPnPError xmlns="urn:schemas-upnp-org:control-1-0"><errorCode>%d</errorCode><errorDescription>%s</errorDescription></UPnPError>`+
+		`</detail></s:Fault></s:Body></s:Envelope>`, xml.Header, code, description)
+}
@@ -0,0 +1,226 @@
+package mpcdlna
+
+import (
+	"github.com/jempe/mpc/library"
+	"github.com/jempe/mpc/storage"
+	"encoding/xml"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+type testBrowseResponse struct {
+	Body struct {
+		Response struct {
+			Result         string
+			NumberReturned int
+			TotalMatches   int
+		} `xml:"BrowseResponse"`
+		Fault struct {
+			ErrorCode int `xml:"detail>UPnPError>errorCode"`
+		} `xml:"Fault"`
+	} `xml:"Body"`
+}
+
+type testDIDL struct {
+	Containers []struct {
+		ID         string `xml:"id,attr"`
+		ChildCount int    `xml:"childCount,attr"`
+		Title      string `xml:"title"`
+	} `xml:"container"`
+	Items []struct {
+		ID       string `xml:"id,attr"`
+		Title    string `xml:"title"`
+		AlbumArt string `xml:"albumArtURI"`
+		Resource string `xml:"res"`
+	} `xml:"item"`
+}
+
+func newTestMediaServer(t *testing.T) (*MediaServer, *httptest.Server) {
+	testStorage := &mpcstorage.Storage{Path: t.TempDir()}
+
+	err := testStorage.InitDb()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() {
+		testStorage.Db.Close()
+	})
+
+	err = testStorage.InsertVideos([]mpclibrary.Video{
+		{File: "movie2.mp4", Title: "Movie 2", ImgURL: "http://example.com/2.jpg", Actors: []mpclibrary.Actor{{Name: "Jane Doe"}}, Categories: []mpclibrary.Category{{Name: "Drama"}}},
+		{File: "movie10.mp4", Title: "Movie 10", Actors: []mpclibrary.Actor{{Name: "Jane Doe"}}},
+		{File: "private.enc", Title: "Private", Encrypted: true, Actors: []mpclibrary.Actor{{Name: "John Doe"}}, Categories: []mpclibrary.Category{{Name: "Drama"}}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = testStorage.GetAllVideos()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mediaServer := NewMediaServer(testStorage, "", t.TempDir())
+
+	httpServer := httptest.NewServer(mediaServer)
+	t.Cleanup(httpServer.Close)
+
+	mediaServer.BaseURL = httpServer.URL
+	mediaServer.Sign = func(videoID string) string {
+		return "signature-" + videoID
+	}
+
+	return mediaServer, httpServer
+}
+
+// browse sends a Browse action like a TV and decodes the DIDL-Lite result
+//
+func browse(t *testing.T, httpServer *httptest.Server, objectID string, flag string, start int, count int) (response testBrowseResponse, didl testDIDL) {
+	body := `<?xml version="1.0"?><s:Envelope xmlns:s="http://schemas.xmlsoap.org/soap/envelope/"><s:Body>` +
+		`<u:Browse xmlns:u="urn:schemas-upnp-org:service:ContentDirectory:1"><ObjectID>` + objectID + `</ObjectID>` +
+		`<BrowseFlag>` + flag + `</BrowseFlag><Filter>*</Filter><StartingIndex>` + strconv.Itoa(start) + `</StartingIndex>` +
+		`<RequestedCount>` + strconv.Itoa(count) + `</RequestedCount><SortCriteria></SortCriteria></u:Browse></s:Body></s:Envelope>`
+
+	request, _ := http.NewRequest(http.MethodPost, httpServer.URL+"/dlna/control/ContentDirectory", strings.NewReader(body))
+	request.Header.Set("SOAPACTION", `"urn:schemas-upnp-org:service:ContentDirectory:1#Browse"`)
+
+	httpResponse, err := http.DefaultClient.Do(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer httpResponse.Body.Close()
+
+	err = xml.NewDecoder(httpResponse.Body).Decode(&response)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if response.Body.Response.Result != "" {
+		err = xml.Unmarshal([]byte(response.Body.Response.Result), &didl)
+		if err != nil {
+			t.Fatal("invalid DIDL-Lite", response.Body.Response.Result, err)
+		}
+	}
+
+	return
+}
+
+func TestBrowse(t *testing.T) {
+	mediaServer, httpServer := newTestMediaServer(t)
+
+	_, root := browse(t, httpServer, rootID, "BrowseDirectChildren", 0, 0)
+
+	if len(root.Containers) != 4 || root.Containers[0].ID != allVideosID || root.Containers[0].ChildCount != 2 {
+		t.Fatal("root should have all videos, actors, categories and playlists", root)
+	}
+
+	response, all := browse(t, httpServer, allVideosID, "BrowseDirectChildren", 0, 0)
+
+	if response.Body.Response.TotalMatches != 2 || len(all.Items) != 2 {
+		t.Fatal("encrypted videos should not be shared", all)
+	}
+
+	if all.Items[0].Title != "Movie 2" || all.Items[1].Title != "Movie 10" {
+		t.Error("videos should be sorted by natural title", all.Items)
+	}
+
+	movie, _ := mediaServer.Storage.GetVideoByFileName("movie2.mp4")
+
+	if all.Items[0].Resource != httpServer.URL+"/videos/"+movie.ID+".mp4?sig=signature-"+movie.ID || all.Items[0].AlbumArt != httpServer.URL+"/videos/thumbs/"+movie.ID+".jpg?sig=signature-"+movie.ID {
+		t.Error("video should link to the signed file and thumb", all.Items[0])
+	}
+
+	if all.Items[1].AlbumArt != "" {
+		t.Error("video without thumb should not have album art", all.Items[1])
+	}
+
+	response, page := browse(t, httpServer, allVideosID, "BrowseDirectChildren", 1, 1)
+
+	if response.Body.Response.NumberReturned != 1 || response.Body.Response.TotalMatches != 2 || page.Items[0].Title != "Movie 10" {
+		t.Error("browse should return the requested page", response.Body.Response, page)
+	}
+
+	_, actors := browse(t, httpServer, actorsID, "BrowseDirectChildren", 0, 0)
+
+	if len(actors.Containers) != 1 || actors.Containers[0].Title != "Jane Doe" || actors.Containers[0].ChildCount != 2 {
+		t.Fatal("actors without shared videos should be hidden", actors)
+	}
+
+	_, actorVideos := browse(t, httpServer, actors.Containers[0].ID, "BrowseDirectChildren", 0, 0)
+
+	if len(actorVideos.Items) != 2 {
+		t.Error("actor should have its videos", actorVideos)
+	}
+
+	_, categories := browse(t, httpServer, categoriesID, "BrowseDirectChildren", 0, 0)
+
+	if len(categories.Containers) != 1 || categories.Containers[0].ChildCount != 1 {
+		t.Fatal("category should only count the shared videos", categories)
+	}
+
+	_, metadata := browse(t, httpServer, "video/"+movie.ID, "BrowseMetadata", 0, 0)
+
+	if len(metadata.Items) != 1 || metadata.Items[0].Title != "Movie 2" {
+		t.Error("metadata of a video expected", metadata)
+	}
+}
+
+func TestBrowseHidden(t *testing.T) {
+	mediaServer, httpServer := newTestMediaServer(t)
+
+	private, _ := mediaServer.Storage.GetVideoByFileName("private.enc")
+
+	collection, err := mediaServer.Storage.SaveCollection(mpcstorage.Collection{Name: "Favorites", Videos: []string{private.ID}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, videos := browse(t, httpServer, "collection/"+strconv.Itoa(collection.ID), "BrowseDirectChildren", 0, 0)
+
+	if len(videos.Items) != 0 {
+		t.Error("encrypted videos of collections should not be shared", videos)
+	}
+
+	playlist, err := mediaServer.Storage.SavePlaylist(mpcstorage.Playlist{Name: "Mine", Owner: "user"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, objectID := range []string{"video/" + private.ID, "playlist/" + playlist.ID, "actor/99", "unknown"} {
+		response, _ := browse(t, httpServer, objectID, "BrowseMetadata", 0, 0)
+
+		if response.Body.Fault.ErrorCode != 701 {
+			t.Error("object should not exist", objectID, response.Body.Fault)
+		}
+	}
+}
+
+func TestDeviceDescription(t *testing.T) {
+	mediaServer, httpServer := newTestMediaServer(t)
+
+	response, err := http.Get(httpServer.URL + "/dlna/device.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer response.Body.Close()
+
+	var description struct {
+		Device struct {
+			UDN      string   `xml:"UDN"`
+			Services []string `xml:"serviceList>service>controlURL"`
+		} `xml:"device"`
+	}
+
+	err = xml.NewDecoder(response.Body).Decode(&description)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if description.Device.UDN != "uuid:"+mediaServer.UUID || len(description.Device.Services) != 2 {
+		t.Error("device description should have the UUID and the services", description)
+	}
+}
@@ -0,0 +1,301 @@
+package mpcdlna
+
+import (
+	"github.com/jempe/mpc/storage"
+	"encoding/xml"
+	"github.com/google/uuid"
+	"net/http"
+	"os"
+	"strings"
+)
+
+const (
+	mediaServerType       = "urn:schemas-upnp-org:device:MediaServer:1"
+	contentDirectoryType  = "urn:schemas-upnp-org:service:ContentDirectory:1"
+	connectionManagerType = "urn:schemas-upnp-org:service:ConnectionManager:1"
+)
+
+// contentFeatures tells the TVs that the videos can be seeked by bytes and streamed
+const contentFeatures = "DLNA.ORG_OP=01;DLNA.ORG_CI=0;DLNA.ORG_FLAGS=01700000000000000000000000000000"
+
+// MediaServer is a UPnP media server that lets the TVs of the LAN browse and play the videos of the library,
+// the encrypted videos are not shared because the TVs can't log in
+type MediaServer struct {
+	Storage *mpcstorage.Storage
+	// BaseURL is the address of the MPC server that the TVs use, like http://192.168.1.10:3000
+	BaseURL     string
+	LibraryPath string
+	Name        string
+	UUID        string
+	// Sign signs the URLs of the video and its thumb, the TVs can't send the session cookie
+	Sign func(videoID string) string
+}
+
+type deviceRoot struct {
+	XMLName     xml.Name    `xml:"root"`
+	Xmlns       string      `xml:"xmlns,attr"`
+	XmlnsDLNA   string      `xml:"xmlns:dlna,attr"`
+	SpecVersion specVersion `xml:"specVersion"`
+	Device      device      `xml:"device"`
+}
+
+type specVersion struct {
+	Major int `xml:"major"`
+	Minor int `xml:"minor"`
+}
+
+type device struct {
+	DeviceType   string    `xml:"deviceType"`
+	FriendlyName string    `xml:"friendlyName"`
+	Manufacturer string    `xml:"manufacturer"`
+	ModelName    string    `xml:"modelName"`
+	UDN          string    `xml:"UDN"`
+	DLNADoc      string    `xml:"dlna:X_DLNADOC"`
+	Services     []service `xml:"serviceList>service"`
+}
+
+type service struct {
+	ServiceType string `xml:"serviceType"`
+	ServiceID   string `xml:"serviceId"`
+	SCPDURL     string `xml:"SCPDURL"`
+	ControlURL  string `xml:"controlURL"`
+	EventSubURL string `xml:"eventSubURL"`
+}
+
+// NewMediaServer creates the media server of the library, the UUID is generated from the host name
+// and the library path so the TVs recognize the server after restarts
+//
+func NewMediaServer(storage *mpcstorage.Storage, baseURL string, libraryPath string) *MediaServer {
+	hostname, err := os.Hostname()
+	if err != nil || hostname == "" {
+		hostname = "localhost"
+	}
+
+	return &MediaServer{
+		Storage:     storage,
+		BaseURL:     strings.TrimSuffix(baseURL, "/"),
+		LibraryPath: libraryPath,
+		Name:        "MPC on " + hostname,
+		UUID:        uuid.NewSHA1(uuid.NameSpaceURL, []byte("mpc:"+hostname+":"+libraryPath)).String(),
+	}
+}
+
+// Location gets the URL of the device description that is announced with SSDP
+//
+func (server *MediaServer) Location() string {
+	return server.BaseURL + "/dlna/device.xml"
+}
+
+// ServeHTTP serves the device description, the service descriptions and the control URLs
+//
+// /dlna/device.xml
+// /dlna/ContentDirectory.xml and /dlna/ConnectionManager.xml
+// POST /dlna/control/ContentDirectory and /dlna/control/ConnectionManager
+// SUBSCRIBE /dlna/event/ContentDirectory and /dlna/event/ConnectionManager
+//
+func (server *MediaServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Path {
+	case "/dlna/device.xml":
+		server.deviceHandler(w, r)
+	case "/dlna/ContentDirectory.xml":
+		writeXML(w, contentDirectorySCPD)
+	case "/dlna/ConnectionManager.xml":
+		writeXML(w, connectionManagerSCPD)
+	case "/dlna/control/ContentDirectory":
+		server.contentDirectoryHandler(w, r)
+	case "/dlna/control/ConnectionManager":
+		server.connectionManagerHandler(w, r)
+	case "/dlna/event/ContentDirectory", "/dlna/event/ConnectionManager":
+		eventHandler(w, r)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// deviceHandler serves the description of the media server and its services
+//
+func (server *MediaServer) deviceHandler(w http.ResponseWriter, r *http.Request) {
+	description := deviceRoot{
+		Xmlns:       "urn:schemas-upnp-org:device-1-0",
+		XmlnsDLNA:   "urn:schemas-dlna-org:device-1-0",
+		SpecVersion: specVersion{Major: 1},
+		Device: device{
+			DeviceType:   mediaServerType,
+			FriendlyName: server.Name,
+			Manufacturer: "MPC",
+			ModelName:    "MPC Media Server",
+			UDN:          "uuid:" + server.UUID,
+			DLNADoc:      "DMS-1.50",
+			Services: []service{
+				{
+					ServiceType: contentDirectoryType,
+					ServiceID:   "urn:upnp-org:serviceId:ContentDirectory",
+					SCPDURL:     "/dlna/ContentDirectory.xml",
+					ControlURL:  "/dlna/control/ContentDirectory",
+					EventSubURL: "/dlna/event/ContentDirectory",
+				},
+				{
+					ServiceType: connectionManagerType,
+					ServiceID:   "urn:upnp-org:serviceId:ConnectionManager",
+					SCPDURL:     "/dlna/ConnectionManager.xml",
+					ControlURL:  "/dlna/control/ConnectionManager",
+					EventSubURL: "/dlna/event/ConnectionManager",
+				},
+			},
+		},
+	}
+
+	data, err := xml.MarshalIndent(description, "", "  ")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writeXML(w, xml.Header+string(data))
+}
+
+// eventHandler accepts the subscriptions of the TVs, the library changes are not evented
+// so the TVs refresh the folders when they browse them
+//
+func eventHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case "SUBSCRIBE":
+		sid := r.Header.Get("SID")
+		if sid == "" {
+			sid = "uuid:" + uuid.New().String()
+		}
+
+		w.Header().Set("SID", sid)
+		w.Header().Set("TIMEOUT", "Second-1800")
+	case "UNSUBSCRIBE":
+	default:
+		http.Error(w, "Invalid Request", http.StatusMethodNotAllowed)
+	}
+}
+
+// StreamHeaders adds the DLNA headers that the TVs need to stream and seek the videos
+//
+func StreamHeaders(w http.ResponseWriter, r *http.Request) {
+	// the TVs expect the header names with this case
+	if r.Header.Get("getcontentFeatures.dlna.org") == "1" {
+		w.Header()["contentFeatures.dlna.org"] = []string{contentFeatures}
+	}
+
+	if r.Header.Get("transferMode.dlna.org") != "" {
+		w.Header()["transferMode.dlna.org"] = []string{"Streaming"}
+	}
+}
+
+// writeXML writes an XML document
+//
+func writeXML(w http.ResponseWriter, data string) {
+	w.Header().Set("Content-Type", `text/xml; charset="utf-8"`)
+	w.Write([]byte(data))
+}
+
+const contentDirectorySCPD = `<?xml version="1.0" encoding="utf-8"?>
+<scpd xmlns="urn:schemas-upnp-org:service-1-0">
+  <specVersion><major>1</major><minor>0</minor></specVersion>
+  <actionList>
+    <action>
+      <name>Browse</name>
+      <argumentList>
+        <argument><name>ObjectID</name><direction>in</direction><relatedStateVariable>A_ARG_TYPE_ObjectID</relatedStateVariable></argument>
+        <argument><name>BrowseFlag</name><direction>in</direction><relatedStateVariable>A_ARG_TYPE_BrowseFlag</relatedStateVariable></argument>
+        <argument><name>Filter</name><direction>in</direction><relatedStateVariable>A_ARG_TYPE_Filter</relatedStateVariable></argument>
+        <argument><name>StartingIndex</name><direction>in</direction><relatedStateVariable>A_ARG_TYPE_Index</relatedStateVariable></argument>
+        <argument><name>RequestedCount</name><direction>in</direction><relatedStateVariable>A_ARG_TYPE_Count</relatedStateVariable></argument>
+        <argument><name>SortCriteria</name><direction>in</direction><relatedStateVariable>A_ARG_TYPE_SortCriteria</relatedStateVariable></argument>
+        <argument><name>Result</name><direction>out</direction><relatedStateVariable>A_ARG_TYPE_Result</relatedStateVariable></argument>
+        <argument><name>NumberReturned</name><direction>out</direction><relatedStateVariable>A_ARG_TYPE_Count</relatedStateVariable></argument>
+        <argument><name>TotalMatches</name><direction>out</direction><relatedStateVariable>A_ARG_TYPE_Count</relatedStateVariable></argument>
+        <argument><name>UpdateID</name><direction>out</direction><relatedStateVariable>A_ARG_TYPE_UpdateID</relatedStateVariable></argument>
+      </argumentList>
+    </action>
+    <action>
+      <name>GetSearchCapabilities</name>
+      <argumentList>
+        <argument><name>SearchCaps</name><direction>out</direction><relatedStateVariable>SearchCapabilities</relatedStateVariable></argument>
+      </argumentList>
+    </action>
+    <action>
+      <name>GetSortCapabilities</name>
+      <argumentList>
+        <argument><name>SortCaps</name><direction>out</direction><relatedStateVariable>SortCapabilities</relatedStateVariable></argument>
+      </argumentList>
+    </action>
+    <action>
+      <name>GetSystemUpdateID</name>
+      <argumentList>
+        <argument><name>Id</name><direction>out</direction><relatedStateVariable>SystemUpdateID</relatedStateVariable></argument>
+      </argumentList>
+    </action>
+  </actionList>
+  <serviceStateTable>
+    <stateVariable sendEvents="no"><name>A_ARG_TYPE_ObjectID</name><dataType>string</dataType></stateVariable>
+    <stateVariable sendEvents="no"><name>A_ARG_TYPE_Result</name><dataType>string</dataType></stateVariable>
+    <stateVariable sendEvents="no">
+      <name>A_ARG_TYPE_BrowseFlag</name><dataType>string</dataType>
+      <allowedValueList><allowedValue>BrowseMetadata</allowedValue><allowedValue>BrowseDirectChildren</allowedValue></allowedValueList>
+    </stateVariable>
+    <stateVariable sendEvents="no"><name>A_ARG_TYPE_Filter</name><dataType>string</dataType></stateVariable>
+    <stateVariable sendEvents="no"><name>A_ARG_TYPE_SortCriteria</name><dataType>string</dataType></stateVariable>
+    <stateVariable sendEvents="no"><name>A_ARG_TYPE_Index</name><dataType>ui4</dataType></stateVariable>
+    <stateVariable sendEvents="no"><name>A_ARG_TYPE_Count</name><dataType>ui4</dataType></stateVariable>
+    <stateVariable sendEvents="no"><name>A_ARG_TYPE_UpdateID</name><dataType>ui4</dataType></stateVariable>
+    <stateVariable sendEvents="no"><name>SearchCapabilities</name><dataType>string</dataType></stateVariable>
+    <stateVariable sendEvents="no"><name>SortCapabilities</name><dataType>string</dataType></stateVariable>
+    <stateVariable sendEvents="yes"><name>SystemUpdateID</name><dataType>ui4</dataType></stateVariable>
+  </serviceStateTable>
+</scpd>`
+
+const connectionManagerSCPD = `<?xml version="1.0" encoding="utf-8"?>
+<scpd xmlns="urn:schemas-upnp-org:service-1-0">
+  <specVersion><major>1</major><minor>0</minor></specVersion>
+  <actionList>
+    <action>
+      <name>GetProtocolInfo</name>
+      <argumentList>
+        <argument><name>Source</name><direction>out</direction><relatedStateVariable>SourceProtocolInfo</relatedStateVariable></argument>
+        <argument><name>Sink</name><direction>out</direction><relatedStateVariable>SinkProtocolInfo</relatedStateVariable></argument>
+      </argumentList>
+    </action>
+    <action>
+      <name>GetCurrentConnectionIDs</name>
+      <argumentList>
+        <argument><name>ConnectionIDs</name><direction>out</direction><relatedStateVariable>CurrentConnectionIDs</relatedStateVariable></argument>
+      </argumentList>
+    </action>
+    <action>
+      <name>GetCurrentConnectionInfo</name>
+      <argumentList>
+        <argument><name>ConnectionID</name><direction>in</direction><relatedStateVariable>A_ARG_TYPE_ConnectionID</relatedStateVariable></argument>
+        <argument><name>RcsID</name><direction>out</direction><relatedStateVariable>A_ARG_TYPE_RcsID</relatedStateVariable></argument>
+        <argument><name>AVTransportID</name><direction>out</direction><relatedStateVariable>A_ARG_TYPE_AVTransportID</relatedStateVariable></argument>
+        <argument><name>ProtocolInfo</name><direction>out</direction><relatedStateVariable>A_ARG_TYPE_ProtocolInfo</relatedStateVariable></argument>
+        <argument><name>PeerConnectionManager</name><direction>out</direction><relatedStateVariable>A_ARG_TYPE_ConnectionManager</relatedStateVariable></argument>
+        <argument><name>PeerConnectionID</name><direction>out</direction><relatedStateVariable>A_ARG_TYPE_ConnectionID</relatedStateVariable></argument>
+        <argument><name>Direction</name><direction>out</direction><relatedStateVariable>A_ARG_TYPE_Direction</relatedStateVariable></argument>
+        <argument><name>Status</name><direction>out</direction><relatedStateVariable>A_ARG_TYPE_ConnectionStatus</relatedStateVariable></argument>
+      </argumentList>
+    </action>
+  </actionList>
+  <serviceStateTable>
+    <stateVariable sendEvents="yes"><name>SourceProtocolInfo</name><dataType>string</dataType></stateVariable>
+    <stateVariable sendEvents="yes"><name>SinkProtocolInfo</name><dataType>string</dataType></stateVariable>
+    <stateVariable sendEvents="yes"><name>CurrentConnectionIDs</name><dataType>string</dataType></stateVariable>
+    <stateVariable sendEvents="no">
+      <name>A_ARG_TYPE_ConnectionStatus</name><dataType>string</dataType>
+      <allowedValueList><allowedValue>OK</allowedValue><allowedValue>ContentFormatMismatch</allowedValue><allowedValue>InsufficientBandwidth</allowedValue><allowedValue>UnreliableChannel</allowedValue><allowedValue>Unknown</allowedValue></allowedValueList>
+    </stateVariable>
+    <stateVariable sendEvents="no"><name>A_ARG_TYPE_ConnectionManager</name><dataType>string</dataType></stateVariable>
+    <stateVariable sendEvents="no">
+      <name>A_ARG_TYPE_Direction</name><dataType>string</dataType>
+      <allowedValueList><allowedValue>Input</allowedValue><allowedValue>Output</allowedValue></allowedValueList>
+    </stateVariable>
+    <stateVariable sendEvents="no"><name>A_ARG_TYPE_ProtocolInfo</name><dataType>string</dataType></stateVariable>
+    <stateVariable sendEvents="no"><name>A_ARG_TYPE_ConnectionID</name><dataType>i4</dataType></stateVariable>
+    <stateVariable sendEvents="no"><name>A_ARG_TYPE_AVTransportID</name><dataType>i4</dataType></stateVariable>
+    <stateVariable sendEvents="no"><name>A_ARG_TYPE_RcsID</name><dataType>i4</dataType></stateVariable>
+  </serviceStateTable>
+</scpd>`
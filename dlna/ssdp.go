@@ -0,0 +1,253 @@
+package mpcdlna
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ssdpAddress is the multicast group where the UPnP devices announce themselves and the TVs search for them
+const ssdpAddress = "239.255.255.250:1900"
+
+const serverHeader = "Linux/1.0 UPnP/1.0 MPC/1.0"
+
+// SSDP announces the media server on the LAN and answers the searches of the TVs
+type SSDP struct {
+	// Location is the URL of the device description
+	Location string
+	UUID     string
+	// MaxAge is the number of seconds that the TVs keep the announcement, it is repeated before it expires
+	MaxAge int
+
+	listener   net.PacketConn
+	sender     net.PacketConn
+	notifyAddr net.Addr
+
+	// done is closed when the server is closed, the mutex keeps Serve from advertising after that
+	done        chan struct{}
+	mutex       sync.Mutex
+	advertising sync.WaitGroup
+}
+
+// NewSSDP joins the SSDP multicast group on the interface that has the IP,
+// the announcements and answers are sent from that IP so they reach the same network
+//
+func NewSSDP(ip string, location string, uuid string) (*SSDP, error) {
+	iface, err := interfaceByIP(ip)
+	if err != nil {
+		return nil, err
+	}
+
+	group, err := net.ResolveUDPAddr("udp4", ssdpAddress)
+	if err != nil {
+		return nil, err
+	}
+
+	listener, err := net.ListenMulticastUDP("udp4", iface, group)
+	if err != nil {
+		return nil, err
+	}
+
+	sender, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.ParseIP(ip)})
+	if err != nil {
+		listener.Close()
+		return nil, err
+	}
+
+	return newSSDP(listener, sender, group, location, uuid), nil
+}
+
+// newSSDP creates the SSDP server with the connections, the tests use local connections instead of the multicast group
+//
+func newSSDP(listener net.PacketConn, sender net.PacketConn, notifyAddr net.Addr, location string, uuid string) *SSDP {
+	return &SSDP{
+		Location:   location,
+		UUID:       uuid,
+		MaxAge:     1800,
+		listener:   listener,
+		sender:     sender,
+		notifyAddr: notifyAddr,
+		done:       make(chan struct{}),
+	}
+}
+
+// Serve announces the server and answers the searches until the SSDP server is closed
+//
+func (s *SSDP) Serve() error {
+	s.mutex.Lock()
+
+	select {
+	case <-s.done:
+		s.mutex.Unlock()
+		return nil
+	default:
+	}
+
+	s.advertising.Add(1)
+	s.mutex.Unlock()
+
+	go s.advertise()
+
+	buffer := make([]byte, 2048)
+
+	for {
+		n, addr, err := s.listener.ReadFrom(buffer)
+		if err != nil {
+			select {
+			case <-s.done:
+				return nil
+			default:
+				return err
+			}
+		}
+
+		s.handle(buffer[:n], addr)
+	}
+}
+
+// Close tells the TVs that the server is leaving and closes the connections
+//
+func (s *SSDP) Close() error {
+	s.mutex.Lock()
+
+	select {
+	case <-s.done:
+		s.mutex.Unlock()
+		return nil
+	default:
+	}
+
+	close(s.done)
+	s.mutex.Unlock()
+
+	s.advertising.Wait()
+
+	s.notify("ssdp:byebye")
+
+	if s.sender != s.listener {
+		s.sender.Close()
+	}
+
+	return s.listener.Close()
+}
+
+// advertise sends the alive notifications when the server starts and before the previous ones expire
+//
+func (s *SSDP) advertise() {
+	defer s.advertising.Done()
+
+	s.notify("ssdp:alive")
+
+	ticker := time.NewTicker(time.Duration(s.MaxAge) * time.Second / 2)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			s.notify("ssdp:alive")
+		case <-s.done:
+			return
+		}
+	}
+}
+
+// notify sends a notification for every device and service type of the server
+//
+func (s *SSDP) notify(nts string) {
+	for _, nt := range s.targets() {
+		message := "NOTIFY * HTTP/1.1\r\n" +
+			"HOST: " + ssdpAddress + "\r\n" +
+			"NT: " + nt + "\r\n" +
+			"NTS: " + nts + "\r\n" +
+			"USN: " + s.usn(nt) + "\r\n"
+
+		if nts == "ssdp:alive" {
+			message += fmt.Sprintf("CACHE-CONTROL: max-age=%d\r\n", s.MaxAge) +
+				"LOCATION: " + s.Location + "\r\n" +
+				"SERVER: " + serverHeader + "\r\n"
+		}
+
+		_, err := s.sender.WriteTo([]byte(message+"\r\n"), s.notifyAddr)
+		if err != nil {
+			log.Println("SSDP:", err)
+		}
+	}
+}
+
+// handle answers the M-SEARCH requests that look for the server, other messages are ignored
+//
+func (s *SSDP) handle(data []byte, addr net.Addr) {
+	request, err := http.ReadRequest(bufio.NewReader(bytes.NewReader(data)))
+	if err != nil || request.Method != "M-SEARCH" || request.Header.Get("MAN") != `"ssdp:discover"` {
+		return
+	}
+
+	searchTarget := request.Header.Get("ST")
+
+	for _, target := range s.targets() {
+		if searchTarget != "ssdp:all" && searchTarget != target {
+			continue
+		}
+
+		response := "HTTP/1.1 200 OK\r\n" +
+			fmt.Sprintf("CACHE-CONTROL: max-age=%d\r\n", s.MaxAge) +
+			"DATE: " + time.Now().UTC().Format(http.TimeFormat) + "\r\n" +
+			"EXT:\r\n" +
+			"LOCATION: " + s.Location + "\r\n" +
+			"SERVER: " + serverHeader + "\r\n" +
+			"ST: " + target + "\r\n" +
+			"USN: " + s.usn(target) + "\r\n\r\n"
+
+		_, err := s.sender.WriteTo([]byte(response), addr)
+		if err != nil {
+			log.Println("SSDP:", err)
+		}
+	}
+}
+
+// targets are the device and service types that the server announces
+//
+func (s *SSDP) targets() []string {
+	return []string{"upnp:rootdevice", "uuid:" + s.UUID, mediaServerType, contentDirectoryType, connectionManagerType}
+}
+
+// usn gets the unique service name of a type
+//
+func (s *SSDP) usn(target string) string {
+	if strings.HasPrefix(target, "uuid:") {
+		return target
+	}
+
+	return "uuid:" + s.UUID + "::" + target
+}
+
+// interfaceByIP gets the network interface that has the IP
+//
+func interfaceByIP(ip string) (*net.Interface, error) {
+	interfaces, err := net.Interfaces()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, iface := range interfaces {
+		addresses, err := iface.Addrs()
+		if err != nil {
+			continue
+		}
+
+		for _, address := range addresses {
+			if ipNet, ok := address.(*net.IPNet); ok && ipNet.IP.String() == ip {
+				return &iface, nil
+			}
+		}
+	}
+
+	return nil, errors.New("interface_not_found")
+}
@@ -0,0 +1,149 @@
+package mpcdlna
+
+import (
+	"bufio"
+	"bytes"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testUUID = "5b6ac7e4-0000-4000-8000-000000000001"
+
+// newTestSSDP starts an SSDP server on a local port and a fake client that gets its notifications
+//
+func newTestSSDP(t *testing.T) (*SSDP, net.PacketConn) {
+	listener, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	client, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() {
+		client.Close()
+	})
+
+	ssdp := newSSDP(listener, listener, client.LocalAddr(), "http://127.0.0.1:3000/dlna/device.xml", testUUID)
+
+	return ssdp, client
+}
+
+// readPacket reads a packet of the fake client, it returns an empty string when nothing arrives
+//
+func readPacket(t *testing.T, client net.PacketConn, timeout time.Duration) string {
+	buffer := make([]byte, 2048)
+
+	client.SetReadDeadline(time.Now().Add(timeout))
+
+	n, _, err := client.ReadFrom(buffer)
+	if err != nil {
+		return ""
+	}
+
+	return string(buffer[:n])
+}
+
+// readNotifications reads the notifications sent for every type of the server
+//
+func readNotifications(t *testing.T, client net.PacketConn) map[string]*http.Request {
+	notifications := make(map[string]*http.Request)
+
+	for i := 0; i < 5; i++ {
+		packet := readPacket(t, client, time.Second)
+
+		request, err := http.ReadRequest(bufio.NewReader(strings.NewReader(packet)))
+		if err != nil || request.Method != "NOTIFY" {
+			t.Fatal("notification expected", packet, err)
+		}
+
+		notifications[request.Header.Get("NT")] = request
+	}
+
+	return notifications
+}
+
+func TestSSDPNotify(t *testing.T) {
+	ssdp, client := newTestSSDP(t)
+
+	go ssdp.Serve()
+
+	alive := readNotifications(t, client)
+
+	for _, nt := range []string{"upnp:rootdevice", "uuid:" + testUUID, mediaServerType, contentDirectoryType, connectionManagerType} {
+		notification, ok := alive[nt]
+		if !ok {
+			t.Fatal("the server should announce", nt)
+		}
+
+		if notification.Header.Get("NTS") != "ssdp:alive" || notification.Header.Get("LOCATION") != ssdp.Location {
+			t.Error("alive notification should have the location", notification.Header)
+		}
+	}
+
+	if alive[mediaServerType].Header.Get("USN") != "uuid:"+testUUID+"::"+mediaServerType {
+		t.Error("wrong USN", alive[mediaServerType].Header.Get("USN"))
+	}
+
+	ssdp.Close()
+
+	for nt, notification := range readNotifications(t, client) {
+		if notification.Header.Get("NTS") != "ssdp:byebye" {
+			t.Error("the server should say goodbye when it closes", nt, notification.Header)
+		}
+	}
+}
+
+func TestSSDPSearch(t *testing.T) {
+	ssdp, client := newTestSSDP(t)
+	defer ssdp.Close()
+
+	go ssdp.Serve()
+
+	readNotifications(t, client)
+
+	search := func(target string) {
+		message := "M-SEARCH * HTTP/1.1\r\n" +
+			"HOST: 239.255.255.250:1900\r\n" +
+			"MAN: \"ssdp:discover\"\r\n" +
+			"MX: 1\r\n" +
+			"ST: " + target + "\r\n\r\n"
+
+		_, err := client.WriteTo([]byte(message), ssdp.listener.LocalAddr())
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	search(mediaServerType)
+
+	packet := readPacket(t, client, time.Second)
+
+	response, err := http.ReadResponse(bufio.NewReader(bytes.NewReader([]byte(packet))), nil)
+	if err != nil {
+		t.Fatal("search response expected", packet, err)
+	}
+
+	if response.StatusCode != http.StatusOK || response.Header.Get("ST") != mediaServerType || response.Header.Get("LOCATION") != ssdp.Location {
+		t.Error("response should have the searched type and the location", response.Header)
+	}
+
+	search("ssdp:all")
+
+	for i := 0; i < 5; i++ {
+		if readPacket(t, client, time.Second) == "" {
+			t.Fatal("the server should answer every type when all are searched")
+		}
+	}
+
+	search("urn:schemas-upnp-org:device:MediaRenderer:1")
+
+	if packet := readPacket(t, client, 200*time.Millisecond); packet != "" {
+		t.Error("the server should not answer searches of other devices", packet)
+	}
+}
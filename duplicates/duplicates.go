@@ -0,0 +1,266 @@
+// MPC Duplicates finds the videos that are copies of other videos of the library, like re-encodes and trimmed copies.
+// The screenshots of the videos are compared with perceptual hashes, so copies with a different file are found
+//
+package mpcduplicates
+
+import (
+	"github.com/jempe/mpc/artwork"
+	"image"
+	"math/bits"
+	"sort"
+	"time"
+)
+
+// Fingerprint has the hashes of the sampled frames of a video
+type Fingerprint struct {
+	VideoID string `json:"videoID"`
+	// File is the file of the video when the frames were hashed, the fingerprint is old when the file changes
+	File     string    `json:"file"`
+	Duration int       `json:"duration"`
+	Hashes   []uint64  `json:"hashes"`
+	Created  time.Time `json:"created"`
+}
+
+// Options are the limits used to decide if two videos are copies
+type Options struct {
+	// MaxDistance is the max number of different bits of two similar frames
+	MaxDistance int
+	// MinMatch is the min fraction of the frames of the shorter video that must be similar to a frame of the other video
+	MinMatch float64
+	// DurationTolerance is the max difference of the durations as a fraction of the longer video
+	DurationTolerance float64
+}
+
+// DefaultOptions find re-encodes and copies trimmed up to a fifth of the video
+var DefaultOptions = Options{MaxDistance: 10, MinMatch: 0.6, DurationTolerance: 0.2}
+
+// Copy is a video of a group of duplicates with the data used to choose the best copy
+type Copy struct {
+	VideoID  string `json:"id"`
+	Width    int    `json:"width"`
+	Height   int    `json:"height"`
+	Duration int    `json:"duration"`
+	// Size is the size of the video file in bytes
+	Size int64 `json:"size"`
+	// Bitrate is the bitrate of the video file in bits per second
+	Bitrate int64 `json:"bitrate"`
+}
+
+// HashFrames gets the hashes of the frames of a video, the frames are JPEG images
+//
+func HashFrames(frames [][]byte) (hashes []uint64, err error) {
+	for _, frame := range frames {
+		img, err := mpcartwork.Decode(frame)
+		if err != nil {
+			return nil, err
+		}
+
+		hashes = append(hashes, FrameHash(img))
+	}
+
+	return
+}
+
+// FrameHash gets the difference hash of an image, the image is reduced to 9x8 gray pixels
+// and every bit tells if a pixel is brighter than the pixel on its right
+//
+func FrameHash(img image.Image) (hash uint64) {
+	var gray [8][9]float64
+
+	bounds := img.Bounds()
+
+	for row := 0; row < 8; row++ {
+		for column := 0; column < 9; column++ {
+			gray[row][column] = averageGray(img, image.Rect(
+				bounds.Min.X+column*bounds.Dx()/9,
+				bounds.Min.Y+row*bounds.Dy()/8,
+				bounds.Min.X+(column+1)*bounds.Dx()/9,
+				bounds.Min.Y+(row+1)*bounds.Dy()/8,
+			))
+		}
+	}
+
+	for row := 0; row < 8; row++ {
+		for column := 0; column < 8; column++ {
+			hash <<= 1
+
+			if gray[row][column] > gray[row][column+1] {
+				hash |= 1
+			}
+		}
+	}
+
+	return
+}
+
+// averageGray gets the average brightness of the pixels of an area of the image
+//
+func averageGray(img image.Image, area image.Rectangle) float64 {
+	if area.Empty() {
+		area = image.Rect(area.Min.X, area.Min.Y, area.Min.X+1, area.Min.Y+1).Intersect(img.Bounds())
+	}
+
+	var total float64
+	var count int
+
+	for y := area.Min.Y; y < area.Max.Y; y++ {
+		for x := area.Min.X; x < area.Max.X; x++ {
+			r, g, b, _ := img.At(x, y).RGBA()
+
+			total += 0.299*float64(r) + 0.587*float64(g) + 0.114*float64(b)
+			count++
+		}
+	}
+
+	if count == 0 {
+		return 0
+	}
+
+	return total / float64(count)
+}
+
+// Distance gets the number of different bits of two hashes
+//
+func Distance(a uint64, b uint64) int {
+	return bits.OnesCount64(a ^ b)
+}
+
+// Similar checks if two fingerprints are copies of the same video
+//
+func Similar(a Fingerprint, b Fingerprint, options Options) bool {
+	longer := a.Duration
+	if b.Duration > longer {
+		longer = b.Duration
+	}
+
+	difference := a.Duration - b.Duration
+	if difference < 0 {
+		difference = -difference
+	}
+
+	if float64(difference) > float64(longer)*options.DurationTolerance {
+		return false
+	}
+
+	return matchFraction(a.Hashes, b.Hashes, options.MaxDistance) >= options.MinMatch
+}
+
+// matchFraction gets the fraction of the frames of the shorter list that are similar to a frame of the other list,
+// the frames without detail like black frames are skipped because they are similar in every video
+//
+func matchFraction(a []uint64, b []uint64, maxDistance int) float64 {
+	a = detailedFrames(a)
+	b = detailedFrames(b)
+
+	if len(b) < len(a) {
+		a, b = b, a
+	}
+
+	if len(a) == 0 {
+		return 0
+	}
+
+	matches := 0
+
+	for _, hash := range a {
+		for _, other := range b {
+			if Distance(hash, other) <= maxDistance {
+				matches++
+				break
+			}
+		}
+	}
+
+	return float64(matches) / float64(len(a))
+}
+
+// detailedFrames removes the hashes of the frames without detail, a frame of a single color has no brighter pixels
+//
+func detailedFrames(hashes []uint64) (detailed []uint64) {
+	for _, hash := range hashes {
+		if bits.OnesCount64(hash) > 2 {
+			detailed = append(detailed, hash)
+		}
+	}
+
+	return
+}
+
+// Group gets the groups of videos that are copies of each other, a video is in the group when it's similar to
+// any video of the group. The groups and their videos are sorted by ID
+//
+func Group(fingerprints []Fingerprint, options Options) (groups [][]string) {
+	parents := make([]int, len(fingerprints))
+	for index := range parents {
+		parents[index] = index
+	}
+
+	var root func(index int) int
+	root = func(index int) int {
+		if parents[index] != index {
+			parents[index] = root(parents[index])
+		}
+
+		return parents[index]
+	}
+
+	for i := range fingerprints {
+		for j := i + 1; j < len(fingerprints); j++ {
+			if root(i) != root(j) && Similar(fingerprints[i], fingerprints[j], options) {
+				parents[root(j)] = root(i)
+			}
+		}
+	}
+
+	members := make(map[int][]string)
+
+	for index, fingerprint := range fingerprints {
+		members[root(index)] = append(members[root(index)], fingerprint.VideoID)
+	}
+
+	for _, videoIDs := range members {
+		if len(videoIDs) > 1 {
+			sort.Strings(videoIDs)
+			groups = append(groups, videoIDs)
+		}
+	}
+
+	sort.Slice(groups, func(i, j int) bool {
+		return groups[i][0] < groups[j][0]
+	})
+
+	return
+}
+
+// BestCopy gets the ID of the copy with the highest resolution, the ties are decided by the bitrate, the duration
+// because the trimmed copies are shorter, and the size of the file
+//
+func BestCopy(copies []Copy) (videoID string) {
+	var best Copy
+
+	for index, candidate := range copies {
+		if index == 0 || betterCopy(candidate, best) {
+			best = candidate
+		}
+	}
+
+	return best.VideoID
+}
+
+// betterCopy checks if the copy is better than the other copy
+//
+func betterCopy(candidate Copy, other Copy) bool {
+	if candidate.Width*candidate.Height != other.Width*other.Height {
+		return candidate.Width*candidate.Height > other.Width*other.Height
+	}
+
+	if candidate.Bitrate != other.Bitrate {
+		return candidate.Bitrate > other.Bitrate
+	}
+
+	if candidate.Duration != other.Duration {
+		return candidate.Duration > other.Duration
+	}
+
+	return candidate.Size > other.Size
+}
@@ -0,0 +1,107 @@
+package mpcduplicates
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"math"
+	"testing"
+)
+
+// testFrame draws a frame with a pattern of the seed, the same seed draws the same frame at any size
+func testFrame(seed int, width int, height int) image.Image {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			angle := 2*math.Pi*(float64(x)/float64(width)*float64(seed%3+1)+float64(y)/float64(height)*float64(seed%4+1)) + float64(seed)
+			value := uint8(127.5 + 127.5*math.Sin(angle))
+
+			img.Set(x, y, color.RGBA{R: value, G: value / 2, B: 255 - value, A: 255})
+		}
+	}
+
+	return img
+}
+
+func testHashes(seeds []int, width int, height int) (hashes []uint64) {
+	for _, seed := range seeds {
+		hashes = append(hashes, FrameHash(testFrame(seed, width, height)))
+	}
+
+	return
+}
+
+func TestFrameHash(t *testing.T) {
+	original := FrameHash(testFrame(1, 640, 360))
+	resized := FrameHash(testFrame(1, 320, 180))
+	other := FrameHash(testFrame(5, 640, 360))
+
+	if Distance(original, resized) > DefaultOptions.MaxDistance {
+		t.Error("the resized frame should be similar", Distance(original, resized))
+	}
+
+	if Distance(original, other) <= DefaultOptions.MaxDistance {
+		t.Error("different frames should not be similar", Distance(original, other))
+	}
+
+	if hash := FrameHash(image.NewGray(image.Rect(0, 0, 64, 64))); hash != 0 {
+		t.Error("a black frame should have no bits", hash)
+	}
+
+	var data bytes.Buffer
+
+	err := jpeg.Encode(&data, testFrame(1, 640, 360), &jpeg.Options{Quality: 40})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hashes, err := HashFrames([][]byte{data.Bytes()})
+	if err != nil || len(hashes) != 1 || Distance(hashes[0], original) > DefaultOptions.MaxDistance {
+		t.Error("the re-encoded frame should be similar", hashes, err)
+	}
+
+	if _, err := HashFrames([][]byte{[]byte("not an image")}); err == nil {
+		t.Error("invalid frames should fail")
+	}
+}
+
+func TestGroup(t *testing.T) {
+	fingerprints := []Fingerprint{
+		{VideoID: "original", Duration: 600, Hashes: testHashes([]int{1, 2, 3, 4, 5, 6}, 640, 360)},
+		{VideoID: "reencoded", Duration: 601, Hashes: testHashes([]int{1, 2, 3, 4, 5, 6}, 320, 180)},
+		{VideoID: "trimmed", Duration: 540, Hashes: testHashes([]int{2, 3, 4, 5, 6}, 640, 360)},
+		{VideoID: "other", Duration: 600, Hashes: testHashes([]int{10, 11, 12, 13, 14, 15}, 640, 360)},
+		{VideoID: "short", Duration: 60, Hashes: testHashes([]int{1, 2, 3}, 640, 360)},
+		{VideoID: "black", Duration: 600, Hashes: []uint64{0, 0, 0}},
+		{VideoID: "blank", Duration: 600, Hashes: []uint64{0, 0}},
+	}
+
+	groups := Group(fingerprints, DefaultOptions)
+
+	if len(groups) != 1 {
+		t.Fatal("there should be one group", groups)
+	}
+
+	if len(groups[0]) != 3 || groups[0][0] != "original" || groups[0][1] != "reencoded" || groups[0][2] != "trimmed" {
+		t.Error("wrong group", groups[0])
+	}
+}
+
+func TestBestCopy(t *testing.T) {
+	copies := []Copy{
+		{VideoID: "small", Width: 640, Height: 360, Duration: 600, Size: 200, Bitrate: 1000},
+		{VideoID: "trimmed", Width: 1280, Height: 720, Duration: 540, Size: 500, Bitrate: 4000},
+		{VideoID: "best", Width: 1280, Height: 720, Duration: 600, Size: 550, Bitrate: 4000},
+		{VideoID: "low", Width: 1280, Height: 720, Duration: 600, Size: 300, Bitrate: 2000},
+	}
+
+	if best := BestCopy(copies); best != "best" {
+		t.Error("wrong best copy", best)
+	}
+
+	if best := BestCopy(nil); best != "" {
+		t.Error("there is no best copy without copies", best)
+	}
+}
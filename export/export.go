@@ -0,0 +1,232 @@
+// MPC Export writes the videos of the library as M3U8 and XSPF playlists for VLC, Kodi and the other players
+//
+package mpcexport
+
+import (
+	"github.com/jempe/mpc/library"
+	"github.com/jempe/mpc/storage"
+	"bufio"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// playlist formats
+const (
+	FormatM3U8 = "m3u8"
+	FormatXSPF = "xspf"
+)
+
+// Selection chooses the videos of a playlist, only one of the playlist, the collection, the actor or the filter is used
+type Selection struct {
+	Playlist   string
+	Collection int
+	Actor      int
+	Filter     mpcstorage.VideoFilter
+	// Sort is the order of the filtered videos, it uses the same values as the videos list
+	Sort string
+}
+
+// Playlist is a list of tracks with a title
+type Playlist struct {
+	Title  string
+	Tracks []Track
+}
+
+// Track is a video of a playlist
+type Track struct {
+	Title    string
+	Creator  string
+	Location string
+	Image    string
+	// Duration is the length of the video in seconds
+	Duration int
+}
+
+type xspfPlaylist struct {
+	XMLName xml.Name    `xml:"playlist"`
+	Version string      `xml:"version,attr"`
+	Xmlns   string      `xml:"xmlns,attr"`
+	Title   string      `xml:"title,omitempty"`
+	Tracks  []xspfTrack `xml:"trackList>track"`
+}
+
+type xspfTrack struct {
+	Location string `xml:"location"`
+	Title    string `xml:"title,omitempty"`
+	Creator  string `xml:"creator,omitempty"`
+	Image    string `xml:"image,omitempty"`
+	// Duration is the length in milliseconds
+	Duration int `xml:"duration,omitempty"`
+}
+
+// Select gets the videos and the title of the selection, the permissions of the playlists are checked by the callers
+//
+func Select(storage *mpcstorage.Storage, selection Selection) (title string, videos mpclibrary.Videos, err error) {
+	all := len(storage.Videos)
+
+	switch {
+	case selection.Playlist != "":
+		playlist, err := storage.GetPlaylist(selection.Playlist)
+		if err != nil {
+			return "", nil, errors.New("playlist_not_exists")
+		}
+
+		return playlist.Name, storage.GetVideosByIDs(0, all, playlist.Videos).Videos, nil
+	case selection.Collection != 0:
+		collection, err := storage.GetCollection(selection.Collection)
+		if err != nil {
+			return "", nil, errors.New("collection_not_exists")
+		}
+
+		return collection.Name, storage.GetVideosByIDs(0, all, collection.Videos).Videos, nil
+	case selection.Actor != 0:
+		actor, _ := storage.GetActorByID(selection.Actor)
+		if actor.Name == "" {
+			return "", nil, errors.New("actor_not_exists")
+		}
+
+		// the actor filter matches the lower case names
+		filter := mpcstorage.VideoFilter{Actor: "^" + regexp.QuoteMeta(strings.ToLower(actor.Name)) + "$"}
+
+		return actor.Name, storage.GetVideos(0, all, "natural", filter, 0).Videos, nil
+	}
+
+	sortBy := selection.Sort
+	if sortBy == "" {
+		sortBy = "natural"
+	}
+
+	return "MPC", storage.GetVideos(0, all, sortBy, selection.Filter, 0).Videos, nil
+}
+
+// NewPlaylist builds the tracks of the videos, sign gets the signature of the URLs of a video
+// for the players that can't log in
+//
+func NewPlaylist(title string, videos mpclibrary.Videos, baseURL string, sign func(videoID string) (string, error)) (playlist Playlist, err error) {
+	playlist.Title = title
+
+	for _, video := range videos {
+		query := ""
+
+		if sign != nil {
+			signature, err := sign(video.ID)
+			if err != nil {
+				return playlist, err
+			}
+
+			query = "?sig=" + url.QueryEscape(signature)
+		}
+
+		var actors []string
+
+		for _, actor := range video.Actors {
+			actors = append(actors, actor.Name)
+		}
+
+		playlist.Tracks = append(playlist.Tracks, Track{
+			Title:    video.Title,
+			Creator:  strings.Join(actors, ", "),
+			Location: baseURL + "/videos/" + video.ID + ".mp4" + query,
+			Image:    baseURL + "/videos/thumbs/" + video.ID + ".jpg" + query,
+			Duration: video.Duration,
+		})
+	}
+
+	return
+}
+
+// ContentType gets the MIME type of the format
+//
+func ContentType(format string) string {
+	if format == FormatXSPF {
+		return "application/xspf+xml"
+	}
+
+	return "application/vnd.apple.mpegurl"
+}
+
+// Write writes the playlist in the format
+//
+func Write(w io.Writer, format string, playlist Playlist) error {
+	switch format {
+	case FormatM3U8:
+		return WriteM3U8(w, playlist)
+	case FormatXSPF:
+		return WriteXSPF(w, playlist)
+	}
+
+	return errors.New("export_format_invalid")
+}
+
+// WriteM3U8 writes the playlist as an extended M3U with UTF-8 titles
+//
+func WriteM3U8(w io.Writer, playlist Playlist) error {
+	buffer := bufio.NewWriter(w)
+
+	fmt.Fprintln(buffer, "#EXTM3U")
+
+	if playlist.Title != "" {
+		fmt.Fprintln(buffer, "#PLAYLIST:"+m3uText(playlist.Title))
+	}
+
+	for _, track := range playlist.Tracks {
+		duration := track.Duration
+		if duration <= 0 {
+			duration = -1
+		}
+
+		title := track.Title
+		if track.Creator != "" {
+			title = track.Creator + " - " + title
+		}
+
+		fmt.Fprintf(buffer, "#EXTINF:%d,%s\n", duration, m3uText(title))
+		fmt.Fprintln(buffer, track.Location)
+	}
+
+	return buffer.Flush()
+}
+
+// WriteXSPF writes the playlist as XSPF, the durations are in milliseconds
+//
+func WriteXSPF(w io.Writer, playlist Playlist) error {
+	output := xspfPlaylist{Version: "1", Xmlns: "http://xspf.org/ns/0/", Title: playlist.Title}
+
+	for _, track := range playlist.Tracks {
+		output.Tracks = append(output.Tracks, xspfTrack{
+			Location: track.Location,
+			Title:    track.Title,
+			Creator:  track.Creator,
+			Image:    track.Image,
+			Duration: track.Duration * 1000,
+		})
+	}
+
+	_, err := io.WriteString(w, xml.Header)
+	if err != nil {
+		return err
+	}
+
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "\t")
+
+	err = encoder.Encode(output)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.WriteString(w, "\n")
+
+	return err
+}
+
+// m3uText removes the line breaks that would break the M3U lines
+//
+func m3uText(text string) string {
+	return strings.Join(strings.Fields(text), " ")
+}
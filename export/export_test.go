@@ -0,0 +1,142 @@
+package mpcexport
+
+import (
+	"github.com/jempe/mpc/library"
+	"github.com/jempe/mpc/storage"
+	"bytes"
+	"encoding/xml"
+	"strings"
+	"testing"
+)
+
+func newTestStorage(t *testing.T) *mpcstorage.Storage {
+	storage := &mpcstorage.Storage{Path: t.TempDir()}
+
+	err := storage.InitDb()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() {
+		storage.Db.Close()
+	})
+
+	err = storage.InsertVideos([]mpclibrary.Video{
+		{File: "movie10.mp4", Title: "Movie 10", Duration: 600, Actors: []mpclibrary.Actor{{Name: "Jane Doe"}}},
+		{File: "movie2.mp4", Title: "Movie 2", Duration: 90, Actors: []mpclibrary.Actor{{Name: "Jane Doe"}, {Name: "John Doe"}}},
+		{File: "other.mp4", Title: "Other", Actors: []mpclibrary.Actor{{Name: "Jane Does"}}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = storage.GetAllVideos()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return storage
+}
+
+func TestSelect(t *testing.T) {
+	storage := newTestStorage(t)
+
+	var actorID int
+
+	for id, actor := range storage.Actors {
+		if actor.Name == "Jane Doe" {
+			actorID = id
+		}
+	}
+
+	title, videos, err := Select(storage, Selection{Actor: actorID})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if title != "Jane Doe" || len(videos) != 2 || videos[0].Title != "Movie 2" || videos[1].Title != "Movie 10" {
+		t.Error("the videos of the actor should be sorted by natural title", title, videos)
+	}
+
+	movie, _ := storage.GetVideoByFileName("movie10.mp4")
+	other, _ := storage.GetVideoByFileName("other.mp4")
+
+	playlist, err := storage.SavePlaylist(mpcstorage.Playlist{Name: "Favorites", Owner: "user", Videos: []string{other.ID, movie.ID}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	title, videos, err = Select(storage, Selection{Playlist: playlist.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if title != "Favorites" || len(videos) != 2 || videos[0].ID != other.ID {
+		t.Error("the playlist should keep its order", title, videos)
+	}
+
+	_, videos, _ = Select(storage, Selection{Filter: mpcstorage.VideoFilter{Title: "^movie"}})
+
+	if len(videos) != 2 {
+		t.Error("the filter should select the movies", videos)
+	}
+
+	if _, _, err := Select(storage, Selection{Actor: 999}); err == nil {
+		t.Error("unknown actors should fail")
+	}
+}
+
+func TestWrite(t *testing.T) {
+	videos := mpclibrary.Videos{
+		{ID: "abc", Title: "Movie\n2", Duration: 90, Actors: []mpclibrary.Actor{{Name: "Jane Doe"}}},
+		{ID: "def", Title: "Unknown length"},
+	}
+
+	playlist, err := NewPlaylist("Jane & Co", videos, "http://mpc:3000", func(videoID string) (string, error) {
+		return "sig+" + videoID, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var m3u bytes.Buffer
+
+	err = Write(&m3u, FormatM3U8, playlist)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "#EXTM3U\n#PLAYLIST:Jane & Co\n" +
+		"#EXTINF:90,Jane Doe - Movie 2\nhttp://mpc:3000/videos/abc.mp4?sig=sig%2Babc\n" +
+		"#EXTINF:-1,Unknown length\nhttp://mpc:3000/videos/def.mp4?sig=sig%2Bdef\n"
+
+	if m3u.String() != expected {
+		t.Errorf("wrong M3U8 playlist:\n%s", m3u.String())
+	}
+
+	var xspf bytes.Buffer
+
+	err = Write(&xspf, FormatXSPF, playlist)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var decoded xspfPlaylist
+
+	err = xml.Unmarshal(xspf.Bytes(), &decoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if decoded.Title != "Jane & Co" || len(decoded.Tracks) != 2 || decoded.Tracks[0].Duration != 90000 || decoded.Tracks[0].Image != "http://mpc:3000/videos/thumbs/abc.jpg?sig=sig%2Babc" {
+		t.Errorf("wrong XSPF playlist:\n%s", xspf.String())
+	}
+
+	if !strings.HasPrefix(xspf.String(), xml.Header) {
+		t.Error("the XSPF playlist should have the XML header")
+	}
+
+	if Write(&xspf, "pls", playlist) == nil {
+		t.Error("unknown formats should fail")
+	}
+}
@@ -0,0 +1,472 @@
+// MPC Fsck checks that the DB and the library folder agree: the videos of the DB have files, the files of the library
+// have videos, the screenshots belong to a video, the actors, categories, tags and series of the videos exist and the
+// files have the MD5 sum of the DB. The repairs only change the DB or move the files to the quarantine folder
+//
+package mpcfsck
+
+import (
+	"github.com/jempe/mpc/library"
+	"github.com/jempe/mpc/storage"
+	"github.com/jempe/mpc/utils"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// kinds of the issues
+const (
+	// KindMissingFile is a video of the DB whose file doesn't exist
+	KindMissingFile = "missing_file"
+	// KindOrphanFile is a video file of the library that is not in the DB
+	KindOrphanFile = "orphan_file"
+	// KindOrphanScreenshots is a folder of screenshots that doesn't belong to a video
+	KindOrphanScreenshots = "orphan_screenshots"
+	// KindMissingReference is a video with actors, categories, tags or series that don't exist
+	KindMissingReference = "missing_reference"
+	// KindChecksumMismatch is a video file whose MD5 sum is not the MD5 sum of the DB
+	KindChecksumMismatch = "checksum_mismatch"
+	// KindMissingInfo is a video without size or duration
+	KindMissingInfo = "missing_info"
+)
+
+// Kinds are the kinds of the issues in the order of the report
+var Kinds = []string{KindMissingFile, KindOrphanFile, KindOrphanScreenshots, KindMissingReference, KindChecksumMismatch, KindMissingInfo}
+
+// repairs of the issues
+const (
+	// RepairRelink changes the file of the video to a file of the library that is not in the DB
+	RepairRelink = "relink"
+	// RepairReprobe reads the size and duration of the video file again
+	RepairReprobe = "reprobe"
+	// RepairPrune removes the video or the references that don't exist from the DB
+	RepairPrune = "prune"
+	// RepairQuarantine moves the file or folder to the quarantine folder of the library
+	RepairQuarantine = "quarantine"
+)
+
+// QuarantineFolder is the folder of the library where the quarantined files are moved
+const QuarantineFolder = "quarantine"
+
+// Issue is a difference between the DB and the library and the repair that fixes it
+type Issue struct {
+	Kind    string `json:"kind"`
+	VideoID string `json:"videoID,omitempty"`
+	// Path is the path of the file or folder relative to the library
+	Path   string `json:"path,omitempty"`
+	Detail string `json:"detail"`
+	Repair string `json:"repair"`
+	// Target is the file that the video is linked to by the relink repair
+	Target string `json:"target,omitempty"`
+}
+
+// Report has the issues found by a check
+type Report struct {
+	Issues []Issue `json:"issues"`
+	// Videos is the number of videos of the DB
+	Videos int `json:"videos"`
+	// Files is the number of video files of the library
+	Files    int       `json:"files"`
+	Started  time.Time `json:"started"`
+	Finished time.Time `json:"finished"`
+}
+
+// Result is the result of the repair of an issue
+type Result struct {
+	Issue   Issue  `json:"issue"`
+	Applied bool   `json:"applied"`
+	Error   string `json:"error,omitempty"`
+}
+
+// Checker checks the DB and the library folder
+type Checker struct {
+	Storage *mpcstorage.Storage
+	Library *mpclibrary.Library
+	// Checksums compares the MD5 sum of the video files with the DB, every file is read
+	Checksums bool
+	// Probe gets the size and duration of a video file, mpcutils.FFProbe is used when it's nil
+	Probe func(file string) (mpcutils.VideoInfo, error)
+	// User is saved in the edit history of the repaired videos
+	User string
+}
+
+// ByKind groups the issues of the report by kind
+//
+func (report Report) ByKind() map[string][]Issue {
+	kinds := make(map[string][]Issue)
+
+	for _, issue := range report.Issues {
+		kinds[issue.Kind] = append(kinds[issue.Kind], issue)
+	}
+
+	return kinds
+}
+
+// Filter gets the issues of the kinds, all the issues are returned when there are no kinds
+//
+func Filter(issues []Issue, kinds []string) (filtered []Issue) {
+	if len(kinds) == 0 {
+		return issues
+	}
+
+	for _, issue := range issues {
+		for _, kind := range kinds {
+			if issue.Kind == kind {
+				filtered = append(filtered, issue)
+			}
+		}
+	}
+
+	return
+}
+
+// Check compares the DB with the library folder
+//
+func (checker *Checker) Check() (report Report, err error) {
+	report.Started = time.Now()
+	report.Issues = []Issue{}
+
+	if !mpcutils.IsDir(checker.Library.Path) {
+		return report, errors.New("library_not_exists")
+	}
+
+	files, err := checker.videoFiles()
+	if err != nil {
+		return
+	}
+
+	report.Videos = checker.Storage.CountVideos()
+	report.Files = len(files)
+
+	linked := make(map[string]bool)
+	screenshots := make(map[string]bool)
+
+	for _, videoID := range checker.videoIDs() {
+		video, _ := checker.Storage.GetVideoByID(videoID)
+
+		linked[video.File] = true
+		screenshots[filepath.Base(checker.Library.ScreenshotsFolder(video))] = true
+
+		if missing := checker.Storage.MissingReferences(videoID); !missing.Empty() {
+			report.Issues = append(report.Issues, Issue{Kind: KindMissingReference, VideoID: videoID, Detail: missingDetail(missing), Repair: RepairPrune})
+		}
+
+		if !mpcutils.Exists(checker.Library.Path + "/" + video.File) {
+			continue
+		}
+
+		if checker.Checksums && !video.Encrypted && video.Md5Sum != "" {
+			md5Sum, err := mpcutils.FileMD5(checker.Library.Path + "/" + video.File)
+			if err != nil {
+				return report, err
+			}
+
+			if md5Sum != video.Md5Sum {
+				report.Issues = append(report.Issues, Issue{Kind: KindChecksumMismatch, VideoID: videoID, Path: video.File, Detail: "the MD5 sum of the file is " + md5Sum + ", the DB has " + video.Md5Sum, Repair: RepairReprobe})
+			}
+		}
+
+		if !video.Encrypted && (video.Duration == 0 || video.Width == 0 || video.Height == 0) {
+			report.Issues = append(report.Issues, Issue{Kind: KindMissingInfo, VideoID: videoID, Path: video.File, Detail: "the video has no size or duration", Repair: RepairReprobe})
+		}
+	}
+
+	var orphans []string
+
+	for _, file := range files {
+		if !linked[file] {
+			orphans = append(orphans, file)
+		}
+	}
+
+	relinked := make(map[string]bool)
+
+	for _, videoID := range checker.videoIDs() {
+		video, _ := checker.Storage.GetVideoByID(videoID)
+
+		if mpcutils.Exists(checker.Library.Path + "/" + video.File) {
+			continue
+		}
+
+		issue := Issue{Kind: KindMissingFile, VideoID: videoID, Path: video.File, Detail: "the video file doesn't exist", Repair: RepairPrune}
+
+		if target := checker.relinkTarget(video, orphans, relinked); target != "" {
+			relinked[target] = true
+
+			issue.Repair = RepairRelink
+			issue.Target = target
+			issue.Detail += ", " + target + " is the same video"
+		}
+
+		report.Issues = append(report.Issues, issue)
+	}
+
+	// the files that are relinked are not orphans
+	for _, file := range orphans {
+		if !relinked[file] {
+			report.Issues = append(report.Issues, Issue{Kind: KindOrphanFile, Path: file, Detail: "the file is not in the DB", Repair: RepairQuarantine})
+		}
+	}
+
+	folders, err := ioutil.ReadDir(checker.Library.Path + "/thumbs")
+	if err != nil && !os.IsNotExist(err) {
+		return
+	}
+
+	for _, folder := range folders {
+		if folder.IsDir() && !screenshots[folder.Name()] {
+			report.Issues = append(report.Issues, Issue{Kind: KindOrphanScreenshots, Path: "thumbs/" + folder.Name(), Detail: "the screenshots don't belong to a video", Repair: RepairQuarantine})
+		}
+	}
+
+	sort.SliceStable(report.Issues, func(i, j int) bool {
+		return kindOrder(report.Issues[i].Kind) < kindOrder(report.Issues[j].Kind)
+	})
+
+	report.Finished = time.Now()
+
+	return report, nil
+}
+
+// Repair applies the repairs of the issues, when dryRun is set the issues are only checked
+//
+func (checker *Checker) Repair(issues []Issue, dryRun bool) (results []Result) {
+	results = []Result{}
+
+	for _, issue := range issues {
+		result := Result{Issue: issue}
+
+		err := checker.validRepair(issue)
+
+		if err == nil && !dryRun {
+			err = checker.repair(issue)
+			result.Applied = err == nil
+		}
+
+		if err != nil {
+			result.Error = err.Error()
+		}
+
+		results = append(results, result)
+	}
+
+	return
+}
+
+// validRepair checks that the repair of the issue is safe
+//
+func (checker *Checker) validRepair(issue Issue) error {
+	switch issue.Repair {
+	case RepairRelink:
+		if issue.Kind != KindMissingFile || !mpcutils.Exists(checker.Library.Path+"/"+issue.Target) || !libraryPath(issue.Target) {
+			return errors.New("fsck_target_invalid")
+		}
+	case RepairReprobe:
+		if issue.Kind != KindChecksumMismatch && issue.Kind != KindMissingInfo {
+			return errors.New("fsck_repair_invalid")
+		}
+	case RepairPrune:
+		if issue.Kind != KindMissingFile && issue.Kind != KindMissingReference {
+			return errors.New("fsck_repair_invalid")
+		}
+
+		// the video is not pruned when its file is back
+		if issue.Kind == KindMissingFile && mpcutils.Exists(checker.Library.Path+"/"+issue.Path) {
+			return errors.New("fsck_file_exists")
+		}
+	case RepairQuarantine:
+		if (issue.Kind != KindOrphanFile && issue.Kind != KindOrphanScreenshots) || !libraryPath(issue.Path) {
+			return errors.New("fsck_repair_invalid")
+		}
+	default:
+		return errors.New("fsck_repair_invalid")
+	}
+
+	return nil
+}
+
+// repair applies the repair of an issue
+//
+func (checker *Checker) repair(issue Issue) (err error) {
+	switch issue.Repair {
+	case RepairRelink:
+		return checker.Storage.SetVideoFile(issue.VideoID, issue.Target, strings.HasSuffix(issue.Target, ".enc"))
+	case RepairReprobe:
+		video, _ := checker.Storage.GetVideoByID(issue.VideoID)
+		if video.ID == "" {
+			return errors.New("video_not_exists")
+		}
+
+		info, err := checker.probe(checker.Library.Path + "/" + video.File)
+		if err != nil {
+			return err
+		}
+
+		return checker.Storage.SetVideoInfo(issue.VideoID, info)
+	case RepairPrune:
+		if issue.Kind == KindMissingReference {
+			_, err = checker.Storage.RemoveMissingReferences(issue.VideoID, checker.User)
+			return
+		}
+
+		_, err = checker.Storage.DeleteVideos([]string{issue.VideoID}, false)
+		return
+	case RepairQuarantine:
+		return checker.quarantine(issue.Path)
+	}
+
+	return errors.New("fsck_repair_invalid")
+}
+
+// quarantine moves a file or folder of the library to the quarantine folder, the folders of the path are kept
+//
+func (checker *Checker) quarantine(path string) error {
+	target := filepath.Join(checker.Library.Path, QuarantineFolder, filepath.FromSlash(path))
+
+	if mpcutils.Exists(target) {
+		target += "." + strconv.FormatInt(time.Now().Unix(), 10)
+	}
+
+	err := os.MkdirAll(filepath.Dir(target), 0700)
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(filepath.Join(checker.Library.Path, filepath.FromSlash(path)), target)
+}
+
+// probe gets the size and duration of a video file
+//
+func (checker *Checker) probe(file string) (mpcutils.VideoInfo, error) {
+	if checker.Probe != nil {
+		return checker.Probe(file)
+	}
+
+	return mpcutils.FFProbe(file)
+}
+
+// videoFiles gets the names of the video files and encrypted videos of the library folder
+//
+func (checker *Checker) videoFiles() (files []string, err error) {
+	entries, err := ioutil.ReadDir(checker.Library.Path)
+	if err != nil {
+		return
+	}
+
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+
+		isVideo, _, _ := mpcutils.IsVideo(entry.Name())
+
+		encrypted := strings.HasSuffix(entry.Name(), ".enc") && !strings.HasSuffix(entry.Name(), "_thumb.enc")
+
+		if isVideo || encrypted {
+			files = append(files, entry.Name())
+		}
+	}
+
+	return
+}
+
+// videoIDs gets the IDs of the videos of the DB sorted, so the reports are always in the same order
+//
+func (checker *Checker) videoIDs() (videoIDs []string) {
+	videoIDs = checker.Storage.VideoIDs()
+
+	sort.Strings(videoIDs)
+
+	return
+}
+
+// relinkTarget finds the orphan file that is the video: the encrypted videos are named by their ID, and the other
+// videos by their MD5 sum, their ID or their original name. The MD5 sum of the file is compared when it's known
+//
+func (checker *Checker) relinkTarget(video mpclibrary.Video, orphans []string, relinked map[string]bool) string {
+	var names []string
+
+	if video.Encrypted {
+		names = []string{video.ID + ".enc"}
+	} else {
+		extension := video.Extension
+		if extension == "" {
+			extension = strings.TrimPrefix(filepath.Ext(video.File), ".")
+		}
+
+		if video.Md5Sum != "" {
+			names = append(names, video.Md5Sum+"."+extension)
+		}
+
+		names = append(names, video.ID+"."+extension, video.OrigFile)
+	}
+
+	for _, name := range names {
+		for _, orphan := range orphans {
+			if orphan != name || relinked[orphan] {
+				continue
+			}
+
+			if !video.Encrypted && video.Md5Sum != "" {
+				md5Sum, err := mpcutils.FileMD5(checker.Library.Path + "/" + orphan)
+				if err != nil || md5Sum != video.Md5Sum {
+					continue
+				}
+			}
+
+			return orphan
+		}
+	}
+
+	return ""
+}
+
+// missingDetail describes the references that don't exist
+//
+func missingDetail(missing mpcstorage.References) string {
+	var details []string
+
+	if len(missing.Actors) > 0 {
+		details = append(details, fmt.Sprint("actors ", missing.Actors))
+	}
+
+	if len(missing.Categories) > 0 {
+		details = append(details, fmt.Sprint("categories ", missing.Categories))
+	}
+
+	if len(missing.Tags) > 0 {
+		details = append(details, fmt.Sprint("tags ", missing.Tags))
+	}
+
+	if missing.Series != 0 {
+		details = append(details, fmt.Sprint("series ", missing.Series))
+	}
+
+	return "missing " + strings.Join(details, ", ")
+}
+
+// libraryPath checks that the path is inside the library and it's not the quarantine folder
+//
+func libraryPath(path string) bool {
+	clean := filepath.ToSlash(filepath.Clean(filepath.FromSlash(path)))
+
+	return path != "" && clean != "." && !strings.HasPrefix(clean, "../") && clean != ".." && !filepath.IsAbs(path) &&
+		clean != QuarantineFolder && !strings.HasPrefix(clean, QuarantineFolder+"/")
+}
+
+// kindOrder gets the position of the kind in the report
+//
+func kindOrder(kind string) int {
+	for index, reportKind := range Kinds {
+		if reportKind == kind {
+			return index
+		}
+	}
+
+	return len(Kinds)
+}
@@ -0,0 +1,189 @@
+package mpcfsck
+
+import (
+	"github.com/jempe/mpc/library"
+	"github.com/jempe/mpc/storage"
+	"github.com/jempe/mpc/utils"
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func writeFile(t *testing.T, path string, data string) {
+	err := ioutil.WriteFile(path, []byte(data), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func newTestChecker(t *testing.T) *Checker {
+	testStorage := &mpcstorage.Storage{Path: t.TempDir()}
+
+	err := testStorage.InitDb()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() {
+		testStorage.Db.Close()
+	})
+
+	library := &mpclibrary.Library{Path: t.TempDir()}
+
+	probe := func(file string) (mpcutils.VideoInfo, error) {
+		return mpcutils.VideoInfo{Width: 640, Height: 360, Duration: 60, Step: 2}, nil
+	}
+
+	return &Checker{Storage: testStorage, Library: library, Checksums: true, Probe: probe, User: "fsck"}
+}
+
+func TestCheckAndRepair(t *testing.T) {
+	checker := newTestChecker(t)
+	libraryPath := checker.Library.Path
+
+	writeFile(t, libraryPath+"/good.mp4", "good video")
+	goodMd5, _ := mpcutils.FileMD5(libraryPath + "/good.mp4")
+	os.Rename(libraryPath+"/good.mp4", libraryPath+"/"+goodMd5+".mp4")
+
+	writeFile(t, libraryPath+"/moved.mp4", "moved video")
+	movedMd5, _ := mpcutils.FileMD5(libraryPath + "/moved.mp4")
+	os.Rename(libraryPath+"/moved.mp4", libraryPath+"/"+movedMd5+".mp4")
+
+	writeFile(t, libraryPath+"/changed.mp4", "changed video")
+	writeFile(t, libraryPath+"/stray.mp4", "stray video")
+	writeFile(t, libraryPath+"/secret.enc", "encrypted video")
+
+	os.MkdirAll(libraryPath+"/thumbs/"+goodMd5, 0700)
+	os.MkdirAll(libraryPath+"/thumbs/deadbeef", 0700)
+
+	videos := []mpclibrary.Video{
+		{File: goodMd5 + ".mp4", Md5Sum: goodMd5, Extension: "mp4", Title: "Good", Width: 640, Height: 360, Duration: 60, Actors: []mpclibrary.Actor{{Name: "Jane Doe"}}},
+		{File: "old_name.mp4", Md5Sum: movedMd5, Extension: "mp4", Title: "Moved", Width: 640, Height: 360, Duration: 60},
+		{File: "changed.mp4", Md5Sum: "00000000000000000000000000000000", Extension: "mp4", Title: "Changed"},
+		{File: "secret.enc", Title: "Secret", Encrypted: true},
+		{File: "gone.enc", Title: "Gone", Encrypted: true},
+	}
+
+	err := checker.Storage.InsertVideos(videos)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = checker.Storage.GetAllVideos()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the actor of the good video is deleted without updating the video
+	actor, _ := checker.Storage.GetActorByName("Jane Doe")
+	delete(checker.Storage.Actors, actor.ID)
+
+	report, err := checker.Check()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	kinds := report.ByKind()
+
+	if len(kinds[KindMissingFile]) != 2 {
+		t.Fatal("the moved and gone videos should be missing", kinds[KindMissingFile])
+	}
+
+	for _, issue := range kinds[KindMissingFile] {
+		if issue.Path == "old_name.mp4" && (issue.Repair != RepairRelink || issue.Target != movedMd5+".mp4") {
+			t.Error("the moved video should be relinked", issue)
+		}
+
+		if issue.Path == "gone.enc" && issue.Repair != RepairPrune {
+			t.Error("the gone video should be pruned", issue)
+		}
+	}
+
+	if len(kinds[KindOrphanFile]) != 1 || kinds[KindOrphanFile][0].Path != "stray.mp4" {
+		t.Error("the stray file should be an orphan", kinds[KindOrphanFile])
+	}
+
+	if len(kinds[KindOrphanScreenshots]) != 1 || kinds[KindOrphanScreenshots][0].Path != "thumbs/deadbeef" {
+		t.Error("the screenshots without video should be orphans", kinds[KindOrphanScreenshots])
+	}
+
+	if len(kinds[KindMissingReference]) != 1 || kinds[KindMissingReference][0].VideoID != goodMd5 {
+		t.Error("the deleted actor should be missing", kinds[KindMissingReference])
+	}
+
+	if len(kinds[KindChecksumMismatch]) != 1 || kinds[KindChecksumMismatch][0].Path != "changed.mp4" {
+		t.Error("the changed file should not match its MD5 sum", kinds[KindChecksumMismatch])
+	}
+
+	if len(kinds[KindMissingInfo]) != 1 || kinds[KindMissingInfo][0].Path != "changed.mp4" {
+		t.Error("the changed video has no size", kinds[KindMissingInfo])
+	}
+
+	if report.Videos != 5 || report.Files != 5 {
+		t.Error("wrong number of videos and files", report.Videos, report.Files)
+	}
+
+	results := checker.Repair(report.Issues, true)
+	for _, result := range results {
+		if result.Applied || result.Error != "" {
+			t.Error("the dry run should not apply the repairs", result)
+		}
+	}
+
+	if !mpcutils.Exists(libraryPath + "/stray.mp4") {
+		t.Error("the dry run should not move the files")
+	}
+
+	results = checker.Repair(report.Issues, false)
+	for _, result := range results {
+		if !result.Applied {
+			t.Error("the repair should be applied", result)
+		}
+	}
+
+	if !mpcutils.Exists(libraryPath+"/"+QuarantineFolder+"/stray.mp4") || !mpcutils.IsDir(libraryPath+"/"+QuarantineFolder+"/thumbs/deadbeef") {
+		t.Error("the orphans should be quarantined")
+	}
+
+	moved, _ := checker.Storage.GetVideoByID(movedMd5)
+	if moved.File != movedMd5+".mp4" || moved.Encrypted {
+		t.Error("the moved video should be relinked", moved.File)
+	}
+
+	changed, _ := checker.Storage.GetVideoByID("00000000000000000000000000000000")
+	if changed.Width != 640 || changed.Duration != 60 {
+		t.Error("the changed video should be probed again", changed)
+	}
+
+	if missing := checker.Storage.MissingReferences(goodMd5); !missing.Empty() {
+		t.Error("the missing actor should be pruned", missing)
+	}
+
+	report, err = checker.Check()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the MD5 sum names the screenshots of the video, it's not changed by the repairs
+	if len(report.Issues) != 1 || report.Issues[0].Kind != KindChecksumMismatch {
+		t.Error("only the checksum mismatch should remain", report.Issues)
+	}
+}
+
+func TestRepairValidation(t *testing.T) {
+	checker := newTestChecker(t)
+
+	issues := []Issue{
+		{Kind: KindOrphanFile, Path: "../outside.mp4", Repair: RepairQuarantine},
+		{Kind: KindOrphanFile, Path: QuarantineFolder + "/video.mp4", Repair: RepairQuarantine},
+		{Kind: KindMissingFile, VideoID: "video", Repair: RepairRelink, Target: "missing.mp4"},
+		{Kind: KindOrphanFile, Path: "video.mp4", Repair: RepairPrune},
+		{Kind: KindMissingInfo, VideoID: "video", Repair: "delete"},
+	}
+
+	for _, result := range checker.Repair(issues, false) {
+		if result.Applied || result.Error == "" {
+			t.Error("the unsafe repair should be rejected", result.Issue)
+		}
+	}
+}
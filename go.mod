@@ -9,5 +9,5 @@ require (
 	github.com/google/uuid v1.2.0
 	github.com/gorilla/websocket v1.4.2
 	github.com/jempe/encdec v0.0.0-20180806164515-5dfdd1b50580
-	github.com/spf13/viper v1.8.1
+	golang.org/x/sys v0.0.0-20210510120138-977fb7262007 // indirect
 )
@@ -1,4 +1,12 @@
 var socket;
+var player_code = "";
+var player_queue = null;
+var protocol_version = 1;
+var party = null;
+var party_sync = null;
+var clock_offset = 0;
+var clock_rtt = Infinity;
+var loading_video_id = "";
 
 if (window["WebSocket"])
 {
@@ -7,7 +15,7 @@ if (window["WebSocket"])
 
 function start_remote()
 {
-	socket = new WebSocket("ws://" + location.host + "/remote");
+	socket = new WebSocket((location.protocol === "https:" ? "wss://" : "ws://") + location.host + "/remote?player_id=" + encodeURIComponent(player_id()) + "&name=" + encodeURIComponent(player_name()));
 	socket.onclose = function() {
 		console.log("Connection has been closed.");
 		setTimeout(function()
@@ -16,9 +24,352 @@ function start_remote()
 		}, 500);
 	}
 	socket.onmessage = function(e) {
-		filter = JSON.parse(e.data);
-		app.setState({"selected" : 0, "offset" : 0});
-		requestVideos(0);
-	}	
+		var message = JSON.parse(e.data);
+
+		if(message.v != protocol_version)
+		{
+			console.log("Unsupported remote message:", e.data);
+			return;
+		}
+
+		handle_remote_message(message);
+	}
 }
 
+function handle_remote_message(message)
+{
+	var video_player = document.getElementById("video_player");
+
+	switch(message.type)
+	{
+		case "welcome":
+			player_code = message.code;
+			console.log("Player pairing code:", player_code);
+			estimate_clock_offset();
+			break;
+		case "timeSync":
+			update_clock_offset(message.clientTime, message.serverTime);
+			return;
+		case "party":
+			party = message.party || null;
+			console.log("Watch party:", party);
+			break;
+		case "sync":
+			party_sync = message;
+			apply_party_sync();
+			return;
+		case "error":
+			console.log("Remote error:", message.error);
+			break;
+		case "play":
+			if(video_player)
+			{
+				video_player.play();
+			}
+			else if(typeof(app) == "object")
+			{
+				app.playVideo();
+			}
+			break;
+		case "pause":
+			if(video_player)
+			{
+				video_player.pause();
+			}
+			break;
+		case "seek":
+			if(video_player)
+			{
+				video_player.currentTime = message.position;
+			}
+			break;
+		case "volume":
+			if(video_player)
+			{
+				video_player.volume = message.volume;
+			}
+			break;
+		case "queueState":
+			player_queue = message.queueState;
+			return;
+		case "next":
+			if(app.state.selected < app.state.total_videos - 1)
+			{
+				app.previewVideo(app.state.selected + 1);
+			}
+			break;
+		case "previous":
+			if(app.state.selected > 0)
+			{
+				app.previewVideo(app.state.selected - 1);
+			}
+			break;
+		case "playVideo":
+			play_video_id(message.video);
+			break;
+		case "subtitle":
+			if(video_player)
+			{
+				for(var i = 0; i < video_player.textTracks.length; i++)
+				{
+					video_player.textTracks[i].mode = (i == message.track) ? "showing" : "disabled";
+				}
+			}
+			break;
+		case "audio":
+			if(video_player && video_player.audioTracks)
+			{
+				for(var i = 0; i < video_player.audioTracks.length; i++)
+				{
+					video_player.audioTracks[i].enabled = (i == message.track);
+				}
+			}
+			break;
+		case "filter":
+			filter = message.filter;
+			app.setState({"selected" : 0, "offset" : 0, "view" : "grid"});
+			requestVideos(0);
+			break;
+	}
+
+	send_state();
+}
+
+// estimate_clock_offset sends some time requests to the server, the answer with the shortest round trip is used
+function estimate_clock_offset()
+{
+	clock_rtt = Infinity;
+
+	for(var i = 0; i < 5; i++)
+	{
+		setTimeout(function() {
+			if(socket && socket.readyState === WebSocket.OPEN)
+			{
+				socket.send(JSON.stringify({ "v" : protocol_version, "type" : "timeSync", "clientTime" : Date.now() }));
+			}
+		}, i * 200);
+	}
+}
+
+// update_clock_offset calculates the difference between the server clock and the local clock
+function update_clock_offset(client_time, server_time)
+{
+	var now = Date.now();
+	var rtt = now - client_time;
+
+	if(rtt < clock_rtt)
+	{
+		clock_rtt = rtt;
+		clock_offset = server_time - (client_time + rtt / 2);
+	}
+}
+
+// server_now gets the current time of the server clock
+function server_now()
+{
+	return Date.now() + clock_offset;
+}
+
+// apply_party_sync moves the player to the position of the host, small drifts are corrected changing the playback rate
+function apply_party_sync()
+{
+	if(!party_sync || !party_sync.state || !party_sync.state.video)
+	{
+		return;
+	}
+
+	var state = party_sync.state;
+
+	if(!selected_video || selected_video.id != state.video)
+	{
+		play_video_id(state.video);
+		return;
+	}
+
+	var video_player = document.getElementById("video_player");
+
+	if(!video_player || video_player.readyState < 1)
+	{
+		return;
+	}
+
+	var position = state.position;
+
+	if(!state.paused)
+	{
+		position += (server_now() - party_sync.serverTime) / 1000;
+	}
+
+	var drift = video_player.currentTime - position;
+
+	if(Math.abs(drift) > 1 || state.paused)
+	{
+		video_player.currentTime = position;
+		video_player.playbackRate = 1;
+	}
+	else if(Math.abs(drift) > 0.1)
+	{
+		video_player.playbackRate = drift > 0 ? 0.95 : 1.05;
+	}
+	else
+	{
+		video_player.playbackRate = 1;
+	}
+
+	if(state.paused && !video_player.paused)
+	{
+		video_player.pause();
+	}
+	else if(!state.paused && video_player.paused)
+	{
+		video_player.play();
+	}
+}
+
+// is_party_follower checks if the player follows the host of a watch party
+function is_party_follower()
+{
+	return party != null && party.host != player_id();
+}
+
+// play_video_id loads the video data and opens it in the player
+function play_video_id(video_id)
+{
+	if(loading_video_id == video_id)
+	{
+		return;
+	}
+
+	loading_video_id = video_id;
+
+	var video_request = new XMLHttpRequest();
+
+	video_request.onreadystatechange = function() {
+		if(video_request.readyState === 4) {
+			loading_video_id = "";
+		}
+
+		if(video_request.readyState === 4 && video_request.status === 200) {
+			var video = JSON.parse(video_request.responseText);
+
+			app.setState({ "videos" : [video], "total_videos" : 1, "offset" : 0, "selected" : 0, "view" : "player" });
+		}
+	}
+
+	video_request.open("GET", "/videos/" + encodeURIComponent(video_id), true);
+	video_request.send();
+}
+
+// send_state reports the current video, position, paused and volume to the remotes
+function send_state()
+{
+	if(!socket || socket.readyState !== WebSocket.OPEN || typeof(app) != "object" || !selected_video)
+	{
+		return;
+	}
+
+	var video_player = document.getElementById("video_player");
+	var state = { "video" : selected_video.id, "position" : 0, "duration" : selected_video.duration, "paused" : true, "volume" : 1 };
+
+	if(video_player)
+	{
+		state.position = video_player.currentTime;
+		state.duration = video_player.duration || state.duration;
+		state.paused = video_player.paused;
+		state.volume = video_player.volume;
+	}
+
+	socket.send(JSON.stringify({ "v" : protocol_version, "type" : "state", "state" : state }));
+}
+
+setInterval(send_state, 5000);
+
+// the server plays the next video of the queue when the video ends
+document.addEventListener("ended", function(e) {
+	if(e.target.id == "video_player" && selected_video)
+	{
+		record_watch(selected_video.id);
+	}
+
+	if(e.target.id == "video_player" && selected_video && socket && socket.readyState === WebSocket.OPEN)
+	{
+		socket.send(JSON.stringify({ "v" : protocol_version, "type" : "ended", "video" : selected_video.id }));
+	}
+}, true);
+
+// a video is watched when it ends or when this part of it was played
+var watched_progress = 0.9;
+var watched_video_id = "";
+
+// record_watch tells the server that the user watched the video, once every time the video is loaded
+function record_watch(video_id)
+{
+	if(watched_video_id == video_id)
+	{
+		return;
+	}
+
+	watched_video_id = video_id;
+
+	var watch_request = new XMLHttpRequest();
+
+	watch_request.open("POST", "/videos/" + encodeURIComponent(video_id) + "/watched", true);
+	watch_request.send();
+}
+
+document.addEventListener("timeupdate", function(e) {
+	if(e.target.id == "video_player" && selected_video && e.target.duration > 0 && e.target.currentTime >= e.target.duration * watched_progress)
+	{
+		record_watch(selected_video.id);
+	}
+}, true);
+
+// a loaded video is recorded again when it is watched, followers join the video of the host at its current position
+document.addEventListener("loadedmetadata", function(e) {
+	if(e.target.id == "video_player")
+	{
+		watched_video_id = "";
+	}
+
+	if(e.target.id == "video_player" && is_party_follower())
+	{
+		apply_party_sync();
+	}
+}, true);
+
+// followers correct the drift between the reports of the host
+setInterval(function() {
+	if(is_party_follower())
+	{
+		apply_party_sync();
+	}
+}, 1000);
+
+["play", "pause", "seeked", "volumechange"].forEach(function(event_name) {
+	document.addEventListener(event_name, function(e) {
+		if(e.target.id == "video_player")
+		{
+			send_state();
+		}
+	}, true);
+});
+
+// player_id gets the ID of this player, it is saved so the player keeps its ID after reloading
+function player_id()
+{
+	var id = localStorage.getItem("player_id");
+
+	if(!id)
+	{
+		id = Math.random().toString(36).substring(2) + Date.now().toString(36);
+		localStorage.setItem("player_id", id);
+	}
+
+	return id;
+}
+
+// player_name gets the name of the player that is shown in the remotes
+function player_name()
+{
+	return localStorage.getItem("player_name") || "Player " + player_id().substring(0, 4);
+}
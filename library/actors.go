@@ -0,0 +1,134 @@
+package mpclibrary
+
+import (
+	"github.com/jempe/encdec"
+	"github.com/jempe/mpc/utils"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ActorsFolder is the folder inside the library where actor photos are saved
+const ActorsFolder = "actors"
+
+// SaveActorPhoto saves the profile photo of an actor in the library and returns its file name.
+// If a key is provided the photo is encrypted
+//
+func (lib *Library) SaveActorPhoto(actorID int, data []byte, key []byte) (file string, err error) {
+	contentType := http.DetectContentType(data)
+
+	var extension string
+
+	if contentType == "image/jpeg" {
+		extension = ".jpg"
+	} else if contentType == "image/png" {
+		extension = ".png"
+	} else {
+		return file, errors.New("actor_photo_invalid_type")
+	}
+
+	photosFolder := lib.Path + "/" + ActorsFolder
+
+	if !mpcutils.Exists(photosFolder) {
+		err = os.MkdirAll(photosFolder, 0700)
+
+		if err != nil {
+			return
+		}
+	}
+
+	if len(key) > 0 {
+		data, err = encdec.Encrypt(data, key)
+
+		if err != nil {
+			return
+		}
+
+		extension = extension + ".enc"
+	}
+
+	file = strconv.Itoa(actorID) + extension
+
+	err = ioutil.WriteFile(photosFolder+"/"+file, data, 0644)
+
+	return
+}
+
+// EncryptActorPhoto encrypts an existing actor photo, removes the plain file and returns the new file name
+//
+func (lib *Library) EncryptActorPhoto(file string, key []byte) (encryptedFile string, err error) {
+	if file == "" {
+		return file, errors.New("actor_photo_not_exists")
+	}
+
+	if strings.HasSuffix(file, ".enc") {
+		return file, nil
+	}
+
+	photoPath := lib.Path + "/" + ActorsFolder + "/" + file
+
+	data, err := ioutil.ReadFile(photoPath)
+	if err != nil {
+		return
+	}
+
+	encrypted, err := encdec.Encrypt(data, key)
+	if err != nil {
+		return
+	}
+
+	encryptedFile = file + ".enc"
+
+	err = ioutil.WriteFile(lib.Path+"/"+ActorsFolder+"/"+encryptedFile, encrypted, 0644)
+	if err != nil {
+		return
+	}
+
+	err = os.Remove(photoPath)
+
+	return
+}
+
+// ActorPhoto reads an actor photo and gets the time when it was saved, encrypted photos are decrypted with the key
+//
+func (lib *Library) ActorPhoto(file string, key []byte) (data []byte, modTime time.Time, err error) {
+	if file == "" {
+		return data, modTime, errors.New("actor_photo_not_exists")
+	}
+
+	photoPath := lib.Path + "/" + ActorsFolder + "/" + file
+
+	info, err := os.Stat(photoPath)
+	if err != nil {
+		return
+	}
+
+	modTime = info.ModTime()
+
+	data, err = ioutil.ReadFile(photoPath)
+	if err != nil {
+		return
+	}
+
+	if strings.HasSuffix(file, ".enc") {
+		data, err = encdec.Decrypt(data, key)
+	}
+
+	return
+}
+
+// RemoveActorPhoto deletes an actor photo from the library
+//
+func (lib *Library) RemoveActorPhoto(file string) error {
+	photoPath := lib.Path + "/" + ActorsFolder + "/" + file
+
+	if file == "" || !mpcutils.Exists(photoPath) {
+		return nil
+	}
+
+	return os.Remove(photoPath)
+}
@@ -0,0 +1,149 @@
+package mpclibrary
+
+import (
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+type Series struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+type EpisodeInfo struct {
+	Show    string `json:"show"`
+	Season  int    `json:"season"`
+	Episode int    `json:"episode"`
+}
+
+// episodePatterns are the common episode naming patterns, the first group is the show name.
+// Patterns without season number are considered to be the first season
+var episodePatterns = []struct {
+	reg       *regexp.Regexp
+	hasSeason bool
+}{
+	{regexp.MustCompile(`(?i)^(.*?)[\s._-]*\bs(\d{1,2})[\s._-]*e(\d{1,3})\b`), true},
+	{regexp.MustCompile(`(?i)^(.*?)[\s._-]*\b(\d{1,2})x(\d{2,3})\b`), true},
+	{regexp.MustCompile(`(?i)^(.*?)[\s._-]*\bseason[\s._-]*(\d{1,2})[\s._,-]*(?:episode|ep)[\s._-]*(\d{1,3})\b`), true},
+	{regexp.MustCompile(`(?i)^(.*?)[\s._-]*\b(?:episode|ep)[\s._-]*(\d{1,3})\b`), false},
+	{regexp.MustCompile(`(?i)^(.*?)[\s._-]*\bpart[\s._-]*(\d{1,3})\b`), false},
+}
+
+var spacesReg = regexp.MustCompile(`\s+`)
+
+// ParseEpisode gets the show name, season and episode numbers from a file name or title.
+// It understands the S01E02, 1x02, Season 1 Episode 2, Episode 2 and Part 2 patterns
+//
+func ParseEpisode(name string) (info EpisodeInfo, ok bool) {
+	if IsVideoExtension(filepath.Ext(name)) {
+		name = strings.TrimSuffix(name, filepath.Ext(name))
+	}
+
+	for _, pattern := range episodePatterns {
+		matches := pattern.reg.FindStringSubmatch(name)
+
+		if matches == nil {
+			continue
+		}
+
+		info.Show = cleanShowName(matches[1])
+		info.Season = 1
+
+		if pattern.hasSeason {
+			info.Season, _ = strconv.Atoi(matches[2])
+			info.Episode, _ = strconv.Atoi(matches[3])
+		} else {
+			info.Episode, _ = strconv.Atoi(matches[2])
+		}
+
+		return info, true
+	}
+
+	return
+}
+
+// IsVideoExtension checks if the extension is one of the supported video extensions
+//
+func IsVideoExtension(extension string) bool {
+	extension = strings.ToLower(extension)
+
+	return extension == ".mp4" || extension == ".ogv" || extension == ".enc"
+}
+
+// cleanShowName replaces the separators of the show name with spaces
+//
+func cleanShowName(show string) string {
+	show = strings.NewReplacer(".", " ", "_", " ").Replace(show)
+	show = strings.Trim(show, " -([")
+
+	return spacesReg.ReplaceAllString(show, " ")
+}
+
+// NaturalLess compares two strings taking into account the value of the numbers, so "Episode 2" goes before "Episode 10"
+//
+func NaturalLess(a string, b string) bool {
+	a = strings.ToLower(a)
+	b = strings.ToLower(b)
+
+	for a != "" && b != "" {
+		chunkA, restA := naturalChunk(a)
+		chunkB, restB := naturalChunk(b)
+
+		numberA, errA := strconv.Atoi(chunkA)
+		numberB, errB := strconv.Atoi(chunkB)
+
+		if errA == nil && errB == nil {
+			if numberA != numberB {
+				return numberA < numberB
+			}
+		} else if chunkA != chunkB {
+			return chunkA < chunkB
+		}
+
+		a = restA
+		b = restB
+	}
+
+	return len(a) < len(b)
+}
+
+// naturalChunk splits the first group of digits or non digits of the string
+//
+func naturalChunk(s string) (chunk string, rest string) {
+	isDigit := unicode.IsDigit(rune(s[0]))
+
+	for i, char := range s {
+		if unicode.IsDigit(char) != isDigit {
+			return s[:i], s[i:]
+		}
+	}
+
+	return s, ""
+}
+
+type ByNaturalTitle struct {
+	Videos
+}
+
+func (s ByNaturalTitle) Less(i, j int) bool {
+	return NaturalLess(s.Videos[i].Title, s.Videos[j].Title)
+}
+
+type ByEpisode struct {
+	Videos
+}
+
+func (s ByEpisode) Less(i, j int) bool {
+	if s.Videos[i].Season != s.Videos[j].Season {
+		return s.Videos[i].Season < s.Videos[j].Season
+	}
+
+	if s.Videos[i].Episode != s.Videos[j].Episode {
+		return s.Videos[i].Episode < s.Videos[j].Episode
+	}
+
+	return NaturalLess(s.Videos[i].Title, s.Videos[j].Title)
+}
@@ -0,0 +1,44 @@
+package mpclibrary
+
+import (
+	"sort"
+	"testing"
+)
+
+func TestParseEpisode(t *testing.T) {
+	tests := []struct {
+		name string
+		info EpisodeInfo
+		ok   bool
+	}{
+		{"The.Show.S01E02.720p.mp4", EpisodeInfo{"The Show", 1, 2}, true},
+		{"The Show - 2x10 - Title.mp4", EpisodeInfo{"The Show", 2, 10}, true},
+		{"The Show Season 3 Episode 4", EpisodeInfo{"The Show", 3, 4}, true},
+		{"Documentary Part 3.mp4", EpisodeInfo{"Documentary", 1, 3}, true},
+		{"Some Movie.mp4", EpisodeInfo{}, false},
+	}
+
+	for _, test := range tests {
+		info, ok := ParseEpisode(test.name)
+
+		if ok != test.ok || info != test.info {
+			t.Errorf("ParseEpisode(%q) = %+v, %v; want %+v, %v", test.name, info, ok, test.info, test.ok)
+		}
+	}
+}
+
+func TestNaturalLess(t *testing.T) {
+	titles := []string{"Episode 10", "episode 2", "Episode 1", "Episode 1b"}
+
+	sort.Slice(titles, func(i, j int) bool {
+		return NaturalLess(titles[i], titles[j])
+	})
+
+	expected := []string{"Episode 1", "Episode 1b", "episode 2", "Episode 10"}
+
+	for i := range expected {
+		if titles[i] != expected[i] {
+			t.Fatalf("sorted titles = %v; want %v", titles, expected)
+		}
+	}
+}
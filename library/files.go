@@ -0,0 +1,304 @@
+package mpclibrary
+
+import (
+	"github.com/jempe/encdec"
+	"github.com/jempe/mpc/utils"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ScreenshotsFolder gets the folder where the screenshots of the video are saved
+//
+func (lib *Library) ScreenshotsFolder(videoData Video) string {
+	folder := videoData.Md5Sum
+
+	if folder == "" {
+		folder = videoData.ID
+	}
+
+	return lib.Path + "/thumbs/" + folder
+}
+
+// Screenshots reads the screenshots of the video sorted by time, the screenshots of the encrypted videos are decrypted
+// with the key and the missing screenshots of the other videos are generated
+//
+func (lib *Library) Screenshots(videoData Video, key []byte) (screenshots [][]byte, err error) {
+	extension := ".jpg"
+
+	if videoData.Encrypted {
+		extension = ".enc"
+	} else {
+		err = lib.GenerateScreenshots(videoData)
+		if err != nil {
+			return
+		}
+	}
+
+	files, err := filepath.Glob(lib.ScreenshotsFolder(videoData) + "/*" + extension)
+	if err != nil {
+		return
+	}
+
+	// the screenshots are named by their time in seconds
+	sort.Slice(files, func(i, j int) bool {
+		first, _ := strconv.Atoi(strings.TrimSuffix(filepath.Base(files[i]), extension))
+		second, _ := strconv.Atoi(strings.TrimSuffix(filepath.Base(files[j]), extension))
+
+		return first < second
+	})
+
+	for _, file := range files {
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+
+		if videoData.Encrypted {
+			data, err = encdec.Decrypt(data, key)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		screenshots = append(screenshots, data)
+	}
+
+	if len(screenshots) == 0 {
+		err = errors.New("screenshots_not_exist")
+	}
+
+	return
+}
+
+// ThumbnailPath gets the path of the video thumbnail
+//
+func (lib *Library) ThumbnailPath(videoData Video) string {
+	if videoData.Encrypted {
+		return lib.Path + "/" + strings.Replace(videoData.File, ".enc", "_thumb.enc", 1)
+	}
+
+	return lib.Path + "/" + videoData.File + ".jpg"
+}
+
+// RegenerateScreenshots deletes the screenshots of the video and generates them again
+//
+func (lib *Library) RegenerateScreenshots(videoData Video) error {
+	if videoData.Encrypted {
+		return errors.New("video_encrypted")
+	}
+
+	screenshots, err := filepath.Glob(lib.ScreenshotsFolder(videoData) + "/*.jpg")
+	if err != nil {
+		return err
+	}
+
+	for _, screenshot := range screenshots {
+		err = os.Remove(screenshot)
+		if err != nil {
+			return err
+		}
+	}
+
+	return lib.GenerateScreenshots(videoData)
+}
+
+// RegenerateThumbnail replaces the video thumbnail with a frame of the video
+//
+func (lib *Library) RegenerateThumbnail(videoData Video) error {
+	if videoData.Encrypted {
+		return errors.New("video_encrypted")
+	}
+
+	thumbPath := lib.ThumbnailPath(videoData)
+
+	if mpcutils.Exists(thumbPath) {
+		err := os.Remove(thumbPath)
+		if err != nil {
+			return err
+		}
+	}
+
+	return mpcutils.SaveScreenshot(lib.Path+"/"+videoData.File, strconv.Itoa(videoData.Duration/3), thumbPath)
+}
+
+// DeleteVideoFiles deletes the video file, its thumbnail and screenshots
+//
+func (lib *Library) DeleteVideoFiles(videoData Video) error {
+	// the screenshots folder is named by the MD5 sum or the ID, without them it's the folder of all the screenshots
+	if videoData.File == "" || (videoData.Md5Sum == "" && videoData.ID == "") {
+		return errors.New("video_file_empty")
+	}
+
+	for _, path := range []string{lib.Path + "/" + videoData.File, lib.ThumbnailPath(videoData)} {
+		err := os.Remove(path)
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+
+	return os.RemoveAll(lib.ScreenshotsFolder(videoData))
+}
+
+// FileChange has the files written by EncryptVideo and DecryptVideo, the old files are kept until the change
+// is committed so the video keeps working when the DB can't be updated
+type FileChange struct {
+	// File is the new file of the video
+	File    string
+	Sources []string
+	Targets []string
+}
+
+// Commit removes the old files once the DB uses the new file
+//
+func (change FileChange) Commit() error {
+	for _, source := range change.Sources {
+		err := os.Remove(source)
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Rollback removes the new files, the old files are not changed
+//
+func (change FileChange) Rollback() {
+	for _, target := range change.Targets {
+		os.Remove(target)
+	}
+}
+
+// convert writes the source file converted with the function into the target file
+//
+func (change *FileChange) convert(source string, target string, convert func(data []byte) ([]byte, error)) error {
+	data, err := ioutil.ReadFile(source)
+	if err != nil {
+		return err
+	}
+
+	converted, err := convert(data)
+	if err != nil {
+		return err
+	}
+
+	// the target is added before it's written so the rollback removes a partial file
+	change.Targets = append(change.Targets, target)
+
+	err = ioutil.WriteFile(target, converted, 0644)
+	if err != nil {
+		return err
+	}
+
+	change.Sources = append(change.Sources, source)
+
+	return nil
+}
+
+// EncryptVideo writes the encrypted video file, thumbnail and screenshots next to the plain files, the plain files
+// are removed when the change is committed. The encrypted files are removed when there is an error
+//
+func (lib *Library) EncryptVideo(videoData Video, key []byte) (change FileChange, err error) {
+	if videoData.Encrypted {
+		return change, errors.New("video_encrypted")
+	}
+
+	encrypt := func(data []byte) ([]byte, error) {
+		return encdec.Encrypt(data, key)
+	}
+
+	defer func() {
+		if err != nil {
+			change.Rollback()
+		}
+	}()
+
+	change.File = videoData.ID + ".enc"
+
+	err = change.convert(lib.Path+"/"+videoData.File, lib.Path+"/"+change.File, encrypt)
+	if err != nil {
+		return
+	}
+
+	thumbPath := lib.ThumbnailPath(videoData)
+
+	if mpcutils.Exists(thumbPath) {
+		err = change.convert(thumbPath, lib.Path+"/"+videoData.ID+"_thumb.enc", encrypt)
+		if err != nil {
+			return
+		}
+	}
+
+	screenshots, err := filepath.Glob(lib.ScreenshotsFolder(videoData) + "/*.jpg")
+	if err != nil {
+		return
+	}
+
+	for _, screenshot := range screenshots {
+		err = change.convert(screenshot, strings.TrimSuffix(screenshot, ".jpg")+".enc", encrypt)
+		if err != nil {
+			return
+		}
+	}
+
+	return
+}
+
+// DecryptVideo writes the decrypted video file, thumbnail and screenshots next to the encrypted files, the encrypted
+// files are removed when the change is committed. The decrypted files are removed when there is an error
+//
+func (lib *Library) DecryptVideo(videoData Video, key []byte) (change FileChange, err error) {
+	if !videoData.Encrypted {
+		return change, errors.New("video_not_encrypted")
+	}
+
+	decrypt := func(data []byte) ([]byte, error) {
+		return encdec.Decrypt(data, key)
+	}
+
+	defer func() {
+		if err != nil {
+			change.Rollback()
+		}
+	}()
+
+	extension := videoData.Extension
+	if extension == "" {
+		extension = "mp4"
+	}
+
+	change.File = videoData.ID + "." + extension
+
+	err = change.convert(lib.Path+"/"+videoData.File, lib.Path+"/"+change.File, decrypt)
+	if err != nil {
+		return
+	}
+
+	thumbPath := lib.ThumbnailPath(videoData)
+
+	if mpcutils.Exists(thumbPath) {
+		err = change.convert(thumbPath, lib.Path+"/"+change.File+".jpg", decrypt)
+		if err != nil {
+			return
+		}
+	}
+
+	screenshots, err := filepath.Glob(lib.ScreenshotsFolder(videoData) + "/*.enc")
+	if err != nil {
+		return
+	}
+
+	for _, screenshot := range screenshots {
+		err = change.convert(screenshot, strings.TrimSuffix(screenshot, ".enc")+".jpg", decrypt)
+		if err != nil {
+			return
+		}
+	}
+
+	return
+}
@@ -0,0 +1,95 @@
+package mpclibrary
+
+import (
+	"github.com/jempe/mpc/utils"
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+var testKey = []byte("ThisisAT3stKey12")
+
+// newTestVideoFiles writes a video with its thumb and a screenshot in the library
+//
+func newTestVideoFiles(t *testing.T, lib *Library, video Video) {
+	err := os.MkdirAll(lib.ScreenshotsFolder(video), 0700)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, path := range []string{lib.Path + "/" + video.File, lib.ThumbnailPath(video), lib.ScreenshotsFolder(video) + "/10.jpg"} {
+		err = ioutil.WriteFile(path, []byte("data of "+path), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestEncryptVideoCommit(t *testing.T) {
+	lib := &Library{Path: t.TempDir()}
+	video := Video{ID: "abc", File: "movie.mp4", Extension: "mp4"}
+
+	newTestVideoFiles(t, lib, video)
+
+	change, err := lib.EncryptVideo(video, testKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if change.File != "abc.enc" || len(change.Targets) != 3 || len(change.Sources) != 3 {
+		t.Fatal("the video, the thumb and the screenshot should be encrypted", change)
+	}
+
+	// the plain files are kept until the change is committed
+	if !mpcutils.Exists(lib.Path+"/movie.mp4") || !mpcutils.Exists(lib.Path+"/abc.enc") || !mpcutils.Exists(lib.Path+"/thumbs/abc/10.enc") {
+		t.Fatal("the plain and the encrypted files should exist before the commit")
+	}
+
+	err = change.Commit()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if mpcutils.Exists(lib.Path+"/movie.mp4") || mpcutils.Exists(lib.Path+"/movie.mp4.jpg") || mpcutils.Exists(lib.Path+"/thumbs/abc/10.jpg") {
+		t.Error("the plain files should be removed by the commit")
+	}
+
+	encrypted := Video{ID: "abc", File: change.File, Extension: "mp4", Encrypted: true}
+
+	change, err = lib.DecryptVideo(encrypted, testKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	change.Rollback()
+
+	if mpcutils.Exists(lib.Path+"/abc.mp4") || mpcutils.Exists(lib.Path+"/thumbs/abc/10.jpg") || !mpcutils.Exists(lib.Path+"/abc.enc") {
+		t.Error("the rollback should remove the decrypted files and keep the encrypted files")
+	}
+}
+
+func TestEncryptVideoError(t *testing.T) {
+	lib := &Library{Path: t.TempDir()}
+	video := Video{ID: "abc", File: "movie.mp4"}
+
+	newTestVideoFiles(t, lib, video)
+
+	// a screenshot that can't be read fails after the video and the thumb were encrypted
+	err := os.Mkdir(lib.ScreenshotsFolder(video)+"/20.jpg", 0700)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = lib.EncryptVideo(video, testKey)
+	if err == nil {
+		t.Fatal("the screenshot folder should not be encrypted")
+	}
+
+	if mpcutils.Exists(lib.Path+"/abc.enc") || mpcutils.Exists(lib.Path+"/abc_thumb.enc") || mpcutils.Exists(lib.Path+"/thumbs/abc/10.enc") {
+		t.Error("the encrypted files should be removed after an error")
+	}
+
+	if !mpcutils.Exists(lib.Path+"/movie.mp4") || !mpcutils.Exists(lib.Path+"/movie.mp4.jpg") || !mpcutils.Exists(lib.Path+"/thumbs/abc/10.jpg") {
+		t.Error("the plain files should be kept after an error")
+	}
+}
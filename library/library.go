@@ -9,6 +9,7 @@ import (
 	"io/ioutil"
 	"log"
 	"os"
+	"path/filepath"
 	"strconv"
 	"strings"
 	"time"
@@ -33,6 +34,10 @@ type Video struct {
 	SubtitlesURL string     `json:"subtitlesURL"`
 	Categories   []Category `json:"categories"`
 	Actors       []Actor    `json:"actors"`
+	Tags         []Tag      `json:"tags"`
+	Series       string     `json:"series"`
+	Season       int        `json:"season"`
+	Episode      int        `json:"episode"`
 	Extension    string     `json:"extension"`
 	Width        int        `json:"width"`
 	Height       int        `json:"height"`
@@ -43,19 +48,30 @@ type Video struct {
 	Path         string     `json:"path"`
 	Md5Sum       string     `json:"md5sum"`
 	Encrypted    bool       `json:"encrypted"`
+	Added        time.Time  `json:"added"`
 	Order        int        `json:"order"`
+	// MetadataSources has the metadata provider of every field that was read on import
+	MetadataSources map[string]string `json:"metadataSources,omitempty"`
 }
 
 type Category struct {
+	ID     int    `json:"id"`
+	Name   string `json:"name"`
+	Parent int    `json:"parent"`
+}
+
+type Tag struct {
 	ID   int    `json:"id"`
 	Name string `json:"name"`
 }
 
 type Actor struct {
-	ID     int       `json:"id"`
-	Name   string    `json:"name"`
-	Gender string    `json:"gender"`
-	Birth  time.Time `json:birth`
+	ID      int       `json:"id"`
+	Name    string    `json:"name"`
+	Gender  string    `json:"gender"`
+	Birth   time.Time `json:"birth"`
+	Aliases []string  `json:"aliases"`
+	Photo   string    `json:"photo"`
 }
 
 type VideoJSON struct {
@@ -68,6 +84,9 @@ type VideoJSON struct {
 	URL         string   `json:"url"`
 	Image       string   `json:"image"`
 	Md5Sum      string   `json:"md5sum"`
+	Series      string   `json:"series,omitempty"`
+	Season      int      `json:"season,omitempty"`
+	Episode     int      `json:"episode,omitempty"`
 }
 
 type Settings struct {
@@ -75,6 +94,8 @@ type Settings struct {
 	ClusterID   string
 	InstanceID  string
 	HMACkey     []byte
+	// MediaKeyGeneration changes when the key of the signed media URLs is rotated
+	MediaKeyGeneration int
 }
 
 // ScanDirectory  scans a folder to find videos
@@ -90,21 +111,12 @@ func (lib *Library) Scan(directory string) (err error) {
 	lib.Videos = Videos{}
 
 	for _, f := range files {
-		isVid, fileName, fileExtension := mpcutils.IsVideo(f.Name())
+		isVid, _, fileExtension := mpcutils.IsVideo(f.Name())
 
 		if isVid {
-			thisVideo := Video{}
-
-			videoJSON := lib.Path + "/" + strings.Replace(fileName, ".mp4", ".json", 1)
-			if mpcutils.Exists(videoJSON) {
-				thisVideoJSON, err := GetJSONData(videoJSON)
+			thisVideo := ReadMetadata(lib.Path + "/" + f.Name())
 
-				if err == nil {
-					thisVideo = thisVideoJSON
-				} else {
-					log.Println(err)
-				}
-			} else {
+			if thisVideo.Title == "" {
 				thisVideo.Title = f.Name()
 			}
 
@@ -148,6 +160,9 @@ func GetJSONData(videoJSON string) (thisVideo Video, err error) {
 			thisVideo.Actors = videoActors
 			thisVideo.Categories = videoCategories
 			thisVideo.Md5Sum = videoDataJSON.Md5Sum
+			thisVideo.Series = videoDataJSON.Series
+			thisVideo.Season = videoDataJSON.Season
+			thisVideo.Episode = videoDataJSON.Episode
 
 			videoDate, err := mpcutils.ParseDate(videoDataJSON.ReleaseDate)
 
@@ -178,16 +193,18 @@ func (lib *Library) SaveJSONData(videoData Video) (err error) {
 		categories = append(categories, category.Name)
 	}
 
-	output := VideoJSON{Description: videoData.Description, Title: videoData.Title, URL: videoData.VideoURL, Image: videoData.ImgURL, Actors: actors, Categories: categories, Md5Sum: videoData.Md5Sum, ReleaseDate: videoData.PubDate.Format("2006-01-02")}
+	output := VideoJSON{Description: videoData.Description, Title: videoData.Title, URL: videoData.VideoURL, Image: videoData.ImgURL, Actors: actors, Categories: categories, Md5Sum: videoData.Md5Sum, ReleaseDate: videoData.PubDate.Format("2006-01-02"), Series: videoData.Series, Season: videoData.Season, Episode: videoData.Episode}
 
-	if videoData.Md5Sum != "" {
+	jsonPath := lib.JSONDataPath(videoData)
+
+	if jsonPath != "" {
 		json, err := json.MarshalIndent(output, "", "\t")
 
 		if err == nil {
-			jsonPath := lib.Path + "/" + videoData.Md5Sum + ".json"
-
 			err = ioutil.WriteFile(jsonPath, json, 0644)
 		}
+
+		return err
 	} else {
 		err = errors.New("file doesn't have MD5 checkSum")
 	}
@@ -195,6 +212,21 @@ func (lib *Library) SaveJSONData(videoData Video) (err error) {
 	return err
 }
 
+// JSONDataPath gets the path of the json file of the video, imported videos use the MD5 checksum as name
+// and scanned videos use the name of the video file
+//
+func (lib *Library) JSONDataPath(videoData Video) string {
+	if videoData.Md5Sum != "" {
+		return lib.Path + "/" + videoData.Md5Sum + ".json"
+	}
+
+	if videoData.File != "" {
+		return lib.Path + "/" + strings.TrimSuffix(videoData.File, filepath.Ext(videoData.File)) + ".json"
+	}
+
+	return ""
+}
+
 // importVideo gets md5sum of video and copies it to the library folder and return video info
 //
 func (lib *Library) ImportVideo(file string) (video Video, err error) {
@@ -214,16 +246,9 @@ func (lib *Library) ImportVideo(file string) (video Video, err error) {
 					err = mpcutils.CopyFile(file, targetFile)
 
 					if err == nil {
-						videoJSON := strings.Replace(file, "."+extension, ".json", 1)
-						if mpcutils.Exists(videoJSON) {
-							thisVideoJSON, err := GetJSONData(videoJSON)
-
-							if err == nil {
-								video = thisVideoJSON
+						video = ReadMetadata(file)
 
-								lib.SaveJSONData(video)
-							}
-						} else {
+						if video.Title == "" {
 							video.Title = fileName
 						}
 
@@ -262,7 +287,7 @@ func (lib *Library) ImportVideo(file string) (video Video, err error) {
 //
 func (lib *Library) GenerateScreenshots(videoData Video) (err error) {
 	if videoData.Step > 0 {
-		screenshotFolder := lib.Path + "/thumbs/" + videoData.Md5Sum
+		screenshotFolder := lib.ScreenshotsFolder(videoData)
 
 		if !mpcutils.Exists(screenshotFolder) {
 			log.Println("Screenshots folder doesn't exist. Creating folder ", screenshotFolder)
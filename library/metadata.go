@@ -0,0 +1,390 @@
+package mpclibrary
+
+import (
+	"github.com/jempe/mpc/utils"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"io/ioutil"
+	"log"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MetadataProvider reads the metadata of a video from a sidecar file or from the video file
+type MetadataProvider interface {
+	// Name is the source that is recorded in the metadata sources of the videos
+	Name() string
+	// Read gets the metadata of the video file, the fields that the provider doesn't know are empty.
+	// ok is false when the provider doesn't have data for the file, like when the sidecar doesn't exist
+	Read(videoFile string) (metadata Video, ok bool, err error)
+}
+
+// metadata fields that are merged from the providers, the names are the json names of the video fields
+const (
+	FieldTitle       = "title"
+	FieldDescription = "description"
+	FieldPubDate     = "pubDate"
+	FieldActors      = "actors"
+	FieldCategories  = "categories"
+	FieldVideoURL    = "videoURL"
+	FieldImgURL      = "imgURL"
+	FieldSeries      = "series"
+	FieldSeason      = "season"
+	FieldEpisode     = "episode"
+	FieldMd5Sum      = "md5sum"
+)
+
+type registeredProvider struct {
+	provider MetadataProvider
+	priority int
+}
+
+var (
+	metadataProviders []registeredProvider
+	metadataMutex     sync.RWMutex
+)
+
+func init() {
+	RegisterMetadataProvider(VideoJSONProvider{}, 40)
+	RegisterMetadataProvider(NFOProvider{}, 30)
+	RegisterMetadataProvider(YTDLPProvider{}, 20)
+	RegisterMetadataProvider(FFProbeTagsProvider{Probe: mpcutils.FFProbeTags}, 10)
+}
+
+// RegisterMetadataProvider adds a provider to the registry, the fields of the providers with a higher priority win.
+// A provider with the same name replaces the registered one
+//
+func RegisterMetadataProvider(provider MetadataProvider, priority int) {
+	metadataMutex.Lock()
+	defer metadataMutex.Unlock()
+
+	for index, registered := range metadataProviders {
+		if registered.provider.Name() == provider.Name() {
+			metadataProviders = append(metadataProviders[:index], metadataProviders[index+1:]...)
+			break
+		}
+	}
+
+	metadataProviders = append(metadataProviders, registeredProvider{provider: provider, priority: priority})
+
+	sort.SliceStable(metadataProviders, func(i, j int) bool {
+		return metadataProviders[i].priority > metadataProviders[j].priority
+	})
+}
+
+// MetadataProviders gets the registered providers from the highest to the lowest priority
+//
+func MetadataProviders() (providers []MetadataProvider) {
+	metadataMutex.RLock()
+	defer metadataMutex.RUnlock()
+
+	for _, registered := range metadataProviders {
+		providers = append(providers, registered.provider)
+	}
+
+	return
+}
+
+// ReadMetadata reads the metadata of the video file with all the registered providers
+//
+func ReadMetadata(videoFile string) Video {
+	return ReadMetadataWith(videoFile, MetadataProviders())
+}
+
+// ReadMetadataWith merges the metadata of the providers field by field, every field comes from the first provider
+// that has it and its source is saved in the metadata sources. The errors of a provider are logged and its data is skipped
+//
+func ReadMetadataWith(videoFile string, providers []MetadataProvider) (video Video) {
+	for _, provider := range providers {
+		metadata, ok, err := provider.Read(videoFile)
+		if err != nil {
+			log.Println("Metadata", provider.Name(), videoFile, err)
+			continue
+		}
+
+		if ok {
+			mergeMetadata(&video, metadata, provider.Name())
+		}
+	}
+
+	return
+}
+
+// mergeMetadata sets the empty fields of the video with the fields of the metadata
+//
+func mergeMetadata(video *Video, metadata Video, source string) {
+	set := func(field string, empty bool, apply func()) {
+		if !empty && video.MetadataSources[field] == "" {
+			if video.MetadataSources == nil {
+				video.MetadataSources = make(map[string]string)
+			}
+
+			apply()
+			video.MetadataSources[field] = source
+		}
+	}
+
+	set(FieldTitle, metadata.Title == "", func() { video.Title = metadata.Title })
+	set(FieldDescription, metadata.Description == "", func() { video.Description = metadata.Description })
+	set(FieldPubDate, metadata.PubDate.IsZero(), func() { video.PubDate = metadata.PubDate })
+	set(FieldActors, len(metadata.Actors) == 0, func() { video.Actors = metadata.Actors })
+	set(FieldCategories, len(metadata.Categories) == 0, func() { video.Categories = metadata.Categories })
+	set(FieldVideoURL, metadata.VideoURL == "", func() { video.VideoURL = metadata.VideoURL })
+	set(FieldImgURL, metadata.ImgURL == "", func() { video.ImgURL = metadata.ImgURL })
+	set(FieldSeries, metadata.Series == "", func() { video.Series = metadata.Series })
+	set(FieldSeason, metadata.Season == 0, func() { video.Season = metadata.Season })
+	set(FieldEpisode, metadata.Episode == 0, func() { video.Episode = metadata.Episode })
+	set(FieldMd5Sum, metadata.Md5Sum == "", func() { video.Md5Sum = metadata.Md5Sum })
+}
+
+// sidecarPath gets the path of a sidecar file that has the name of the video without extension
+//
+func sidecarPath(videoFile string, extension string) string {
+	return strings.TrimSuffix(videoFile, filepath.Ext(videoFile)) + extension
+}
+
+// VideoJSONProvider reads the json files saved by MPC
+type VideoJSONProvider struct{}
+
+func (provider VideoJSONProvider) Name() string {
+	return "mpc"
+}
+
+func (provider VideoJSONProvider) Read(videoFile string) (metadata Video, ok bool, err error) {
+	jsonPath := sidecarPath(videoFile, ".json")
+
+	if !mpcutils.Exists(jsonPath) {
+		return
+	}
+
+	metadata, err = GetJSONData(jsonPath)
+
+	return metadata, err == nil, err
+}
+
+// NFOProvider reads the Kodi NFO files of movies and episodes
+type NFOProvider struct{}
+
+func (provider NFOProvider) Name() string {
+	return "nfo"
+}
+
+func (provider NFOProvider) Read(videoFile string) (metadata Video, ok bool, err error) {
+	nfoPath := sidecarPath(videoFile, ".nfo")
+
+	if !mpcutils.Exists(nfoPath) {
+		return
+	}
+
+	data, err := ioutil.ReadFile(nfoPath)
+	if err != nil {
+		return
+	}
+
+	var nfo nfoVideo
+
+	err = xml.Unmarshal(data, &nfo)
+	if err != nil {
+		return
+	}
+
+	metadata.Title = strings.TrimSpace(nfo.Title)
+	metadata.Description = strings.TrimSpace(nfo.Plot)
+	metadata.Series = strings.TrimSpace(nfo.ShowTitle)
+	metadata.Season = nfo.Season
+	metadata.Episode = nfo.Episode
+
+	for _, date := range []string{nfo.Premiered, nfo.Aired} {
+		if pubDate, err := time.Parse("2006-01-02", strings.TrimSpace(date)); err == nil {
+			metadata.PubDate = pubDate
+			break
+		}
+	}
+
+	if metadata.PubDate.IsZero() && nfo.Year > 0 {
+		metadata.PubDate = time.Date(nfo.Year, time.January, 1, 0, 0, 0, 0, time.UTC)
+	}
+
+	if len(nfo.Thumbs) > 0 {
+		metadata.ImgURL = strings.TrimSpace(nfo.Thumbs[0].URL)
+	}
+
+	sort.SliceStable(nfo.Actors, func(i, j int) bool {
+		return nfo.Actors[i].Order < nfo.Actors[j].Order
+	})
+
+	for _, actor := range nfo.Actors {
+		if name := strings.TrimSpace(actor.Name); name != "" {
+			metadata.Actors = append(metadata.Actors, Actor{Name: name})
+		}
+	}
+
+	for _, genre := range nfo.Genres {
+		if name := strings.TrimSpace(genre); name != "" {
+			metadata.Categories = append(metadata.Categories, Category{Name: name})
+		}
+	}
+
+	return metadata, true, nil
+}
+
+// YTDLPProvider reads the .info.json files written by yt-dlp with --write-info-json
+type YTDLPProvider struct{}
+
+type ytdlpInfo struct {
+	Title         string   `json:"title"`
+	Description   string   `json:"description"`
+	UploadDate    string   `json:"upload_date"`
+	ReleaseDate   string   `json:"release_date"`
+	WebpageURL    string   `json:"webpage_url"`
+	Thumbnail     string   `json:"thumbnail"`
+	Cast          []string `json:"cast"`
+	Creator       string   `json:"creator"`
+	Categories    []string `json:"categories"`
+	Series        string   `json:"series"`
+	SeasonNumber  int      `json:"season_number"`
+	EpisodeNumber int      `json:"episode_number"`
+}
+
+func (provider YTDLPProvider) Name() string {
+	return "yt-dlp"
+}
+
+func (provider YTDLPProvider) Read(videoFile string) (metadata Video, ok bool, err error) {
+	infoPath := sidecarPath(videoFile, ".info.json")
+
+	if !mpcutils.Exists(infoPath) {
+		return
+	}
+
+	data, err := ioutil.ReadFile(infoPath)
+	if err != nil {
+		return
+	}
+
+	var info ytdlpInfo
+
+	err = json.Unmarshal(data, &info)
+	if err != nil {
+		return
+	}
+
+	metadata.Title = info.Title
+	metadata.Description = info.Description
+	metadata.VideoURL = info.WebpageURL
+	metadata.ImgURL = info.Thumbnail
+	metadata.Series = info.Series
+	metadata.Season = info.SeasonNumber
+	metadata.Episode = info.EpisodeNumber
+
+	// the dates of yt-dlp are YYYYMMDD
+	for _, date := range []string{info.ReleaseDate, info.UploadDate} {
+		if pubDate, err := time.Parse("20060102", date); err == nil {
+			metadata.PubDate = pubDate
+			break
+		}
+	}
+
+	actors := info.Cast
+	if len(actors) == 0 && info.Creator != "" {
+		actors = splitNames(info.Creator)
+	}
+
+	for _, actor := range actors {
+		metadata.Actors = append(metadata.Actors, Actor{Name: actor})
+	}
+
+	for _, category := range info.Categories {
+		metadata.Categories = append(metadata.Categories, Category{Name: category})
+	}
+
+	return metadata, true, nil
+}
+
+// FFProbeTagsProvider reads the tags of the video container, like the tags written by ffmpeg -metadata
+type FFProbeTagsProvider struct {
+	// Probe gets the lower case tags of the container
+	Probe func(videoFile string) (map[string]string, error)
+}
+
+func (provider FFProbeTagsProvider) Name() string {
+	return "ffprobe"
+}
+
+func (provider FFProbeTagsProvider) Read(videoFile string) (metadata Video, ok bool, err error) {
+	if filepath.Ext(videoFile) == ".enc" || !mpcutils.Exists(videoFile) {
+		return
+	}
+
+	tags, err := provider.Probe(videoFile)
+	if errors.Is(err, exec.ErrNotFound) {
+		// the container tags are skipped when ffprobe is not installed
+		return metadata, false, nil
+	}
+
+	if err != nil || len(tags) == 0 {
+		return
+	}
+
+	first := func(names ...string) string {
+		for _, name := range names {
+			if value := strings.TrimSpace(tags[name]); value != "" {
+				return value
+			}
+		}
+
+		return ""
+	}
+
+	metadata.Title = first("title")
+	metadata.Description = first("description", "synopsis", "comment")
+	metadata.Series = first("show")
+	metadata.VideoURL = first("purl")
+	metadata.Season, _ = strconv.Atoi(first("season_number"))
+	metadata.Episode, _ = strconv.Atoi(first("episode_sort", "episode_id"))
+
+	// the dates can be a year, a date or a time like the creation time
+	if date := first("date", "creation_time"); date != "" {
+		if pubDate, err := time.Parse("2006-01-02", date[:minInt(len(date), 10)]); err == nil {
+			metadata.PubDate = pubDate
+		} else if year, err := strconv.Atoi(date); err == nil && year > 0 {
+			metadata.PubDate = time.Date(year, time.January, 1, 0, 0, 0, 0, time.UTC)
+		}
+	}
+
+	for _, actor := range splitNames(first("artist", "album_artist")) {
+		metadata.Actors = append(metadata.Actors, Actor{Name: actor})
+	}
+
+	for _, genre := range splitNames(first("genre")) {
+		metadata.Categories = append(metadata.Categories, Category{Name: genre})
+	}
+
+	return metadata, true, nil
+}
+
+// splitNames splits the names of a tag separated by commas, semicolons or slashes
+//
+func splitNames(value string) (names []string) {
+	for _, name := range strings.FieldsFunc(value, func(r rune) bool { return r == ',' || r == ';' || r == '/' }) {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+
+	return
+}
+
+func minInt(a int, b int) int {
+	if a < b {
+		return a
+	}
+
+	return b
+}
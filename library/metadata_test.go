@@ -0,0 +1,119 @@
+package mpclibrary
+
+import (
+	"io/ioutil"
+	"testing"
+	"time"
+)
+
+func writeFile(t *testing.T, path string, content string) {
+	err := ioutil.WriteFile(path, []byte(content), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReadMetadata(t *testing.T) {
+	folder := t.TempDir()
+	videoFile := folder + "/The.Show.S01E02.mp4"
+
+	writeFile(t, videoFile, "video")
+
+	writeFile(t, folder+"/The.Show.S01E02.json", `{"title": "Our Title", "actors": ["Jane Doe"]}`)
+
+	writeFile(t, folder+"/The.Show.S01E02.nfo", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<episodedetails>
+	<title>NFO Title</title>
+	<showtitle>The Show</showtitle>
+	<season>1</season>
+	<episode>2</episode>
+	<plot>The plot of the NFO</plot>
+	<aired>2019-03-04</aired>
+	<actor><name>Second Actor</name><order>1</order></actor>
+	<actor><name>First Actor</name><order>0</order></actor>
+</episodedetails>`)
+
+	writeFile(t, folder+"/The.Show.S01E02.info.json", `{"title": "yt-dlp title", "description": "yt-dlp description",
+		"upload_date": "20200517", "webpage_url": "https://example.com/watch", "thumbnail": "https://example.com/thumb.jpg",
+		"categories": ["Entertainment"]}`)
+
+	probe := FFProbeTagsProvider{Probe: func(videoFile string) (map[string]string, error) {
+		return map[string]string{"title": "Tag title", "genre": "Drama; Comedy", "purl": "https://example.com/tag"}, nil
+	}}
+
+	video := ReadMetadataWith(videoFile, []MetadataProvider{VideoJSONProvider{}, NFOProvider{}, YTDLPProvider{}, probe})
+
+	if video.Title != "Our Title" || video.MetadataSources[FieldTitle] != "mpc" {
+		t.Error("the title of the provider with the highest priority should win", video.Title, video.MetadataSources)
+	}
+
+	if len(video.Actors) != 1 || video.Actors[0].Name != "Jane Doe" || video.MetadataSources[FieldActors] != "mpc" {
+		t.Error("the actors should be merged as one field", video.Actors)
+	}
+
+	if video.Description != "The plot of the NFO" || video.Series != "The Show" || video.Season != 1 || video.Episode != 2 || video.MetadataSources[FieldSeries] != "nfo" {
+		t.Error("the NFO should fill the fields that the json doesn't have", video)
+	}
+
+	if !video.PubDate.Equal(time.Date(2019, 3, 4, 0, 0, 0, 0, time.UTC)) || video.MetadataSources[FieldPubDate] != "nfo" {
+		t.Error("the date of the NFO should win over yt-dlp", video.PubDate)
+	}
+
+	if video.VideoURL != "https://example.com/watch" || video.ImgURL != "https://example.com/thumb.jpg" || video.MetadataSources[FieldImgURL] != "yt-dlp" {
+		t.Error("the URLs should come from yt-dlp", video.VideoURL, video.ImgURL)
+	}
+
+	if len(video.Categories) != 1 || video.Categories[0].Name != "Entertainment" || video.MetadataSources[FieldCategories] != "yt-dlp" {
+		t.Error("the categories should come from yt-dlp", video.Categories)
+	}
+
+	if _, ok := video.MetadataSources[FieldMd5Sum]; ok {
+		t.Error("fields without data should not have sources", video.MetadataSources)
+	}
+
+	// only the container tags are read when there are no sidecars
+	otherFile := folder + "/other.mp4"
+	writeFile(t, otherFile, "video")
+
+	video = ReadMetadataWith(otherFile, []MetadataProvider{VideoJSONProvider{}, NFOProvider{}, probe})
+
+	if video.Title != "Tag title" || len(video.Categories) != 2 || video.Categories[1].Name != "Comedy" || video.MetadataSources[FieldVideoURL] != "ffprobe" {
+		t.Error("the container tags should be used", video)
+	}
+}
+
+func TestNFOProviderOrder(t *testing.T) {
+	folder := t.TempDir()
+
+	writeFile(t, folder+"/movie.nfo", `<movie><title>Movie</title><year>1999</year>
+		<actor><name>Second</name><order>1</order></actor><actor><name>First</name><order>0</order></actor>
+		<thumb aspect="poster">http://example.com/poster.jpg</thumb><genre>Drama</genre></movie>`)
+
+	metadata, ok, err := NFOProvider{}.Read(folder + "/movie.mkv")
+	if err != nil || !ok {
+		t.Fatal(ok, err)
+	}
+
+	if metadata.PubDate.Year() != 1999 || metadata.ImgURL != "http://example.com/poster.jpg" || metadata.Actors[0].Name != "First" || metadata.Categories[0].Name != "Drama" {
+		t.Error("wrong NFO metadata", metadata)
+	}
+
+	_, ok, _ = NFOProvider{}.Read(folder + "/other.mkv")
+	if ok {
+		t.Error("videos without NFO should not have metadata")
+	}
+}
+
+func TestRegisterMetadataProvider(t *testing.T) {
+	defer func(registered []registeredProvider) {
+		metadataProviders = registered
+	}(append([]registeredProvider{}, metadataProviders...))
+
+	RegisterMetadataProvider(NFOProvider{}, 50)
+
+	providers := MetadataProviders()
+
+	if len(providers) != 4 || providers[0].Name() != "nfo" || providers[1].Name() != "mpc" || providers[3].Name() != "ffprobe" {
+		t.Error("the providers should be sorted by priority and replaced by name", providers)
+	}
+}
@@ -0,0 +1,141 @@
+package mpclibrary
+
+import (
+	"encoding/xml"
+	"errors"
+	"io"
+	"io/ioutil"
+	"strings"
+)
+
+// nfoVideo has the fields of the Kodi movie and episode NFO files, the root element is movie or episodedetails
+type nfoVideo struct {
+	XMLName   xml.Name
+	Title     string     `xml:"title"`
+	ShowTitle string     `xml:"showtitle,omitempty"`
+	Season    int        `xml:"season,omitempty"`
+	Episode   int        `xml:"episode,omitempty"`
+	Plot      string     `xml:"plot,omitempty"`
+	Premiered string     `xml:"premiered,omitempty"`
+	Aired     string     `xml:"aired,omitempty"`
+	Year      int        `xml:"year,omitempty"`
+	Runtime   int        `xml:"runtime,omitempty"`
+	Thumbs    []nfoThumb `xml:"thumb"`
+	Genres    []string   `xml:"genre"`
+	Tags      []string   `xml:"tag"`
+	Actors    []nfoActor `xml:"actor"`
+	UniqueID  nfoID      `xml:"uniqueid"`
+}
+
+type nfoThumb struct {
+	Aspect string `xml:"aspect,attr"`
+	URL    string `xml:",chardata"`
+}
+
+type nfoActor struct {
+	Name  string `xml:"name"`
+	Order int    `xml:"order"`
+}
+
+type nfoID struct {
+	Type    string `xml:"type,attr"`
+	Default bool   `xml:"default,attr"`
+	ID      string `xml:",chardata"`
+}
+
+// WriteNFO writes the video data as a Kodi NFO, the videos of a series are written as episodes
+//
+func WriteNFO(w io.Writer, videoData Video) error {
+	output := nfoVideo{
+		XMLName:  xml.Name{Local: "movie"},
+		Title:    videoData.Title,
+		Plot:     videoData.Description,
+		UniqueID: nfoID{Type: "mpc", Default: true, ID: videoData.ID},
+	}
+
+	if videoData.Series != "" {
+		output.XMLName.Local = "episodedetails"
+		output.ShowTitle = videoData.Series
+		output.Season = videoData.Season
+		output.Episode = videoData.Episode
+	}
+
+	if !videoData.PubDate.IsZero() {
+		if videoData.Series != "" {
+			output.Aired = videoData.PubDate.Format("2006-01-02")
+		} else {
+			output.Premiered = videoData.PubDate.Format("2006-01-02")
+		}
+	}
+
+	// the runtime of the NFO files is in minutes
+	if videoData.Duration > 0 {
+		output.Runtime = (videoData.Duration + 59) / 60
+	}
+
+	if videoData.ThumbURL != "" {
+		output.Thumbs = []nfoThumb{{Aspect: "poster", URL: videoData.ThumbURL}}
+	} else if videoData.ImgURL != "" {
+		output.Thumbs = []nfoThumb{{Aspect: "poster", URL: videoData.ImgURL}}
+	}
+
+	for _, category := range videoData.Categories {
+		output.Genres = append(output.Genres, category.Name)
+	}
+
+	for _, tag := range videoData.Tags {
+		output.Tags = append(output.Tags, tag.Name)
+	}
+
+	for order, actor := range videoData.Actors {
+		output.Actors = append(output.Actors, nfoActor{Name: actor.Name, Order: order})
+	}
+
+	_, err := io.WriteString(w, `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>`+"\n")
+	if err != nil {
+		return err
+	}
+
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "\t")
+
+	err = encoder.Encode(output)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.WriteString(w, "\n")
+
+	return err
+}
+
+// SaveNFOData saves the video data on a Kodi NFO file next to the json file
+//
+func (lib *Library) SaveNFOData(videoData Video) (err error) {
+	nfoPath := lib.NFODataPath(videoData)
+
+	if nfoPath == "" {
+		return errors.New("file doesn't have MD5 checkSum")
+	}
+
+	var output strings.Builder
+
+	err = WriteNFO(&output, videoData)
+	if err != nil {
+		return
+	}
+
+	return ioutil.WriteFile(nfoPath, []byte(output.String()), 0644)
+}
+
+// NFODataPath gets the path of the NFO file of the video, it has the same name as the json file
+//
+func (lib *Library) NFODataPath(videoData Video) string {
+	jsonPath := lib.JSONDataPath(videoData)
+
+	if jsonPath == "" {
+		return ""
+	}
+
+	return strings.TrimSuffix(jsonPath, ".json") + ".nfo"
+}
@@ -0,0 +1,65 @@
+package mpclibrary
+
+import (
+	"encoding/xml"
+	"io/ioutil"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSaveNFOData(t *testing.T) {
+	lib := &Library{Path: t.TempDir()}
+
+	video := Video{
+		ID:          "abc",
+		Title:       "Movie & More",
+		Description: "A plot",
+		PubDate:     time.Date(2020, 5, 17, 0, 0, 0, 0, time.UTC),
+		Duration:    3601,
+		ImgURL:      "http://example.com/poster.jpg",
+		File:        "movie.mp4",
+		Actors:      []Actor{{Name: "Jane Doe"}, {Name: "John Doe"}},
+		Categories:  []Category{{Name: "Drama"}},
+	}
+
+	err := lib.SaveNFOData(video)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if lib.NFODataPath(video) != lib.Path+"/movie.nfo" {
+		t.Error("the NFO file should have the name of the video", lib.NFODataPath(video))
+	}
+
+	data, err := ioutil.ReadFile(lib.Path + "/movie.nfo")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var movie nfoVideo
+
+	err = xml.Unmarshal(data, &movie)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if movie.XMLName.Local != "movie" || movie.Title != "Movie & More" || movie.Plot != "A plot" || movie.Premiered != "2020-05-17" || movie.Runtime != 61 {
+		t.Errorf("wrong movie NFO:\n%s", data)
+	}
+
+	if len(movie.Thumbs) != 1 || movie.Thumbs[0].URL != "http://example.com/poster.jpg" || len(movie.Genres) != 1 || len(movie.Actors) != 2 || movie.Actors[1].Name != "John Doe" || movie.Actors[1].Order != 1 {
+		t.Errorf("wrong movie NFO:\n%s", data)
+	}
+
+	var episode strings.Builder
+
+	err = WriteNFO(&episode, Video{Title: "Pilot", Series: "The Show", Season: 1, Episode: 2, PubDate: video.PubDate})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(episode.String(), "<episodedetails>") || !strings.Contains(episode.String(), "<showtitle>The Show</showtitle>") || !strings.Contains(episode.String(), "<aired>2020-05-17</aired>") {
+		t.Errorf("wrong episode NFO:\n%s", episode.String())
+	}
+}
@@ -1,28 +1,35 @@
 package main
 
 import (
+	"context"
 	"embed"
 	"flag"
+	"fmt"
+	"github.com/jempe/mpc/artwork"
 	"github.com/jempe/mpc/auth"
+	"github.com/jempe/mpc/config"
+	"github.com/jempe/mpc/dlna"
 	"github.com/jempe/mpc/library"
 	"github.com/jempe/mpc/remote"
+	"github.com/jempe/mpc/scraper"
 	"github.com/jempe/mpc/server"
 	"github.com/jempe/mpc/storage"
+	"github.com/jempe/mpc/usage"
 	"github.com/jempe/mpc/users"
 	"github.com/jempe/mpc/utils"
 	"html/template"
 	"log"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 )
 
-var libraryPath = flag.String("path", "", "Define the path of your videos folder")
-var mpcConfigPath = flag.String("config", "", "Define the path of config folder")
 var storage *mpcstorage.Storage
-var port = "3000"
 var libPath string
 var library *mpclibrary.Library
 var indexTemplate *template.Template
-var key string = "ThisisAT3stKey123"
 
 //go:embed tmpl/index.html
 //go:embed html/js/* html/fonts/* html/css/* html/images/*
@@ -34,19 +41,39 @@ type IndexPage struct {
 }
 
 func main() {
+	// mpc config print shows the effective settings and where they came from
+	if len(os.Args) > 2 && os.Args[1] == "config" && os.Args[2] == "print" {
+		printConfig(os.Args[3:])
+		return
+	}
+
+	config := mpcconfig.New()
+	config.Flags(flag.CommandLine)
 	flag.Parse()
 
-	var configPath string
-	if *mpcConfigPath == "" {
-		configPath = mpcutils.ConfigFolder()
-	} else {
-		configPath = *mpcConfigPath
+	// the invalid settings stop the server before it opens the DB
+	err := config.Load()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	warnings, err := config.Validate()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	for _, warning := range warnings {
+		log.Println("config:", warning)
 	}
 
+	config.UseTools()
+
+	configPath := config.ConfigPath
+
 	// Initialize BoltDB
 	storage = &mpcstorage.Storage{Path: configPath}
 
-	err := storage.InitDb()
+	err = storage.InitDb()
 	mpcutils.CheckErr(err)
 
 	// Load all videos
@@ -55,12 +82,12 @@ func main() {
 	settings, err := storage.GetSettings()
 	mpcutils.CheckErr(err)
 
-	if settings.LibraryPath == "" && *libraryPath != "" {
-		settings.LibraryPath = *libraryPath
+	if settings.LibraryPath == "" && config.LibraryPath != "" {
+		settings.LibraryPath = config.LibraryPath
 		err = storage.SaveSettings(settings)
 		mpcutils.CheckErr(err)
 
-		settings.LibraryPath = *libraryPath
+		settings.LibraryPath = config.LibraryPath
 	}
 
 	if settings.HMACkey == nil {
@@ -80,18 +107,35 @@ func main() {
 	library = &mpclibrary.Library{Path: settings.LibraryPath, Settings: settings}
 
 	//Init auth library
-	auth := &mpcauth.Auth{Key: settings.HMACkey, Storage: storage}
+	auth := &mpcauth.Auth{Key: settings.HMACkey, Storage: storage, MediaKeyGeneration: settings.MediaKeyGeneration}
 
-	id, err := storage.InsertUser(mpcusers.User{Name: "Admin", Email: "test@jempe.org", Password: "test1234"})
+	id, err := storage.InsertUser(mpcusers.User{Name: config.AdminName, Email: config.AdminEmail, Password: config.AdminPassword, Role: mpcusers.RoleAdmin})
 	log.Println("uuid:", id)
 
 	// load and parse index page template
 	//paths := []string{"tmpl/index.html"}
 	indexTemplate = template.Must(template.ParseFS(content, "tmpl/index.html"))
 
+	// the JSON scrapers are optional, they are described in scrapers.json of the config folder
+	if scrapersConfig := configPath + "/scrapers.json"; mpcutils.Exists(scrapersConfig) {
+		names, err := mpcscraper.LoadJSONScrapers(scrapersConfig)
+		mpcutils.CheckErr(err)
+		log.Println("scrapers:", names)
+	}
+
 	localIP := mpcutils.GetLocalIP()
 
-	server := &mpcserver.Server{IP: localIP, Storage: storage, Library: library, Key: key, Auth: auth}
+	server := &mpcserver.Server{IP: localIP, Storage: storage, Library: library, Key: config.Key, Auth: auth, BaseURL: config.URL(localIP)}
+	server.Artwork = &mpcartwork.Store{Path: configPath + "/artwork"}
+	server.ImageCache = &mpcartwork.Cache{Path: configPath + "/cache", Key: []byte(config.Key)}
+	server.SessionTTL = config.SessionTTL
+	server.Workers = config.Workers
+
+	// the cleanup policy is optional, it's described in cleanup.json of the config folder
+	if cleanupConfig := configPath + "/cleanup.json"; mpcutils.Exists(cleanupConfig) {
+		server.CleanupPolicy, err = mpcusage.LoadPolicy(cleanupConfig)
+		mpcutils.CheckErr(err)
+	}
 
 	http.HandleFunc("/", homeHandler)
 	http.Handle("/html/", http.FileServer(http.FS(content)))
@@ -99,24 +143,147 @@ func main() {
 	//http.Handle("/css/", http.StripPrefix("/css/", http.FileServer(http.FS(content))))
 	//http.Handle("/images/", http.StripPrefix("/images/", http.FileServer(http.FS(content))))
 	http.HandleFunc("/actors.json", server.ActorsHandler)
+	http.HandleFunc("/actors/", server.ActorHandler)
+	http.HandleFunc("/categories.json", server.CategoriesHandler)
+	http.HandleFunc("/categories/", server.CategoryHandler)
+	http.HandleFunc("/tags.json", server.TagsHandler)
+	http.HandleFunc("/tags/", server.TagHandler)
+	http.HandleFunc("/playlists.json", server.PlaylistsHandler)
+	http.HandleFunc("/playlists/", server.PlaylistHandler)
+	http.HandleFunc("/collections.json", server.CollectionsHandler)
+	http.HandleFunc("/collections/", server.CollectionHandler)
+	http.HandleFunc("/smart.json", server.SmartPlaylistsHandler)
+	http.HandleFunc("/smart/", server.SmartPlaylistHandler)
+	http.HandleFunc("/home.json", server.HomeHandler)
+	http.HandleFunc("/series.json", server.SeriesHandler)
+	http.HandleFunc("/series/", server.SeriesItemHandler)
 	http.HandleFunc("/videos.json", server.VideosHandler)
+	http.HandleFunc("/videos.m3u8", server.ExportHandler)
+	http.HandleFunc("/videos.xspf", server.ExportHandler)
 	http.HandleFunc("/videos/", server.VideoFileHandler)
 	http.HandleFunc("/scan/", server.ScanHandler)
+	http.HandleFunc("/bulk", server.BulkHandler)
+	http.HandleFunc("/bulk/", server.BulkJobHandler)
 	http.HandleFunc("/videos/thumbs/", server.ThumbsHandler)
 	http.HandleFunc("/videos/screenshots/", server.ScreenshotsHandler)
+	http.HandleFunc("/media/sign", server.MediaSignHandler)
+	http.HandleFunc("/media/rotate", server.MediaKeyHandler)
+	http.HandleFunc("/metadata/", server.MetadataHandler)
+	http.HandleFunc("/duplicates", server.DuplicatesHandler)
+	http.HandleFunc("/duplicates/", server.DuplicatesHandler)
+	http.HandleFunc("/fsck", server.FsckHandler)
+	http.HandleFunc("/fsck/", server.FsckHandler)
+	http.HandleFunc("/usage", server.UsageHandler)
+	http.HandleFunc("/usage/", server.UsageHandler)
 
 	http.Handle("/admin/", http.StripPrefix("/admin/", http.FileServer(http.Dir("html/admin"))))
 	http.HandleFunc("/login", server.LoginHandler)
 	http.HandleFunc("/isloggedin", server.IsLoggedIn)
 
 	remote := mpcremote.NewRemote()
+	remote.AllowedOrigins = config.AllowedOrigins
+	remote.Authenticate = server.RemoteIdentity
+	remote.Queues = storage
+
+	server.ConnectPlayer = remote.ConnectLocal
 
 	http.Handle("/remote", remote)
+	http.HandleFunc("/remote/players", remote.PlayersHandler)
+	http.HandleFunc("/remote/devices", server.RemoteDevicesHandler)
+	http.HandleFunc("/remote/devices/", server.RemoteDevicesHandler)
+	http.HandleFunc("/remote/invites", server.RemoteInvitesHandler)
+	http.HandleFunc("/remote/metrics", remote.MetricsHandler)
+	http.HandleFunc("/cast/", server.CastHandler)
 
 	go remote.Run()
 
-	log.Println("MPC server running on", "http://"+localIP+":"+port)
-	panic(http.ListenAndServe(":"+port, nil))
+	cleanupStop := make(chan struct{})
+
+	if server.CleanupPolicy.Enabled() {
+		go server.RunCleanup(6*time.Hour, cleanupStop)
+	}
+
+	var ssdp *mpcdlna.SSDP
+
+	if config.DLNA {
+		if config.TLS() {
+			log.Println("DLNA: the TVs load the videos from", server.BaseURL, "and usually reject its certificate, DLNA needs the server without TLS")
+		}
+
+		mediaServer := mpcdlna.NewMediaServer(storage, server.BaseURL, settings.LibraryPath)
+		mediaServer.Sign = server.DLNASignature
+
+		http.Handle("/dlna/", mediaServer)
+
+		ssdp, err = mpcdlna.NewSSDP(localIP, mediaServer.Location(), mediaServer.UUID)
+		mpcutils.CheckErr(err)
+
+		go func() {
+			err := ssdp.Serve()
+			if err != nil {
+				log.Println("SSDP:", err)
+			}
+		}()
+
+		log.Println("DLNA media server:", mediaServer.Name)
+	}
+
+	httpServer := &http.Server{Addr: config.ListenAddress()}
+
+	// the websocket connections are not closed by the http server
+	httpServer.RegisterOnShutdown(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		err := remote.Shutdown(ctx)
+		if err != nil {
+			log.Println("Remote shutdown:", err)
+		}
+	})
+
+	httpServer.RegisterOnShutdown(func() {
+		close(cleanupStop)
+	})
+
+	go func() {
+		stop := make(chan os.Signal, 1)
+		signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+		<-stop
+
+		log.Println("MPC server stopping")
+
+		// the TVs remove the media server when it says goodbye
+		if ssdp != nil {
+			ssdp.Close()
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		err := httpServer.Shutdown(ctx)
+		if err != nil {
+			log.Println("Server shutdown:", err)
+		}
+	}()
+
+	log.Println("MPC server running on", server.BaseURL)
+
+	if config.TLS() {
+		err = httpServer.ListenAndServeTLS(config.TLSCert, config.TLSKey)
+	} else {
+		err = httpServer.ListenAndServe()
+	}
+	if err != http.ErrServerClosed {
+		panic(err)
+	}
+
+	// wait for the remote connections to close
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	remote.Shutdown(ctx)
+
+	storage.Db.Close()
 }
 
 // Handle index page server requests
@@ -124,3 +291,33 @@ func main() {
 func homeHandler(w http.ResponseWriter, r *http.Request) {
 	indexTemplate.Execute(w, nil)
 }
+
+// printConfig shows every setting with its effective value and its source, the problems of the settings are shown
+// after them
+//
+func printConfig(args []string) {
+	config := mpcconfig.New()
+
+	flags := flag.NewFlagSet("config print", flag.ExitOnError)
+	config.Flags(flags)
+	flags.Parse(args)
+
+	err := config.Load()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	config.Print(os.Stdout)
+
+	warnings, err := config.Validate()
+
+	for _, warning := range warnings {
+		fmt.Println("warning:", warning)
+	}
+
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,71 @@
+package mpcremote
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Identity is the user or paired device that opened a remote connection
+type Identity struct {
+	User       string   `json:"user"`
+	Device     string   `json:"device,omitempty"`
+	Role       string   `json:"role"`
+	Admin      bool     `json:"admin"`
+	Restricted bool     `json:"restricted"`
+	Players    []string `json:"players,omitempty"`
+}
+
+// CanControl checks if the identity can control the player, restricted identities can only control the listed players
+//
+func (identity Identity) CanControl(playerID string) bool {
+	if !identity.Restricted {
+		return true
+	}
+
+	for _, player := range identity.Players {
+		if player == playerID {
+			return true
+		}
+	}
+
+	return false
+}
+
+// authenticate gets the identity of the request, connections are rejected when there is no authenticator
+//
+func (r *remote) authenticate(req *http.Request) (Identity, error) {
+	if r.Authenticate == nil {
+		return Identity{}, errors.New("remote_auth_not_configured")
+	}
+
+	return r.Authenticate(req)
+}
+
+// checkOrigin accepts requests from the same host or from the allowed origins.
+// Requests without origin don't come from browsers and are accepted because they are already authenticated
+//
+func (r *remote) checkOrigin(req *http.Request) bool {
+	origin := req.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+
+	originURL, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+
+	if strings.EqualFold(originURL.Host, req.Host) {
+		return true
+	}
+
+	for _, allowed := range r.AllowedOrigins {
+		if strings.EqualFold(strings.TrimSuffix(allowed, "/"), origin) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,99 @@
+package mpcremote
+
+import (
+	"github.com/gorilla/websocket"
+	"time"
+)
+
+// conn is the part of the websocket connection used by the clients, the tests use fake connections
+type conn interface {
+	ReadMessage() (messageType int, p []byte, err error)
+	WriteMessage(messageType int, data []byte) error
+	WriteControl(messageType int, data []byte, deadline time.Time) error
+	SetReadLimit(limit int64)
+	SetReadDeadline(t time.Time) error
+	SetWriteDeadline(t time.Time) error
+	SetPongHandler(h func(appData string) error)
+	Close() error
+}
+
+type client struct {
+	socket   conn
+	send     chan []byte
+	remote   *remote
+	isPlayer bool
+	remoteId string
+	playerId string
+	name     string
+	code     string
+	target   string
+	state    *PlayerState
+	party    string
+	identity Identity
+	joined   time.Time
+}
+
+type message struct {
+	msg    []byte
+	sender *client
+}
+
+// read forwards the messages of the client to the remote, the connection is closed when the client
+// doesn't answer the pings or sends messages bigger than the limit
+//
+func (c *client) read() {
+	defer c.socket.Close()
+
+	c.socket.SetReadLimit(c.remote.maxMessageSize)
+	c.socket.SetReadDeadline(time.Now().Add(c.remote.pongWait))
+	c.socket.SetPongHandler(func(string) error {
+		return c.socket.SetReadDeadline(time.Now().Add(c.remote.pongWait))
+	})
+
+	for {
+		_, msg, err := c.socket.ReadMessage()
+		if err != nil {
+			return
+		}
+
+		select {
+		case c.remote.forward <- message{msg: msg, sender: c}:
+		case <-c.remote.done:
+			return
+		}
+	}
+}
+
+// write sends the queued messages and the pings to the client,
+// the connection is closed when the send channel is closed or a write fails
+//
+func (c *client) write() {
+	ticker := time.NewTicker(c.remote.pingPeriod)
+
+	defer func() {
+		ticker.Stop()
+		c.socket.Close()
+	}()
+
+	for {
+		select {
+		case msg, ok := <-c.send:
+			if !ok {
+				c.socket.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseGoingAway, ""), time.Now().Add(c.remote.writeWait))
+				return
+			}
+
+			c.socket.SetWriteDeadline(time.Now().Add(c.remote.writeWait))
+
+			err := c.socket.WriteMessage(websocket.TextMessage, msg)
+			if err != nil {
+				return
+			}
+		case <-ticker.C:
+			err := c.socket.WriteControl(websocket.PingMessage, nil, time.Now().Add(c.remote.writeWait))
+			if err != nil {
+				return
+			}
+		}
+	}
+}
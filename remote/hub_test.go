@@ -0,0 +1,246 @@
+package mpcremote
+
+import (
+	"context"
+	"errors"
+	"github.com/gorilla/websocket"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fakeConn is an in memory connection, slow connections block their writes until the write deadline
+type fakeConn struct {
+	in     chan []byte
+	out    chan []byte
+	closed chan struct{}
+	slow   bool
+
+	closeOnce     sync.Once
+	mutex         sync.Mutex
+	writeDeadline time.Time
+	readLimit     int64
+	pings         int32
+	closeFrames   int32
+}
+
+func newFakeConn(slow bool) *fakeConn {
+	return &fakeConn{in: make(chan []byte), out: make(chan []byte, 1024), closed: make(chan struct{}), slow: slow}
+}
+
+func (c *fakeConn) ReadMessage() (int, []byte, error) {
+	select {
+	case msg := <-c.in:
+		c.mutex.Lock()
+		limit := c.readLimit
+		c.mutex.Unlock()
+
+		if limit > 0 && int64(len(msg)) > limit {
+			c.Close()
+			return 0, nil, websocket.ErrReadLimit
+		}
+
+		return websocket.TextMessage, msg, nil
+	case <-c.closed:
+		return 0, nil, errors.New("connection closed")
+	}
+}
+
+func (c *fakeConn) WriteMessage(messageType int, data []byte) error {
+	if c.slow {
+		c.mutex.Lock()
+		deadline := c.writeDeadline
+		c.mutex.Unlock()
+
+		select {
+		case <-time.After(time.Until(deadline)):
+			return errors.New("write timeout")
+		case <-c.closed:
+			return errors.New("connection closed")
+		}
+	}
+
+	select {
+	case c.out <- data:
+		return nil
+	case <-c.closed:
+		return errors.New("connection closed")
+	}
+}
+
+func (c *fakeConn) WriteControl(messageType int, data []byte, deadline time.Time) error {
+	switch messageType {
+	case websocket.PingMessage:
+		atomic.AddInt32(&c.pings, 1)
+	case websocket.CloseMessage:
+		atomic.AddInt32(&c.closeFrames, 1)
+	}
+
+	return nil
+}
+
+func (c *fakeConn) SetReadLimit(limit int64) {
+	c.mutex.Lock()
+	c.readLimit = limit
+	c.mutex.Unlock()
+}
+
+func (c *fakeConn) SetReadDeadline(t time.Time) error {
+	return nil
+}
+
+func (c *fakeConn) SetWriteDeadline(t time.Time) error {
+	c.mutex.Lock()
+	c.writeDeadline = t
+	c.mutex.Unlock()
+
+	return nil
+}
+
+func (c *fakeConn) SetPongHandler(h func(appData string) error) {}
+
+func (c *fakeConn) Close() error {
+	c.closeOnce.Do(func() { close(c.closed) })
+	return nil
+}
+
+func (c *fakeConn) isClosed() bool {
+	select {
+	case <-c.closed:
+		return true
+	default:
+		return false
+	}
+}
+
+// newFastRemote creates a remote with short connection times for the tests
+//
+func newFastRemote() *remote {
+	hub := NewRemote()
+	hub.writeWait = 50 * time.Millisecond
+	hub.pingPeriod = 20 * time.Millisecond
+
+	return hub
+}
+
+// waitFor checks the condition until it's true or the time is over
+//
+func waitFor(t *testing.T, description string, condition func() bool) {
+	deadline := time.Now().Add(2 * time.Second)
+
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatal("timeout waiting for", description)
+		}
+
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestSlowClientsAreDisconnected(t *testing.T) {
+	hub := newFastRemote()
+	go hub.Run()
+	defer hub.Shutdown(context.Background())
+
+	admin := Identity{User: "admin", Role: "admin", Admin: true}
+
+	player := newFakeConn(false)
+	go hub.serve(player, admin, "tv1", "", "TV")
+
+	slow := newFakeConn(true)
+	go hub.serve(slow, admin, "", "slow-phone", "")
+
+	fast := newFakeConn(false)
+	go hub.serve(fast, admin, "", "fast-phone", "")
+
+	waitFor(t, "clients", func() bool { return hub.GetMetrics().Clients == 3 })
+
+	slow.in <- []byte(`{"v":1,"type":"pair","playerId":"tv1"}`)
+	fast.in <- []byte(`{"v":1,"type":"pair","playerId":"tv1"}`)
+
+	for i := 0; i < messageBufferSize+10; i++ {
+		player.in <- []byte(`{"v":1,"type":"state","state":{"video":"abc","position":1,"volume":1}}`)
+	}
+
+	waitFor(t, "slow client disconnection", func() bool { return slow.isClosed() })
+
+	metrics := hub.GetMetrics()
+	if metrics.Dropped == 0 || metrics.Disconnected != 1 || metrics.Clients != 2 {
+		t.Error("slow client should be dropped and disconnected", metrics)
+	}
+
+	if fast.isClosed() || player.isClosed() {
+		t.Error("other clients should stay connected")
+	}
+
+	player.in <- []byte(`{"v":1,"type":"state","state":{"video":"xyz","position":2,"volume":1}}`)
+
+	waitFor(t, "message to the fast client", func() bool {
+		for {
+			select {
+			case msg := <-fast.out:
+				if string(msg) == `{"v":1,"type":"state","playerId":"tv1","state":{"video":"xyz","position":2,"duration":0,"paused":false,"volume":1}}` {
+					return true
+				}
+			default:
+				return false
+			}
+		}
+	})
+}
+
+func TestPingsAndReadLimit(t *testing.T) {
+	hub := newFastRemote()
+	go hub.Run()
+	defer hub.Shutdown(context.Background())
+
+	phone := newFakeConn(false)
+	go hub.serve(phone, Identity{User: "user"}, "", "phone", "")
+
+	waitFor(t, "pings", func() bool { return atomic.LoadInt32(&phone.pings) >= 2 })
+
+	phone.in <- make([]byte, hub.maxMessageSize+1)
+
+	waitFor(t, "disconnection after big message", func() bool { return hub.GetMetrics().Clients == 0 })
+}
+
+func TestShutdownClosesClients(t *testing.T) {
+	hub := NewRemote()
+	go hub.Run()
+
+	player := newFakeConn(false)
+	go hub.serve(player, Identity{User: "user"}, "tv1", "", "TV")
+
+	phone := newFakeConn(false)
+	go hub.serve(phone, Identity{User: "user"}, "", "phone", "")
+
+	waitFor(t, "clients", func() bool { return hub.GetMetrics().Clients == 2 })
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	err := hub.Shutdown(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !player.isClosed() || !phone.isClosed() {
+		t.Error("connections should be closed after the shutdown")
+	}
+
+	if atomic.LoadInt32(&player.closeFrames) != 1 {
+		t.Error("clients should get a close message")
+	}
+
+	late := newFakeConn(false)
+	hub.serve(late, Identity{User: "user"}, "", "late", "")
+
+	if !late.isClosed() {
+		t.Error("new connections should be rejected after the shutdown")
+	}
+
+	if players := hub.Players(); len(players) != 0 {
+		t.Error("no players should be online after the shutdown", players)
+	}
+}
@@ -0,0 +1,118 @@
+package mpcremote
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/gorilla/websocket"
+	"io"
+	"sync"
+	"time"
+)
+
+// LocalConn connects a player or a remote that runs in the server, like a cast session, without a websocket.
+// The messages sent to it arrive in Messages and the messages it sends are routed like the messages of the websockets
+type LocalConn struct {
+	messages  chan Message
+	incoming  chan []byte
+	closed    chan struct{}
+	closeOnce sync.Once
+}
+
+// ConnectLocal connects a local client to the remote, it is a player when the remote ID is empty
+//
+func (r *remote) ConnectLocal(identity Identity, playerID string, remoteID string, name string) *LocalConn {
+	local := &LocalConn{
+		messages: make(chan Message, messageBufferSize),
+		incoming: make(chan []byte),
+		closed:   make(chan struct{}),
+	}
+
+	go r.serve(local, identity, playerID, remoteID, name)
+
+	return local
+}
+
+// Messages gets the messages that the remote sends to the client
+//
+func (local *LocalConn) Messages() <-chan Message {
+	return local.messages
+}
+
+// Done is closed when the connection is closed by the client or by the remote
+//
+func (local *LocalConn) Done() <-chan struct{} {
+	return local.closed
+}
+
+// Send sends a message of the client to the remote
+//
+func (local *LocalConn) Send(msg Message) error {
+	msg.Version = ProtocolVersion
+
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+
+	select {
+	case local.incoming <- data:
+		return nil
+	case <-local.closed:
+		return errors.New("connection_closed")
+	}
+}
+
+// Close disconnects the client from the remote
+//
+func (local *LocalConn) Close() error {
+	local.closeOnce.Do(func() {
+		close(local.closed)
+	})
+
+	return nil
+}
+
+// ReadMessage waits for the next message of the client
+//
+func (local *LocalConn) ReadMessage() (messageType int, p []byte, err error) {
+	select {
+	case data := <-local.incoming:
+		return websocket.TextMessage, data, nil
+	case <-local.closed:
+		return 0, nil, io.EOF
+	}
+}
+
+// WriteMessage decodes a message of the remote for the client
+//
+func (local *LocalConn) WriteMessage(messageType int, data []byte) error {
+	var msg Message
+
+	err := json.Unmarshal(data, &msg)
+	if err != nil {
+		return err
+	}
+
+	select {
+	case local.messages <- msg:
+		return nil
+	case <-local.closed:
+		return io.EOF
+	}
+}
+
+// WriteControl closes the connection when the remote disconnects the client, the pings are not needed
+//
+func (local *LocalConn) WriteControl(messageType int, data []byte, deadline time.Time) error {
+	if messageType == websocket.CloseMessage {
+		local.Close()
+	}
+
+	return nil
+}
+
+// the local connections don't have limits or timeouts
+func (local *LocalConn) SetReadLimit(limit int64)                    {}
+func (local *LocalConn) SetReadDeadline(t time.Time) error           { return nil }
+func (local *LocalConn) SetWriteDeadline(t time.Time) error          { return nil }
+func (local *LocalConn) SetPongHandler(h func(appData string) error) {}
@@ -0,0 +1,176 @@
+package mpcremote
+
+import (
+	"log"
+	"time"
+)
+
+// Party is a group of players that watch the same video, the followers copy the playback of the host
+type Party struct {
+	ID      string   `json:"id"`
+	Host    string   `json:"host"`
+	Members []string `json:"members"`
+	// State is the last state of the host and StateTime the server time in milliseconds when it was received
+	State     *PlayerState `json:"state,omitempty"`
+	StateTime int64        `json:"stateTime,omitempty"`
+}
+
+// serverTime gets the current server time in milliseconds
+//
+func serverTime() int64 {
+	return time.Now().UnixNano() / int64(time.Millisecond)
+}
+
+// partyPlayer gets the player that the party message is for, remotes act on their paired player
+//
+func (r *remote) partyPlayer(sender *client) (*client, bool) {
+	if sender.isPlayer {
+		return sender, true
+	}
+
+	player, ok := r.players[sender.target]
+	if !ok || !sender.identity.CanControl(player.playerId) {
+		r.sendMessage(sender, Message{Type: TypeError, Error: "remote_not_paired"})
+		return nil, false
+	}
+
+	return player, true
+}
+
+// handleParty creates, joins or leaves a watch party
+//
+func (r *remote) handleParty(sender *client, msg Message) {
+	player, ok := r.partyPlayer(sender)
+	if !ok {
+		return
+	}
+
+	switch msg.Type {
+	case TypePartyCreate:
+		r.leaveParty(player)
+
+		party := &Party{ID: r.newPartyID(), Host: player.playerId, Members: []string{player.playerId}, State: player.state, StateTime: serverTime()}
+		r.parties[party.ID] = party
+		player.party = party.ID
+
+		log.Println("Party created:", party.ID, "Host:", player.playerId)
+		r.notifyParty(party)
+	case TypePartyJoin:
+		party, ok := r.parties[msg.PartyID]
+		if !ok {
+			r.sendMessage(sender, Message{Type: TypeError, Error: "party_not_exists"})
+			return
+		}
+
+		if player.party != party.ID {
+			r.leaveParty(player)
+
+			party.Members = append(party.Members, player.playerId)
+			player.party = party.ID
+		}
+
+		r.notifyParty(party)
+
+		// the new member starts at the current position of the host
+		if party.State != nil && party.Host != player.playerId {
+			r.sendMessage(player, Message{Type: TypeSync, PartyID: party.ID, State: party.State, ServerTime: party.StateTime})
+		}
+	case TypePartyLeave:
+		if player.party == "" {
+			r.sendMessage(sender, Message{Type: TypeError, Error: "party_not_member"})
+			return
+		}
+
+		r.leaveParty(player)
+		r.sendMessage(player, Message{Type: TypeParty})
+	}
+}
+
+// syncParty saves the state of the host with the server time and sends it to the other members of the party
+//
+func (r *remote) syncParty(player *client) {
+	party, ok := r.parties[player.party]
+	if !ok || party.Host != player.playerId {
+		return
+	}
+
+	party.State = player.state
+	party.StateTime = serverTime()
+
+	for _, member := range party.Members {
+		if client, ok := r.players[member]; ok && member != party.Host {
+			r.sendMessage(client, Message{Type: TypeSync, PartyID: party.ID, State: party.State, ServerTime: party.StateTime})
+		}
+	}
+}
+
+// leaveParty removes the player from its party, the member that joined first after the host becomes the new host
+// and the party is deleted when it is empty
+//
+func (r *remote) leaveParty(player *client) {
+	party, ok := r.parties[player.party]
+	player.party = ""
+
+	if !ok {
+		return
+	}
+
+	var members []string
+
+	for _, member := range party.Members {
+		if member != player.playerId {
+			members = append(members, member)
+		}
+	}
+
+	party.Members = members
+
+	if len(party.Members) == 0 {
+		delete(r.parties, party.ID)
+		log.Println("Party closed:", party.ID)
+		return
+	}
+
+	if party.Host == player.playerId {
+		party.Host = party.Members[0]
+		log.Println("Party host changed:", party.ID, "Host:", party.Host)
+
+		// the last known position of the old host is kept until the new host reports its state
+		if newHost, ok := r.players[party.Host]; ok && newHost.state != nil {
+			party.State = newHost.state
+			party.StateTime = serverTime()
+		}
+	}
+
+	r.notifyParty(party)
+}
+
+// notifyParty sends the members and host of the party to its members and to the remotes paired with them
+//
+func (r *remote) notifyParty(party *Party) {
+	for client := range r.clients {
+		member := client.playerId
+		if !client.isPlayer {
+			member = client.target
+		}
+
+		for _, partyMember := range party.Members {
+			if partyMember == member {
+				r.sendMessage(client, Message{Type: TypeParty, Party: party, ServerTime: serverTime()})
+				break
+			}
+		}
+	}
+}
+
+// newPartyID generates a party ID that is easy to share and is not used by other party
+//
+func (r *remote) newPartyID() string {
+	for {
+		id := randomCode()
+
+		if _, used := r.parties[id]; !used {
+			return id
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package mpcremote
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+// nextMessage reads the messages of the fake connection until it gets one of the type
+//
+func nextMessage(t *testing.T, conn *fakeConn, messageType string) (msg Message) {
+	timeout := time.After(2 * time.Second)
+
+	for {
+		select {
+		case data := <-conn.out:
+			err := json.Unmarshal(data, &msg)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if msg.Type == messageType {
+				return
+			}
+		case <-timeout:
+			t.Fatal("timeout waiting for message", messageType)
+		}
+	}
+}
+
+func TestWatchParty(t *testing.T) {
+	hub := NewRemote()
+	go hub.Run()
+	defer hub.Shutdown(context.Background())
+
+	user := Identity{User: "user"}
+
+	host := newFakeConn(false)
+	go hub.serve(host, user, "tv1", "", "Living Room")
+	nextMessage(t, host, TypeWelcome)
+
+	host.in <- []byte(`{"v":1,"type":"partyCreate"}`)
+	party := nextMessage(t, host, TypeParty).Party
+
+	if party == nil || party.Host != "tv1" || len(party.Members) != 1 {
+		t.Fatal("player should be the host of the new party", party)
+	}
+
+	host.in <- []byte(`{"v":1,"type":"state","state":{"video":"abc","position":120,"volume":1}}`)
+
+	follower := newFakeConn(false)
+	go hub.serve(follower, user, "tv2", "", "Bedroom")
+	nextMessage(t, follower, TypeWelcome)
+
+	follower.in <- []byte(`{"v":1,"type":"partyJoin","partyId":"` + party.ID + `"}`)
+
+	if joined := nextMessage(t, host, TypeParty).Party; len(joined.Members) != 2 {
+		t.Error("host should see the new member", joined)
+	}
+
+	sync := nextMessage(t, follower, TypeSync)
+	if sync.State == nil || sync.State.Video != "abc" || sync.State.Position != 120 || sync.ServerTime == 0 {
+		t.Error("new members should start at the position of the host", sync)
+	}
+
+	host.in <- []byte(`{"v":1,"type":"state","state":{"video":"abc","position":130,"paused":true,"volume":1}}`)
+
+	if sync := nextMessage(t, follower, TypeSync); sync.State.Position != 130 || !sync.State.Paused {
+		t.Error("host events should be sent to the followers", sync)
+	}
+
+	follower.in <- []byte(`{"v":1,"type":"timeSync","clientTime":1000}`)
+
+	if reply := nextMessage(t, follower, TypeTimeSync); reply.ClientTime != 1000 || reply.ServerTime == 0 {
+		t.Error("time sync should return the client and server times", reply)
+	}
+
+	host.Close()
+
+	if handoff := nextMessage(t, follower, TypeParty).Party; handoff.Host != "tv2" || len(handoff.Members) != 1 {
+		t.Error("host status should go to the remaining member", handoff)
+	}
+}
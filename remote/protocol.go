@@ -0,0 +1,221 @@
+package mpcremote
+
+import (
+	"github.com/jempe/mpc/storage"
+	"encoding/json"
+	"errors"
+)
+
+// ProtocolVersion is the version of the remote control messages, messages with other versions are rejected
+const ProtocolVersion = 1
+
+// message types sent by the remotes to the players
+const (
+	TypePlay      = "play"
+	TypePause     = "pause"
+	TypeSeek      = "seek"
+	TypeVolume    = "volume"
+	TypeNext      = "next"
+	TypePrevious  = "previous"
+	TypePlayVideo = "playVideo"
+	TypeQueue     = "queue"
+	TypeSubtitle  = "subtitle"
+	TypeAudio     = "audio"
+	TypeFilter    = "filter"
+)
+
+// message types handled by the server
+const (
+	TypePair        = "pair"
+	TypeUnpair      = "unpair"
+	TypeGetState    = "getState"
+	TypeState       = "state"
+	TypePartyCreate = "partyCreate"
+	TypePartyJoin   = "partyJoin"
+	TypePartyLeave  = "partyLeave"
+	TypeTimeSync    = "timeSync"
+	TypeEnded       = "ended"
+	TypeGetQueue    = "getQueue"
+)
+
+// message types sent by the server
+const (
+	TypeWelcome    = "welcome"
+	TypePaired     = "paired"
+	TypeUnpaired   = "unpaired"
+	TypePresence   = "presence"
+	TypeParty      = "party"
+	TypeSync       = "sync"
+	TypeQueueState = "queueState"
+	TypeError      = "error"
+)
+
+// queue operations
+const (
+	QueueAdd      = "add"
+	QueuePlayNext = "playNext"
+	QueueRemove   = "remove"
+	QueueMove     = "move"
+	QueueClear    = "clear"
+	QueueShuffle  = "shuffle"
+	QueueRepeat   = "repeat"
+	QueuePlay     = "play"
+)
+
+// Message is a remote control message, the fields that are used depend on the type
+type Message struct {
+	Version  int             `json:"v"`
+	Type     string          `json:"type"`
+	From     string          `json:"from,omitempty"`
+	Player   *Player         `json:"player,omitempty"`
+	PlayerID string          `json:"playerId,omitempty"`
+	Code     string          `json:"code,omitempty"`
+	Online   bool            `json:"online,omitempty"`
+	Video    string          `json:"video,omitempty"`
+	Position *float64        `json:"position,omitempty"`
+	Volume   *float64        `json:"volume,omitempty"`
+	Track    *int            `json:"track,omitempty"`
+	Queue    *QueueChange    `json:"queue,omitempty"`
+	Filter   json.RawMessage `json:"filter,omitempty"`
+	State    *PlayerState    `json:"state,omitempty"`
+	Party    *Party          `json:"party,omitempty"`
+	// QueueState is the queue of a player sent by the server
+	QueueState *mpcstorage.PlayerQueue `json:"queueState,omitempty"`
+	PartyID    string                  `json:"partyId,omitempty"`
+	// ServerTime and ClientTime are unix times in milliseconds used to estimate the clock offset
+	ServerTime int64  `json:"serverTime,omitempty"`
+	ClientTime int64  `json:"clientTime,omitempty"`
+	Error      string `json:"error,omitempty"`
+}
+
+// QueueChange is a change of the queue of a player
+type QueueChange struct {
+	Op     string   `json:"op"`
+	Videos []string `json:"videos,omitempty"`
+	Index  int      `json:"index,omitempty"`
+	To     int      `json:"to,omitempty"`
+	Repeat string   `json:"repeat,omitempty"`
+}
+
+// PlayerState is the playback state reported by a player
+type PlayerState struct {
+	Video    string  `json:"video"`
+	Position float64 `json:"position"`
+	Duration float64 `json:"duration"`
+	Paused   bool    `json:"paused"`
+	Volume   float64 `json:"volume"`
+}
+
+// ParseMessage decodes a message and checks that it is valid
+//
+func ParseMessage(data []byte) (msg Message, err error) {
+	err = json.Unmarshal(data, &msg)
+	if err != nil {
+		return msg, errors.New("message_malformed")
+	}
+
+	return msg, msg.Validate()
+}
+
+// Validate checks the version of the message and the fields that its type requires
+//
+func (msg Message) Validate() error {
+	if msg.Version != ProtocolVersion {
+		return errors.New("message_version_invalid")
+	}
+
+	switch msg.Type {
+	case TypePlay, TypePause, TypeNext, TypePrevious, TypeUnpair, TypeGetState, TypePartyCreate, TypePartyLeave, TypeTimeSync, TypeGetQueue:
+		return nil
+	case TypeEnded:
+		if msg.Video == "" {
+			return errors.New("video_empty")
+		}
+	case TypePartyJoin:
+		if msg.PartyID == "" {
+			return errors.New("party_empty")
+		}
+	case TypePair:
+		if msg.PlayerID == "" && msg.Code == "" {
+			return errors.New("pair_player_empty")
+		}
+	case TypeSeek:
+		if msg.Position == nil || *msg.Position < 0 {
+			return errors.New("seek_position_invalid")
+		}
+	case TypeVolume:
+		if msg.Volume == nil || *msg.Volume < 0 || *msg.Volume > 1 {
+			return errors.New("volume_invalid")
+		}
+	case TypePlayVideo:
+		if msg.Video == "" {
+			return errors.New("video_empty")
+		}
+	case TypeSubtitle, TypeAudio:
+		// track -1 disables the subtitles
+		if msg.Track == nil || *msg.Track < -1 || (msg.Type == TypeAudio && *msg.Track < 0) {
+			return errors.New("track_invalid")
+		}
+	case TypeFilter:
+		var filter map[string]interface{}
+
+		if json.Unmarshal(msg.Filter, &filter) != nil || filter == nil {
+			return errors.New("filter_invalid")
+		}
+	case TypeQueue:
+		return msg.Queue.validate()
+	case TypeState:
+		if msg.State == nil || msg.State.Position < 0 || msg.State.Volume < 0 || msg.State.Volume > 1 {
+			return errors.New("state_invalid")
+		}
+	default:
+		return errors.New("message_type_invalid")
+	}
+
+	return nil
+}
+
+// validate checks that the queue change has the fields required by its operation
+//
+func (change *QueueChange) validate() error {
+	if change == nil {
+		return errors.New("queue_invalid")
+	}
+
+	switch change.Op {
+	case QueueAdd, QueuePlayNext:
+		if len(change.Videos) == 0 {
+			return errors.New("queue_videos_empty")
+		}
+
+		for _, video := range change.Videos {
+			if video == "" {
+				return errors.New("video_empty")
+			}
+		}
+	case QueueRemove, QueueMove, QueuePlay:
+		if change.Index < 0 || change.To < 0 {
+			return errors.New("queue_index_invalid")
+		}
+	case QueueRepeat:
+		if change.Repeat != mpcstorage.RepeatOff && change.Repeat != mpcstorage.RepeatAll && change.Repeat != mpcstorage.RepeatOne {
+			return errors.New("queue_repeat_invalid")
+		}
+	case QueueClear, QueueShuffle:
+	default:
+		return errors.New("queue_op_invalid")
+	}
+
+	return nil
+}
+
+// isCommand checks if the message is a command that is forwarded from a remote to a player
+//
+func (msg Message) isCommand() bool {
+	switch msg.Type {
+	case TypePlay, TypePause, TypeSeek, TypeVolume, TypeNext, TypePrevious, TypePlayVideo, TypeSubtitle, TypeAudio, TypeFilter:
+		return true
+	}
+
+	return false
+}
@@ -0,0 +1,39 @@
+package mpcremote
+
+import (
+	"testing"
+)
+
+func TestParseMessage(t *testing.T) {
+	tests := []struct {
+		data string
+		err  string
+	}{
+		{`{"v":1,"type":"play"}`, ""},
+		{`{"v":1,"type":"seek","position":30.5}`, ""},
+		{`{"v":1,"type":"volume","volume":0.3}`, ""},
+		{`{"v":1,"type":"subtitle","track":-1}`, ""},
+		{`{"v":1,"type":"queue","queue":{"op":"add","videos":["abc"]}}`, ""},
+		{`{"v":1,"type":"filter","filter":{"title":"news"}}`, ""},
+		{`{"title":"news"}`, "message_version_invalid"},
+		{`{"v":2,"type":"play"}`, "message_version_invalid"},
+		{`{"v":1,"type":"rewind"}`, "message_type_invalid"},
+		{`{"v":1,"type":"seek"}`, "seek_position_invalid"},
+		{`{"v":1,"type":"volume","volume":2}`, "volume_invalid"},
+		{`{"v":1,"type":"audio","track":-1}`, "track_invalid"},
+		{`{"v":1,"type":"playVideo"}`, "video_empty"},
+		{`{"v":1,"type":"queue","queue":{"op":"shuffle"}}`, ""},
+		{`{"v":1,"type":"queue","queue":{"op":"repeat","repeat":"twice"}}`, "queue_repeat_invalid"},
+		{`{"v":1,"type":"queue","queue":{"op":"sort"}}`, "queue_op_invalid"},
+		{`{"v":1,"type":"filter","filter":"news"}`, "filter_invalid"},
+		{`not json`, "message_malformed"},
+	}
+
+	for _, test := range tests {
+		_, err := ParseMessage([]byte(test.data))
+
+		if (test.err == "" && err != nil) || (test.err != "" && (err == nil || err.Error() != test.err)) {
+			t.Errorf("ParseMessage(%s) error = %v; want %q", test.data, err, test.err)
+		}
+	}
+}
@@ -0,0 +1,242 @@
+package mpcremote
+
+import (
+	"github.com/jempe/mpc/storage"
+	"errors"
+	"log"
+	"math/rand"
+)
+
+// QueueStore saves the queues of the players so they survive reloads and restarts
+type QueueStore interface {
+	GetPlayerQueue(playerID string) (mpcstorage.PlayerQueue, error)
+	SavePlayerQueue(queue mpcstorage.PlayerQueue) error
+}
+
+// playerQueue gets the queue of the player from the cache or from the store
+//
+func (r *remote) playerQueue(playerID string) *mpcstorage.PlayerQueue {
+	if queue, ok := r.queues[playerID]; ok {
+		return queue
+	}
+
+	queue := mpcstorage.PlayerQueue{Player: playerID, Videos: []string{}, Current: -1, Repeat: mpcstorage.RepeatOff}
+
+	if r.Queues != nil {
+		storedQueue, err := r.Queues.GetPlayerQueue(playerID)
+		if err != nil {
+			log.Println("Queue:", err)
+		} else {
+			queue = storedQueue
+		}
+	}
+
+	r.queues[playerID] = &queue
+
+	return &queue
+}
+
+// saveQueue saves the queue and sends it to the player and the remotes paired with it
+//
+func (r *remote) saveQueue(queue *mpcstorage.PlayerQueue) {
+	if r.Queues != nil {
+		err := r.Queues.SavePlayerQueue(*queue)
+		if err != nil {
+			log.Println("Queue:", err)
+		}
+	}
+
+	r.sendQueue(queue)
+}
+
+// sendQueue sends the queue to the player and the remotes paired with it
+//
+func (r *remote) sendQueue(queue *mpcstorage.PlayerQueue) {
+	for client := range r.clients {
+		if (client.isPlayer && client.playerId == queue.Player) || (!client.isPlayer && client.target == queue.Player) {
+			r.sendMessage(client, Message{Type: TypeQueueState, PlayerID: queue.Player, QueueState: queue})
+		}
+	}
+}
+
+// handleQueue changes the queue of the player, remotes change the queue of their paired player
+//
+func (r *remote) handleQueue(sender *client, msg Message) {
+	playerID := sender.playerId
+	if !sender.isPlayer {
+		playerID = sender.target
+
+		if playerID == "" || !sender.identity.CanControl(playerID) {
+			r.sendMessage(sender, Message{Type: TypeError, Error: "remote_not_paired"})
+			return
+		}
+	}
+
+	queue := r.playerQueue(playerID)
+
+	play, err := applyQueueChange(queue, *msg.Queue, r.random)
+	if err != nil {
+		r.sendMessage(sender, Message{Type: TypeError, Error: err.Error()})
+		return
+	}
+
+	if play {
+		r.playQueueVideo(queue)
+	}
+
+	r.saveQueue(queue)
+}
+
+// sendQueueState sends the queue of a player to the client, the paired player is used when the ID is empty
+//
+func (r *remote) sendQueueState(sender *client, playerID string) {
+	if sender.isPlayer {
+		playerID = sender.playerId
+	} else if playerID == "" {
+		playerID = sender.target
+	}
+
+	if playerID == "" || !sender.identity.CanControl(playerID) {
+		r.sendMessage(sender, Message{Type: TypeError, Error: "player_not_exists"})
+		return
+	}
+
+	r.sendMessage(sender, Message{Type: TypeQueueState, PlayerID: playerID, QueueState: r.playerQueue(playerID)})
+}
+
+// videoEnded plays the next video of the queue when the video that ended is the current video of the queue
+//
+func (r *remote) videoEnded(player *client, videoID string) {
+	queue := r.playerQueue(player.playerId)
+
+	if queue.Current < 0 || queue.Current >= len(queue.Videos) || queue.Videos[queue.Current] != videoID {
+		return
+	}
+
+	if advanceQueue(queue, false) {
+		r.playQueueVideo(queue)
+		r.saveQueue(queue)
+	}
+}
+
+// skipQueue moves to the next or previous video of the queue of the player,
+// it returns false when the queue doesn't have a video in that direction
+//
+func (r *remote) skipQueue(playerID string, next bool) bool {
+	queue := r.playerQueue(playerID)
+
+	if next && !advanceQueue(queue, true) {
+		return false
+	}
+
+	if !next {
+		if queue.Current <= 0 {
+			return false
+		}
+
+		queue.Current--
+	}
+
+	r.playQueueVideo(queue)
+	r.saveQueue(queue)
+
+	return true
+}
+
+// playQueueVideo tells the player to play the current video of the queue
+//
+func (r *remote) playQueueVideo(queue *mpcstorage.PlayerQueue) {
+	player, ok := r.players[queue.Player]
+	if !ok || queue.Current < 0 || queue.Current >= len(queue.Videos) {
+		return
+	}
+
+	r.sendMessage(player, Message{Type: TypePlayVideo, Video: queue.Videos[queue.Current]})
+}
+
+// applyQueueChange changes the queue, it returns true when the current video changed and has to be played
+//
+func applyQueueChange(queue *mpcstorage.PlayerQueue, change QueueChange, random *rand.Rand) (play bool, err error) {
+	switch change.Op {
+	case QueueAdd:
+		queue.Videos = append(queue.Videos, change.Videos...)
+	case QueuePlayNext:
+		next := queue.Current + 1
+
+		videos := append([]string{}, queue.Videos[:next]...)
+		videos = append(videos, change.Videos...)
+		queue.Videos = append(videos, queue.Videos[next:]...)
+	case QueueRemove:
+		if change.Index >= len(queue.Videos) {
+			return false, errors.New("queue_index_invalid")
+		}
+
+		queue.Videos = append(queue.Videos[:change.Index:change.Index], queue.Videos[change.Index+1:]...)
+
+		// the video after the removed one plays next
+		if change.Index <= queue.Current {
+			queue.Current--
+		}
+	case QueueMove:
+		if change.Index >= len(queue.Videos) || change.To >= len(queue.Videos) {
+			return false, errors.New("queue_index_invalid")
+		}
+
+		video := queue.Videos[change.Index]
+
+		videos := append(queue.Videos[:change.Index:change.Index], queue.Videos[change.Index+1:]...)
+		videos = append(videos[:change.To:change.To], append([]string{video}, videos[change.To:]...)...)
+		queue.Videos = videos
+
+		// the current index follows the video that is playing
+		switch {
+		case change.Index == queue.Current:
+			queue.Current = change.To
+		case change.Index < queue.Current && change.To >= queue.Current:
+			queue.Current--
+		case change.Index > queue.Current && change.To <= queue.Current:
+			queue.Current++
+		}
+	case QueueClear:
+		queue.Videos = []string{}
+		queue.Current = -1
+	case QueueShuffle:
+		// only the videos that didn't play yet are shuffled
+		upNext := queue.Videos[queue.Current+1:]
+
+		random.Shuffle(len(upNext), func(i, j int) {
+			upNext[i], upNext[j] = upNext[j], upNext[i]
+		})
+	case QueueRepeat:
+		queue.Repeat = change.Repeat
+	case QueuePlay:
+		if change.Index >= len(queue.Videos) {
+			return false, errors.New("queue_index_invalid")
+		}
+
+		queue.Current = change.Index
+		play = true
+	}
+
+	return
+}
+
+// advanceQueue moves the queue to the next video according to the repeat mode, skipping ignores the repeat of one video.
+// It returns false when there are no more videos to play
+//
+func advanceQueue(queue *mpcstorage.PlayerQueue, skip bool) bool {
+	switch {
+	case len(queue.Videos) == 0:
+		return false
+	case queue.Repeat == mpcstorage.RepeatOne && queue.Current >= 0 && !skip:
+		return true
+	case queue.Current+1 < len(queue.Videos):
+		queue.Current++
+		return true
+	case queue.Repeat != mpcstorage.RepeatOff:
+		queue.Current = 0
+		return true
+	}
+
+	return false
+}
@@ -0,0 +1,166 @@
+package mpcremote
+
+import (
+	"github.com/jempe/mpc/storage"
+	"context"
+	"math/rand"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+// memoryQueues is a queue store that keeps the queues in memory
+type memoryQueues struct {
+	mutex  sync.Mutex
+	queues map[string]mpcstorage.PlayerQueue
+}
+
+func (store *memoryQueues) GetPlayerQueue(playerID string) (mpcstorage.PlayerQueue, error) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	queue, ok := store.queues[playerID]
+	if !ok {
+		queue = mpcstorage.PlayerQueue{Player: playerID, Videos: []string{}, Current: -1, Repeat: mpcstorage.RepeatOff}
+	}
+
+	queue.Videos = append([]string{}, queue.Videos...)
+
+	return queue, nil
+}
+
+func (store *memoryQueues) SavePlayerQueue(queue mpcstorage.PlayerQueue) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	queue.Videos = append([]string{}, queue.Videos...)
+	store.queues[queue.Player] = queue
+
+	return nil
+}
+
+func TestApplyQueueChange(t *testing.T) {
+	random := rand.New(rand.NewSource(1))
+
+	queue := &mpcstorage.PlayerQueue{Player: "tv1", Videos: []string{}, Current: -1, Repeat: mpcstorage.RepeatOff}
+
+	steps := []struct {
+		change  QueueChange
+		videos  []string
+		current int
+	}{
+		{QueueChange{Op: QueueAdd, Videos: []string{"a", "b", "c"}}, []string{"a", "b", "c"}, -1},
+		{QueueChange{Op: QueuePlay, Index: 1}, []string{"a", "b", "c"}, 1},
+		{QueueChange{Op: QueuePlayNext, Videos: []string{"d"}}, []string{"a", "b", "d", "c"}, 1},
+		{QueueChange{Op: QueueMove, Index: 1, To: 3}, []string{"a", "d", "c", "b"}, 3},
+		{QueueChange{Op: QueueRemove, Index: 0}, []string{"d", "c", "b"}, 2},
+		{QueueChange{Op: QueueClear}, []string{}, -1},
+	}
+
+	for _, step := range steps {
+		_, err := applyQueueChange(queue, step.change, random)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !reflect.DeepEqual(queue.Videos, step.videos) || queue.Current != step.current {
+			t.Fatalf("after %s queue is %v at %d; want %v at %d", step.change.Op, queue.Videos, queue.Current, step.videos, step.current)
+		}
+	}
+
+	_, err := applyQueueChange(queue, QueueChange{Op: QueueRemove, Index: 0}, random)
+	if err == nil {
+		t.Error("removing from an empty queue should fail")
+	}
+
+	queue.Videos = []string{"a", "b", "c", "d", "e"}
+	queue.Current = 1
+
+	applyQueueChange(queue, QueueChange{Op: QueueShuffle}, random)
+
+	if queue.Videos[0] != "a" || queue.Videos[1] != "b" || len(queue.Videos) != 5 {
+		t.Error("shuffle should keep the videos that already played", queue.Videos)
+	}
+}
+
+func TestAdvanceQueue(t *testing.T) {
+	queue := &mpcstorage.PlayerQueue{Videos: []string{"a", "b"}, Current: 1, Repeat: mpcstorage.RepeatOff}
+
+	if advanceQueue(queue, false) {
+		t.Error("queue without repeat should stop at the end")
+	}
+
+	queue.Repeat = mpcstorage.RepeatAll
+
+	if !advanceQueue(queue, false) || queue.Current != 0 {
+		t.Error("repeat all should go back to the first video", queue.Current)
+	}
+
+	queue.Repeat = mpcstorage.RepeatOne
+
+	if !advanceQueue(queue, false) || queue.Current != 0 {
+		t.Error("repeat one should play the same video", queue.Current)
+	}
+
+	if !advanceQueue(queue, true) || queue.Current != 1 {
+		t.Error("skipping should go to the next video with repeat one", queue.Current)
+	}
+}
+
+func TestQueueAutoAdvanceAndPersistence(t *testing.T) {
+	store := &memoryQueues{queues: make(map[string]mpcstorage.PlayerQueue)}
+	user := Identity{User: "user"}
+
+	hub := NewRemote()
+	hub.Queues = store
+	go hub.Run()
+
+	player := newFakeConn(false)
+	go hub.serve(player, user, "tv1", "", "TV")
+	nextMessage(t, player, TypeWelcome)
+
+	phone := newFakeConn(false)
+	go hub.serve(phone, user, "", "phone", "")
+
+	phone.in <- []byte(`{"v":1,"type":"pair","playerId":"tv1"}`)
+	nextMessage(t, phone, TypePaired)
+
+	phone.in <- []byte(`{"v":1,"type":"queue","queue":{"op":"add","videos":["a","b"]}}`)
+
+	if state := nextMessage(t, phone, TypeQueueState).QueueState; len(state.Videos) != 2 {
+		t.Fatal("remote should see the queue changes", state)
+	}
+
+	phone.in <- []byte(`{"v":1,"type":"queue","queue":{"op":"play","index":0}}`)
+
+	if msg := nextMessage(t, player, TypePlayVideo); msg.Video != "a" {
+		t.Error("player should play the selected video of the queue", msg)
+	}
+
+	player.in <- []byte(`{"v":1,"type":"ended","video":"a"}`)
+
+	if msg := nextMessage(t, player, TypePlayVideo); msg.Video != "b" {
+		t.Error("player should advance to the next video when the video ends", msg)
+	}
+
+	// the first state is the one of the play change
+	nextMessage(t, phone, TypeQueueState)
+
+	if state := nextMessage(t, phone, TypeQueueState).QueueState; state.Current != 1 {
+		t.Error("remote should see the new current video", state)
+	}
+
+	hub.Shutdown(context.Background())
+
+	restarted := NewRemote()
+	restarted.Queues = store
+	go restarted.Run()
+	defer restarted.Shutdown(context.Background())
+
+	reloaded := newFakeConn(false)
+	go restarted.serve(reloaded, user, "tv1", "", "TV")
+
+	if state := nextMessage(t, reloaded, TypeQueueState).QueueState; !reflect.DeepEqual(state.Videos, []string{"a", "b"}) || state.Current != 1 {
+		t.Error("queue should survive a restart", state)
+	}
+}
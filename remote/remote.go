@@ -1,16 +1,71 @@
 package mpcremote
 
 import (
+	"github.com/jempe/mpc/storage"
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
 	"github.com/gorilla/websocket"
 	"log"
+	"math/big"
+	mathrand "math/rand"
 	"net/http"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
 )
 
 type remote struct {
+	// metrics are updated with atomic operations so they are the first field to keep them aligned
+	metrics Metrics
+
+	// Authenticate gets the user or device of the connections, requests without identity are rejected
+	Authenticate func(*http.Request) (Identity, error)
+	// AllowedOrigins are the origins that can connect besides the server host
+	AllowedOrigins []string
+	// Queues saves the queues of the players, the queues are only kept in memory when it's nil
+	Queues QueueStore
+
+	// connection limits, the tests use shorter times
+	writeWait      time.Duration
+	pongWait       time.Duration
+	pingPeriod     time.Duration
+	maxMessageSize int64
+
 	forward chan message
 	join    chan *client
 	leave   chan *client
+	list    chan chan []Player
 	clients map[*client]bool
+	players map[string]*client
+	parties map[string]*Party
+	queues  map[string]*mpcstorage.PlayerQueue
+	random  *mathrand.Rand
+
+	// done is closed when the remote is shut down
+	done     chan struct{}
+	closing  bool
+	mutex    sync.Mutex
+	sessions sync.WaitGroup
+}
+
+// Metrics are the counters of the remote connections
+type Metrics struct {
+	Clients      int64 `json:"clients"`
+	Players      int64 `json:"players"`
+	Messages     int64 `json:"messages"`
+	Dropped      int64 `json:"dropped"`
+	Disconnected int64 `json:"disconnected"`
+}
+
+// Player is an online player session that remotes can claim
+type Player struct {
+	ID      string    `json:"id"`
+	Name    string    `json:"name"`
+	Remotes int       `json:"remotes"`
+	Joined  time.Time `json:"joined"`
 }
 
 func NewRemote() *remote {
@@ -18,31 +73,411 @@ func NewRemote() *remote {
 		forward: make(chan message),
 		join:    make(chan *client),
 		leave:   make(chan *client),
+		list:    make(chan chan []Player),
 		clients: make(map[*client]bool),
+		players: make(map[string]*client),
+		parties: make(map[string]*Party),
+		queues:  make(map[string]*mpcstorage.PlayerQueue),
+		random:  mathrand.New(mathrand.NewSource(time.Now().UnixNano())),
+		done:    make(chan struct{}),
+
+		writeWait:      writeWait,
+		pongWait:       pongWait,
+		pingPeriod:     pingPeriod,
+		maxMessageSize: maxMessageSize,
 	}
 }
 
+// Run routes the messages between the clients until the remote is shut down
+//
 func (r *remote) Run() {
 	for {
 		select {
+		case <-r.done:
+			for client := range r.clients {
+				r.disconnect(client)
+			}
+
+			return
 		case client := <-r.join:
 			// joining
 			r.clients[client] = true
-			log.Println("New remote joined")
+			atomic.AddInt64(&r.metrics.Clients, 1)
+
+			if client.isPlayer && !client.identity.CanControl(client.playerId) {
+				r.sendMessage(client, Message{Type: TypeError, Error: "player_not_allowed"})
+				client.isPlayer = false
+			}
+
+			if client.isPlayer {
+				if previous, ok := r.players[client.playerId]; ok {
+					client.code = previous.code
+				} else {
+					client.code = r.newCode()
+				}
+
+				if _, ok := r.players[client.playerId]; !ok {
+					atomic.AddInt64(&r.metrics.Players, 1)
+				}
+
+				r.players[client.playerId] = client
+
+				// players that reconnect keep their party
+				for _, party := range r.parties {
+					for _, member := range party.Members {
+						if member == client.playerId {
+							client.party = party.ID
+						}
+					}
+				}
+
+				r.sendMessage(client, Message{Type: TypeWelcome, Player: r.player(client), Code: client.code, PartyID: client.party, ServerTime: serverTime()})
+				r.sendMessage(client, Message{Type: TypeQueueState, PlayerID: client.playerId, QueueState: r.playerQueue(client.playerId)})
+				r.notifyPresence(client, true)
+				log.Println("Player joined:", client.name, "Player ID:", client.playerId)
+			} else {
+				log.Println("New remote joined")
+			}
 		case client := <-r.leave:
 			// leaving
-			delete(r.clients, client)
-			close(client.send)
-			log.Println("Remote Control left")
+			r.disconnect(client)
 		case message := <-r.forward:
-			msg := message.msg
-			log.Println("Message received: ", string(msg), "Player ID:", message.senderId)
-			// forward message to all clients
-			for client := range r.clients {
-				client.send <- msg
-				log.Println(" -- sent to Player")
+			sender := message.sender
+			log.Println("Message received: ", string(message.msg), "Player ID:", sender.playerId)
+
+			msg, err := ParseMessage(message.msg)
+			if err != nil {
+				r.sendMessage(sender, Message{Type: TypeError, Error: err.Error()})
+				continue
+			}
+
+			switch {
+			case msg.Type == TypePair || msg.Type == TypeUnpair:
+				r.pair(sender, msg)
+			case msg.Type == TypeGetState:
+				r.sendState(sender, msg.PlayerID)
+			case msg.Type == TypePartyCreate || msg.Type == TypePartyJoin || msg.Type == TypePartyLeave:
+				r.handleParty(sender, msg)
+			case msg.Type == TypeQueue:
+				r.handleQueue(sender, msg)
+			case msg.Type == TypeGetQueue:
+				r.sendQueueState(sender, msg.PlayerID)
+			case msg.Type == TypeEnded && sender.isPlayer:
+				r.videoEnded(sender, msg.Video)
+			case msg.Type == TypeTimeSync:
+				// the client estimates its clock offset with the time of the request and the server time
+				r.sendMessage(sender, Message{Type: TypeTimeSync, ClientTime: msg.ClientTime, ServerTime: serverTime()})
+			case msg.Type == TypeState && sender.isPlayer:
+				sender.state = msg.State
+
+				if sender.party != "" {
+					r.syncParty(sender)
+				}
+
+				// forward the state to the remotes that claimed the player
+				for client := range r.clients {
+					if !client.isPlayer && client.target == sender.playerId {
+						r.sendMessage(client, Message{Type: TypeState, PlayerID: sender.playerId, State: sender.state})
+					}
+				}
+			case msg.isCommand() && !sender.isPlayer:
+				player, ok := r.players[sender.target]
+				if !ok || !sender.identity.CanControl(player.playerId) {
+					r.sendMessage(sender, Message{Type: TypeError, Error: "remote_not_paired"})
+					continue
+				}
+
+				// next and previous move in the queue when it has videos in that direction
+				if (msg.Type == TypeNext || msg.Type == TypePrevious) && r.skipQueue(player.playerId, msg.Type == TypeNext) {
+					continue
+				}
+
+				msg.From = sender.remoteId
+				r.sendMessage(player, msg)
+				log.Println(" -- sent to Player", player.playerId)
+			default:
+				r.sendMessage(sender, Message{Type: TypeError, Error: "message_not_allowed"})
+			}
+		case response := <-r.list:
+			response <- r.onlinePlayers()
+		}
+	}
+}
+
+// pair claims a player by ID or pairing code for the remote, unpair releases it
+//
+func (r *remote) pair(sender *client, msg Message) {
+	if sender.isPlayer {
+		r.sendMessage(sender, Message{Type: TypeError, Error: "player_cannot_pair"})
+		return
+	}
+
+	if msg.Type == TypeUnpair {
+		sender.target = ""
+		r.sendMessage(sender, Message{Type: TypeUnpaired})
+		return
+	}
+
+	for _, player := range r.players {
+		if (msg.PlayerID != "" && msg.PlayerID == player.playerId) || (msg.Code != "" && msg.Code == player.code) {
+			if !sender.identity.CanControl(player.playerId) {
+				r.sendMessage(sender, Message{Type: TypeError, Error: "player_not_allowed"})
+				return
+			}
+
+			sender.target = player.playerId
+			r.sendMessage(sender, Message{Type: TypePaired, Player: r.player(player), State: player.state})
+			return
+		}
+	}
+
+	r.sendMessage(sender, Message{Type: TypeError, Error: "player_not_exists"})
+}
+
+// sendState sends the last state reported by a player, the paired player is used when the ID is empty
+//
+func (r *remote) sendState(sender *client, playerID string) {
+	if playerID == "" {
+		playerID = sender.target
+	}
+
+	player, ok := r.players[playerID]
+	if !ok || !sender.identity.CanControl(playerID) {
+		r.sendMessage(sender, Message{Type: TypeError, Error: "player_not_exists"})
+		return
+	}
+
+	state := player.state
+	if state == nil {
+		state = &PlayerState{}
+	}
+
+	r.sendMessage(sender, Message{Type: TypeState, PlayerID: playerID, State: state})
+}
+
+// notifyPresence tells the remotes that can control the player that it joined or left
+//
+func (r *remote) notifyPresence(player *client, online bool) {
+	for client := range r.clients {
+		if !client.isPlayer && client.identity.CanControl(player.playerId) {
+			r.sendMessage(client, Message{Type: TypePresence, Player: r.player(player), Online: online})
+		}
+	}
+}
+
+// disconnect removes the client from the remote and closes its send channel, the write loop closes the connection.
+// Clients that were already removed are ignored
+//
+func (r *remote) disconnect(client *client) {
+	if !r.clients[client] {
+		return
+	}
+
+	delete(r.clients, client)
+	close(client.send)
+	atomic.AddInt64(&r.metrics.Clients, -1)
+
+	if client.isPlayer && r.players[client.playerId] == client {
+		delete(r.players, client.playerId)
+		atomic.AddInt64(&r.metrics.Players, -1)
+		r.leaveParty(client)
+		r.notifyPresence(client, false)
+		log.Println("Player left:", client.name, "Player ID:", client.playerId)
+	} else {
+		log.Println("Remote Control left")
+	}
+}
+
+// sendMessage sends a message to the client with the current protocol version
+//
+func (r *remote) sendMessage(client *client, message Message) {
+	message.Version = ProtocolVersion
+
+	msg, err := json.Marshal(message)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+
+	r.send(client, msg)
+}
+
+// send queues the message for the client without blocking the remote,
+// the message is dropped and the client is disconnected when its queue is full
+//
+func (r *remote) send(client *client, msg []byte) {
+	if !r.clients[client] {
+		return
+	}
+
+	select {
+	case client.send <- msg:
+		atomic.AddInt64(&r.metrics.Messages, 1)
+	default:
+		atomic.AddInt64(&r.metrics.Dropped, 1)
+		atomic.AddInt64(&r.metrics.Disconnected, 1)
+		log.Println("Slow client disconnected, Player ID:", client.playerId, "Remote ID:", client.remoteId)
+		r.disconnect(client)
+	}
+}
+
+// player gets the public information of a player client
+//
+func (r *remote) player(client *client) *Player {
+	player := Player{ID: client.playerId, Name: client.name, Joined: client.joined}
+
+	for remote := range r.clients {
+		if !remote.isPlayer && remote.target == client.playerId {
+			player.Remotes++
+		}
+	}
+
+	return &player
+}
+
+// onlinePlayers gets the list of online players sorted by name
+//
+func (r *remote) onlinePlayers() []Player {
+	players := []Player{}
+
+	for _, client := range r.players {
+		players = append(players, *r.player(client))
+	}
+
+	sort.Slice(players, func(i, j int) bool {
+		return players[i].Name < players[j].Name
+	})
+
+	return players
+}
+
+// newCode generates a pairing code that is not used by other player
+//
+func (r *remote) newCode() string {
+	for {
+		code := randomCode()
+
+		used := false
+		for _, player := range r.players {
+			if player.code == code {
+				used = true
 			}
 		}
+
+		if !used {
+			return code
+		}
+	}
+}
+
+// randomCode generates a random code of 6 digits
+//
+func randomCode() string {
+	for {
+		number, err := rand.Int(rand.Reader, big.NewInt(1000000))
+		if err == nil {
+			return fmt.Sprintf("%06d", number.Int64())
+		}
+
+		log.Println(err)
+	}
+}
+
+// Players gets the list of online players
+//
+func (r *remote) Players() []Player {
+	response := make(chan []Player, 1)
+
+	select {
+	case r.list <- response:
+		return <-response
+	case <-r.done:
+		return []Player{}
+	}
+}
+
+// GetMetrics gets the current counters of the remote
+//
+func (r *remote) GetMetrics() Metrics {
+	return Metrics{
+		Clients:      atomic.LoadInt64(&r.metrics.Clients),
+		Players:      atomic.LoadInt64(&r.metrics.Players),
+		Messages:     atomic.LoadInt64(&r.metrics.Messages),
+		Dropped:      atomic.LoadInt64(&r.metrics.Dropped),
+		Disconnected: atomic.LoadInt64(&r.metrics.Disconnected),
+	}
+}
+
+// MetricsHandler shows the JSON metrics of the remote to admins
+//
+func (r *remote) MetricsHandler(w http.ResponseWriter, req *http.Request) {
+	identity, err := r.authenticate(req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	if !identity.Admin {
+		http.Error(w, "user_not_admin", http.StatusForbidden)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	err = json.NewEncoder(w).Encode(r.GetMetrics())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// Shutdown closes the connections of all the clients and waits until they finish or the context is done,
+// new connections are rejected after the shutdown
+//
+func (r *remote) Shutdown(ctx context.Context) error {
+	r.mutex.Lock()
+	if !r.closing {
+		r.closing = true
+		close(r.done)
+	}
+	r.mutex.Unlock()
+
+	finished := make(chan struct{})
+
+	go func() {
+		r.sessions.Wait()
+		close(finished)
+	}()
+
+	select {
+	case <-finished:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// PlayersHandler shows the JSON list of online players that the user can control
+//
+func (r *remote) PlayersHandler(w http.ResponseWriter, req *http.Request) {
+	identity, err := r.authenticate(req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	players := []Player{}
+
+	for _, player := range r.Players() {
+		if identity.CanControl(player.ID) {
+			players = append(players, player)
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	err = json.NewEncoder(w).Encode(players)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
 	}
 }
 
@@ -51,63 +486,85 @@ const (
 	messageBufferSize = 256
 )
 
-var upgrader = &websocket.Upgrader{ReadBufferSize: socketBufferSize, WriteBufferSize: socketBufferSize}
+// default connection limits
+const (
+	// writeWait is the time allowed to write a message
+	writeWait = 10 * time.Second
+	// pongWait is the time allowed to read the next pong from the client
+	pongWait = 60 * time.Second
+	// pingPeriod is the time between pings, it must be less than pongWait
+	pingPeriod = pongWait * 9 / 10
+	// maxMessageSize is the maximum size of the messages sent by the clients
+	maxMessageSize = 16 * 1024
+)
 
+// ServeHTTP connects players and remotes, players connect with the player_id and name parameters
+// and remotes with the id parameter. The connections need a session or the token of a paired device
+//
 func (r *remote) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	identity, err := r.authenticate(req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	upgrader := &websocket.Upgrader{ReadBufferSize: socketBufferSize, WriteBufferSize: socketBufferSize, CheckOrigin: r.checkOrigin}
+
+	// the upgrader sends the error response to the client
 	socket, err := upgrader.Upgrade(w, req, nil)
 	if err != nil {
-		log.Fatal("ServeHTTP:", err)
+		log.Println("ServeHTTP:", err)
 		return
 	}
 
-	for cl, status := range r.clients {
-		log.Println(cl.playerId, status)
+	query := req.URL.Query()
+
+	r.serve(socket, identity, query.Get("player_id"), query.Get("id"), query.Get("name"))
+}
+
+// serve runs the connection of a client until it is closed
+//
+func (r *remote) serve(socket conn, identity Identity, playerID string, remoteID string, name string) {
+	r.mutex.Lock()
+	if r.closing {
+		r.mutex.Unlock()
+		socket.Close()
+		return
 	}
+	r.sessions.Add(1)
+	r.mutex.Unlock()
+
+	defer r.sessions.Done()
 
 	client := &client{
 		socket:   socket,
 		send:     make(chan []byte, messageBufferSize),
 		remote:   r,
-		playerId: req.URL.Query().Get("player_id"),
-		remoteId: req.URL.Query().Get("id"),
+		playerId: playerID,
+		remoteId: remoteID,
+		name:     name,
+		identity: identity,
+		joined:   time.Now(),
 	}
-	r.join <- client
-	defer func() { r.leave <- client }()
-	go client.write()
-	client.read()
-}
 
-type client struct {
-	socket   *websocket.Conn
-	send     chan []byte
-	remote   *remote
-	isPlayer bool
-	remoteId string
-	playerId string
-}
+	client.isPlayer = client.playerId != "" && client.remoteId == ""
 
-type message struct {
-	msg      []byte
-	senderId string
-}
+	if client.isPlayer && client.name == "" {
+		client.name = client.playerId
+	}
 
-func (c *client) read() {
-	defer c.socket.Close()
-	for {
-		_, msg, err := c.socket.ReadMessage()
-		if err != nil {
-			return
-		}
-		c.remote.forward <- message{msg: msg, senderId: c.playerId}
+	select {
+	case r.join <- client:
+	case <-r.done:
+		socket.Close()
+		return
 	}
-}
 
-func (c *client) write() {
-	defer c.socket.Close()
-	for msg := range c.send {
-		err := c.socket.WriteMessage(websocket.TextMessage, msg)
-		if err != nil {
-			return
-		}
+	go client.write()
+	client.read()
+
+	select {
+	case r.leave <- client:
+	case <-r.done:
 	}
 }
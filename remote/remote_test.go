@@ -0,0 +1,184 @@
+package mpcremote
+
+import (
+	"errors"
+	"github.com/gorilla/websocket"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testIdentity authenticates the test connections with the user parameter, the guest user can only control tv1
+//
+func testIdentity(req *http.Request) (Identity, error) {
+	switch req.URL.Query().Get("user") {
+	case "":
+		return Identity{}, errors.New("user_not_logged_in")
+	case "guest":
+		return Identity{User: "guest", Role: "guest", Restricted: true, Players: []string{"tv1"}}, nil
+	}
+
+	return Identity{User: req.URL.Query().Get("user"), Role: "user"}, nil
+}
+
+func newTestRemote(t *testing.T) (*remote, *httptest.Server) {
+	hub := NewRemote()
+	hub.Authenticate = testIdentity
+	go hub.Run()
+
+	server := httptest.NewServer(hub)
+	t.Cleanup(server.Close)
+
+	return hub, server
+}
+
+func dial(t *testing.T, server *httptest.Server, query string) *websocket.Conn {
+	if !strings.Contains(query, "user=") {
+		query = "user=admin&" + query
+	}
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"/remote?"+query, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() { conn.Close() })
+
+	return conn
+}
+
+// readMessage reads the next message of the connection, the queue states that players get when they join are skipped
+//
+func readMessage(t *testing.T, conn *websocket.Conn) (msg Message) {
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+
+	for {
+		msg = Message{}
+
+		err := conn.ReadJSON(&msg)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if msg.Type != TypeQueueState {
+			return
+		}
+	}
+}
+
+func TestPairingRoutesToTarget(t *testing.T) {
+	hub, server := newTestRemote(t)
+
+	livingRoom := dial(t, server, "player_id=tv1&name=Living+Room")
+	welcome := readMessage(t, livingRoom)
+
+	bedroom := dial(t, server, "player_id=tv2&name=Bedroom")
+	readMessage(t, bedroom)
+
+	phone := dial(t, server, "id=phone")
+
+	if players := hub.Players(); len(players) != 2 || players[0].Name != "Bedroom" {
+		t.Fatal("both players should be online", players)
+	}
+
+	phone.WriteJSON(Message{Version: ProtocolVersion, Type: TypePlay})
+	if reply := readMessage(t, phone); reply.Error != "remote_not_paired" {
+		t.Error("unpaired remotes should get an error", reply)
+	}
+
+	phone.WriteJSON(Message{Version: ProtocolVersion, Type: TypePair, Code: welcome.Code})
+	if reply := readMessage(t, phone); reply.Type != TypePaired || reply.Player.ID != "tv1" {
+		t.Fatal("remote should be paired with the living room player", reply)
+	}
+
+	phone.WriteMessage(websocket.TextMessage, []byte(`{"v":1,"type":"filter","filter":{"title":"news"}}`))
+
+	if msg := readMessage(t, livingRoom); msg.Type != TypeFilter || string(msg.Filter) != `{"title":"news"}` || msg.From != "phone" {
+		t.Error("message should be delivered to the paired player", msg)
+	}
+
+	bedroom.SetReadDeadline(time.Now().Add(200 * time.Millisecond))
+	for {
+		var msg Message
+
+		if err := bedroom.ReadJSON(&msg); err != nil {
+			break
+		}
+
+		if msg.Type != TypeQueueState {
+			t.Error("message should not be delivered to other players", msg)
+		}
+	}
+
+	bedroom.Close()
+
+	presence := readMessage(t, phone)
+	if presence.Type != TypePresence || presence.Online || presence.Player.ID != "tv2" {
+		t.Error("remotes should be notified when a player leaves", presence)
+	}
+
+	livingRoom.WriteJSON(Message{Version: ProtocolVersion, Type: TypeState, State: &PlayerState{Video: "abc", Position: 12, Volume: 0.5}})
+	if msg := readMessage(t, phone); msg.Type != TypeState || msg.PlayerID != "tv1" || msg.State.Video != "abc" {
+		t.Error("player state should be forwarded to the paired remote", msg)
+	}
+
+	tablet := dial(t, server, "id=tablet")
+
+	tablet.WriteJSON(Message{Version: ProtocolVersion, Type: TypeGetState, PlayerID: "tv1"})
+	if msg := readMessage(t, tablet); msg.Type != TypeState || msg.State.Position != 12 {
+		t.Error("remotes should be able to query the state of any player", msg)
+	}
+}
+
+func TestGuestsControlInvitedPlayers(t *testing.T) {
+	_, server := newTestRemote(t)
+
+	invited := dial(t, server, "player_id=tv1")
+	readMessage(t, invited)
+
+	other := dial(t, server, "player_id=tv2")
+	readMessage(t, other)
+
+	guest := dial(t, server, "user=guest&id=guest-phone")
+
+	guest.WriteJSON(Message{Version: ProtocolVersion, Type: TypePair, PlayerID: "tv2"})
+	if reply := readMessage(t, guest); reply.Error != "player_not_allowed" {
+		t.Error("guests should not pair with players they were not invited to", reply)
+	}
+
+	guest.WriteJSON(Message{Version: ProtocolVersion, Type: TypeGetState, PlayerID: "tv2"})
+	if reply := readMessage(t, guest); reply.Type != TypeError {
+		t.Error("guests should not get the state of players they were not invited to", reply)
+	}
+
+	guest.WriteJSON(Message{Version: ProtocolVersion, Type: TypePair, PlayerID: "tv1"})
+	if reply := readMessage(t, guest); reply.Type != TypePaired {
+		t.Error("guests should pair with invited players", reply)
+	}
+}
+
+func TestRejectedConnections(t *testing.T) {
+	_, server := newTestRemote(t)
+
+	url := "ws" + strings.TrimPrefix(server.URL, "http") + "/remote"
+
+	_, resp, err := websocket.DefaultDialer.Dial(url, nil)
+	if err == nil || resp.StatusCode != http.StatusUnauthorized {
+		t.Error("connections without identity should be rejected", err)
+	}
+
+	header := http.Header{"Origin": []string{"http://evil.example.com"}}
+
+	_, resp, err = websocket.DefaultDialer.Dial(url+"?user=admin", header)
+	if err == nil || resp.StatusCode != http.StatusForbidden {
+		t.Error("connections from other origins should be rejected", err)
+	}
+
+	conn := dial(t, server, "id=phone")
+	conn.WriteJSON(Message{Version: ProtocolVersion, Type: TypeUnpair})
+	if reply := readMessage(t, conn); reply.Type != TypeUnpaired {
+		t.Error("the server should keep working after rejected connections", reply)
+	}
+}
@@ -0,0 +1,309 @@
+package mpcscraper
+
+import (
+	"github.com/jempe/mpc/library"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// maxResponseSize is the max size of the responses of the JSON scrapers
+const maxResponseSize = 5 << 20
+
+// defaultTimeout is the timeout of the requests when the config doesn't have one
+const defaultTimeout = 15 * time.Second
+
+// JSONConfig describes a site with a JSON API, the URL is a template that gets the query,
+// like https://api.example.com/search?q={{.Name | urlquery}}
+type JSONConfig struct {
+	Name    string            `json:"name"`
+	URL     string            `json:"url"`
+	Headers map[string]string `json:"headers"`
+	// Results is the path of the list of results in the response, it's empty when the response is the list
+	Results string     `json:"results"`
+	Fields  JSONFields `json:"fields"`
+	// ImagePrefix is added to the image paths that are not absolute URLs
+	ImagePrefix string `json:"imagePrefix"`
+	// DateFormat is the Go layout of the dates, the default is 2006-01-02
+	DateFormat string `json:"dateFormat"`
+	// Timeout of the requests in seconds
+	Timeout    int `json:"timeout"`
+	MaxResults int `json:"maxResults"`
+}
+
+// JSONFields are the paths of the fields in every result, the keys are separated by dots
+// and the lists are mapped, like cast.name for the names of a list of actors
+type JSONFields struct {
+	ID          string `json:"id"`
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	Date        string `json:"date"`
+	Actors      string `json:"actors"`
+	Categories  string `json:"categories"`
+	Image       string `json:"image"`
+	URL         string `json:"url"`
+}
+
+// JSONScraper gets the results from a JSON API described by a config
+type JSONScraper struct {
+	Config JSONConfig
+	Client *http.Client
+
+	url *template.Template
+}
+
+// NewJSONScraper checks the config and parses the URL template
+//
+func NewJSONScraper(config JSONConfig) (*JSONScraper, error) {
+	if config.Name == "" || config.URL == "" || config.Fields.Title == "" {
+		return nil, errors.New("scraper_config_invalid")
+	}
+
+	urlTemplate, err := template.New(config.Name).Funcs(template.FuncMap{"pathescape": url.PathEscape}).Parse(config.URL)
+	if err != nil {
+		return nil, err
+	}
+
+	timeout := defaultTimeout
+	if config.Timeout > 0 {
+		timeout = time.Duration(config.Timeout) * time.Second
+	}
+
+	if config.DateFormat == "" {
+		config.DateFormat = "2006-01-02"
+	}
+
+	return &JSONScraper{Config: config, Client: &http.Client{Timeout: timeout}, url: urlTemplate}, nil
+}
+
+// LoadJSONScrapers registers the JSON scrapers of a config file that has a list of configs
+//
+func LoadJSONScrapers(configFile string) (names []string, err error) {
+	data, err := ioutil.ReadFile(configFile)
+	if err != nil {
+		return
+	}
+
+	var configs []JSONConfig
+
+	err = json.Unmarshal(data, &configs)
+	if err != nil {
+		return
+	}
+
+	for _, config := range configs {
+		scraper, err := NewJSONScraper(config)
+		if err != nil {
+			return names, fmt.Errorf("scraper %q: %v", config.Name, err)
+		}
+
+		Register(scraper)
+		names = append(names, config.Name)
+	}
+
+	return
+}
+
+func (scraper *JSONScraper) Name() string {
+	return scraper.Config.Name
+}
+
+// Search requests the URL of the query and maps the results with the field paths
+//
+func (scraper *JSONScraper) Search(ctx context.Context, query Query) (results []Result, err error) {
+	var requestURL bytes.Buffer
+
+	err = scraper.url.Execute(&requestURL, query)
+	if err != nil {
+		return
+	}
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, requestURL.String(), nil)
+	if err != nil {
+		return
+	}
+
+	request.Header.Set("Accept", "application/json")
+
+	for name, value := range scraper.Config.Headers {
+		request.Header.Set(name, value)
+	}
+
+	response, err := scraper.Client.Do(request)
+	if err != nil {
+		return
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return nil, errors.New("scraper_request_failed: " + response.Status)
+	}
+
+	var data interface{}
+
+	err = json.NewDecoder(io.LimitReader(response.Body, maxResponseSize)).Decode(&data)
+	if err != nil {
+		return
+	}
+
+	items := []interface{}{data}
+
+	if scraper.Config.Results != "" {
+		items = lookup(data, scraper.Config.Results)
+	} else if list, ok := data.([]interface{}); ok {
+		items = list
+	}
+
+	results = []Result{}
+
+	for _, item := range items {
+		result := scraper.result(item)
+
+		if result.Video.Title == "" {
+			continue
+		}
+
+		results = append(results, result)
+
+		if scraper.Config.MaxResults > 0 && len(results) == scraper.Config.MaxResults {
+			break
+		}
+	}
+
+	return
+}
+
+// result maps an item of the response to a result
+//
+func (scraper *JSONScraper) result(item interface{}) (result Result) {
+	fields := scraper.Config.Fields
+
+	result.Scraper = scraper.Config.Name
+	result.ID = first(item, fields.ID)
+
+	video := &result.Video
+
+	video.Title = first(item, fields.Title)
+	video.Description = first(item, fields.Description)
+	video.VideoURL = first(item, fields.URL)
+
+	if date := first(item, fields.Date); date != "" {
+		if pubDate, err := time.Parse(scraper.Config.DateFormat, date); err == nil {
+			video.PubDate = pubDate
+		}
+	}
+
+	if image := first(item, fields.Image); image != "" {
+		if !strings.HasPrefix(image, "http://") && !strings.HasPrefix(image, "https://") {
+			image = scraper.Config.ImagePrefix + image
+		}
+
+		video.ImgURL = image
+	}
+
+	for _, actor := range all(item, fields.Actors) {
+		video.Actors = append(video.Actors, mpclibrary.Actor{Name: actor})
+	}
+
+	for _, category := range all(item, fields.Categories) {
+		video.Categories = append(video.Categories, mpclibrary.Category{Name: category})
+	}
+
+	return
+}
+
+// lookup gets the values of a path, the lists in the path are mapped and flattened
+//
+func lookup(data interface{}, path string) []interface{} {
+	values := []interface{}{data}
+
+	for _, key := range strings.Split(path, ".") {
+		var next []interface{}
+
+		for _, value := range values {
+			if list, ok := value.([]interface{}); ok {
+				for _, element := range list {
+					if object, ok := element.(map[string]interface{}); ok {
+						if child, ok := object[key]; ok {
+							next = append(next, child)
+						}
+					}
+				}
+
+				continue
+			}
+
+			if object, ok := value.(map[string]interface{}); ok {
+				if child, ok := object[key]; ok {
+					next = append(next, child)
+				}
+			}
+		}
+
+		values = next
+	}
+
+	// the lists at the end of the path are flattened
+	var flattened []interface{}
+
+	for _, value := range values {
+		if list, ok := value.([]interface{}); ok {
+			flattened = append(flattened, list...)
+		} else {
+			flattened = append(flattened, value)
+		}
+	}
+
+	return flattened
+}
+
+// all gets the non empty strings of a path
+//
+func all(data interface{}, path string) (texts []string) {
+	if path == "" {
+		return
+	}
+
+	for _, value := range lookup(data, path) {
+		if text := strings.TrimSpace(toString(value)); text != "" {
+			texts = append(texts, text)
+		}
+	}
+
+	return
+}
+
+// first gets the first non empty string of a path
+//
+func first(data interface{}, path string) string {
+	texts := all(data, path)
+
+	if len(texts) == 0 {
+		return ""
+	}
+
+	return texts[0]
+}
+
+func toString(value interface{}) string {
+	switch typed := value.(type) {
+	case string:
+		return typed
+	case float64:
+		return strconv.FormatFloat(typed, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(typed)
+	}
+
+	return ""
+}
@@ -0,0 +1,94 @@
+// MPC Scraper looks up the metadata of the videos in external sites, the results are reviewed by an admin
+// before they are saved
+//
+package mpcscraper
+
+import (
+	"github.com/jempe/mpc/library"
+	"context"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Query has the data of the video that the scrapers use to find it
+type Query struct {
+	Title string `json:"title"`
+	// File is the original name of the video file
+	File string `json:"file"`
+	// Name is the file name without extension and with spaces instead of dots and underscores
+	Name   string `json:"name"`
+	Md5Sum string `json:"md5sum"`
+}
+
+// Result is a video found by a scraper, the video has the title, description, date, actors, categories,
+// artwork and URL that the scraper found
+type Result struct {
+	Scraper string `json:"scraper"`
+	// ID is the ID of the video in the external site
+	ID    string           `json:"id"`
+	Video mpclibrary.Video `json:"video"`
+}
+
+// Scraper finds the metadata of a video in an external site
+type Scraper interface {
+	Name() string
+	Search(ctx context.Context, query Query) ([]Result, error)
+}
+
+var (
+	scrapers     = make(map[string]Scraper)
+	scrapersLock sync.RWMutex
+)
+
+// Register adds a scraper, a scraper with the same name is replaced
+//
+func Register(scraper Scraper) {
+	scrapersLock.Lock()
+	defer scrapersLock.Unlock()
+
+	scrapers[scraper.Name()] = scraper
+}
+
+// Get gets the scraper with the name
+//
+func Get(name string) (scraper Scraper, ok bool) {
+	scrapersLock.RLock()
+	defer scrapersLock.RUnlock()
+
+	scraper, ok = scrapers[name]
+
+	return
+}
+
+// Names gets the names of the registered scrapers sorted by name
+//
+func Names() (names []string) {
+	scrapersLock.RLock()
+	defer scrapersLock.RUnlock()
+
+	names = []string{}
+
+	for name := range scrapers {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	return
+}
+
+// NewQuery builds the query of a video, the original file name is used when the video was imported
+//
+func NewQuery(video mpclibrary.Video) Query {
+	file := video.OrigFile
+	if file == "" {
+		file = video.File
+	}
+
+	name := strings.TrimSuffix(file, filepath.Ext(file))
+	name = strings.Join(strings.Fields(strings.NewReplacer(".", " ", "_", " ").Replace(name)), " ")
+
+	return Query{Title: video.Title, File: file, Name: name, Md5Sum: video.Md5Sum}
+}
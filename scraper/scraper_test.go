@@ -0,0 +1,136 @@
+package mpcscraper
+
+import (
+	"github.com/jempe/mpc/library"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// fixtureServer serves the recorded responses of testdata, the requests are sent to the channel
+//
+func fixtureServer(t *testing.T, fixture string, requests chan<- *http.Request) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requests != nil {
+			requests <- r
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		http.ServeFile(w, r, "testdata/"+fixture)
+	}))
+
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+func TestJSONScraper(t *testing.T) {
+	requests := make(chan *http.Request, 1)
+	server := fixtureServer(t, "search.json", requests)
+
+	scraper, err := NewJSONScraper(JSONConfig{
+		Name:        "example",
+		URL:         server.URL + "/search.json?q={{.Name | urlquery}}&md5={{.Md5Sum}}",
+		Headers:     map[string]string{"X-Api-Key": "secret"},
+		Results:     "data.results",
+		ImagePrefix: "https://images.example.com",
+		MaxResults:  2,
+		Fields: JSONFields{
+			ID:          "id",
+			Title:       "name",
+			Description: "overview",
+			Date:        "released",
+			Actors:      "cast.name",
+			Categories:  "genres",
+			Image:       "poster",
+			URL:         "link",
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	query := NewQuery(mpclibrary.Video{File: "abc.mp4", OrigFile: "Big.Buck_Bunny.2008.mp4", Md5Sum: "abc"})
+
+	if query.Name != "Big Buck Bunny 2008" {
+		t.Error("the name should be built from the original file", query.Name)
+	}
+
+	results, err := scraper.Search(context.Background(), query)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	request := <-requests
+
+	if request.URL.Query().Get("q") != "Big Buck Bunny 2008" || request.URL.Query().Get("md5") != "abc" {
+		t.Error("the query should be sent in the URL", request.URL)
+	}
+
+	if request.Header.Get("X-Api-Key") != "secret" {
+		t.Error("the headers of the config should be sent", request.Header)
+	}
+
+	if len(results) != 2 {
+		t.Fatal("the results should be limited by max results", results)
+	}
+
+	video := results[0].Video
+
+	if results[0].ID != "1842" || results[0].Scraper != "example" || video.Title != "Big Buck Bunny" || video.Description != "A giant rabbit takes revenge on three rodents." {
+		t.Error("wrong result", results[0])
+	}
+
+	if !video.PubDate.Equal(time.Date(2008, 5, 20, 0, 0, 0, 0, time.UTC)) {
+		t.Error("wrong date", video.PubDate)
+	}
+
+	if len(video.Actors) != 2 || video.Actors[1].Name != "Frank" || len(video.Categories) != 2 || video.Categories[0].Name != "Animation" {
+		t.Error("the lists should be mapped", video.Actors, video.Categories)
+	}
+
+	if video.ImgURL != "https://images.example.com/posters/1842.jpg" || video.VideoURL != "https://videos.example.com/1842" {
+		t.Error("wrong URLs", video.ImgURL, video.VideoURL)
+	}
+
+	if results[1].Video.ImgURL != "https://cdn.example.com/2017.jpg" || !results[1].Video.PubDate.IsZero() || len(results[1].Video.Actors) != 0 {
+		t.Error("absolute images should be kept and invalid dates skipped", results[1].Video)
+	}
+}
+
+func TestJSONScraperErrors(t *testing.T) {
+	server := fixtureServer(t, "missing.json", nil)
+
+	scraper, err := NewJSONScraper(JSONConfig{Name: "example", URL: server.URL + "/{{.Name | pathescape}}", Fields: JSONFields{Title: "name"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = scraper.Search(context.Background(), Query{Name: "video"})
+	if err == nil {
+		t.Error("the responses that are not OK should fail")
+	}
+
+	_, err = NewJSONScraper(JSONConfig{Name: "example", URL: server.URL})
+	if err == nil {
+		t.Error("configs without title field should be rejected")
+	}
+}
+
+func TestLoadJSONScrapers(t *testing.T) {
+	names, err := LoadJSONScrapers("testdata/scrapers.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(names) != 1 || names[0] != "example" {
+		t.Error("the scrapers of the config should be loaded", names)
+	}
+
+	scraper, ok := Get("example")
+	if !ok || scraper.(*JSONScraper).Config.DateFormat != "2006-01-02" {
+		t.Error("the scraper should be registered with the default date format", scraper)
+	}
+}
@@ -0,0 +1,262 @@
+package mpcserver
+
+import (
+	"github.com/jempe/mpc/utils"
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxActorPhotoSize is the max size of the uploaded actor photos
+const maxActorPhotoSize = 10 << 20
+
+type ActorUpdate struct {
+	Name    *string   `json:"name"`
+	Gender  *string   `json:"gender"`
+	Birth   *string   `json:"birth"`
+	Aliases *[]string `json:"aliases"`
+}
+
+type ActorMerge struct {
+	Source int `json:"source"`
+}
+
+type ActorAlias struct {
+	Alias string `json:"alias"`
+}
+
+// ActorHandler manages the actors
+//
+// GET, PATCH and DELETE /actors/{id}, the changes need an admin
+// POST /actors/{id}/aliases adds an alias
+// POST /actors/{id}/merge merges the source actor into the actor, the source photo is removed when the actor has a photo
+// GET and POST /actors/{id}/photo gets and uploads the actor photo, the photo needs a session because it can be encrypted
+// POST /actors/{id}/photo/encrypt encrypts the actor photo
+//
+func (server *Server) ActorHandler(w http.ResponseWriter, r *http.Request) {
+	uriSegments := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+
+	if len(uriSegments) < 2 {
+		http.Error(w, "Invalid Request", http.StatusBadRequest)
+		return
+	}
+
+	actorID, err := strconv.Atoi(uriSegments[1])
+	if err != nil {
+		http.Error(w, "Invalid Request", http.StatusBadRequest)
+		return
+	}
+
+	// everyone can see the actors and their photos, only the admins change them
+	if r.Method != http.MethodGet {
+		if _, ok := server.adminUser(w, r); !ok {
+			return
+		}
+	}
+
+	actor, _ := server.Storage.GetActorByID(actorID)
+	if actor.Name == "" {
+		http.Error(w, "actor_not_exists", http.StatusNotFound)
+		return
+	}
+
+	action := strings.Join(uriSegments[2:], "/")
+
+	switch {
+	case action == "" && r.Method == http.MethodGet:
+		writeJSON(w, actor)
+	case action == "" && r.Method == http.MethodPatch:
+		server.updateActor(w, r, actorID)
+	case action == "" && r.Method == http.MethodDelete:
+		err = server.Storage.DeleteActor(actorID)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+
+		err = server.Library.RemoveActorPhoto(actor.Photo)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	case action == "aliases" && r.Method == http.MethodPost:
+		var alias ActorAlias
+
+		err = json.NewDecoder(r.Body).Decode(&alias)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		actor, err = server.Storage.AddActorAlias(actorID, alias.Alias)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		writeJSON(w, actor)
+	case action == "merge" && r.Method == http.MethodPost:
+		var merge ActorMerge
+
+		err = json.NewDecoder(r.Body).Decode(&merge)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		source, _ := server.Storage.GetActorByID(merge.Source)
+
+		actor, err = server.Storage.MergeActors(merge.Source, actorID)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		// the actor keeps the photo of the source when it has no photo, otherwise the source photo is not used
+		if source.Photo != "" && source.Photo != actor.Photo {
+			err = server.Library.RemoveActorPhoto(source.Photo)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+		}
+
+		writeJSON(w, actor)
+	case action == "photo" && r.Method == http.MethodGet:
+		if _, ok := server.loggedUser(w, r); !ok {
+			return
+		}
+
+		data, modTime, err := server.Library.ActorPhoto(actor.Photo, []byte(server.Key))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+
+		http.ServeContent(w, r, strings.TrimSuffix(actor.Photo, ".enc"), modTime, bytes.NewReader(data))
+	case action == "photo" && r.Method == http.MethodPost:
+		server.uploadActorPhoto(w, r, actorID)
+	case action == "photo/encrypt" && r.Method == http.MethodPost:
+		actor.Photo, err = server.Library.EncryptActorPhoto(actor.Photo, []byte(server.Key))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		err = server.Storage.UpdateActor(actor)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		writeJSON(w, actor)
+	default:
+		http.Error(w, "Invalid Request", http.StatusBadRequest)
+	}
+}
+
+// updateActor updates the profile fields of the actor that are present in the request
+//
+func (server *Server) updateActor(w http.ResponseWriter, r *http.Request, actorID int) {
+	var update ActorUpdate
+
+	err := json.NewDecoder(r.Body).Decode(&update)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	actor, _ := server.Storage.GetActorByID(actorID)
+
+	if update.Name != nil {
+		actor.Name = *update.Name
+	}
+
+	if update.Gender != nil {
+		actor.Gender = *update.Gender
+	}
+
+	if update.Birth != nil {
+		actor.Birth = time.Time{}
+
+		if *update.Birth != "" {
+			actor.Birth, err = mpcutils.ParseDate(*update.Birth)
+
+			if err != nil || actor.Birth.IsZero() {
+				http.Error(w, "actor_birth_invalid", http.StatusBadRequest)
+				return
+			}
+		}
+	}
+
+	if update.Aliases != nil {
+		actor.Aliases = *update.Aliases
+	}
+
+	err = server.Storage.UpdateActor(actor)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	actor, _ = server.Storage.GetActorByID(actorID)
+
+	writeJSON(w, actor)
+}
+
+// uploadActorPhoto saves the photo sent in the "photo" form field, the photo is encrypted when the "encrypt" field is set
+//
+func (server *Server) uploadActorPhoto(w http.ResponseWriter, r *http.Request, actorID int) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxActorPhotoSize)
+
+	file, _, err := r.FormFile("photo")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	defer file.Close()
+
+	data, err := ioutil.ReadAll(file)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var key []byte
+
+	if r.FormValue("encrypt") != "" {
+		key = []byte(server.Key)
+	}
+
+	actor, _ := server.Storage.GetActorByID(actorID)
+
+	photo, err := server.Library.SaveActorPhoto(actorID, data, key)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if actor.Photo != "" && actor.Photo != photo {
+		err = server.Library.RemoveActorPhoto(actor.Photo)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	actor.Photo = photo
+
+	err = server.Storage.UpdateActor(actor)
+	if err != nil {
+		http.Error(w, "actor_photo_save_error", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, actor)
+}
@@ -0,0 +1,128 @@
+package mpcserver
+
+import (
+	"github.com/jempe/mpc/library"
+	"github.com/jempe/mpc/users"
+	"github.com/jempe/mpc/utils"
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestActorPhotoNeedsSession(t *testing.T) {
+	server := newTestServer(t)
+
+	err := server.Storage.InsertVideos([]mpclibrary.Video{{File: "movie.mp4", Title: "Movie", Encrypted: true, Actors: []mpclibrary.Actor{{Name: "Jane Doe"}}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	actor, _ := server.Storage.GetActorByName("Jane Doe")
+
+	photo := []byte("\xff\xd8\xff\xe0 photo of Jane Doe")
+
+	actor.Photo, err = server.Library.SaveActorPhoto(actor.ID, photo, []byte(server.Key))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = server.Storage.UpdateActor(actor)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	saved := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	err = os.Chtimes(server.Library.Path+"/"+mpclibrary.ActorsFolder+"/"+actor.Photo, saved, saved)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	photoURL := "/actors/" + strconv.Itoa(actor.ID) + "/photo"
+
+	w := httptest.NewRecorder()
+	server.ActorHandler(w, httptest.NewRequest(http.MethodGet, photoURL, nil))
+
+	if w.Code != http.StatusUnauthorized {
+		t.Error("the encrypted photo should not be served without a session", w.Code)
+	}
+
+	r := httptest.NewRequest(http.MethodGet, photoURL, nil)
+	r.AddCookie(sessionCookie(t, server, "user"))
+
+	w = httptest.NewRecorder()
+	server.ActorHandler(w, r)
+
+	if w.Code != http.StatusOK || !bytes.Equal(w.Body.Bytes(), photo) {
+		t.Fatal("the photo should be decrypted for the users", w.Code)
+	}
+
+	if w.Header().Get("Last-Modified") != saved.Format(http.TimeFormat) {
+		t.Error("the time of the photo file should be sent", w.Header().Get("Last-Modified"))
+	}
+}
+
+func TestMergeActorsPhotos(t *testing.T) {
+	server := newTestServer(t)
+
+	err := server.Storage.InsertVideos([]mpclibrary.Video{{File: "movie.mp4", Title: "Movie", Encrypted: true, Actors: []mpclibrary.Actor{{Name: "Jane Doe"}, {Name: "J. Doe"}, {Name: "Janie"}}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	admin := newTestUser(t, server, mpcusers.RoleAdmin)
+
+	withPhoto := func(name string) mpclibrary.Actor {
+		actor, _ := server.Storage.GetActorByName(name)
+
+		actor.Photo, err = server.Library.SaveActorPhoto(actor.ID, []byte("\xff\xd8\xff\xe0 photo of "+name), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = server.Storage.UpdateActor(actor)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return actor
+	}
+
+	merge := func(source mpclibrary.Actor, target mpclibrary.Actor) mpclibrary.Actor {
+		r := httptest.NewRequest(http.MethodPost, "/actors/"+strconv.Itoa(target.ID)+"/merge", strings.NewReader(`{"source": `+strconv.Itoa(source.ID)+`}`))
+		r.AddCookie(sessionCookie(t, server, admin))
+
+		w := httptest.NewRecorder()
+		server.ActorHandler(w, r)
+
+		if w.Code != http.StatusOK {
+			t.Fatal(w.Code, w.Body.String())
+		}
+
+		actor, _ := server.Storage.GetActorByID(target.ID)
+
+		return actor
+	}
+
+	photoExists := func(photo string) bool {
+		return mpcutils.Exists(server.Library.Path + "/" + mpclibrary.ActorsFolder + "/" + photo)
+	}
+
+	jane := withPhoto("Jane Doe")
+	short := withPhoto("J. Doe")
+
+	if actor := merge(short, jane); actor.Photo != jane.Photo || photoExists(short.Photo) || !photoExists(jane.Photo) {
+		t.Error("the photo of the source should be removed when the actor has a photo", actor.Photo)
+	}
+
+	janie, _ := server.Storage.GetActorByName("Janie")
+
+	if actor := merge(jane, janie); actor.Photo != jane.Photo || !photoExists(jane.Photo) {
+		t.Error("the actor without photo should keep the photo of the source", actor.Photo)
+	}
+}
@@ -0,0 +1,407 @@
+package mpcserver
+
+import (
+	"github.com/jempe/mpc/library"
+	"github.com/jempe/mpc/storage"
+	"github.com/jempe/mpc/utils"
+	"encoding/json"
+	"errors"
+	"github.com/google/uuid"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+type BulkRequest struct {
+	Filter *mpcstorage.VideoFilter `json:"filter"`
+	IDs    []string                `json:"ids"`
+	Action string                  `json:"action"`
+	Values []string                `json:"values"`
+	Fields VideoPatch              `json:"fields"`
+	DryRun bool                    `json:"dryRun"`
+	// All must be set to apply the action to all the videos with an empty filter
+	All bool `json:"all"`
+}
+
+type BulkJob struct {
+	ID       string                  `json:"id"`
+	Action   string                  `json:"action"`
+	DryRun   bool                    `json:"dryRun"`
+	Status   string                  `json:"status"`
+	Total    int                     `json:"total"`
+	Done     int                     `json:"done"`
+	Failed   int                     `json:"failed"`
+	Errors   []string                `json:"errors"`
+	Changes  []mpcstorage.BulkChange `json:"changes"`
+	Started  time.Time               `json:"started"`
+	Finished time.Time               `json:"finished"`
+}
+
+const (
+	BulkJobRunning  = "running"
+	BulkJobFinished = "finished"
+	BulkJobFailed   = "failed"
+)
+
+// bulkJobTTL is the time that the finished jobs are kept
+const bulkJobTTL = time.Hour
+
+// fileActions are the bulk actions that change the library files, they run in the background
+var fileActions = map[string]bool{
+	"screenshots":  true,
+	"thumbnails":   true,
+	"encrypt":      true,
+	"decrypt":      true,
+	"fingerprints": true,
+}
+
+// BulkHandler applies an action to the videos that meet a filter or to a list of videos
+//
+// DB actions: addActors, removeActors, addCategories, removeCategories, addTags, removeTags, set and delete
+// An empty filter needs "all": true, the finished jobs are kept for an hour
+// File actions: screenshots, thumbnails, encrypt, decrypt and fingerprints
+//
+func (server *Server) BulkHandler(w http.ResponseWriter, r *http.Request) {
+	user, ok := server.adminUser(w, r)
+	if !ok {
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		http.Error(w, "Invalid Request", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var request BulkRequest
+
+	err := json.NewDecoder(r.Body).Decode(&request)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	videoIDs := request.IDs
+
+	if request.Filter != nil {
+		// an empty filter matches the whole library, it must be confirmed with all
+		if request.Filter.Empty() && !request.All {
+			http.Error(w, "bulk_filter_empty", http.StatusBadRequest)
+			return
+		}
+
+		videoIDs = server.Storage.FilterVideoIDs(*request.Filter)
+	}
+
+	job := server.newBulkJob(request.Action, request.DryRun, len(videoIDs))
+
+	if fileActions[request.Action] {
+		if request.DryRun {
+			job.Changes = server.previewFileAction(request.Action, videoIDs)
+			server.finishBulkJob(job, nil)
+		} else {
+			go server.runFileAction(job, videoIDs)
+		}
+
+		writeJSON(w, server.bulkJobStatus(job.ID))
+		return
+	}
+
+	var changes []mpcstorage.BulkChange
+
+	if request.Action == "delete" {
+		changes, err = server.Storage.DeleteVideos(videoIDs, request.DryRun)
+	} else {
+		var update func(video mpclibrary.Video) mpcstorage.VideoUpdate
+
+		update, err = bulkUpdate(request)
+		if err == nil {
+			changes, err = server.Storage.BulkUpdateVideos(videoIDs, update, user, request.DryRun)
+		}
+	}
+
+	job.Changes = changes
+	server.finishBulkJob(job, err)
+
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	writeJSON(w, server.bulkJobStatus(job.ID))
+}
+
+// BulkJobHandler shows the progress of a bulk job, GET /bulk/{id}
+//
+func (server *Server) BulkJobHandler(w http.ResponseWriter, r *http.Request) {
+	_, ok := server.adminUser(w, r)
+	if !ok {
+		return
+	}
+
+	job := server.bulkJobStatus(strings.TrimPrefix(r.URL.Path, "/bulk/"))
+	if job.ID == "" {
+		http.Error(w, "job_not_exists", http.StatusNotFound)
+		return
+	}
+
+	writeJSON(w, job)
+}
+
+// bulkUpdate gets the function that creates the update of every video for a DB action
+//
+func bulkUpdate(request BulkRequest) (update func(video mpclibrary.Video) mpcstorage.VideoUpdate, err error) {
+	switch request.Action {
+	case "addActors", "removeActors":
+		update = func(video mpclibrary.Video) mpcstorage.VideoUpdate {
+			var names []string
+			for _, actor := range video.Actors {
+				names = append(names, actor.Name)
+			}
+
+			names = changeNames(names, request.Values, request.Action == "addActors")
+
+			return mpcstorage.VideoUpdate{Actors: &names}
+		}
+	case "addCategories", "removeCategories":
+		update = func(video mpclibrary.Video) mpcstorage.VideoUpdate {
+			var names []string
+			for _, category := range video.Categories {
+				names = append(names, category.Name)
+			}
+
+			names = changeNames(names, request.Values, request.Action == "addCategories")
+
+			return mpcstorage.VideoUpdate{Categories: &names}
+		}
+	case "addTags", "removeTags":
+		update = func(video mpclibrary.Video) mpcstorage.VideoUpdate {
+			var names []string
+			for _, tag := range video.Tags {
+				names = append(names, tag.Name)
+			}
+
+			names = changeNames(names, request.Values, request.Action == "addTags")
+
+			return mpcstorage.VideoUpdate{Tags: &names}
+		}
+	case "set":
+		fields := mpcstorage.VideoUpdate{Title: request.Fields.Title, Description: request.Fields.Description}
+
+		if request.Fields.PubDate != nil {
+			var pubDate time.Time
+
+			if *request.Fields.PubDate != "" {
+				pubDate, err = mpcutils.ParseDate(*request.Fields.PubDate)
+				if err != nil || pubDate.IsZero() {
+					return update, errors.New("video_date_invalid")
+				}
+			}
+
+			fields.PubDate = &pubDate
+		}
+
+		update = func(video mpclibrary.Video) mpcstorage.VideoUpdate {
+			return fields
+		}
+	default:
+		err = errors.New("bulk_action_invalid")
+	}
+
+	return
+}
+
+// changeNames adds or removes the values from the list of names
+//
+func changeNames(names []string, values []string, add bool) (changed []string) {
+	changed = []string{}
+
+	for _, name := range names {
+		found := false
+		for _, value := range values {
+			if strings.EqualFold(name, strings.TrimSpace(value)) {
+				found = true
+			}
+		}
+
+		if add || !found {
+			changed = append(changed, name)
+		}
+	}
+
+	if add {
+		for _, value := range values {
+			found := false
+			for _, name := range changed {
+				if strings.EqualFold(name, strings.TrimSpace(value)) {
+					found = true
+				}
+			}
+
+			if !found {
+				changed = append(changed, value)
+			}
+		}
+	}
+
+	return
+}
+
+// previewFileAction lists the videos that would be changed by a file action
+//
+func (server *Server) previewFileAction(action string, videoIDs []string) (changes []mpcstorage.BulkChange) {
+	for _, videoID := range videoIDs {
+		video, _ := server.Storage.GetVideoByID(videoID)
+
+		changes = append(changes, mpcstorage.BulkChange{VideoID: videoID, Title: video.Title, Changes: []mpcstorage.FieldChange{{Field: action, Old: video.File, New: video.File}}})
+	}
+
+	return
+}
+
+// runFileAction applies a file action to every video and updates the job progress, the workers of the
+// server process the videos at the same time
+//
+func (server *Server) runFileAction(job *BulkJob, videoIDs []string) {
+	workers := server.Workers
+	if workers < 1 {
+		workers = 1
+	}
+
+	queue := make(chan string)
+
+	var wg sync.WaitGroup
+
+	for worker := 0; worker < workers; worker++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			for videoID := range queue {
+				server.fileAction(job, videoID)
+			}
+		}()
+	}
+
+	for _, videoID := range videoIDs {
+		queue <- videoID
+	}
+
+	close(queue)
+	wg.Wait()
+
+	server.finishBulkJob(job, nil)
+}
+
+// fileAction applies the file action of the job to a video and adds the result to the job
+//
+func (server *Server) fileAction(job *BulkJob, videoID string) {
+	key := []byte(server.Key)
+
+	video, _ := server.Storage.GetVideoByID(videoID)
+
+	var err error
+	var change mpclibrary.FileChange
+
+	if video.ID == "" {
+		err = errors.New("video_not_exists")
+	} else {
+		switch job.Action {
+		case "screenshots":
+			err = server.Library.RegenerateScreenshots(video)
+		case "thumbnails":
+			err = server.Library.RegenerateThumbnail(video)
+		case "encrypt":
+			change, err = server.Library.EncryptVideo(video, key)
+			if err == nil {
+				err = server.commitFileChange(videoID, change, true)
+			}
+		case "decrypt":
+			change, err = server.Library.DecryptVideo(video, key)
+			if err == nil {
+				err = server.commitFileChange(videoID, change, false)
+			}
+		case "fingerprints":
+			err = server.fingerprintVideo(video)
+		}
+	}
+
+	server.jobsMutex.Lock()
+	job.Done++
+	if err != nil {
+		job.Failed++
+		job.Errors = append(job.Errors, videoID+": "+err.Error())
+	} else {
+		job.Changes = append(job.Changes, mpcstorage.BulkChange{VideoID: videoID, Title: video.Title, Changes: []mpcstorage.FieldChange{{Field: job.Action, Old: video.File, New: change.File}}})
+	}
+	server.jobsMutex.Unlock()
+}
+
+// commitFileChange points the video to its new file and removes the old files, the new files are removed
+// when the DB can't be updated so the video keeps its old files
+//
+func (server *Server) commitFileChange(videoID string, change mpclibrary.FileChange, encrypted bool) error {
+	err := server.Storage.SetVideoFile(videoID, change.File, encrypted)
+	if err != nil {
+		change.Rollback()
+		return err
+	}
+
+	return change.Commit()
+}
+
+// newBulkJob registers a new bulk job, the jobs that finished more than bulkJobTTL ago are removed
+//
+func (server *Server) newBulkJob(action string, dryRun bool, total int) *BulkJob {
+	job := &BulkJob{ID: uuid.New().String(), Action: action, DryRun: dryRun, Status: BulkJobRunning, Total: total, Started: time.Now()}
+
+	server.jobsMutex.Lock()
+	defer server.jobsMutex.Unlock()
+
+	if server.jobs == nil {
+		server.jobs = make(map[string]*BulkJob)
+	}
+
+	for jobID, oldJob := range server.jobs {
+		if oldJob.Status != BulkJobRunning && time.Since(oldJob.Finished) > bulkJobTTL {
+			delete(server.jobs, jobID)
+		}
+	}
+
+	server.jobs[job.ID] = job
+
+	return job
+}
+
+// finishBulkJob marks the job as finished or failed
+//
+func (server *Server) finishBulkJob(job *BulkJob, err error) {
+	server.jobsMutex.Lock()
+	defer server.jobsMutex.Unlock()
+
+	job.Finished = time.Now()
+	job.Status = BulkJobFinished
+
+	if err != nil {
+		job.Status = BulkJobFailed
+		job.Errors = append(job.Errors, err.Error())
+	} else if !fileActions[job.Action] || job.DryRun {
+		job.Done = job.Total
+	}
+}
+
+// bulkJobStatus gets a copy of the job
+//
+func (server *Server) bulkJobStatus(jobID string) (job BulkJob) {
+	server.jobsMutex.Lock()
+	defer server.jobsMutex.Unlock()
+
+	if server.jobs[jobID] != nil {
+		job = *server.jobs[jobID]
+		job.Errors = append([]string{}, job.Errors...)
+		job.Changes = append([]mpcstorage.BulkChange{}, job.Changes...)
+	}
+
+	return
+}
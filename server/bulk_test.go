@@ -0,0 +1,150 @@
+package mpcserver
+
+import (
+	"github.com/jempe/mpc/auth"
+	"github.com/jempe/mpc/library"
+	"github.com/jempe/mpc/storage"
+	"github.com/jempe/mpc/users"
+	"github.com/jempe/mpc/utils"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+	"testing"
+)
+
+// newTestServer creates a server with a library that has a video for every file name
+//
+func newTestServer(t *testing.T, files ...string) *Server {
+	libraryPath := t.TempDir()
+
+	testStorage := &mpcstorage.Storage{Path: t.TempDir()}
+
+	err := testStorage.InitDb()
+	if err != nil {
+		t.Fatal("Init DB error", err)
+	}
+
+	t.Cleanup(func() {
+		testStorage.Db.Close()
+	})
+
+	var videos []mpclibrary.Video
+
+	for _, file := range files {
+		err = ioutil.WriteFile(libraryPath+"/"+file, []byte("video "+file), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		videos = append(videos, mpclibrary.Video{File: file, Title: file})
+	}
+
+	err = testStorage.InsertVideos(videos)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = testStorage.GetAllVideos()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	auth := &mpcauth.Auth{Key: []byte("test session key"), Storage: testStorage}
+
+	return &Server{Storage: testStorage, Library: &mpclibrary.Library{Path: libraryPath}, Key: "ThisisAT3stKey12", Auth: auth}
+}
+
+// newTestUser creates a user with the role and gets its UUID
+//
+func newTestUser(t *testing.T, server *Server, role string) string {
+	userID, err := server.Storage.InsertUser(mpcusers.User{Name: role, Email: role + "@example.com", Password: "test1234", Role: role})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return userID
+}
+
+// sessionCookie logs in the user and gets the session cookie
+//
+func sessionCookie(t *testing.T, server *Server, userID string) *http.Cookie {
+	token, err := server.Auth.GenerateTokenString(userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &http.Cookie{Name: "sessionID", Value: token}
+}
+
+func TestBulkEncryptWhileReading(t *testing.T) {
+	var files []string
+
+	for index := 0; index < 20; index++ {
+		files = append(files, fmt.Sprintf("video%02d.mp4", index))
+	}
+
+	server := newTestServer(t, files...)
+	server.Workers = 4
+
+	videoIDs := server.Storage.VideoIDs()
+	job := server.newBulkJob("encrypt", false, len(videoIDs))
+
+	done := make(chan bool)
+
+	go func() {
+		server.runFileAction(job, videoIDs)
+		close(done)
+	}()
+
+	// the HTTP handlers read the videos while the job changes them, the storage lock is checked with
+	// go test -race -gcflags=all=-d=checkptr=0 because boltdb doesn't pass the pointer checks of -race
+	for running := true; running; {
+		select {
+		case <-done:
+			running = false
+		default:
+			server.Storage.GetVideos(0, 10, "title", mpcstorage.VideoFilter{}, 0)
+			server.Storage.GetVideoByID(videoIDs[0])
+			server.Storage.GetTagList()
+		}
+	}
+
+	status := server.bulkJobStatus(job.ID)
+	if status.Status != BulkJobFinished || status.Done != len(videoIDs) || status.Failed != 0 {
+		t.Fatal("the job should encrypt all the videos", status.Status, status.Done, status.Errors)
+	}
+
+	for _, videoID := range videoIDs {
+		video, _ := server.Storage.GetVideoByID(videoID)
+
+		if !video.Encrypted || !mpcutils.Exists(server.Library.Path+"/"+video.File) {
+			t.Error("the video should point to the encrypted file", video.File)
+		}
+	}
+
+	if plain, _ := filepath.Glob(server.Library.Path + "/*.mp4"); len(plain) != 0 {
+		t.Error("the plain files should be removed", plain)
+	}
+}
+
+func TestCommitFileChangeRollback(t *testing.T) {
+	server := newTestServer(t, "video.mp4")
+
+	video, _ := server.Storage.GetVideoByFileName("video.mp4")
+
+	change, err := server.Library.EncryptVideo(video, []byte(server.Key))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the DB update fails when the video was deleted while its files were encrypted
+	err = server.commitFileChange("deleted", change, true)
+	if err == nil {
+		t.Fatal("the change of a missing video should fail")
+	}
+
+	if mpcutils.Exists(server.Library.Path+"/"+change.File) || !mpcutils.Exists(server.Library.Path+"/video.mp4") {
+		t.Error("the encrypted file should be removed and the plain file kept")
+	}
+}
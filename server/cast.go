@@ -0,0 +1,308 @@
+package mpcserver
+
+import (
+	"github.com/jempe/mpc/auth"
+	"github.com/jempe/mpc/cast"
+	"github.com/jempe/mpc/remote"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// castSignatureTTL is how long a receiver can load the signed video after its end, the signature covers
+// the length of the video plus this margin, a video that is resumed after its signature expired gets a new one
+const castSignatureTTL = 10 * time.Minute
+
+// castDiscoveryTimeout is how long the server waits for the answers of the receivers
+const castDiscoveryTimeout = 2 * time.Second
+
+// castReceiverTTL is how long the receivers of a discovery are used before they are discovered again
+const castReceiverTTL = 5 * time.Minute
+
+// discoverReceivers finds the cast receivers of the LAN
+var discoverReceivers = mpccast.Discover
+
+// CastRequest starts a cast on a receiver, only the ID of the receiver is used and its address is
+// taken from the receivers that the server discovered
+type CastRequest struct {
+	Receiver mpccast.Receiver `json:"receiver"`
+	Video    string           `json:"video"`
+}
+
+type CastSessionInfo struct {
+	ID       string                `json:"id"`
+	Receiver mpccast.Receiver      `json:"receiver"`
+	State    mpcremote.PlayerState `json:"state"`
+}
+
+// CastHandler discovers the cast receivers of the LAN and plays videos on them, every cast session
+// is a player of the remote so the remotes control it like the web players
+//
+// GET /cast/receivers
+// GET and POST /cast/sessions
+// DELETE /cast/sessions/{id}
+//
+func (server *Server) CastHandler(w http.ResponseWriter, r *http.Request) {
+	user, ok := server.remoteUser(w, r)
+	if !ok {
+		return
+	}
+
+	identity := mpcremote.Identity{User: user.UUID, Role: user.Role, Admin: user.IsAdmin()}
+
+	uriSegments := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+
+	switch {
+	case len(uriSegments) == 2 && uriSegments[1] == "receivers" && r.Method == http.MethodGet:
+		receivers, err := server.discoverCastReceivers()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		writeJSON(w, receivers)
+	case len(uriSegments) == 2 && uriSegments[1] == "sessions" && r.Method == http.MethodGet:
+		writeJSON(w, server.castSessions())
+	case len(uriSegments) == 2 && uriSegments[1] == "sessions" && r.Method == http.MethodPost:
+		var request CastRequest
+
+		err := json.NewDecoder(r.Body).Decode(&request)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		if request.Receiver.ID == "" {
+			http.Error(w, "receiver_invalid", http.StatusBadRequest)
+			return
+		}
+
+		receiver, err := server.castReceiver(request.Receiver.ID)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		session, err := server.startCast(identity, receiver, request.Video)
+		if err == errCastNotAllowed {
+			http.Error(w, err.Error(), http.StatusForbidden)
+			return
+		} else if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		writeJSON(w, CastSessionInfo{ID: session.ID, Receiver: session.Receiver, State: session.State()})
+	case len(uriSegments) == 3 && uriSegments[1] == "sessions" && r.Method == http.MethodDelete:
+		server.castsMutex.Lock()
+		session, ok := server.casts[uriSegments[2]]
+		server.castsMutex.Unlock()
+
+		if !ok {
+			http.Error(w, "cast_session_not_exists", http.StatusNotFound)
+			return
+		}
+
+		// the admins can stop the sessions of the other users
+		if !identity.CanControl(session.ID) || (session.Owner != identity.User && !identity.Admin) {
+			http.Error(w, errCastNotAllowed.Error(), http.StatusForbidden)
+			return
+		}
+
+		session.Stop()
+
+		writeJSON(w, CastSessionInfo{ID: session.ID, Receiver: session.Receiver, State: session.State()})
+	default:
+		http.Error(w, "method_not_allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// errCastNotAllowed is the error of the requests on a cast session of another user
+var errCastNotAllowed = errors.New("cast_session_not_allowed")
+
+// discoverCastReceivers finds the receivers of the LAN and keeps them for castReceiver
+//
+func (server *Server) discoverCastReceivers() ([]mpccast.Receiver, error) {
+	receivers, err := discoverReceivers(castDiscoveryTimeout)
+	if err != nil {
+		return nil, err
+	}
+
+	found := make(map[string]mpccast.Receiver)
+
+	for _, receiver := range receivers {
+		found[receiver.ID] = receiver
+	}
+
+	server.castsMutex.Lock()
+	server.castReceivers = found
+	server.castReceiversFound = time.Now()
+	server.castsMutex.Unlock()
+
+	return receivers, nil
+}
+
+// castReceiver gets a receiver found by the last discovery, the receivers are discovered again when the
+// discovery is old or when it didn't find the receiver, so the clients can't cast to any host of the network
+//
+func (server *Server) castReceiver(receiverID string) (mpccast.Receiver, error) {
+	server.castsMutex.Lock()
+	receiver, ok := server.castReceivers[receiverID]
+	fresh := time.Since(server.castReceiversFound) < castReceiverTTL
+	server.castsMutex.Unlock()
+
+	if ok && fresh {
+		return receiver, nil
+	}
+
+	receivers, err := server.discoverCastReceivers()
+	if err != nil {
+		return mpccast.Receiver{}, err
+	}
+
+	for _, receiver := range receivers {
+		if receiver.ID == receiverID {
+			return receiver, nil
+		}
+	}
+
+	return mpccast.Receiver{}, errors.New("cast_receiver_not_exists")
+}
+
+// startCast plays the video on the receiver, the receiver keeps its session so the next videos are loaded
+// in the same session and its remote queue is kept. The session is only reused by the user that started it
+// because the URLs of its videos are signed for that user
+//
+func (server *Server) startCast(identity mpcremote.Identity, receiver mpccast.Receiver, videoID string) (*mpccast.Session, error) {
+	if server.ConnectPlayer == nil {
+		return nil, errors.New("remote_not_available")
+	}
+
+	id := "cast-" + receiver.ID
+
+	if !identity.CanControl(id) {
+		return nil, errCastNotAllowed
+	}
+
+	mediaFor := server.castMediaFor(identity.User)
+
+	if _, err := mediaFor(videoID); err != nil {
+		return nil, err
+	}
+
+	server.castsMutex.Lock()
+	session, ok := server.casts[id]
+	server.castsMutex.Unlock()
+
+	if ok {
+		return reuseCast(session, identity, videoID)
+	}
+
+	client, err := mpccast.Dial(receiver.Address())
+	if err != nil {
+		return nil, err
+	}
+
+	err = client.Launch(mpccast.DefaultMediaReceiver)
+	if err != nil {
+		client.Close()
+		return nil, err
+	}
+
+	player := server.ConnectPlayer(identity, id, "", receiver.Name)
+
+	session = mpccast.NewSession(id, receiver, identity.User, client, player, mediaFor)
+
+	err = session.Play(videoID)
+	if err != nil {
+		session.Close()
+		return nil, err
+	}
+
+	server.castsMutex.Lock()
+	if server.casts == nil {
+		server.casts = make(map[string]*mpccast.Session)
+	}
+
+	// another request could start a session with the same receiver at the same time
+	if previous, ok := server.casts[id]; ok {
+		server.castsMutex.Unlock()
+		session.Close()
+
+		return reuseCast(previous, identity, videoID)
+	}
+
+	server.casts[id] = session
+	server.castsMutex.Unlock()
+
+	go func() {
+		session.Run()
+
+		server.castsMutex.Lock()
+		if server.casts[id] == session {
+			delete(server.casts, id)
+		}
+		server.castsMutex.Unlock()
+
+		log.Println("Cast session closed:", receiver.Name)
+	}()
+
+	return session, nil
+}
+
+// reuseCast plays the video in the running session of the receiver if the user started it
+//
+func reuseCast(session *mpccast.Session, identity mpcremote.Identity, videoID string) (*mpccast.Session, error) {
+	if session.Owner != identity.User {
+		return nil, errCastNotAllowed
+	}
+
+	return session, session.Play(videoID)
+}
+
+// castSessions lists the running cast sessions
+//
+func (server *Server) castSessions() (sessions []CastSessionInfo) {
+	sessions = []CastSessionInfo{}
+
+	server.castsMutex.Lock()
+	defer server.castsMutex.Unlock()
+
+	for _, session := range server.casts {
+		sessions = append(sessions, CastSessionInfo{ID: session.ID, Receiver: session.Receiver, State: session.State()})
+	}
+
+	return
+}
+
+// castMediaFor gets the media of the videos for the receivers, the receivers don't have the session cookie
+// so the URLs are signed for the user that started the session, every load of a video gets a new signature
+// that expires a while after the end of the video
+//
+func (server *Server) castMediaFor(userID string) func(videoID string) (mpccast.Media, error) {
+	return func(videoID string) (media mpccast.Media, err error) {
+		video, err := server.Storage.GetVideoByID(videoID)
+		if err != nil || video.ID == "" {
+			return media, errors.New("video_not_exists")
+		}
+
+		ttl := time.Duration(video.Duration)*time.Second + castSignatureTTL
+
+		signed, err := server.signMedia(video.ID, userID, []string{mpcauth.OperationStream, mpcauth.OperationThumb}, ttl)
+		if err != nil {
+			return
+		}
+
+		media.URL = signed.StreamURL
+		media.ContentType = "video/mp4"
+		media.Title = video.Title
+		media.ImageURL = signed.ThumbURL
+		media.Duration = float64(video.Duration)
+		media.Expires = signed.Expires
+
+		return
+	}
+}
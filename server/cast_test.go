@@ -0,0 +1,51 @@
+package mpcserver
+
+import (
+	"github.com/jempe/mpc/cast"
+	"github.com/jempe/mpc/users"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCastOnlyDiscoveredReceivers(t *testing.T) {
+	server := newTestServer(t, "movie.mp4")
+	user := sessionCookie(t, server, newTestUser(t, server, mpcusers.RoleUser))
+
+	discoveries := 0
+
+	discoverReceivers = func(timeout time.Duration) ([]mpccast.Receiver, error) {
+		discoveries++
+		return []mpccast.Receiver{{ID: "tv", Name: "TV", Host: "192.168.1.20", Port: 8009}}, nil
+	}
+
+	t.Cleanup(func() {
+		discoverReceivers = mpccast.Discover
+	})
+
+	// the address sent by the client is replaced by the address that was discovered
+	receiver, err := server.castReceiver("tv")
+	if err != nil || receiver.Host != "192.168.1.20" || receiver.Port != 8009 {
+		t.Error("the receiver should be found by the discovery", receiver, err)
+	}
+
+	if _, err = server.castReceiver("tv"); err != nil || discoveries != 1 {
+		t.Error("the receivers of the last discovery should be used", discoveries, err)
+	}
+
+	r := httptest.NewRequest(http.MethodPost, "/cast/sessions", strings.NewReader(`{"receiver": {"id": "router", "host": "192.168.1.1", "port": 80}, "video": "movie"}`))
+	r.AddCookie(user)
+
+	w := httptest.NewRecorder()
+	server.CastHandler(w, r)
+
+	if w.Code != http.StatusBadRequest || strings.TrimSpace(w.Body.String()) != "cast_receiver_not_exists" {
+		t.Error("the receivers that were not discovered should be rejected", w.Code, w.Body.String())
+	}
+
+	if discoveries != 2 {
+		t.Error("an unknown receiver should be discovered again", discoveries)
+	}
+}
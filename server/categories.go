@@ -0,0 +1,141 @@
+package mpcserver
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+type CategoryUpdate struct {
+	Name   *string `json:"name"`
+	Parent *int    `json:"parent"`
+}
+
+type CategoryMerge struct {
+	Source int `json:"source"`
+}
+
+// CategoriesHandler shows the JSON categories list and creates new categories
+//
+func (server *Server) CategoriesHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodPost {
+		if _, ok := server.adminUser(w, r); !ok {
+			return
+		}
+
+		var update CategoryUpdate
+
+		err := json.NewDecoder(r.Body).Decode(&update)
+		if err != nil || update.Name == nil {
+			http.Error(w, "Invalid Request", http.StatusBadRequest)
+			return
+		}
+
+		parent := 0
+		if update.Parent != nil {
+			parent = *update.Parent
+		}
+
+		category, err := server.Storage.CreateCategory(*update.Name, parent)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		writeJSON(w, category)
+		return
+	}
+
+	writeJSON(w, server.Storage.GetCategoryList())
+}
+
+// CategoryHandler manages the categories
+//
+// GET, PATCH and DELETE /categories/{id}, the changes need an admin
+// POST /categories/{id}/merge merges the source category into the category
+//
+func (server *Server) CategoryHandler(w http.ResponseWriter, r *http.Request) {
+	uriSegments := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+
+	if len(uriSegments) < 2 {
+		http.Error(w, "Invalid Request", http.StatusBadRequest)
+		return
+	}
+
+	categoryID, err := strconv.Atoi(uriSegments[1])
+	if err != nil {
+		http.Error(w, "Invalid Request", http.StatusBadRequest)
+		return
+	}
+
+	// everyone can see the categories, only the admins change them
+	if r.Method != http.MethodGet {
+		if _, ok := server.adminUser(w, r); !ok {
+			return
+		}
+	}
+
+	category, _ := server.Storage.GetCategoryByID(categoryID)
+	if category.Name == "" {
+		http.Error(w, "category_not_exists", http.StatusNotFound)
+		return
+	}
+
+	action := strings.Join(uriSegments[2:], "/")
+
+	switch {
+	case action == "" && r.Method == http.MethodGet:
+		writeJSON(w, category)
+	case action == "" && r.Method == http.MethodPatch:
+		var update CategoryUpdate
+
+		err = json.NewDecoder(r.Body).Decode(&update)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		if update.Name != nil {
+			category.Name = *update.Name
+		}
+
+		if update.Parent != nil {
+			category.Parent = *update.Parent
+		}
+
+		err = server.Storage.UpdateCategory(category)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		writeJSON(w, category)
+	case action == "" && r.Method == http.MethodDelete:
+		err = server.Storage.DeleteCategory(categoryID)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	case action == "merge" && r.Method == http.MethodPost:
+		var merge CategoryMerge
+
+		err = json.NewDecoder(r.Body).Decode(&merge)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		category, err = server.Storage.MergeCategories(merge.Source, categoryID)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		writeJSON(w, category)
+	default:
+		http.Error(w, "Invalid Request", http.StatusBadRequest)
+	}
+}
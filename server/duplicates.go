@@ -0,0 +1,225 @@
+package mpcserver
+
+import (
+	"github.com/jempe/mpc/duplicates"
+	"github.com/jempe/mpc/library"
+	"github.com/jempe/mpc/storage"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+	"strings"
+)
+
+type DuplicatesReport struct {
+	Groups []DuplicateGroup `json:"groups"`
+	// Fingerprinted is the number of videos whose frames were hashed, the other videos are not compared
+	Fingerprinted int `json:"fingerprinted"`
+	Total         int `json:"total"`
+}
+
+type DuplicateGroup struct {
+	// Best is the ID of the copy that is kept by default
+	Best   string           `json:"best"`
+	Videos []DuplicateVideo `json:"videos"`
+}
+
+type DuplicateVideo struct {
+	mpcduplicates.Copy
+	Title     string `json:"title"`
+	File      string `json:"file"`
+	Encrypted bool   `json:"encrypted"`
+}
+
+type MergeRequest struct {
+	// Keep is the ID of the video that is kept, the best copy is kept when it's empty
+	Keep   string   `json:"keep"`
+	Videos []string `json:"videos"`
+	// DeleteFiles deletes the files of the duplicates from the library
+	DeleteFiles bool `json:"deleteFiles"`
+	DryRun      bool `json:"dryRun"`
+}
+
+// DuplicatesHandler finds the videos that are copies of other videos by the hashes of their screenshots
+//
+// GET /duplicates shows the groups of duplicates with the resolution, bitrate and size of every copy
+// POST /duplicates/scan hashes the screenshots of the videos that don't have a fingerprint, the progress is in /bulk/{id}
+// POST /duplicates/merge keeps a copy with the metadata of the others and deletes the others
+//
+func (server *Server) DuplicatesHandler(w http.ResponseWriter, r *http.Request) {
+	user, ok := server.adminUser(w, r)
+	if !ok {
+		return
+	}
+
+	action := strings.Trim(strings.TrimPrefix(r.URL.Path, "/duplicates"), "/")
+
+	switch {
+	case action == "" && r.Method == http.MethodGet:
+		report, err := server.duplicatesReport()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		writeJSON(w, report)
+	case action == "scan" && r.Method == http.MethodPost:
+		fingerprints, err := server.Storage.GetFingerprints()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		fingerprinted := make(map[string]bool)
+		for _, fingerprint := range fingerprints {
+			fingerprinted[fingerprint.VideoID] = true
+		}
+
+		var videoIDs []string
+		for _, videoID := range server.Storage.VideoIDs() {
+			if !fingerprinted[videoID] {
+				videoIDs = append(videoIDs, videoID)
+			}
+		}
+
+		job := server.newBulkJob("fingerprints", false, len(videoIDs))
+
+		go server.runFileAction(job, videoIDs)
+
+		writeJSON(w, server.bulkJobStatus(job.ID))
+	case action == "merge" && r.Method == http.MethodPost:
+		var request MergeRequest
+
+		err := json.NewDecoder(r.Body).Decode(&request)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		changes, err := server.mergeDuplicates(request, user)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		writeJSON(w, changes)
+	default:
+		http.Error(w, "method_not_allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// duplicatesReport groups the videos that have a fingerprint
+//
+func (server *Server) duplicatesReport() (report DuplicatesReport, err error) {
+	fingerprints, err := server.Storage.GetFingerprints()
+	if err != nil {
+		return
+	}
+
+	report.Groups = []DuplicateGroup{}
+	report.Fingerprinted = len(fingerprints)
+	report.Total = server.Storage.CountVideos()
+
+	for _, videoIDs := range mpcduplicates.Group(fingerprints, mpcduplicates.DefaultOptions) {
+		var group DuplicateGroup
+		var copies []mpcduplicates.Copy
+
+		for _, videoID := range videoIDs {
+			video, _ := server.Storage.GetVideoByID(videoID)
+
+			duplicate := DuplicateVideo{Copy: server.videoCopy(video), Title: video.Title, File: video.File, Encrypted: video.Encrypted}
+
+			group.Videos = append(group.Videos, duplicate)
+			copies = append(copies, duplicate.Copy)
+		}
+
+		group.Best = mpcduplicates.BestCopy(copies)
+
+		report.Groups = append(report.Groups, group)
+	}
+
+	return
+}
+
+// videoCopy gets the resolution, size and bitrate of the video file
+//
+func (server *Server) videoCopy(video mpclibrary.Video) mpcduplicates.Copy {
+	videoCopy := mpcduplicates.Copy{VideoID: video.ID, Width: video.Width, Height: video.Height, Duration: video.Duration}
+
+	if info, err := os.Stat(server.Library.Path + "/" + video.File); err == nil {
+		videoCopy.Size = info.Size()
+
+		if video.Duration > 0 {
+			videoCopy.Bitrate = videoCopy.Size * 8 / int64(video.Duration)
+		}
+	}
+
+	return videoCopy
+}
+
+// mergeDuplicates merges the videos into the video that is kept and deletes the files of the others when it's requested
+//
+func (server *Server) mergeDuplicates(request MergeRequest, user string) (changes []mpcstorage.BulkChange, err error) {
+	keep := request.Keep
+
+	if keep == "" {
+		var copies []mpcduplicates.Copy
+
+		for _, videoID := range request.Videos {
+			video, _ := server.Storage.GetVideoByID(videoID)
+			if video.ID == "" {
+				return nil, errors.New("video_not_exists")
+			}
+
+			copies = append(copies, server.videoCopy(video))
+		}
+
+		keep = mpcduplicates.BestCopy(copies)
+	}
+
+	var duplicates []mpclibrary.Video
+
+	for _, videoID := range request.Videos {
+		if videoID == keep {
+			continue
+		}
+
+		video, _ := server.Storage.GetVideoByID(videoID)
+		duplicates = append(duplicates, video)
+	}
+
+	var duplicateIDs []string
+	for _, video := range duplicates {
+		duplicateIDs = append(duplicateIDs, video.ID)
+	}
+
+	changes, err = server.Storage.MergeVideos(keep, duplicateIDs, user, request.DryRun)
+	if err != nil || request.DryRun || !request.DeleteFiles {
+		return
+	}
+
+	for _, video := range duplicates {
+		err = server.Library.DeleteVideoFiles(video)
+		if err != nil {
+			return
+		}
+	}
+
+	return
+}
+
+// fingerprintVideo hashes the screenshots of the video and saves its fingerprint
+//
+func (server *Server) fingerprintVideo(video mpclibrary.Video) error {
+	screenshots, err := server.Library.Screenshots(video, []byte(server.Key))
+	if err != nil {
+		return err
+	}
+
+	hashes, err := mpcduplicates.HashFrames(screenshots)
+	if err != nil {
+		return err
+	}
+
+	return server.Storage.SaveFingerprint(mpcduplicates.Fingerprint{VideoID: video.ID, File: video.File, Duration: video.Duration, Hashes: hashes})
+}
@@ -0,0 +1,93 @@
+package mpcserver
+
+import (
+	"github.com/jempe/mpc/auth"
+	"github.com/jempe/mpc/export"
+	"bytes"
+	"mime"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// exportSignatureTTL is the default validity of the URLs of the exported playlists
+const exportSignatureTTL = 24 * time.Hour
+
+// ExportHandler exports a playlist, a collection, the videos of an actor or the filtered videos as a playlist
+// for VLC and Kodi, the videos have signed URLs because the players can't send the session cookie
+//
+// GET /videos.m3u8 and /videos.xspf with the playlist, collection or actor parameters,
+// or the category, title, tag, quality and sort parameters of the videos list
+//
+func (server *Server) ExportHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := server.loggedUser(w, r)
+	if !ok {
+		return
+	}
+
+	format := strings.TrimPrefix(path.Ext(r.URL.Path), ".")
+
+	query := r.URL.Query()
+
+	selection := mpcexport.Selection{
+		Playlist: query.Get("playlist"),
+		Sort:     query.Get("sort"),
+	}
+
+	selection.Collection, _ = strconv.Atoi(query.Get("collection"))
+	selection.Actor, _ = strconv.Atoi(query.Get("actor"))
+	selection.Filter.Category = query.Get("category")
+	selection.Filter.Title = query.Get("title")
+	selection.Filter.Tag = query.Get("tag")
+	selection.Filter.Quality = query.Get("quality")
+
+	if selection.Playlist != "" {
+		playlist, err := server.Storage.GetPlaylist(selection.Playlist)
+		if err != nil || !playlist.CanView(userID) {
+			http.Error(w, "playlist_not_exists", http.StatusNotFound)
+			return
+		}
+	}
+
+	ttl := exportSignatureTTL
+
+	if seconds, err := strconv.Atoi(query.Get("ttl")); err == nil && seconds > 0 {
+		ttl = time.Duration(seconds) * time.Second
+	}
+
+	if ttl > maxMediaSignatureTTL {
+		http.Error(w, "media_ttl_too_long", http.StatusBadRequest)
+		return
+	}
+
+	title, videos, err := mpcexport.Select(server.Storage, selection)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	expires := time.Now().Add(ttl).Unix()
+
+	playlist, err := mpcexport.NewPlaylist(title, videos, server.BaseURL, func(videoID string) (string, error) {
+		return server.Auth.SignMedia(mpcauth.MediaClaims{Video: videoID, User: userID, Operations: []string{mpcauth.OperationStream, mpcauth.OperationThumb}, Expires: expires})
+	})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	var output bytes.Buffer
+
+	err = mpcexport.Write(&output, format, playlist)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", mpcexport.ContentType(format))
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": title + "." + format}))
+
+	w.Write(output.Bytes())
+}
@@ -0,0 +1,67 @@
+package mpcserver
+
+import (
+	"github.com/jempe/mpc/fsck"
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+type FsckRepairRequest struct {
+	// Kinds are the kinds of the issues that are repaired, all the issues are repaired when it's empty
+	Kinds     []string `json:"kinds"`
+	Checksums bool     `json:"checksums"`
+	// DryRun is true when it's not in the request
+	DryRun *bool `json:"dryRun"`
+}
+
+// FsckHandler checks that the DB and the library folder agree and repairs the issues
+//
+// GET /fsck?checksums=1 shows the issues, the MD5 sums of the files are only compared when checksums is set
+// POST /fsck/repair checks the library again and repairs the issues, the repairs are a dry run unless dryRun is false
+//
+func (server *Server) FsckHandler(w http.ResponseWriter, r *http.Request) {
+	user, ok := server.adminUser(w, r)
+	if !ok {
+		return
+	}
+
+	action := strings.Trim(strings.TrimPrefix(r.URL.Path, "/fsck"), "/")
+
+	checker := &mpcfsck.Checker{Storage: server.Storage, Library: server.Library, User: user}
+
+	switch {
+	case action == "" && r.Method == http.MethodGet:
+		checker.Checksums = r.URL.Query().Get("checksums") == "1"
+
+		report, err := checker.Check()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		writeJSON(w, report)
+	case action == "repair" && r.Method == http.MethodPost:
+		var request FsckRepairRequest
+
+		err := json.NewDecoder(r.Body).Decode(&request)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		checker.Checksums = request.Checksums
+
+		report, err := checker.Check()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		dryRun := request.DryRun == nil || *request.DryRun
+
+		writeJSON(w, checker.Repair(mpcfsck.Filter(report.Issues, request.Kinds), dryRun))
+	default:
+		http.Error(w, "method_not_allowed", http.StatusMethodNotAllowed)
+	}
+}
@@ -0,0 +1,135 @@
+package mpcserver
+
+import (
+	"github.com/jempe/mpc/artwork"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// thumbWidths are the widths of the thumbs and screenshots that can be requested with the w parameter
+var thumbWidths = map[int]bool{
+	160:  true,
+	320:  true,
+	480:  true,
+	640:  true,
+	1280: true,
+}
+
+// imageFile is an image of the library that can be resized
+type imageFile struct {
+	// Name identifies the image in the cache of resized images
+	Name    string
+	ModTime time.Time
+	// Encrypted images are saved encrypted in the cache
+	Encrypted bool
+	Load      func() ([]byte, error)
+}
+
+// imageSize gets the size of the w parameter or the size of a variant of the artwork,
+// the label is empty when the original image is requested
+//
+func imageSize(r *http.Request) (label string, size mpcartwork.Size, err error) {
+	if width := r.URL.Query().Get("w"); width != "" {
+		value, err := strconv.Atoi(width)
+		if err != nil || !thumbWidths[value] {
+			return "", size, errors.New("image_width_invalid")
+		}
+
+		return "w" + width, mpcartwork.Size{Width: value}, nil
+	}
+
+	if variant := r.URL.Query().Get("variant"); variant != "" && variant != mpcartwork.VariantOriginal {
+		size, ok := mpcartwork.Variants[variant]
+		if !ok {
+			return "", size, errors.New("artwork_variant_invalid")
+		}
+
+		return variant, size, nil
+	}
+
+	return
+}
+
+// serveImage sends the image resized to the size of the label in the best format that the client accepts,
+// the ETag is the hash of the content and the Last-Modified is the modification time of the original image
+//
+func (server *Server) serveImage(w http.ResponseWriter, r *http.Request, image imageFile, label string, size mpcartwork.Size) {
+	encoder := mpcartwork.NegotiateEncoder(r.Header.Get("Accept"))
+
+	var data []byte
+	var err error
+
+	if label == "" && encoder.ContentType == mpcartwork.JPEG.ContentType {
+		data, err = image.Load()
+	} else {
+		data, err = server.resizedImage(image, label, size, encoder)
+	}
+
+	if err != nil {
+		log.Println("Image", image.Name, err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	sum := sha256.Sum256(data)
+
+	w.Header().Set("Vary", "Accept")
+	w.Header().Set("Content-Type", encoder.ContentType)
+	w.Header().Set("ETag", `"`+hex.EncodeToString(sum[:16])+`"`)
+
+	http.ServeContent(w, r, "", image.ModTime, bytes.NewReader(data))
+}
+
+// resizedImage gets the image from the cache, the images that are not cached or that are older
+// than the original are resized and saved in the cache
+//
+func (server *Server) resizedImage(image imageFile, label string, size mpcartwork.Size, encoder mpcartwork.Encoder) ([]byte, error) {
+	if label == "" {
+		label = mpcartwork.VariantOriginal
+	}
+
+	name := image.Name + "_" + label + encoder.Extension
+
+	if server.ImageCache != nil {
+		data, modTime, err := server.ImageCache.Load(name, image.Encrypted)
+		if err == nil && !modTime.Before(image.ModTime) {
+			return data, nil
+		}
+	}
+
+	data, err := image.Load()
+	if err != nil {
+		return nil, err
+	}
+
+	img, err := mpcartwork.Decode(data)
+	if err != nil {
+		return nil, err
+	}
+
+	if label != mpcartwork.VariantOriginal {
+		img = mpcartwork.Resize(img, size)
+	}
+
+	var resized bytes.Buffer
+
+	err = encoder.Encode(&resized, img)
+	if err != nil {
+		return nil, err
+	}
+
+	if server.ImageCache != nil {
+		err = server.ImageCache.Save(name, resized.Bytes(), image.Encrypted)
+		if err != nil {
+			log.Println("Image cache", name, err)
+		}
+	}
+
+	return resized.Bytes(), nil
+}
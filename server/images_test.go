@@ -0,0 +1,220 @@
+package mpcserver
+
+import (
+	"github.com/jempe/mpc/artwork"
+	"bytes"
+	"context"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+)
+
+// testImageFile creates a JPEG image of 640x480 that counts how many times it is loaded
+//
+func testImageFile(t *testing.T, name string, encrypted bool, loads *int) imageFile {
+	var data bytes.Buffer
+
+	err := jpeg.Encode(&data, image.NewRGBA(image.Rect(0, 0, 640, 480)), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return imageFile{
+		Name:      name,
+		ModTime:   time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+		Encrypted: encrypted,
+		Load: func() ([]byte, error) {
+			*loads++
+			return data.Bytes(), nil
+		},
+	}
+}
+
+// getImage requests the image with the query and the headers
+//
+func getImage(server *Server, image imageFile, query string, headers map[string]string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodGet, "/videos/thumbs/video.jpg"+query, nil)
+
+	for key, value := range headers {
+		r.Header.Set(key, value)
+	}
+
+	w := httptest.NewRecorder()
+
+	label, size, err := imageSize(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return w
+	}
+
+	server.serveImage(w, r, image, label, size)
+
+	return w
+}
+
+func TestImageWidths(t *testing.T) {
+	server := &Server{ImageCache: &mpcartwork.Cache{Path: t.TempDir()}}
+
+	loads := 0
+	image := testImageFile(t, "thumbs/video", false, &loads)
+
+	for _, query := range []string{"?w=321", "?w=0", "?w=big", "?variant=huge"} {
+		if w := getImage(server, image, query, nil); w.Code != http.StatusBadRequest {
+			t.Error("the width should be one of the allowed widths", query, w.Code)
+		}
+	}
+
+	w := getImage(server, image, "?w=320", map[string]string{"Accept": "image/webp,image/*,*/*;q=0.8"})
+	if w.Code != http.StatusOK {
+		t.Fatal(w.Code, w.Body.String())
+	}
+
+	// there is no WebP encoder so the clients that accept WebP get JPEG
+	if w.Header().Get("Content-Type") != "image/jpeg" || w.Header().Get("Vary") != "Accept" {
+		t.Error("wrong headers", w.Header())
+	}
+
+	config, err := jpeg.DecodeConfig(w.Body)
+	if err != nil || config.Width != 320 || config.Height != 240 {
+		t.Error("the image should be resized keeping the aspect ratio", config, err)
+	}
+
+	if loads != 1 {
+		t.Error("the original should be loaded once", loads)
+	}
+}
+
+func TestImageETag(t *testing.T) {
+	server := &Server{ImageCache: &mpcartwork.Cache{Path: t.TempDir()}}
+
+	loads := 0
+	image := testImageFile(t, "thumbs/video", false, &loads)
+
+	w := getImage(server, image, "?w=160", nil)
+
+	etag := w.Header().Get("ETag")
+	if w.Code != http.StatusOK || etag == "" || w.Header().Get("Last-Modified") != "Tue, 02 Jan 2024 03:04:05 GMT" {
+		t.Fatal("the ETag and the time of the original should be sent", w.Code, w.Header())
+	}
+
+	w = getImage(server, image, "?w=160", map[string]string{"If-None-Match": etag})
+	if w.Code != http.StatusNotModified || w.Body.Len() != 0 {
+		t.Error("the same ETag should not send the image again", w.Code)
+	}
+
+	if loads != 1 {
+		t.Error("the resized image should be loaded from the cache", loads)
+	}
+
+	if w = getImage(server, image, "?w=480", map[string]string{"If-None-Match": etag}); w.Code != http.StatusOK || w.Header().Get("ETag") == etag {
+		t.Error("another width should have another ETag", w.Code, w.Header())
+	}
+
+	// a newer original is resized again
+	image.ModTime = time.Now().Add(time.Hour)
+
+	getImage(server, image, "?w=160", nil)
+
+	if loads != 3 {
+		t.Error("the cached image older than the original should be replaced", loads)
+	}
+}
+
+func TestImageEncryptedCache(t *testing.T) {
+	cachePath := t.TempDir()
+	server := &Server{ImageCache: &mpcartwork.Cache{Path: cachePath, Key: []byte("ThisisAT3stKey12")}}
+
+	loads := 0
+	image := testImageFile(t, "screenshots/video/10", true, &loads)
+
+	w := getImage(server, image, "?w=640", nil)
+	if w.Code != http.StatusOK {
+		t.Fatal(w.Code, w.Body.String())
+	}
+
+	if _, err := os.Stat(cachePath + "/screenshots/video/10_w640.jpg"); !os.IsNotExist(err) {
+		t.Error("the image of an encrypted video should not be cached in clear", err)
+	}
+
+	cached, err := ioutil.ReadFile(cachePath + "/screenshots/video/10_w640.jpg.enc")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = jpeg.DecodeConfig(bytes.NewReader(cached)); err == nil {
+		t.Error("the cached image should be encrypted")
+	}
+
+	second := getImage(server, image, "?w=640", nil)
+	if loads != 1 || !bytes.Equal(second.Body.Bytes(), w.Body.Bytes()) {
+		t.Error("the cached image should be decrypted", loads)
+	}
+
+	server.ImageCache.Key = nil
+
+	if w = getImage(server, image, "?w=320", nil); w.Code != http.StatusOK {
+		t.Error("the image should be served when it can't be cached", w.Code)
+	}
+}
+
+func TestCachedThumbChanges(t *testing.T) {
+	server := newTestServer(t, "movie.mp4")
+	server.Artwork = &mpcartwork.Store{Path: t.TempDir()}
+
+	video, _ := server.Storage.GetVideoByFileName("movie.mp4")
+	thumbPath := server.Library.Path + "/movie.mp4.jpg"
+
+	// writeThumb saves a thumb of one color with the time of the file
+	writeThumb := func(fill color.Color, modTime time.Time) {
+		img := image.NewRGBA(image.Rect(0, 0, 64, 48))
+
+		for x := 0; x < 64; x++ {
+			for y := 0; y < 48; y++ {
+				img.Set(x, y, fill)
+			}
+		}
+
+		var data bytes.Buffer
+
+		err := jpeg.Encode(&data, img, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = ioutil.WriteFile(thumbPath, data.Bytes(), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		os.Chtimes(thumbPath, modTime, modTime)
+	}
+
+	writeThumb(color.White, time.Now().Add(-time.Hour))
+
+	first, err := server.cachedThumb(context.Background(), video)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if again, _ := server.cachedThumb(context.Background(), video); again != first {
+		t.Error("the same thumb should keep its hash", first, again)
+	}
+
+	// the thumb is regenerated
+	writeThumb(color.Black, time.Now())
+
+	second, err := server.cachedThumb(context.Background(), video)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if second == first {
+		t.Error("the new thumb should be saved in the artwork cache")
+	}
+}
@@ -0,0 +1,187 @@
+package mpcserver
+
+import (
+	"github.com/jempe/mpc/auth"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// default and maximum validity of the signed media URLs
+const (
+	mediaSignatureTTL    = 6 * time.Hour
+	maxMediaSignatureTTL = 7 * 24 * time.Hour
+)
+
+// dlnaSignatureTTL is the validity of the URLs of the DLNA browse responses, the TVs browse again when they are opened
+const dlnaSignatureTTL = 24 * time.Hour
+
+type MediaSignRequest struct {
+	Video      string   `json:"video"`
+	Operations []string `json:"operations"`
+	// TTL is the validity of the URLs in seconds
+	TTL int `json:"ttl"`
+}
+
+type SignedMedia struct {
+	Video      string    `json:"video"`
+	Operations []string  `json:"operations"`
+	Expires    time.Time `json:"expires"`
+	// Signature is the value of the sig parameter, the players add it to the screenshot URLs
+	Signature string `json:"signature"`
+	StreamURL string `json:"streamURL,omitempty"`
+	ThumbURL  string `json:"thumbURL,omitempty"`
+}
+
+// MediaSignHandler mints signed URLs of a video for the players that can't send the session cookie, like VLC or the TVs
+//
+// POST /media/sign
+//
+func (server *Server) MediaSignHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := server.loggedUser(w, r)
+	if !ok {
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		http.Error(w, "method_not_allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var request MediaSignRequest
+
+	err := json.NewDecoder(r.Body).Decode(&request)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	video, err := server.Storage.GetVideoByID(request.Video)
+	if err != nil || video.ID == "" {
+		http.Error(w, "video_not_exists", http.StatusNotFound)
+		return
+	}
+
+	if len(request.Operations) == 0 {
+		request.Operations = []string{mpcauth.OperationStream, mpcauth.OperationThumb}
+	}
+
+	ttl := time.Duration(request.TTL) * time.Second
+	if request.TTL <= 0 {
+		ttl = mediaSignatureTTL
+	}
+
+	if ttl > maxMediaSignatureTTL {
+		http.Error(w, "media_ttl_too_long", http.StatusBadRequest)
+		return
+	}
+
+	signed, err := server.signMedia(video.ID, userID, request.Operations, ttl)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	writeJSON(w, signed)
+}
+
+// MediaKeyHandler rotates the key of the signed media URLs, all the URLs that were minted stop working
+//
+// POST /media/rotate
+//
+func (server *Server) MediaKeyHandler(w http.ResponseWriter, r *http.Request) {
+	_, ok := server.adminUser(w, r)
+	if !ok {
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		http.Error(w, "method_not_allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	generation, err := server.Auth.RotateMediaKey()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, map[string]int{"generation": generation})
+}
+
+// DLNASignature signs the stream and the thumb of a video for the TVs, the DLNA clients don't have users
+//
+func (server *Server) DLNASignature(videoID string) string {
+	signature, err := server.Auth.SignMedia(mpcauth.MediaClaims{
+		Video:      videoID,
+		Operations: []string{mpcauth.OperationStream, mpcauth.OperationThumb},
+		Expires:    time.Now().Add(dlnaSignatureTTL).Unix(),
+	})
+	if err != nil {
+		return ""
+	}
+
+	return signature
+}
+
+// signMedia signs the operations of a video for the user and builds the URLs
+//
+func (server *Server) signMedia(videoID string, userID string, operations []string, ttl time.Duration) (signed SignedMedia, err error) {
+	expires := time.Now().Add(ttl)
+
+	signature, err := server.Auth.SignMedia(mpcauth.MediaClaims{Video: videoID, User: userID, Operations: operations, Expires: expires.Unix()})
+	if err != nil {
+		return
+	}
+
+	signed = SignedMedia{Video: videoID, Operations: operations, Expires: expires, Signature: signature}
+
+	query := "?sig=" + url.QueryEscape(signature)
+
+	for _, operation := range operations {
+		switch operation {
+		case mpcauth.OperationStream:
+			signed.StreamURL = server.BaseURL + "/videos/" + videoID + ".mp4" + query
+		case mpcauth.OperationThumb:
+			signed.ThumbURL = server.BaseURL + "/videos/thumbs/" + videoID + ".jpg" + query
+		}
+	}
+
+	return
+}
+
+// mediaAccess checks that the request has a session or a signature that allows the operation on the video,
+// otherwise it sends an error response
+//
+func (server *Server) mediaAccess(w http.ResponseWriter, r *http.Request, videoID string, operation string) bool {
+	signature := r.URL.Query().Get("sig")
+
+	if signature == "" {
+		_, ok := server.loggedUser(w, r)
+
+		return ok
+	}
+
+	claims, err := server.Auth.ValidateMediaSignature(signature)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return false
+	}
+
+	if claims.Video != videoID || !claims.Allows(operation) {
+		http.Error(w, "media_operation_not_allowed", http.StatusForbidden)
+		return false
+	}
+
+	// the URLs of the users that were removed stop working
+	if claims.User != "" {
+		user, err := server.Storage.GetUserByUUID(claims.User)
+		if err != nil || user.UUID == "" {
+			http.Error(w, "user_not_exists", http.StatusForbidden)
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,159 @@
+package mpcserver
+
+import (
+	"github.com/jempe/mpc/scraper"
+	"github.com/jempe/mpc/storage"
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// scraperSearchTimeout is how long the scrapers can take to search all the videos of a request
+const scraperSearchTimeout = 2 * time.Minute
+
+type ScraperSearchRequest struct {
+	Videos []string `json:"videos"`
+}
+
+type ScraperSearchResult struct {
+	Video   string                      `json:"video"`
+	Reviews []mpcstorage.MetadataReview `json:"reviews"`
+	Error   string                      `json:"error,omitempty"`
+}
+
+type ReviewRequest struct {
+	Fields []string `json:"fields"`
+}
+
+// MetadataHandler looks up the metadata of the videos with the scrapers, the results are added to the review queue
+// and the videos are updated only when an admin accepts them
+//
+// GET /metadata/scrapers
+// POST /metadata/scrapers/{name}/search
+// GET /metadata/reviews?status=pending
+// GET /metadata/reviews/{id}
+// POST /metadata/reviews/{id}/accept
+// POST /metadata/reviews/{id}/reject
+//
+func (server *Server) MetadataHandler(w http.ResponseWriter, r *http.Request) {
+	user, ok := server.adminUser(w, r)
+	if !ok {
+		return
+	}
+
+	uriSegments := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+
+	switch {
+	case len(uriSegments) == 2 && uriSegments[1] == "scrapers" && r.Method == http.MethodGet:
+		writeJSON(w, mpcscraper.Names())
+	case len(uriSegments) == 4 && uriSegments[1] == "scrapers" && uriSegments[3] == "search" && r.Method == http.MethodPost:
+		scraper, ok := mpcscraper.Get(uriSegments[2])
+		if !ok {
+			http.Error(w, "scraper_not_exists", http.StatusNotFound)
+			return
+		}
+
+		var request ScraperSearchRequest
+
+		err := json.NewDecoder(r.Body).Decode(&request)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		writeJSON(w, server.scrapeVideos(r.Context(), scraper, request.Videos))
+	case len(uriSegments) == 2 && uriSegments[1] == "reviews" && r.Method == http.MethodGet:
+		reviews, err := server.Storage.GetMetadataReviews(r.URL.Query().Get("status"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		writeJSON(w, reviews)
+	case len(uriSegments) == 3 && uriSegments[1] == "reviews" && r.Method == http.MethodGet:
+		review, err := server.Storage.GetMetadataReview(uriSegments[2])
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+
+		writeJSON(w, review)
+	case len(uriSegments) == 4 && uriSegments[1] == "reviews" && uriSegments[3] == "accept" && r.Method == http.MethodPost:
+		var request ReviewRequest
+
+		// the body is optional, all the fields are accepted without it
+		if r.ContentLength != 0 {
+			err := json.NewDecoder(r.Body).Decode(&request)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+
+		review, err := server.Storage.AcceptMetadataReview(uriSegments[2], request.Fields, user)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		writeJSON(w, review)
+	case len(uriSegments) == 4 && uriSegments[1] == "reviews" && uriSegments[3] == "reject" && r.Method == http.MethodPost:
+		review, err := server.Storage.RejectMetadataReview(uriSegments[2], user)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		writeJSON(w, review)
+	default:
+		http.Error(w, "method_not_allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// scrapeVideos searches every video with the scraper and adds its results to the review queue
+//
+func (server *Server) scrapeVideos(ctx context.Context, scraper mpcscraper.Scraper, videoIDs []string) (results []ScraperSearchResult) {
+	ctx, cancel := context.WithTimeout(ctx, scraperSearchTimeout)
+	defer cancel()
+
+	results = []ScraperSearchResult{}
+
+	for _, videoID := range videoIDs {
+		result := ScraperSearchResult{Video: videoID, Reviews: []mpcstorage.MetadataReview{}}
+
+		video, _ := server.Storage.GetVideoByID(videoID)
+		if video.ID == "" {
+			result.Error = "video_not_exists"
+			results = append(results, result)
+			continue
+		}
+
+		found, err := scraper.Search(ctx, mpcscraper.NewQuery(video))
+		if err != nil {
+			result.Error = err.Error()
+			results = append(results, result)
+			continue
+		}
+
+		for _, item := range found {
+			review, err := server.Storage.AddMetadataReview(mpcstorage.MetadataReview{
+				VideoID:  videoID,
+				Scraper:  item.Scraper,
+				SourceID: item.ID,
+				Metadata: item.Video,
+			})
+			if err != nil {
+				result.Error = err.Error()
+				break
+			}
+
+			result.Reviews = append(result.Reviews, review)
+		}
+
+		results = append(results, result)
+	}
+
+	return
+}
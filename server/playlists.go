@@ -0,0 +1,314 @@
+package mpcserver
+
+import (
+	"github.com/jempe/mpc/storage"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+type PlaylistUpdate struct {
+	Name        *string   `json:"name"`
+	Description *string   `json:"description"`
+	Shared      *bool     `json:"shared"`
+	SharedWith  *[]string `json:"sharedWith"`
+	Videos      *[]string `json:"videos"`
+}
+
+type PlaylistVideos struct {
+	Videos []string `json:"videos"`
+	Index  *int     `json:"index"`
+}
+
+// PlaylistsHandler shows the JSON list of playlists of the user and creates new playlists
+//
+func (server *Server) PlaylistsHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := server.loggedUser(w, r)
+	if !ok {
+		return
+	}
+
+	if r.Method == http.MethodPost {
+		var update PlaylistUpdate
+
+		err := json.NewDecoder(r.Body).Decode(&update)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		playlist := mpcstorage.Playlist{Owner: userID}
+		applyPlaylistUpdate(&playlist, update)
+
+		playlist, err = server.Storage.SavePlaylist(playlist)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		writeJSON(w, playlist)
+		return
+	}
+
+	playlists, err := server.Storage.GetPlaylists(userID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, playlists)
+}
+
+// PlaylistHandler manages the playlists, only the owner can change a playlist
+//
+// GET, PATCH and DELETE /playlists/{id}
+// POST /playlists/{id}/videos appends videos, PUT reorders the videos and DELETE removes the video at the index
+//
+func (server *Server) PlaylistHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := server.loggedUser(w, r)
+	if !ok {
+		return
+	}
+
+	uriSegments := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+
+	if len(uriSegments) < 2 {
+		http.Error(w, "Invalid Request", http.StatusBadRequest)
+		return
+	}
+
+	playlist, err := server.Storage.GetPlaylist(uriSegments[1])
+	if err != nil || !playlist.CanView(userID) {
+		http.Error(w, "playlist_not_exists", http.StatusNotFound)
+		return
+	}
+
+	if r.Method != http.MethodGet && !playlist.CanEdit(userID) {
+		http.Error(w, "playlist_not_owner", http.StatusForbidden)
+		return
+	}
+
+	action := strings.Join(uriSegments[2:], "/")
+
+	switch {
+	case action == "" && r.Method == http.MethodGet:
+		writeJSON(w, playlist)
+		return
+	case action == "" && r.Method == http.MethodPatch:
+		var update PlaylistUpdate
+
+		err = json.NewDecoder(r.Body).Decode(&update)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		applyPlaylistUpdate(&playlist, update)
+	case action == "" && r.Method == http.MethodDelete:
+		err = server.Storage.DeletePlaylist(playlist.ID)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+		return
+	case action == "videos":
+		playlist.Videos, err = changePlaylistVideos(r, playlist.Videos)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	default:
+		http.Error(w, "Invalid Request", http.StatusBadRequest)
+		return
+	}
+
+	playlist, err = server.Storage.SavePlaylist(playlist)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	writeJSON(w, playlist)
+}
+
+// CollectionsHandler shows the JSON list of collections, admins can create new collections
+//
+func (server *Server) CollectionsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodPost {
+		_, ok := server.adminUser(w, r)
+		if !ok {
+			return
+		}
+
+		var update PlaylistUpdate
+
+		err := json.NewDecoder(r.Body).Decode(&update)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		var collection mpcstorage.Collection
+		applyCollectionUpdate(&collection, update)
+
+		collection, err = server.Storage.SaveCollection(collection)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		writeJSON(w, collection)
+		return
+	}
+
+	collections, err := server.Storage.GetCollections()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, collections)
+}
+
+// CollectionHandler manages the collections, only admins can change a collection
+//
+// GET, PATCH and DELETE /collections/{id}
+// POST /collections/{id}/videos appends videos, PUT reorders the videos and DELETE removes the video at the index
+//
+func (server *Server) CollectionHandler(w http.ResponseWriter, r *http.Request) {
+	uriSegments := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+
+	if len(uriSegments) < 2 {
+		http.Error(w, "Invalid Request", http.StatusBadRequest)
+		return
+	}
+
+	collectionID, err := strconv.Atoi(uriSegments[1])
+	if err != nil {
+		http.Error(w, "Invalid Request", http.StatusBadRequest)
+		return
+	}
+
+	collection, err := server.Storage.GetCollection(collectionID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	if r.Method == http.MethodGet {
+		writeJSON(w, collection)
+		return
+	}
+
+	_, ok := server.adminUser(w, r)
+	if !ok {
+		return
+	}
+
+	action := strings.Join(uriSegments[2:], "/")
+
+	switch {
+	case action == "" && r.Method == http.MethodPatch:
+		var update PlaylistUpdate
+
+		err = json.NewDecoder(r.Body).Decode(&update)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		applyCollectionUpdate(&collection, update)
+	case action == "" && r.Method == http.MethodDelete:
+		err = server.Storage.DeleteCollection(collectionID)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+		return
+	case action == "videos":
+		collection.Videos, err = changePlaylistVideos(r, collection.Videos)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	default:
+		http.Error(w, "Invalid Request", http.StatusBadRequest)
+		return
+	}
+
+	collection, err = server.Storage.SaveCollection(collection)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	writeJSON(w, collection)
+}
+
+// applyPlaylistUpdate changes the playlist fields that are present in the update
+//
+func applyPlaylistUpdate(playlist *mpcstorage.Playlist, update PlaylistUpdate) {
+	if update.Name != nil {
+		playlist.Name = *update.Name
+	}
+
+	if update.Shared != nil {
+		playlist.Shared = *update.Shared
+	}
+
+	if update.SharedWith != nil {
+		playlist.SharedWith = *update.SharedWith
+	}
+
+	if update.Videos != nil {
+		playlist.Videos = *update.Videos
+	}
+}
+
+// applyCollectionUpdate changes the collection fields that are present in the update
+//
+func applyCollectionUpdate(collection *mpcstorage.Collection, update PlaylistUpdate) {
+	if update.Name != nil {
+		collection.Name = *update.Name
+	}
+
+	if update.Description != nil {
+		collection.Description = *update.Description
+	}
+
+	if update.Videos != nil {
+		collection.Videos = *update.Videos
+	}
+}
+
+// changePlaylistVideos appends, reorders or removes the videos of a playlist or collection
+//
+func changePlaylistVideos(r *http.Request, videos []string) ([]string, error) {
+	var change PlaylistVideos
+
+	err := json.NewDecoder(r.Body).Decode(&change)
+	if err != nil {
+		return videos, err
+	}
+
+	switch r.Method {
+	case http.MethodPost:
+		return append(videos, change.Videos...), nil
+	case http.MethodPut:
+		return mpcstorage.ReorderVideos(videos, change.Videos)
+	case http.MethodDelete:
+		if change.Index == nil || *change.Index < 0 || *change.Index >= len(videos) {
+			return videos, errors.New("playlist_index_invalid")
+		}
+
+		return append(videos[:*change.Index:*change.Index], videos[*change.Index+1:]...), nil
+	}
+
+	return videos, errors.New("Invalid Request")
+}
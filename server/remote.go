@@ -0,0 +1,227 @@
+package mpcserver
+
+import (
+	"github.com/jempe/mpc/auth"
+	"github.com/jempe/mpc/remote"
+	"github.com/jempe/mpc/storage"
+	"github.com/jempe/mpc/users"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+)
+
+type RemoteDeviceRequest struct {
+	Name    string   `json:"name"`
+	Players []string `json:"players"`
+}
+
+type RemoteInvite struct {
+	Player string `json:"player"`
+	User   string `json:"user"`
+}
+
+// RemoteIdentity authenticates the remote connections with the session of the user
+// or with the token of a paired device sent in the token parameter or the Authorization header
+//
+func (server *Server) RemoteIdentity(r *http.Request) (identity mpcremote.Identity, err error) {
+	token := r.URL.Query().Get("token")
+
+	if bearer := r.Header.Get("Authorization"); strings.HasPrefix(bearer, "Bearer ") {
+		token = strings.TrimPrefix(bearer, "Bearer ")
+	}
+
+	var devicePlayers []string
+
+	if token != "" {
+		device, err := server.Storage.GetRemoteDeviceByToken(token)
+		if err != nil {
+			return identity, errors.New("device_token_invalid")
+		}
+
+		identity.User = device.Owner
+		identity.Device = device.ID
+		devicePlayers = device.Players
+	} else {
+		identity.User = server.sessionUser(r)
+	}
+
+	if identity.User == "" {
+		return identity, errors.New("user_not_logged_in")
+	}
+
+	user, err := server.Storage.GetUserByUUID(identity.User)
+	if err != nil || user.UUID == "" {
+		return identity, errors.New("user_not_exists")
+	}
+
+	identity.Role = user.Role
+	identity.Admin = user.IsAdmin()
+
+	if user.IsGuest() {
+		invited, err := server.Storage.InvitedPlayers(user.UUID)
+		if err != nil {
+			return identity, err
+		}
+
+		identity.Restricted = true
+		identity.Players = invited
+
+		if len(devicePlayers) > 0 {
+			identity.Players = intersectStrings(invited, devicePlayers)
+		}
+	} else if len(devicePlayers) > 0 {
+		identity.Restricted = true
+		identity.Players = devicePlayers
+	}
+
+	return identity, nil
+}
+
+// RemoteDevicesHandler lists the paired devices of the user and pairs new devices,
+// the token of a new device is only shown in the response that creates it
+//
+// GET and POST /remote/devices
+// DELETE /remote/devices/{id}
+//
+func (server *Server) RemoteDevicesHandler(w http.ResponseWriter, r *http.Request) {
+	user, ok := server.remoteUser(w, r)
+	if !ok {
+		return
+	}
+
+	owner := user.UUID
+	if user.IsAdmin() {
+		owner = ""
+	}
+
+	uriSegments := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+
+	switch {
+	case len(uriSegments) == 2 && r.Method == http.MethodGet:
+		devices, err := server.Storage.GetRemoteDevices(owner)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		writeJSON(w, devices)
+	case len(uriSegments) == 2 && r.Method == http.MethodPost:
+		var request RemoteDeviceRequest
+
+		err := json.NewDecoder(r.Body).Decode(&request)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		token, err := mpcauth.RandomString(32)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		device, err := server.Storage.CreateRemoteDevice(mpcstorage.RemoteDevice{Name: request.Name, Owner: user.UUID, Players: request.Players}, token)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		writeJSON(w, map[string]interface{}{"device": device, "token": token})
+	case len(uriSegments) == 3 && r.Method == http.MethodDelete:
+		device, err := server.Storage.GetRemoteDevice(uriSegments[2])
+		if err != nil || (owner != "" && device.Owner != owner) {
+			http.Error(w, "device_not_exists", http.StatusNotFound)
+			return
+		}
+
+		err = server.Storage.DeleteRemoteDevice(device.ID)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.Error(w, "Invalid Request", http.StatusBadRequest)
+	}
+}
+
+// RemoteInvitesHandler manages the guests that can control each player, the players don't have an owner so only
+// the admins manage the invitations
+//
+// GET /remote/invites lists the invited users of each player
+// POST /remote/invites invites a user to control a player and DELETE removes the invitation
+//
+func (server *Server) RemoteInvitesHandler(w http.ResponseWriter, r *http.Request) {
+	_, ok := server.adminUser(w, r)
+	if !ok {
+		return
+	}
+
+	if r.Method == http.MethodGet {
+		invites, err := server.Storage.GetPlayerInvites()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		writeJSON(w, invites)
+		return
+	}
+
+	if r.Method != http.MethodPost && r.Method != http.MethodDelete {
+		http.Error(w, "Invalid Request", http.StatusBadRequest)
+		return
+	}
+
+	var invite RemoteInvite
+
+	err := json.NewDecoder(r.Body).Decode(&invite)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	err = server.Storage.SetPlayerInvite(invite.Player, invite.User, r.Method == http.MethodPost)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	writeJSON(w, invite)
+}
+
+// remoteUser gets the logged in user when it can manage the remote devices and invitations, guests can't manage them
+//
+func (server *Server) remoteUser(w http.ResponseWriter, r *http.Request) (user mpcusers.User, ok bool) {
+	userID, ok := server.loggedUser(w, r)
+	if !ok {
+		return
+	}
+
+	ok = false
+
+	user, err := server.Storage.GetUserByUUID(userID)
+	if err != nil || user.UUID == "" || user.IsGuest() {
+		http.Error(w, "user_not_allowed", http.StatusForbidden)
+		return
+	}
+
+	return user, true
+}
+
+// intersectStrings gets the values that are in both lists
+//
+func intersectStrings(a []string, b []string) (intersection []string) {
+	for _, valueA := range a {
+		for _, valueB := range b {
+			if valueA == valueB {
+				intersection = append(intersection, valueA)
+				break
+			}
+		}
+	}
+
+	return
+}
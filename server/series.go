@@ -0,0 +1,57 @@
+package mpcserver
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// SeriesHandler shows the JSON list of series with their number of seasons and episodes
+//
+func (server *Server) SeriesHandler(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, server.Storage.GetSeriesList())
+}
+
+// SeriesItemHandler shows the seasons and episodes of a series
+//
+// GET /series/{id}
+// POST /series/detect finds the episodes of the videos that are not part of a series
+//
+func (server *Server) SeriesItemHandler(w http.ResponseWriter, r *http.Request) {
+	uriSegments := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+
+	if len(uriSegments) != 2 {
+		http.Error(w, "Invalid Request", http.StatusBadRequest)
+		return
+	}
+
+	if uriSegments[1] == "detect" && r.Method == http.MethodPost {
+		_, ok := server.adminUser(w, r)
+		if !ok {
+			return
+		}
+
+		total, err := server.Storage.DetectEpisodes()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		writeJSON(w, map[string]int{"detected": total})
+		return
+	}
+
+	seriesID, err := strconv.Atoi(uriSegments[1])
+	if err != nil || r.Method != http.MethodGet {
+		http.Error(w, "Invalid Request", http.StatusBadRequest)
+		return
+	}
+
+	details, err := server.Storage.GetSeriesDetails(seriesID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	writeJSON(w, details)
+}
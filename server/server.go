@@ -4,20 +4,28 @@ package mpcserver
 
 import (
 	"github.com/jempe/encdec"
+	"github.com/jempe/mpc/artwork"
 	"github.com/jempe/mpc/auth"
+	"github.com/jempe/mpc/cast"
+	"github.com/jempe/mpc/dlna"
 	"github.com/jempe/mpc/library"
+	"github.com/jempe/mpc/remote"
 	"github.com/jempe/mpc/storage"
+	"github.com/jempe/mpc/usage"
 	"github.com/jempe/mpc/utils"
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"image"
 	"io/ioutil"
 	"log"
 	"net/http"
 	"os"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 )
 
@@ -27,23 +35,46 @@ type Server struct {
 	Library *mpclibrary.Library
 	Auth    *mpcauth.Auth
 	Key     string
+	// BaseURL is the URL of the server in the LAN, the cast receivers load the videos from it
+	// so they only work with HTTP, the TVs reject the certificate of an HTTPS server on the raw IP
+	BaseURL string
+	// ConnectPlayer connects the cast sessions to the remote as players
+	ConnectPlayer func(identity mpcremote.Identity, playerID string, remoteID string, name string) *mpcremote.LocalConn
+	// Artwork caches the thumbs of the videos that are not encrypted
+	Artwork *mpcartwork.Store
+	// ImageCache saves the resized thumbs and screenshots
+	ImageCache *mpcartwork.Cache
+	// CleanupPolicy limits the disk space used by the caches and the screenshots
+	CleanupPolicy mpcusage.Policy
+	// SessionTTL is the validity of the login cookie, it's one hour when it's zero
+	SessionTTL time.Duration
+	// Workers is the number of videos that the bulk file actions process at the same time, one when it's zero
+	Workers int
+
+	jobs       map[string]*BulkJob
+	jobsMutex  sync.Mutex
+	casts      map[string]*mpccast.Session
+	castsMutex sync.Mutex
+	// castReceivers are the receivers of the last discovery, the casts only start on them
+	castReceivers      map[string]mpccast.Receiver
+	castReceiversFound time.Time
 }
 
+// ActorsHandler shows JSON actors list with the number of videos of every actor
+//
 func (server *Server) ActorsHandler(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "application/json")
-
-	actorList := server.Storage.Actors
+	offset, view := pageParameters(r, 0, 50)
 
-	jsonResponse, err := json.Marshal(actorList)
-	if err != nil {
-		log.Println(err)
-	}
+	results := server.Storage.GetActors(offset, view, r.URL.Query().Get("search"))
 
-	fmt.Fprintln(w, string(jsonResponse))
+	writeJSON(w, results)
 }
 
 // VideosHandler handles shows JSON videos list
 //
+// When a playlist or a collection is requested the videos are shown in the playlist order,
+// remotes can queue a playlist by sending a filter with the playlist ID
+//
 func (server *Server) VideosHandler(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
@@ -76,7 +107,36 @@ func (server *Server) VideosHandler(w http.ResponseWriter, r *http.Request) {
 
 	sortBy := r.URL.Query().Get("sort")
 
-	results := server.Storage.GetVideos(offset, view, sortBy, filter, seed)
+	if r.URL.Query().Get("playlist") != "" {
+		filter.Playlist = r.URL.Query().Get("playlist")
+	}
+
+	getCollection, err := strconv.Atoi(r.URL.Query().Get("collection"))
+	if err == nil {
+		filter.Collection = getCollection
+	}
+
+	var results mpcstorage.VideoResults
+
+	if filter.Playlist != "" {
+		playlist, err := server.Storage.GetPlaylist(filter.Playlist)
+		if err != nil || !playlist.CanView(server.sessionUser(r)) {
+			http.Error(w, "playlist_not_exists", http.StatusNotFound)
+			return
+		}
+
+		results = server.Storage.GetVideosByIDs(offset, view, playlist.Videos)
+	} else if filter.Collection != 0 {
+		collection, err := server.Storage.GetCollection(filter.Collection)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+
+		results = server.Storage.GetVideosByIDs(offset, view, collection.Videos)
+	} else {
+		results = server.Storage.GetVideos(offset, view, sortBy, filter, seed)
+	}
 
 	responseJSON, err := json.Marshal(results)
 	if err != nil {
@@ -100,6 +160,10 @@ func (server *Server) VideoFileHandler(w http.ResponseWriter, r *http.Request) {
 	if strings.HasSuffix(thumbFile, ".mp4") {
 		videoID := strings.TrimSuffix(thumbFile, ".mp4")
 
+		if !server.mediaAccess(w, r, videoID, mpcauth.OperationStream) {
+			return
+		}
+
 		videoData, err := server.Storage.GetVideoByID(videoID)
 		fmt.Println(videoData.File)
 		if err != nil {
@@ -109,6 +173,8 @@ func (server *Server) VideoFileHandler(w http.ResponseWriter, r *http.Request) {
 
 		var videoFileData []byte
 
+		mpcdlna.StreamHeaders(w, r)
+
 		if strings.HasSuffix(videoData.File, ".enc") {
 			encryptedFilePath := server.Library.Path + "/" + videoData.File
 
@@ -140,80 +206,155 @@ func (server *Server) VideoFileHandler(w http.ResponseWriter, r *http.Request) {
 		}
 
 	} else {
-		fmt.Fprint(w, "Invalid Request")
+		server.VideoHandler(w, r)
 	}
 }
 
-// ThumbsHandler serves thumb files
+// ThumbsHandler serves thumb files, the w parameter resizes the thumb to one of the allowed widths
+// and the variant parameter selects a size of the artwork: thumb, card or backdrop
+//
+// The thumbs of the videos that are not encrypted are served from the artwork cache, the thumb file of the library
+// or the image URL of the video are saved in the cache the first time that they are requested
 //
 func (server *Server) ThumbsHandler(w http.ResponseWriter, r *http.Request) {
 	uri := string(r.URL.Path)
 	uriSegments := strings.Split(uri, "/")
 	thumbFile := uriSegments[3]
 
-	if strings.HasSuffix(thumbFile, ".jpg") {
-		videoID := strings.TrimSuffix(thumbFile, ".jpg")
+	if !strings.HasSuffix(thumbFile, ".jpg") {
+		http.Error(w, "Invalid Request", http.StatusBadRequest)
+		return
+	}
 
-		videoData, err := server.Storage.GetVideoByID(videoID)
+	videoID := strings.TrimSuffix(thumbFile, ".jpg")
+
+	if !server.mediaAccess(w, r, videoID, mpcauth.OperationThumb) {
+		return
+	}
+
+	label, size, err := imageSize(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	videoData, err := server.Storage.GetVideoByID(videoID)
+	if err != nil || videoData.ID == "" {
+		http.Error(w, "video_not_exists", http.StatusNotFound)
+		return
+	}
+
+	if videoData.Encrypted {
+		encryptedThumbPath := server.Library.Path + "/" + strings.Replace(videoData.File, ".enc", "_thumb.enc", 1)
 
+		info, err := os.Stat(encryptedThumbPath)
 		if err != nil {
-			fmt.Fprint(w, err)
+			http.Error(w, "thumb_not_exists", http.StatusNotFound)
 			return
 		}
 
-		if videoData.Encrypted {
-			encryptedThumbPath := server.Library.Path + "/" + strings.Replace(videoData.File, ".enc", "_thumb.enc", 1)
+		server.serveImage(w, r, imageFile{
+			Name:      "thumbs/" + videoID,
+			ModTime:   info.ModTime(),
+			Encrypted: true,
+			Load: func() ([]byte, error) {
+				return server.decryptFile(encryptedThumbPath)
+			},
+		}, label, size)
+		return
+	}
 
-			data, err := ioutil.ReadFile(encryptedThumbPath)
+	hash, err := server.cachedThumb(r.Context(), videoData)
+	if err != nil {
+		log.Println("Thumb", videoID, err)
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
 
-			if err != nil {
-				fmt.Fprint(w, err)
-				return
-			} else {
-				thumbData, err := encdec.Decrypt(data, []byte(server.Key))
+	// the variants of the artwork are saved with the original, they are not resized again
+	variant := mpcartwork.VariantOriginal
+	name := "artwork/" + hash
 
-				if err != nil {
-					fmt.Fprint(w, err)
-					return
-				}
-				http.ServeContent(w, r, thumbFile, time.Now(), bytes.NewReader(thumbData))
-			}
+	if _, ok := mpcartwork.Variants[label]; ok {
+		variant = label
+		name += "_" + variant
+		label = ""
+	}
 
-		} else {
+	artworkPath, err := server.Artwork.File(hash, variant)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	info, err := os.Stat(artworkPath)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
 
-			thumbPath := server.Library.Path + "/" + videoData.File + ".jpg"
+	server.serveImage(w, r, imageFile{
+		Name:    name,
+		ModTime: info.ModTime(),
+		Load: func() ([]byte, error) {
+			return ioutil.ReadFile(artworkPath)
+		},
+	}, label, size)
+}
 
-			if !mpcutils.Exists(thumbPath) {
-				fmt.Println(thumbPath, "doesn't exist")
+// cachedThumb gets the hash of the thumb of a video in the artwork cache, the thumb is saved in the cache
+// from the library or from the image URL when it's not there, or when the thumb file or the URL changed
+//
+func (server *Server) cachedThumb(ctx context.Context, videoData mpclibrary.Video) (hash string, err error) {
+	if server.Artwork == nil {
+		return "", errors.New("artwork_not_available")
+	}
 
-				if videoData.ImgURL != "" {
-					err = mpcutils.DownloadImage(videoData.ImgURL, thumbPath)
+	thumbPath := server.Library.Path + "/" + videoData.File + ".jpg"
 
-					if err != nil {
-						fmt.Fprint(w, err)
-						return
-					}
-				} else {
-					fmt.Fprint(w, errors.New("This video has no thumbnail to download"))
-				}
-			}
+	// the source identifies the version of the thumb, a new thumb file or URL is saved again
+	source := videoData.ImgURL
 
-			file, err := os.Open(thumbPath)
+	thumbInfo, thumbErr := os.Stat(thumbPath)
+	if thumbErr == nil {
+		source = fmt.Sprintf("file %d %d", thumbInfo.ModTime().UnixNano(), thumbInfo.Size())
+	}
 
-			if err != nil {
-				fmt.Fprint(w, err)
-			}
+	hash, ok := server.Artwork.Lookup(videoData.ID, source)
+	if ok {
+		return
+	}
 
-			defer file.Close()
+	if thumbErr == nil {
+		var data []byte
 
-			http.ServeContent(w, r, thumbFile, time.Now(), file)
+		data, err = ioutil.ReadFile(thumbPath)
+		if err != nil {
+			return
+		}
+
+		hash, err = server.Artwork.Import(data)
+	} else if videoData.ImgURL != "" {
+		var img image.Image
+
+		img, err = mpcartwork.Download(ctx, videoData.ImgURL)
+		if err != nil {
+			return
 		}
+
+		hash, err = server.Artwork.Save(img)
 	} else {
-		fmt.Fprint(w, "Invalid Request")
+		return "", errors.New("thumb_not_exists")
+	}
+
+	if err != nil {
+		return
 	}
+
+	return hash, server.Artwork.Link(videoData.ID, hash, source)
 }
 
-// ScreenshotsHandler serve screenshots
+// ScreenshotsHandler serve screenshots, the w parameter resizes the screenshot to one of the allowed widths
 //
 func (server *Server) ScreenshotsHandler(w http.ResponseWriter, r *http.Request) {
 	uri := string(r.URL.Path)
@@ -221,6 +362,16 @@ func (server *Server) ScreenshotsHandler(w http.ResponseWriter, r *http.Request)
 	videoID := uriSegments[3]
 	frameFile := uriSegments[4]
 
+	if !server.mediaAccess(w, r, videoID, mpcauth.OperationScreenshot) {
+		return
+	}
+
+	label, size, err := imageSize(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
 	videoData, err := server.Storage.GetVideoByID(videoID)
 	if err != nil {
 		fmt.Fprint(w, err)
@@ -240,37 +391,30 @@ func (server *Server) ScreenshotsHandler(w http.ResponseWriter, r *http.Request)
 		}
 
 		screenshotsPath := screenshotFolder + "/" + frameFile
+		frameTime := strings.TrimSuffix(frameFile, ".jpg")
 
 		if videoData.Encrypted {
 			screenshotsPath = strings.Replace(screenshotsPath, ".jpg", ".enc", 1)
 
-			if mpcutils.Exists(screenshotsPath) {
-				data, err := ioutil.ReadFile(screenshotsPath)
-
-				if err != nil {
-					fmt.Fprint(w, err)
-					return
-				} else {
-					thumbData, err := encdec.Decrypt(data, []byte(server.Key))
-
-					if err != nil {
-						fmt.Fprint(w, err)
-						return
-					}
-
-					http.ServeContent(w, r, frameFile, time.Now(), bytes.NewReader(thumbData))
-				}
-			} else {
-				fmt.Fprint(w, errors.New(screenshotsPath+"doesn't exist"))
+			info, err := os.Stat(screenshotsPath)
+			if err != nil {
+				http.Error(w, screenshotsPath+" doesn't exist", http.StatusNotFound)
 				return
 			}
 
+			server.serveImage(w, r, imageFile{
+				Name:      "screenshots/" + videoID + "/" + frameTime,
+				ModTime:   info.ModTime(),
+				Encrypted: true,
+				Load: func() ([]byte, error) {
+					return server.decryptFile(screenshotsPath)
+				},
+			}, label, size)
+
 		} else {
 			if !mpcutils.Exists(screenshotsPath) {
 				fmt.Println(screenshotsPath, "doesn't exist")
 
-				frameTime := strings.TrimSuffix(frameFile, ".jpg")
-
 				if mpcutils.Exists(server.Library.Path + "/" + videoData.File) {
 					err = mpcutils.SaveScreenshot(server.Library.Path+"/"+videoData.File, frameTime, screenshotsPath)
 
@@ -284,15 +428,19 @@ func (server *Server) ScreenshotsHandler(w http.ResponseWriter, r *http.Request)
 				}
 			}
 
-			file, err := os.Open(screenshotsPath)
-
+			info, err := os.Stat(screenshotsPath)
 			if err != nil {
-				fmt.Fprint(w, err)
+				http.Error(w, err.Error(), http.StatusNotFound)
+				return
 			}
 
-			defer file.Close()
-
-			http.ServeContent(w, r, frameFile, time.Now(), file)
+			server.serveImage(w, r, imageFile{
+				Name:    "screenshots/" + videoID + "/" + frameTime,
+				ModTime: info.ModTime(),
+				Load: func() ([]byte, error) {
+					return ioutil.ReadFile(screenshotsPath)
+				},
+			}, label, size)
 		}
 
 	} else {
@@ -300,6 +448,17 @@ func (server *Server) ScreenshotsHandler(w http.ResponseWriter, r *http.Request)
 	}
 }
 
+// decryptFile reads an encrypted file of the library
+//
+func (server *Server) decryptFile(path string) ([]byte, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return encdec.Decrypt(data, []byte(server.Key))
+}
+
 // ScanHandler scans the Library
 //
 func (server *Server) ScanHandler(w http.ResponseWriter, r *http.Request) {
@@ -326,6 +485,7 @@ func (server *Server) IsLoggedIn(w http.ResponseWriter, r *http.Request) {
 	cookie, err := r.Cookie("sessionID")
 	if err != nil {
 		fmt.Fprint(w, err)
+		return
 	}
 
 	sessionID, err := server.Auth.ValidateToken(cookie.Value)
@@ -338,14 +498,81 @@ func (server *Server) IsLoggedIn(w http.ResponseWriter, r *http.Request) {
 	fmt.Fprint(w, uuid)
 }
 
+// sessionUser gets the UUID of the logged in user, it returns an empty string when there is no valid session
+//
+func (server *Server) sessionUser(r *http.Request) string {
+	cookie, err := r.Cookie("sessionID")
+	if err != nil {
+		return ""
+	}
+
+	sessionID, err := server.Auth.ValidateToken(cookie.Value)
+	if err != nil || sessionID == "" {
+		return ""
+	}
+
+	return server.Auth.ValidateSessionID(sessionID)
+}
+
+// loggedUser gets the UUID of the logged in user, otherwise it sends an error response
+//
+func (server *Server) loggedUser(w http.ResponseWriter, r *http.Request) (userID string, ok bool) {
+	userID = server.sessionUser(r)
+	if userID == "" {
+		http.Error(w, "user_not_logged_in", http.StatusUnauthorized)
+		return
+	}
+
+	return userID, true
+}
+
+// adminUser gets the UUID of the logged in user when the user is an admin, otherwise it sends an error response
+//
+func (server *Server) adminUser(w http.ResponseWriter, r *http.Request) (userID string, ok bool) {
+	userID, ok = server.loggedUser(w, r)
+	if !ok {
+		return
+	}
+
+	ok = false
+
+	user, err := server.Storage.GetUserByUUID(userID)
+	if err != nil || user.UUID == "" || !user.IsAdmin() {
+		http.Error(w, "user_not_admin", http.StatusForbidden)
+		return
+	}
+
+	return userID, true
+}
+
 // LoginHandler login users
 //
 func (server *Server) LoginHandler(w http.ResponseWriter, r *http.Request) {
 	r.ParseForm()
 	token, err := server.Auth.Authorize(r.FormValue("username"), r.FormValue("password"))
 
-	expiration := time.Now().Add(time.Hour)
+	ttl := server.SessionTTL
+	if ttl == 0 {
+		ttl = time.Hour
+	}
+
+	expiration := time.Now().Add(ttl)
 	cookie := http.Cookie{Name: "sessionID", Value: token, Expires: expiration, HttpOnly: true}
 	http.SetCookie(w, &cookie)
 	fmt.Fprint(w, token, err)
 }
+
+// writeJSON sends the data as a JSON response
+//
+func writeJSON(w http.ResponseWriter, data interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+
+	jsonResponse, err := json.Marshal(data)
+	if err != nil {
+		log.Println(err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	fmt.Fprintln(w, string(jsonResponse))
+}
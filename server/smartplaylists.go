@@ -0,0 +1,236 @@
+package mpcserver
+
+import (
+	"github.com/jempe/mpc/storage"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+type SmartPlaylistUpdate struct {
+	Name       *string                     `json:"name"`
+	Filter     *mpcstorage.VideoFilter     `json:"filter"`
+	Sort       *string                     `json:"sort"`
+	Limit      *int                        `json:"limit"`
+	Conditions *mpcstorage.SmartConditions `json:"conditions"`
+	Shared     *bool                       `json:"shared"`
+	SharedWith *[]string                   `json:"sharedWith"`
+}
+
+type HomeRow struct {
+	SmartPlaylist mpcstorage.SmartPlaylist `json:"smartPlaylist"`
+	Results       mpcstorage.VideoResults  `json:"results"`
+}
+
+// SmartPlaylistsHandler shows the JSON list of smart playlists of the user and creates new smart playlists
+//
+func (server *Server) SmartPlaylistsHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := server.loggedUser(w, r)
+	if !ok {
+		return
+	}
+
+	if r.Method == http.MethodPost {
+		var update SmartPlaylistUpdate
+
+		err := json.NewDecoder(r.Body).Decode(&update)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		smart := mpcstorage.SmartPlaylist{Owner: userID}
+		applySmartPlaylistUpdate(&smart, update)
+
+		smart, err = server.Storage.SaveSmartPlaylist(smart)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		writeJSON(w, smart)
+		return
+	}
+
+	smartPlaylists, err := server.Storage.GetSmartPlaylists(userID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, smartPlaylists)
+}
+
+// SmartPlaylistHandler manages the smart playlists, only the owner can change a smart playlist
+//
+// GET, PATCH and DELETE /smart/{id}
+// GET /smart/{id}/videos shows the videos of the smart playlist for the user
+// POST and DELETE /smart/{id}/pin pins and unpins the smart playlist from the home screen of the user
+//
+func (server *Server) SmartPlaylistHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := server.loggedUser(w, r)
+	if !ok {
+		return
+	}
+
+	uriSegments := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+
+	if len(uriSegments) < 2 {
+		http.Error(w, "Invalid Request", http.StatusBadRequest)
+		return
+	}
+
+	smart, err := server.Storage.GetSmartPlaylist(uriSegments[1])
+	if err != nil || !smart.CanView(userID) {
+		http.Error(w, "smart_playlist_not_exists", http.StatusNotFound)
+		return
+	}
+
+	action := strings.Join(uriSegments[2:], "/")
+
+	switch {
+	case action == "" && r.Method == http.MethodGet:
+		writeJSON(w, smart)
+		return
+	case action == "videos" && r.Method == http.MethodGet:
+		offset, view := pageParameters(r, 0, 20)
+
+		results, err := server.Storage.GetSmartPlaylistVideos(smart, userID, offset, view)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		writeJSON(w, results)
+		return
+	case action == "pin" && r.Method == http.MethodPost:
+		if !smart.IsPinned(userID) {
+			smart.PinnedBy = append(smart.PinnedBy, userID)
+		}
+	case action == "pin" && r.Method == http.MethodDelete:
+		var pinnedBy []string
+
+		for _, user := range smart.PinnedBy {
+			if user != userID {
+				pinnedBy = append(pinnedBy, user)
+			}
+		}
+
+		smart.PinnedBy = pinnedBy
+	case action == "" && r.Method == http.MethodPatch && smart.Owner == userID:
+		var update SmartPlaylistUpdate
+
+		err = json.NewDecoder(r.Body).Decode(&update)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		applySmartPlaylistUpdate(&smart, update)
+	case action == "" && r.Method == http.MethodDelete && smart.Owner == userID:
+		err = server.Storage.DeleteSmartPlaylist(smart.ID)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+		return
+	case action == "" && smart.Owner != userID:
+		http.Error(w, "smart_playlist_not_owner", http.StatusForbidden)
+		return
+	default:
+		http.Error(w, "Invalid Request", http.StatusBadRequest)
+		return
+	}
+
+	smart, err = server.Storage.SaveSmartPlaylist(smart)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	writeJSON(w, smart)
+}
+
+// HomeHandler shows the smart playlists pinned by the user as home screen rows
+//
+func (server *Server) HomeHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := server.loggedUser(w, r)
+	if !ok {
+		return
+	}
+
+	_, view := pageParameters(r, 0, 20)
+
+	smartPlaylists, err := server.Storage.GetSmartPlaylists(userID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	rows := []HomeRow{}
+
+	for _, smart := range smartPlaylists {
+		if smart.IsPinned(userID) {
+			results, err := server.Storage.GetSmartPlaylistVideos(smart, userID, 0, view)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+
+			rows = append(rows, HomeRow{SmartPlaylist: smart, Results: results})
+		}
+	}
+
+	writeJSON(w, rows)
+}
+
+// applySmartPlaylistUpdate changes the smart playlist fields that are present in the update
+//
+func applySmartPlaylistUpdate(smart *mpcstorage.SmartPlaylist, update SmartPlaylistUpdate) {
+	if update.Name != nil {
+		smart.Name = *update.Name
+	}
+
+	if update.Filter != nil {
+		smart.Filter = *update.Filter
+	}
+
+	if update.Sort != nil {
+		smart.Sort = *update.Sort
+	}
+
+	if update.Limit != nil {
+		smart.Limit = *update.Limit
+	}
+
+	if update.Conditions != nil {
+		smart.Conditions = *update.Conditions
+	}
+
+	if update.Shared != nil {
+		smart.Shared = *update.Shared
+	}
+
+	if update.SharedWith != nil {
+		smart.SharedWith = *update.SharedWith
+	}
+}
+
+// pageParameters gets the offset and view query parameters
+//
+func pageParameters(r *http.Request, offset int, view int) (int, int) {
+	getOffset, err := strconv.Atoi(r.URL.Query().Get("offset"))
+	if err == nil {
+		offset = getOffset
+	}
+
+	getView, err := strconv.Atoi(r.URL.Query().Get("view"))
+	if err == nil {
+		view = getView
+	}
+
+	return offset, view
+}
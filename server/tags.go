@@ -0,0 +1,134 @@
+package mpcserver
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+type TagUpdate struct {
+	Name string `json:"name"`
+}
+
+type TagVideos struct {
+	Add    []string `json:"add"`
+	Remove []string `json:"remove"`
+}
+
+// TagsHandler shows the JSON tags list and creates new tags, every logged in user can create tags
+//
+func (server *Server) TagsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodPost {
+		if _, ok := server.loggedUser(w, r); !ok {
+			return
+		}
+
+		var update TagUpdate
+
+		err := json.NewDecoder(r.Body).Decode(&update)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		tag, err := server.Storage.CreateTag(update.Name)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		writeJSON(w, tag)
+		return
+	}
+
+	writeJSON(w, server.Storage.GetTagList())
+}
+
+// TagHandler manages the tags
+//
+// GET, PATCH and DELETE /tags/{id}, renaming and deleting a tag needs an admin
+// POST /tags/{id}/videos adds and removes the tag from many videos at once, every logged in user can tag videos
+//
+func (server *Server) TagHandler(w http.ResponseWriter, r *http.Request) {
+	uriSegments := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+
+	if len(uriSegments) < 2 {
+		http.Error(w, "Invalid Request", http.StatusBadRequest)
+		return
+	}
+
+	tagID, err := strconv.Atoi(uriSegments[1])
+	if err != nil {
+		http.Error(w, "Invalid Request", http.StatusBadRequest)
+		return
+	}
+
+	// everyone can see the tags, the users tag the videos and only the admins rename and delete the tags
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPatch, http.MethodDelete:
+		if _, ok := server.adminUser(w, r); !ok {
+			return
+		}
+	default:
+		if _, ok := server.loggedUser(w, r); !ok {
+			return
+		}
+	}
+
+	tag, _ := server.Storage.GetTagByID(tagID)
+	if tag.Name == "" {
+		http.Error(w, "tag_not_exists", http.StatusNotFound)
+		return
+	}
+
+	action := strings.Join(uriSegments[2:], "/")
+
+	switch {
+	case action == "" && r.Method == http.MethodGet:
+		writeJSON(w, tag)
+	case action == "" && r.Method == http.MethodPatch:
+		var update TagUpdate
+
+		err = json.NewDecoder(r.Body).Decode(&update)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		tag, err = server.Storage.RenameTag(tagID, update.Name)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		writeJSON(w, tag)
+	case action == "" && r.Method == http.MethodDelete:
+		err = server.Storage.DeleteTag(tagID)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	case action == "videos" && r.Method == http.MethodPost:
+		var videos TagVideos
+
+		err = json.NewDecoder(r.Body).Decode(&videos)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		err = server.Storage.TagVideos(tagID, videos.Add, videos.Remove)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		writeJSON(w, tag)
+	default:
+		http.Error(w, "Invalid Request", http.StatusBadRequest)
+	}
+}
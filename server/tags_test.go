@@ -0,0 +1,67 @@
+package mpcserver
+
+import (
+	"github.com/jempe/mpc/users"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestUsersTagVideos(t *testing.T) {
+	server := newTestServer(t, "movie.mp4")
+
+	user := sessionCookie(t, server, newTestUser(t, server, mpcusers.RoleUser))
+	video, _ := server.Storage.GetVideoByFileName("movie.mp4")
+
+	request := func(handler http.HandlerFunc, method string, url string, body string, cookie *http.Cookie) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, url, strings.NewReader(body))
+		if cookie != nil {
+			r.AddCookie(cookie)
+		}
+
+		w := httptest.NewRecorder()
+		handler(w, r)
+
+		return w
+	}
+
+	if w := request(server.TagsHandler, http.MethodPost, "/tags", `{"name": "favorite"}`, nil); w.Code != http.StatusUnauthorized {
+		t.Error("the tags should be created by logged in users", w.Code)
+	}
+
+	w := request(server.TagsHandler, http.MethodPost, "/tags", `{"name": "favorite"}`, user)
+	if w.Code != http.StatusOK {
+		t.Fatal("the users should create tags", w.Code, w.Body.String())
+	}
+
+	var tag struct {
+		ID int `json:"id"`
+	}
+
+	json.NewDecoder(w.Body).Decode(&tag)
+
+	tagURL := "/tags/" + strconv.Itoa(tag.ID)
+
+	if w = request(server.TagHandler, http.MethodPost, tagURL+"/videos", `{"add": ["`+video.ID+`"]}`, user); w.Code != http.StatusOK {
+		t.Fatal("the users should tag videos", w.Code, w.Body.String())
+	}
+
+	if video, _ = server.Storage.GetVideoByID(video.ID); len(video.Tags) != 1 || video.Tags[0].Name != "favorite" {
+		t.Error("the video should have the tag", video.Tags)
+	}
+
+	for _, method := range []string{http.MethodPatch, http.MethodDelete} {
+		if w = request(server.TagHandler, method, tagURL, `{"name": "best"}`, user); w.Code != http.StatusForbidden {
+			t.Error("only the admins should rename and delete the tags", method, w.Code)
+		}
+	}
+
+	admin := sessionCookie(t, server, newTestUser(t, server, mpcusers.RoleAdmin))
+
+	if w = request(server.TagHandler, http.MethodDelete, tagURL, "", admin); w.Code != http.StatusNoContent {
+		t.Error("the admins should delete the tags", w.Code)
+	}
+}
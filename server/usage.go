@@ -0,0 +1,108 @@
+package mpcserver
+
+import (
+	"github.com/jempe/mpc/usage"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+type CleanupRequest struct {
+	// DryRun is true when it's not in the request
+	DryRun *bool `json:"dryRun"`
+}
+
+// UsageHandler reports the disk space used by the videos, their actors and categories and every type of file
+//
+// GET /usage shows the disk usage with the warnings of the cleanup policy
+// GET /usage/policy shows the cleanup policy
+// POST /usage/cleanup applies the cleanup policy, the files are only listed unless dryRun is false
+//
+func (server *Server) UsageHandler(w http.ResponseWriter, r *http.Request) {
+	_, ok := server.adminUser(w, r)
+	if !ok {
+		return
+	}
+
+	action := strings.Trim(strings.TrimPrefix(r.URL.Path, "/usage"), "/")
+
+	switch {
+	case action == "" && r.Method == http.MethodGet:
+		report, err := server.disk().Report()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		report.Warnings = server.CleanupPolicy.Warnings(report.Disk)
+
+		writeJSON(w, report)
+	case action == "policy" && r.Method == http.MethodGet:
+		writeJSON(w, server.CleanupPolicy)
+	case action == "cleanup" && r.Method == http.MethodPost:
+		var request CleanupRequest
+
+		// the body is optional, the cleanup is a dry run without it
+		if r.ContentLength != 0 {
+			err := json.NewDecoder(r.Body).Decode(&request)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+
+		result, err := server.disk().Cleanup(server.CleanupPolicy, request.DryRun == nil || *request.DryRun)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		writeJSON(w, result)
+	default:
+		http.Error(w, "method_not_allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// RunCleanup applies the cleanup policy every interval until the stop channel is closed, the warnings are logged
+//
+func (server *Server) RunCleanup(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		result, err := server.disk().Cleanup(server.CleanupPolicy, false)
+		if err != nil {
+			log.Println("Cleanup:", err)
+		} else if len(result.Actions) > 0 {
+			log.Println("Cleanup: deleted", len(result.Actions), "files,", result.Freed, "bytes")
+		}
+
+		for _, warning := range result.Warnings {
+			log.Println("Cleanup warning:", warning)
+		}
+
+		select {
+		case <-ticker.C:
+		case <-stop:
+			return
+		}
+	}
+}
+
+// disk gets the disk usage of the library, the artwork and the image cache
+//
+func (server *Server) disk() *mpcusage.Disk {
+	disk := &mpcusage.Disk{Storage: server.Storage, Library: server.Library, Folders: make(map[string]string)}
+
+	if server.Artwork != nil {
+		disk.Folders[mpcusage.TypeArtwork] = server.Artwork.Path
+	}
+
+	if server.ImageCache != nil {
+		disk.Folders[mpcusage.TypeCache] = server.ImageCache.Path
+	}
+
+	return disk
+}
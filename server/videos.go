@@ -0,0 +1,134 @@
+package mpcserver
+
+import (
+	"github.com/jempe/mpc/storage"
+	"github.com/jempe/mpc/utils"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+)
+
+type VideoPatch struct {
+	Title       *string   `json:"title"`
+	Description *string   `json:"description"`
+	PubDate     *string   `json:"pubDate"`
+	Actors      *[]string `json:"actors"`
+	Categories  *[]string `json:"categories"`
+	Sidecar     bool      `json:"sidecar"`
+}
+
+// VideoHandler manages the video metadata
+//
+// GET and PATCH /videos/{id}, the changes need an admin
+// GET /videos/{id}/history shows the edit history of the video
+// POST /videos/{id}/watched records that the user watched the video
+// GET /videos/{id}/next gets the next episode of the series
+//
+func (server *Server) VideoHandler(w http.ResponseWriter, r *http.Request) {
+	uriSegments := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+
+	if len(uriSegments) < 2 {
+		http.Error(w, "Invalid Request", http.StatusBadRequest)
+		return
+	}
+
+	videoID := uriSegments[1]
+
+	video, _ := server.Storage.GetVideoByID(videoID)
+	if video.ID == "" {
+		http.Error(w, "video_not_exists", http.StatusNotFound)
+		return
+	}
+
+	action := strings.Join(uriSegments[2:], "/")
+
+	switch {
+	case action == "" && r.Method == http.MethodGet:
+		writeJSON(w, video)
+	case action == "" && r.Method == http.MethodPatch:
+		userID, ok := server.adminUser(w, r)
+		if !ok {
+			return
+		}
+
+		server.patchVideo(w, r, videoID, userID)
+	case action == "watched" && r.Method == http.MethodPost:
+		userID, ok := server.loggedUser(w, r)
+		if !ok {
+			return
+		}
+
+		watch, err := server.Storage.RecordWatch(userID, videoID)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		writeJSON(w, watch)
+	case action == "history" && r.Method == http.MethodGet:
+		history, err := server.Storage.GetVideoHistory(videoID)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		writeJSON(w, history)
+	case action == "next" && r.Method == http.MethodGet:
+		next, err := server.Storage.NextEpisode(videoID)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+
+		writeJSON(w, next)
+	default:
+		http.Error(w, "Invalid Request", http.StatusBadRequest)
+	}
+}
+
+// patchVideo updates the video metadata and writes it to the json file of the video if it's requested, the edit is
+// saved in the history with the user
+//
+func (server *Server) patchVideo(w http.ResponseWriter, r *http.Request, videoID string, userID string) {
+	var patch VideoPatch
+
+	err := json.NewDecoder(r.Body).Decode(&patch)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	update := mpcstorage.VideoUpdate{Title: patch.Title, Description: patch.Description, Actors: patch.Actors, Categories: patch.Categories}
+
+	if patch.PubDate != nil {
+		var pubDate time.Time
+
+		if *patch.PubDate != "" {
+			pubDate, err = mpcutils.ParseDate(*patch.PubDate)
+
+			if err != nil || pubDate.IsZero() {
+				http.Error(w, "video_date_invalid", http.StatusBadRequest)
+				return
+			}
+		}
+
+		update.PubDate = &pubDate
+	}
+
+	video, err := server.Storage.UpdateVideo(videoID, update, userID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if patch.Sidecar {
+		err = server.Library.SaveJSONData(video)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	writeJSON(w, video)
+}
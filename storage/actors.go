@@ -0,0 +1,337 @@
+package mpcstorage
+
+import (
+	"github.com/jempe/mpc/library"
+	"encoding/json"
+	"errors"
+	"github.com/boltdb/bolt"
+	"sort"
+	"strings"
+)
+
+type ActorInfo struct {
+	mpclibrary.Actor
+	Videos int `json:"videos"`
+}
+
+type ActorResults struct {
+	Actors []ActorInfo `json:"actors"`
+	Total  int         `json:"total"`
+	Offset int         `json:"offset"`
+	View   int         `json:"view"`
+}
+
+// GetActors gets the actors that match the search with the number of videos of every actor
+//
+func (storage *Storage) GetActors(offset int, view int, search string) ActorResults {
+	counts := storage.ActorVideoCounts()
+	search = strings.ToLower(strings.TrimSpace(search))
+
+	var actors []ActorInfo
+
+	storage.mutex.RLock()
+	for id, actor := range storage.Actors {
+		if search == "" || actorMatches(actor, search) {
+			actors = append(actors, ActorInfo{Actor: actor, Videos: counts[id]})
+		}
+	}
+	storage.mutex.RUnlock()
+
+	sort.Slice(actors, func(i, j int) bool {
+		return strings.ToLower(actors[i].Name) < strings.ToLower(actors[j].Name)
+	})
+
+	results := ActorResults{Offset: offset, View: view, Total: len(actors)}
+
+	lastResult := offset + view
+
+	if lastResult > len(actors) {
+		lastResult = len(actors)
+	}
+
+	if offset < len(actors) {
+		results.Actors = actors[offset:lastResult]
+	}
+
+	return results
+}
+
+// actorMatches checks if the actor name or one of its aliases contains the search
+//
+func actorMatches(actor mpclibrary.Actor, search string) bool {
+	if strings.Contains(strings.ToLower(actor.Name), search) {
+		return true
+	}
+
+	for _, alias := range actor.Aliases {
+		if strings.Contains(strings.ToLower(alias), search) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ActorVideoCounts gets the number of videos of every actor
+//
+func (storage *Storage) ActorVideoCounts() map[int]int {
+	counts := make(map[int]int)
+
+	storage.mutex.RLock()
+	defer storage.mutex.RUnlock()
+
+	for _, video := range storage.Videos {
+		for _, actor := range video.Actors {
+			counts[actor]++
+		}
+	}
+
+	return counts
+}
+
+// UpdateActor saves the profile of an existing actor
+//
+func (storage *Storage) UpdateActor(actor mpclibrary.Actor) error {
+	dbActor, _ := storage.GetActorByID(actor.ID)
+	if dbActor.Name == "" {
+		return errors.New("actor_not_exists")
+	}
+
+	actor.Name = strings.TrimSpace(actor.Name)
+	if actor.Name == "" {
+		return errors.New("actor_name_empty")
+	}
+
+	names := append([]string{actor.Name}, actor.Aliases...)
+
+	for _, name := range names {
+		sameName, _ := storage.GetActorByName(name)
+		if sameName.Name != "" && sameName.ID != actor.ID {
+			return errors.New("actor_name_exists")
+		}
+	}
+
+	actor.Aliases = cleanAliases(actor.Name, actor.Aliases)
+
+	err := storage.Db.Update(func(tx *bolt.Tx) error {
+		return saveActor(tx.Bucket([]byte("actors")), actor)
+	})
+
+	if err == nil {
+		storage.mutex.Lock()
+		storage.Actors[actor.ID] = actor
+		storage.mutex.Unlock()
+	}
+
+	return err
+}
+
+// AddActorAlias adds an alternative name to an actor
+//
+func (storage *Storage) AddActorAlias(actorID int, alias string) (actor mpclibrary.Actor, err error) {
+	actor, _ = storage.GetActorByID(actorID)
+	if actor.Name == "" {
+		return actor, errors.New("actor_not_exists")
+	}
+
+	alias = strings.TrimSpace(alias)
+	if alias == "" {
+		return actor, errors.New("actor_alias_empty")
+	}
+
+	actor.Aliases = append(actor.Aliases, alias)
+
+	err = storage.UpdateActor(actor)
+
+	return
+}
+
+// MergeActors moves all the videos, names and missing profile data of the source actor to the target actor
+// and deletes the source actor
+//
+func (storage *Storage) MergeActors(sourceID int, targetID int) (actor mpclibrary.Actor, err error) {
+	if sourceID == targetID {
+		return actor, errors.New("actor_merge_same")
+	}
+
+	source, _ := storage.GetActorByID(sourceID)
+	actor, _ = storage.GetActorByID(targetID)
+
+	if source.Name == "" || actor.Name == "" {
+		return actor, errors.New("actor_not_exists")
+	}
+
+	actor.Aliases = cleanAliases(actor.Name, append(append(actor.Aliases, source.Name), source.Aliases...))
+
+	if actor.Gender == "" {
+		actor.Gender = source.Gender
+	}
+
+	if actor.Birth.IsZero() {
+		actor.Birth = source.Birth
+	}
+
+	if actor.Photo == "" {
+		actor.Photo = source.Photo
+	}
+
+	err = storage.Db.Update(func(tx *bolt.Tx) error {
+		err := storage.updateVideos(tx.Bucket([]byte("videos")), func(video *Video) bool {
+			if !containsInt(video.Actors, sourceID) {
+				return false
+			}
+
+			video.Actors = replaceInt(video.Actors, sourceID, targetID)
+			return true
+		})
+		if err != nil {
+			return err
+		}
+
+		actorsBucket := tx.Bucket([]byte("actors"))
+
+		err = actorsBucket.Delete(itob(sourceID))
+		if err != nil {
+			return err
+		}
+
+		return saveActor(actorsBucket, actor)
+	})
+
+	if err != nil {
+		return
+	}
+
+	err = storage.getAllActors()
+	if err != nil {
+		return
+	}
+
+	err = storage.GetAllVideos()
+
+	return
+}
+
+// DeleteActor deletes an actor that is not used by any video
+//
+func (storage *Storage) DeleteActor(actorID int) error {
+	actor, _ := storage.GetActorByID(actorID)
+	if actor.Name == "" {
+		return errors.New("actor_not_exists")
+	}
+
+	if storage.ActorVideoCounts()[actorID] > 0 {
+		return errors.New("actor_in_use")
+	}
+
+	err := storage.Db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket([]byte("actors")).Delete(itob(actorID))
+	})
+
+	if err == nil {
+		storage.mutex.Lock()
+		delete(storage.Actors, actorID)
+		storage.mutex.Unlock()
+	}
+
+	return err
+}
+
+// saveActor saves an actor in the actors bucket
+//
+func saveActor(bucket *bolt.Bucket, actor mpclibrary.Actor) error {
+	jsonActor, err := json.Marshal(actor)
+	if err != nil {
+		return err
+	}
+
+	return bucket.Put(itob(actor.ID), jsonActor)
+}
+
+// updateVideos applies the update function to every video of the bucket and saves the videos that changed
+//
+func (storage *Storage) updateVideos(bucket *bolt.Bucket, update func(video *Video) bool) error {
+	changed := make(map[string]Video)
+
+	c := bucket.Cursor()
+
+	for k, v := c.First(); k != nil; k, v = c.Next() {
+		var dbVideo Video
+
+		err := json.Unmarshal(v, &dbVideo)
+		if err != nil {
+			return err
+		}
+
+		if update(&dbVideo) {
+			changed[string(k)] = dbVideo
+		}
+	}
+
+	for key, dbVideo := range changed {
+		jsonVideo, err := json.Marshal(dbVideo)
+		if err != nil {
+			return err
+		}
+
+		err = bucket.Put([]byte(key), jsonVideo)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// cleanAliases removes empty, repeated aliases and aliases equal to the name
+//
+func cleanAliases(name string, aliases []string) (cleaned []string) {
+	for _, alias := range aliases {
+		alias = strings.TrimSpace(alias)
+
+		if alias == "" || strings.EqualFold(alias, name) {
+			continue
+		}
+
+		repeated := false
+		for _, existing := range cleaned {
+			if strings.EqualFold(existing, alias) {
+				repeated = true
+			}
+		}
+
+		if !repeated {
+			cleaned = append(cleaned, alias)
+		}
+	}
+
+	return
+}
+
+// containsInt checks if the list contains the value
+//
+func containsInt(list []int, value int) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+
+	return false
+}
+
+// replaceInt replaces a value of the list with a new one without repeating values
+//
+func replaceInt(list []int, old int, new int) (replaced []int) {
+	for _, item := range list {
+		if item == old {
+			item = new
+		}
+
+		if !containsInt(replaced, item) {
+			replaced = append(replaced, item)
+		}
+	}
+
+	return
+}
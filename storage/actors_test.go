@@ -0,0 +1,103 @@
+package mpcstorage
+
+import (
+	"github.com/jempe/mpc/library"
+	"strings"
+	"testing"
+)
+
+// newTestStorage creates a DB in a temp folder with an encrypted video for every title, like one.mp4 for One,
+// and gets the IDs of the videos in the same order
+//
+func newTestStorage(t *testing.T, titles ...string) (*Storage, []string) {
+	testStorage := &Storage{Path: t.TempDir()}
+
+	err := testStorage.InitDb()
+	if err != nil {
+		t.Fatal("Init DB error", err)
+	}
+
+	t.Cleanup(func() {
+		testStorage.Db.Close()
+	})
+
+	if len(titles) == 0 {
+		return testStorage, nil
+	}
+
+	var videos []mpclibrary.Video
+
+	for _, title := range titles {
+		videos = append(videos, mpclibrary.Video{File: strings.ToLower(title) + ".mp4", Title: title, Encrypted: true})
+	}
+
+	err = testStorage.InsertVideos(videos)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = testStorage.GetAllVideos()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var videoIDs []string
+
+	for _, video := range videos {
+		dbVideo, _ := testStorage.GetVideoByFileName(video.File)
+		videoIDs = append(videoIDs, dbVideo.ID)
+	}
+
+	return testStorage, videoIDs
+}
+
+func TestMergeActors(t *testing.T) {
+	testStorage, _ := newTestStorage(t)
+
+	videos := []mpclibrary.Video{
+		{File: "first.mp4", Title: "First", Encrypted: true, Actors: []mpclibrary.Actor{{Name: "Jon Doe"}}},
+		{File: "second.mp4", Title: "Second", Encrypted: true, Actors: []mpclibrary.Actor{{Name: "John Doe"}, {Name: "Jon Doe"}}},
+	}
+
+	err := testStorage.InsertVideos(videos)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = testStorage.GetAllVideos()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	source, _ := testStorage.GetActorByName("Jon Doe")
+	target, _ := testStorage.GetActorByName("John Doe")
+
+	actor, err := testStorage.MergeActors(source.ID, target.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(actor.Aliases) != 1 || actor.Aliases[0] != "Jon Doe" {
+		t.Error("source name should be an alias of the target actor", actor.Aliases)
+	}
+
+	if len(testStorage.Actors) != 1 {
+		t.Error("source actor should be deleted")
+	}
+
+	for _, video := range testStorage.Videos {
+		if len(video.Actors) != 1 || video.Actors[0] != target.ID {
+			t.Error("video actors should point to the target actor", video.Title, video.Actors)
+		}
+	}
+
+	aliasActor, _ := testStorage.GetActorByName("jon doe")
+	if aliasActor.ID != target.ID {
+		t.Error("actor should be found by alias")
+	}
+
+	err = testStorage.DeleteActor(target.ID)
+	if err == nil || err.Error() != "actor_in_use" {
+		t.Error("actors with videos shouldn't be deleted")
+	}
+}
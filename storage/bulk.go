@@ -0,0 +1,344 @@
+package mpcstorage
+
+import (
+	"github.com/jempe/mpc/library"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"github.com/boltdb/bolt"
+)
+
+type BulkChange struct {
+	VideoID string        `json:"id"`
+	Title   string        `json:"title"`
+	Changes []FieldChange `json:"changes"`
+}
+
+// FilterVideoIDs gets the IDs of all the videos that meet the search criteria
+//
+func (storage *Storage) FilterVideoIDs(filter VideoFilter) (videoIDs []string) {
+	results := storage.GetVideos(0, storage.CountVideos(), "title", filter, 0)
+
+	for _, video := range results.Videos {
+		videoIDs = append(videoIDs, video.ID)
+	}
+
+	return
+}
+
+// BulkUpdateVideos applies the update returned by the update function to every video in a single transaction.
+// When dryRun is set the changes are returned but the transaction is rolled back
+//
+func (storage *Storage) BulkUpdateVideos(videoIDs []string, update func(video mpclibrary.Video) VideoUpdate, user string, dryRun bool) (changes []BulkChange, err error) {
+	tx, err := storage.Db.Begin(true)
+	if err != nil {
+		return
+	}
+	defer tx.Rollback()
+
+	for _, videoID := range videoIDs {
+		video, _ := storage.GetVideoByID(videoID)
+		if video.ID == "" {
+			storage.reloadAll()
+			return changes, errors.New("video_not_exists")
+		}
+
+		videoUpdate := update(video)
+
+		err = validateVideoUpdate(videoUpdate)
+		if err != nil {
+			storage.reloadAll()
+			return
+		}
+
+		edit, err := storage.updateVideo(tx, videoID, videoUpdate, user)
+		if err != nil {
+			storage.reloadAll()
+			return changes, err
+		}
+
+		if len(edit.Changes) > 0 {
+			changes = append(changes, BulkChange{VideoID: videoID, Title: video.Title, Changes: edit.Changes})
+		}
+	}
+
+	if !dryRun {
+		err = tx.Commit()
+	}
+
+	reloadErr := storage.reloadAll()
+
+	if err == nil {
+		err = reloadErr
+	}
+
+	return
+}
+
+// DeleteVideos deletes the videos and everything that refers to them from the DB in a single transaction, the video files are not deleted.
+// When dryRun is set the videos that would be deleted are returned but the transaction is rolled back
+//
+func (storage *Storage) DeleteVideos(videoIDs []string, dryRun bool) (changes []BulkChange, err error) {
+	tx, err := storage.Db.Begin(true)
+	if err != nil {
+		return
+	}
+	defer tx.Rollback()
+
+	changes, err = deleteVideos(tx, videoIDs)
+	if err != nil || dryRun {
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return
+	}
+
+	err = storage.GetAllVideos()
+
+	return
+}
+
+// deleteVideos deletes the videos with their edit history and fingerprints in the transaction, and removes them
+// from the playlists, collections, player queues, watches and metadata reviews
+//
+func deleteVideos(tx *bolt.Tx, videoIDs []string) (changes []BulkChange, err error) {
+	deleted := make(map[string]bool)
+
+	for _, videoID := range videoIDs {
+		jsonVideo := tx.Bucket([]byte("videos")).Get([]byte(videoID))
+		if jsonVideo == nil {
+			return changes, errors.New("video_not_exists")
+		}
+
+		var dbVideo Video
+
+		err = json.Unmarshal(jsonVideo, &dbVideo)
+		if err != nil {
+			return
+		}
+
+		for _, bucket := range []string{"videos", "history", "fingerprints"} {
+			err = tx.Bucket([]byte(bucket)).Delete([]byte(videoID))
+			if err != nil {
+				return
+			}
+		}
+
+		deleted[videoID] = true
+
+		changes = append(changes, BulkChange{VideoID: videoID, Title: dbVideo.Title, Changes: []FieldChange{{Field: "deleted", Old: false, New: true}}})
+	}
+
+	err = rewriteBucket(tx.Bucket([]byte("playlists")), func(value []byte) ([]byte, error) {
+		var playlist Playlist
+
+		if err := json.Unmarshal(value, &playlist); err != nil {
+			return nil, err
+		}
+
+		var changed bool
+
+		if playlist.Videos, changed = removeVideoIDs(playlist.Videos, deleted); !changed {
+			return value, nil
+		}
+
+		return json.Marshal(playlist)
+	})
+	if err != nil {
+		return
+	}
+
+	err = rewriteBucket(tx.Bucket([]byte("collections")), func(value []byte) ([]byte, error) {
+		var collection Collection
+
+		if err := json.Unmarshal(value, &collection); err != nil {
+			return nil, err
+		}
+
+		var changed bool
+
+		if collection.Videos, changed = removeVideoIDs(collection.Videos, deleted); !changed {
+			return value, nil
+		}
+
+		return json.Marshal(collection)
+	})
+	if err != nil {
+		return
+	}
+
+	err = rewriteBucket(tx.Bucket([]byte("queues")), func(value []byte) ([]byte, error) {
+		var queue PlayerQueue
+
+		if err := json.Unmarshal(value, &queue); err != nil {
+			return nil, err
+		}
+
+		// the current index moves with its video, or to the next video when its video is deleted
+		current := queue.Current
+
+		for index, videoID := range queue.Videos {
+			if deleted[videoID] && index < queue.Current {
+				current--
+			}
+		}
+
+		var changed bool
+
+		if queue.Videos, changed = removeVideoIDs(queue.Videos, deleted); !changed {
+			return value, nil
+		}
+
+		queue.Current = current
+		if queue.Current >= len(queue.Videos) {
+			queue.Current = -1
+		}
+
+		return json.Marshal(queue)
+	})
+	if err != nil {
+		return
+	}
+
+	err = rewriteBucket(tx.Bucket([]byte("watches")), func(value []byte) ([]byte, error) {
+		var watches map[string]Watch
+
+		if err := json.Unmarshal(value, &watches); err != nil {
+			return nil, err
+		}
+
+		changed := false
+
+		for videoID := range watches {
+			if deleted[videoID] {
+				delete(watches, videoID)
+				changed = true
+			}
+		}
+
+		if !changed {
+			return value, nil
+		}
+
+		return json.Marshal(watches)
+	})
+	if err != nil {
+		return
+	}
+
+	err = rewriteBucket(tx.Bucket([]byte("metadatareviews")), func(value []byte) ([]byte, error) {
+		var review MetadataReview
+
+		if err := json.Unmarshal(value, &review); err != nil {
+			return nil, err
+		}
+
+		if deleted[review.VideoID] {
+			return nil, nil
+		}
+
+		return value, nil
+	})
+
+	return
+}
+
+// rewriteBucket replaces every value of the bucket with the value returned by change, the value is deleted when
+// change returns nil. The changes are written after the loop because bolt doesn't allow changes while it iterates
+//
+func rewriteBucket(bucket *bolt.Bucket, change func(value []byte) ([]byte, error)) error {
+	updates := make(map[string][]byte)
+
+	err := bucket.ForEach(func(k, v []byte) error {
+		newValue, err := change(v)
+		if err != nil {
+			return err
+		}
+
+		if newValue == nil || !bytes.Equal(newValue, v) {
+			updates[string(k)] = newValue
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for key, value := range updates {
+		if value == nil {
+			err = bucket.Delete([]byte(key))
+		} else {
+			err = bucket.Put([]byte(key), value)
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// removeVideoIDs removes the deleted videos from a list of videos and checks if any video was removed
+//
+func removeVideoIDs(videos []string, deleted map[string]bool) ([]string, bool) {
+	kept := []string{}
+
+	for _, videoID := range videos {
+		if !deleted[videoID] {
+			kept = append(kept, videoID)
+		}
+	}
+
+	return kept, len(kept) != len(videos)
+}
+
+// SetVideoFile changes the file of a video after it's encrypted or decrypted
+//
+func (storage *Storage) SetVideoFile(videoID string, file string, encrypted bool) error {
+	tx, err := storage.Db.Begin(true)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	videosBucket := tx.Bucket([]byte("videos"))
+
+	jsonVideo := videosBucket.Get([]byte(videoID))
+	if jsonVideo == nil {
+		return errors.New("video_not_exists")
+	}
+
+	var dbVideo Video
+
+	err = json.Unmarshal(jsonVideo, &dbVideo)
+	if err != nil {
+		return err
+	}
+
+	dbVideo.File = file
+	dbVideo.Encrypted = encrypted
+
+	jsonVideo, err = json.Marshal(dbVideo)
+	if err != nil {
+		return err
+	}
+
+	err = videosBucket.Put([]byte(videoID), jsonVideo)
+	if err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	storage.mutex.Lock()
+	storage.Videos[videoID] = dbVideo
+	storage.mutex.Unlock()
+
+	return nil
+}
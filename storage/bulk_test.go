@@ -0,0 +1,160 @@
+package mpcstorage
+
+import (
+	"github.com/jempe/mpc/library"
+	"testing"
+)
+
+func TestBulkUpdateVideosDryRun(t *testing.T) {
+	testStorage, videoIDs := newTestStorage(t, "One", "Two")
+
+	addActor := func(video mpclibrary.Video) VideoUpdate {
+		actors := []string{"Jane Doe"}
+		return VideoUpdate{Actors: &actors}
+	}
+
+	changes, err := testStorage.BulkUpdateVideos(videoIDs, addActor, "user-uuid", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(changes) != 2 || changes[0].Changes[0].Field != "actors" {
+		t.Error("the dry run should return the changes", changes)
+	}
+
+	if actor, _ := testStorage.GetActorByName("Jane Doe"); actor.ID != 0 {
+		t.Error("the dry run should not create the actor", actor)
+	}
+
+	for _, videoID := range videoIDs {
+		video, _ := testStorage.GetVideoByID(videoID)
+		history, _ := testStorage.GetVideoHistory(videoID)
+
+		if len(video.Actors) != 0 || len(history) != 0 {
+			t.Error("the dry run should be rolled back", video.Actors, history)
+		}
+	}
+
+	_, err = testStorage.BulkUpdateVideos(append(videoIDs, "missing"), addActor, "user-uuid", false)
+	if err == nil || err.Error() != "video_not_exists" {
+		t.Error("a missing video should fail the whole update", err)
+	}
+
+	if video, _ := testStorage.GetVideoByID(videoIDs[0]); len(video.Actors) != 0 {
+		t.Error("the failed update should be rolled back", video.Actors)
+	}
+
+	changes, err = testStorage.BulkUpdateVideos(videoIDs, addActor, "user-uuid", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, videoID := range videoIDs {
+		video, _ := testStorage.GetVideoByID(videoID)
+		history, _ := testStorage.GetVideoHistory(videoID)
+
+		if len(video.Actors) != 1 || video.Actors[0].Name != "Jane Doe" || len(history) != 1 || history[0].User != "user-uuid" {
+			t.Error("the update should be saved with its history", video.Actors, history)
+		}
+	}
+}
+
+func TestDeleteVideos(t *testing.T) {
+	testStorage, videoIDs := newTestStorage(t, "One", "Two")
+
+	title := "First"
+
+	_, err := testStorage.UpdateVideo(videoIDs[0], VideoUpdate{Title: &title}, "user-uuid")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	changes, err := testStorage.DeleteVideos(videoIDs[:1], true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(changes) != 1 || changes[0].VideoID != videoIDs[0] || changes[0].Title != "First" {
+		t.Error("the dry run should list the deleted video", changes)
+	}
+
+	if video, _ := testStorage.GetVideoByID(videoIDs[0]); video.ID == "" {
+		t.Error("the dry run should not delete the video")
+	}
+
+	_, err = testStorage.DeleteVideos([]string{videoIDs[0], "missing"}, false)
+	if err == nil || err.Error() != "video_not_exists" {
+		t.Error("a missing video should fail the whole delete", err)
+	}
+
+	if len(testStorage.Videos) != 2 {
+		t.Error("the failed delete should be rolled back", len(testStorage.Videos))
+	}
+
+	_, err = testStorage.DeleteVideos(videoIDs[:1], false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := testStorage.Videos[videoIDs[0]]; ok || len(testStorage.Videos) != 1 {
+		t.Error("the video should be deleted", testStorage.Videos)
+	}
+
+	if history, _ := testStorage.GetVideoHistory(videoIDs[0]); len(history) != 0 {
+		t.Error("the history of the deleted video should be deleted", history)
+	}
+}
+
+func TestDeleteVideosReferences(t *testing.T) {
+	testStorage, videoIDs := newTestStorage(t, "One", "Two")
+
+	playlist, err := testStorage.SavePlaylist(Playlist{Name: "Both", Owner: "user", Videos: videoIDs})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	collection, err := testStorage.SaveCollection(Collection{Name: "Both", Videos: videoIDs})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = testStorage.SavePlayerQueue(PlayerQueue{Player: "tv", Videos: videoIDs, Current: 1, Repeat: RepeatOff})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, videoID := range videoIDs {
+		if _, err = testStorage.RecordWatch("user", videoID); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	_, err = testStorage.AddMetadataReview(MetadataReview{VideoID: videoIDs[0], Scraper: "site"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = testStorage.DeleteVideos(videoIDs[:1], false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	playlist, _ = testStorage.GetPlaylist(playlist.ID)
+	collection, _ = testStorage.GetCollection(collection.ID)
+
+	if len(playlist.Videos) != 1 || playlist.Videos[0] != videoIDs[1] || len(collection.Videos) != 1 || collection.Videos[0] != videoIDs[1] {
+		t.Error("the deleted video should be removed from the playlists and collections", playlist.Videos, collection.Videos)
+	}
+
+	if queue, _ := testStorage.GetPlayerQueue("tv"); len(queue.Videos) != 1 || queue.Current != 0 {
+		t.Error("the queue should keep playing the same video", queue)
+	}
+
+	if watches, _ := testStorage.GetWatches("user"); len(watches) != 1 || watches[videoIDs[1]].Count != 1 {
+		t.Error("the watches of the deleted video should be removed", watches)
+	}
+
+	if reviews, _ := testStorage.GetMetadataReviews(""); len(reviews) != 0 {
+		t.Error("the reviews of the deleted video should be removed", reviews)
+	}
+}
@@ -0,0 +1,293 @@
+package mpcstorage
+
+import (
+	"github.com/jempe/mpc/library"
+	"encoding/json"
+	"errors"
+	"github.com/boltdb/bolt"
+	"sort"
+	"strings"
+)
+
+type CategoryInfo struct {
+	mpclibrary.Category
+	Videos   int   `json:"videos"`
+	Children []int `json:"children"`
+}
+
+// GetCategoryList gets all the categories sorted by name with their children and number of videos
+//
+func (storage *Storage) GetCategoryList() (categories []CategoryInfo) {
+	counts := make(map[int]int)
+
+	storage.mutex.RLock()
+	defer storage.mutex.RUnlock()
+
+	for _, video := range storage.Videos {
+		for _, category := range video.Categories {
+			counts[category]++
+		}
+	}
+
+	for id, category := range storage.Categories {
+		info := CategoryInfo{Category: category, Videos: counts[id], Children: []int{}}
+
+		for childID, child := range storage.Categories {
+			if child.Parent == id {
+				info.Children = append(info.Children, childID)
+			}
+		}
+
+		sort.Ints(info.Children)
+
+		categories = append(categories, info)
+	}
+
+	sort.Slice(categories, func(i, j int) bool {
+		return strings.ToLower(categories[i].Name) < strings.ToLower(categories[j].Name)
+	})
+
+	return
+}
+
+// CreateCategory inserts a new category in the DB
+//
+func (storage *Storage) CreateCategory(name string, parent int) (category mpclibrary.Category, err error) {
+	category = mpclibrary.Category{Name: strings.TrimSpace(name), Parent: parent}
+
+	err = storage.validateCategory(category)
+	if err != nil {
+		return
+	}
+
+	err = storage.Db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte("categories"))
+
+		id, err := bucket.NextSequence()
+		if err != nil {
+			return err
+		}
+
+		category.ID = int(id)
+
+		return saveCategory(bucket, category)
+	})
+
+	if err == nil {
+		storage.mutex.Lock()
+		storage.Categories[category.ID] = category
+		storage.mutex.Unlock()
+	}
+
+	return
+}
+
+// UpdateCategory renames or moves an existing category
+//
+func (storage *Storage) UpdateCategory(category mpclibrary.Category) error {
+	dbCategory, _ := storage.GetCategoryByID(category.ID)
+	if dbCategory.Name == "" {
+		return errors.New("category_not_exists")
+	}
+
+	category.Name = strings.TrimSpace(category.Name)
+
+	err := storage.validateCategory(category)
+	if err != nil {
+		return err
+	}
+
+	err = storage.Db.Update(func(tx *bolt.Tx) error {
+		return saveCategory(tx.Bucket([]byte("categories")), category)
+	})
+
+	if err == nil {
+		storage.mutex.Lock()
+		storage.Categories[category.ID] = category
+		storage.mutex.Unlock()
+	}
+
+	return err
+}
+
+// MergeCategories moves all the videos and children of the source category to the target category
+// and deletes the source category
+//
+func (storage *Storage) MergeCategories(sourceID int, targetID int) (category mpclibrary.Category, err error) {
+	if sourceID == targetID {
+		return category, errors.New("category_merge_same")
+	}
+
+	source, _ := storage.GetCategoryByID(sourceID)
+	category, _ = storage.GetCategoryByID(targetID)
+
+	if source.Name == "" || category.Name == "" {
+		return category, errors.New("category_not_exists")
+	}
+
+	if containsInt(storage.CategoryDescendants(sourceID), targetID) {
+		return category, errors.New("category_parent_cycle")
+	}
+
+	err = storage.Db.Update(func(tx *bolt.Tx) error {
+		err := storage.updateVideos(tx.Bucket([]byte("videos")), func(video *Video) bool {
+			if !containsInt(video.Categories, sourceID) {
+				return false
+			}
+
+			video.Categories = replaceInt(video.Categories, sourceID, targetID)
+			return true
+		})
+		if err != nil {
+			return err
+		}
+
+		categoriesBucket := tx.Bucket([]byte("categories"))
+
+		for _, child := range storage.cachedCategories() {
+			if child.Parent == sourceID {
+				child.Parent = targetID
+
+				err = saveCategory(categoriesBucket, child)
+				if err != nil {
+					return err
+				}
+			}
+		}
+
+		return categoriesBucket.Delete(itob(sourceID))
+	})
+
+	if err != nil {
+		return
+	}
+
+	err = storage.getAllCategories()
+	if err != nil {
+		return
+	}
+
+	err = storage.GetAllVideos()
+
+	return
+}
+
+// DeleteCategory deletes a category that is not used by any video, its children are moved to its parent
+//
+func (storage *Storage) DeleteCategory(categoryID int) error {
+	category, _ := storage.GetCategoryByID(categoryID)
+	if category.Name == "" {
+		return errors.New("category_not_exists")
+	}
+
+	for _, video := range storage.CachedVideos() {
+		if containsInt(video.Categories, categoryID) {
+			return errors.New("category_in_use")
+		}
+	}
+
+	err := storage.Db.Update(func(tx *bolt.Tx) error {
+		categoriesBucket := tx.Bucket([]byte("categories"))
+
+		for _, child := range storage.cachedCategories() {
+			if child.Parent == categoryID {
+				child.Parent = category.Parent
+
+				err := saveCategory(categoriesBucket, child)
+				if err != nil {
+					return err
+				}
+			}
+		}
+
+		return categoriesBucket.Delete(itob(categoryID))
+	})
+
+	if err != nil {
+		return err
+	}
+
+	return storage.getAllCategories()
+}
+
+// CategoryDescendants gets the IDs of all the children of a category and their children
+//
+func (storage *Storage) CategoryDescendants(categoryID int) []int {
+	storage.mutex.RLock()
+	defer storage.mutex.RUnlock()
+
+	return storage.categoryDescendants(categoryID)
+}
+
+// categoryDescendants gets the children of a category and their children, the caller holds the storage lock
+//
+func (storage *Storage) categoryDescendants(categoryID int) (descendants []int) {
+	for id, category := range storage.Categories {
+		if category.Parent == categoryID && id != categoryID {
+			descendants = append(descendants, id)
+			descendants = append(descendants, storage.categoryDescendants(id)...)
+		}
+	}
+
+	return
+}
+
+// categoryPath gets the name of the category and the names of all its parents
+//
+func (storage *Storage) categoryPath(categoryID int) (names []string) {
+	visited := make(map[int]bool)
+
+	storage.mutex.RLock()
+	defer storage.mutex.RUnlock()
+
+	for categoryID != 0 && !visited[categoryID] {
+		visited[categoryID] = true
+
+		category, ok := storage.Categories[categoryID]
+		if !ok {
+			break
+		}
+
+		names = append(names, category.Name)
+		categoryID = category.Parent
+	}
+
+	return
+}
+
+// validateCategory checks the category name and that its parent exists and is not one of its children
+//
+func (storage *Storage) validateCategory(category mpclibrary.Category) error {
+	if category.Name == "" {
+		return errors.New("category_name_empty")
+	}
+
+	sameName, _ := storage.GetCategoryByName(category.Name)
+	if sameName.Name != "" && sameName.ID != category.ID {
+		return errors.New("category_name_exists")
+	}
+
+	if category.Parent != 0 {
+		parent, _ := storage.GetCategoryByID(category.Parent)
+		if parent.Name == "" {
+			return errors.New("category_parent_not_exists")
+		}
+
+		if category.ID != 0 && (category.Parent == category.ID || containsInt(storage.CategoryDescendants(category.ID), category.Parent)) {
+			return errors.New("category_parent_cycle")
+		}
+	}
+
+	return nil
+}
+
+// saveCategory saves a category in the categories bucket
+//
+func saveCategory(bucket *bolt.Bucket, category mpclibrary.Category) error {
+	jsonCategory, err := json.Marshal(category)
+	if err != nil {
+		return err
+	}
+
+	return bucket.Put(itob(category.ID), jsonCategory)
+}
@@ -0,0 +1,46 @@
+package mpcstorage
+
+import (
+	"github.com/jempe/mpc/library"
+	"testing"
+)
+
+func TestCategoryFilterIncludesChildren(t *testing.T) {
+	testStorage, _ := newTestStorage(t)
+
+	movies, err := testStorage.CreateCategory("Movies", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	scifi, err := testStorage.CreateCategory("Sci-Fi", movies.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	videos := []mpclibrary.Video{
+		{File: "space.mp4", Title: "Space", Encrypted: true, Categories: []mpclibrary.Category{{Name: "Sci-Fi"}}},
+		{File: "news.mp4", Title: "News", Encrypted: true},
+	}
+
+	err = testStorage.InsertVideos(videos)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = testStorage.GetAllVideos()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	results := testStorage.GetVideos(0, 10, "title", VideoFilter{Category: "movies"}, 0)
+	if results.Total != 1 || results.Videos[0].Title != "Space" {
+		t.Error("filtering by parent category should include videos of its children", results)
+	}
+
+	movies.Parent = scifi.ID
+	err = testStorage.UpdateCategory(movies)
+	if err == nil || err.Error() != "category_parent_cycle" {
+		t.Error("a category can't be a child of its children")
+	}
+}
@@ -18,17 +18,25 @@ import (
 	"math/rand"
 	"os"
 	"regexp"
+	"strconv"
 	"sort"
 	"strings"
+	"sync"
 	"time"
 )
 
 type Storage struct {
-	Db         *bolt.DB
-	Path       string
+	Db   *bolt.DB
+	Path string
+	// the maps cache the DB, the HTTP handlers and the background jobs use them at the same time
+	// so they are only read and written with the storage lock
 	Videos     map[string]Video
 	Categories map[int]mpclibrary.Category
 	Actors     map[int]mpclibrary.Actor
+	Tags       map[int]mpclibrary.Tag
+	Series     map[int]mpclibrary.Series
+
+	mutex sync.RWMutex
 }
 
 type Video struct {
@@ -42,6 +50,10 @@ type Video struct {
 	SubtitlesURL string    `json:"subtitlesURL"`
 	Categories   []int     `json:"categories"`
 	Actors       []int     `json:"actors"`
+	Tags         []int     `json:"tags"`
+	Series       int       `json:"series"`
+	Season       int       `json:"season"`
+	Episode      int       `json:"episode"`
 	Extension    string    `json:"extension"`
 	Width        int       `json:"width"`
 	Height       int       `json:"height"`
@@ -52,6 +64,9 @@ type Video struct {
 	Path         string    `json:"path"`
 	Md5Sum       string    `json:"md5sum"`
 	Encrypted    bool      `json:"encrypted"`
+	Added        time.Time `json:"added"`
+	// MetadataSources has the metadata provider of every field that was read on import
+	MetadataSources map[string]string `json:"metadataSources,omitempty"`
 }
 
 type VideoResults struct {
@@ -62,11 +77,20 @@ type VideoResults struct {
 }
 
 type VideoFilter struct {
-	Category string `json:"category"`
-	Title    string `json:"title"`
-	Actor    string `json:"actor"`
-	Quality  string `json:"quality"`
-	Duration [2]int `json:"duration"`
+	Category   string `json:"category"`
+	Title      string `json:"title"`
+	Actor      string `json:"actor"`
+	Tag        string `json:"tag"`
+	Quality    string `json:"quality"`
+	Duration   [2]int `json:"duration"`
+	Playlist   string `json:"playlist"`
+	Collection int    `json:"collection"`
+}
+
+// Empty checks if the filter has no condition, an empty filter matches all the videos
+//
+func (filter VideoFilter) Empty() bool {
+	return filter == VideoFilter{}
 }
 
 // Initialize DB
@@ -121,6 +145,66 @@ func (storage *Storage) InitDb() error {
 		return err
 	}
 
+	err = storage.createBucket("tags")
+	if err != nil {
+		return err
+	}
+
+	err = storage.createBucket("history")
+	if err != nil {
+		return err
+	}
+
+	err = storage.createBucket("playlists")
+	if err != nil {
+		return err
+	}
+
+	err = storage.createBucket("collections")
+	if err != nil {
+		return err
+	}
+
+	err = storage.createBucket("smartplaylists")
+	if err != nil {
+		return err
+	}
+
+	err = storage.createBucket("watches")
+	if err != nil {
+		return err
+	}
+
+	err = storage.createBucket("series")
+	if err != nil {
+		return err
+	}
+
+	err = storage.createBucket("remotedevices")
+	if err != nil {
+		return err
+	}
+
+	err = storage.createBucket("remoteinvites")
+	if err != nil {
+		return err
+	}
+
+	err = storage.createBucket("queues")
+	if err != nil {
+		return err
+	}
+
+	err = storage.createBucket("metadatareviews")
+	if err != nil {
+		return err
+	}
+
+	err = storage.createBucket("fingerprints")
+	if err != nil {
+		return err
+	}
+
 	err = storage.getAllActors()
 	if err != nil {
 		return err
@@ -131,6 +215,16 @@ func (storage *Storage) InitDb() error {
 		return err
 	}
 
+	err = storage.getAllTags()
+	if err != nil {
+		return err
+	}
+
+	err = storage.getAllSeries()
+	if err != nil {
+		return err
+	}
+
 	err = storage.GetAllVideos()
 	if err != nil {
 		return err
@@ -145,7 +239,6 @@ func (storage *Storage) InitDb() error {
 //
 func (storage *Storage) GetVideos(offset int, view int, sortBy string, filter VideoFilter, seed int64) VideoResults {
 	var videos mpclibrary.Videos
-	var dbVideo Video
 	var err error
 
 	totalActiveFilters := 0
@@ -174,6 +267,18 @@ func (storage *Storage) GetVideos(offset int, view int, sortBy string, filter Vi
 		}
 	}
 
+	filterTag := false
+
+	var tagReg *regexp.Regexp
+
+	if filter.Tag != "" {
+		tagReg, err = regexp.Compile(filter.Tag)
+		if err == nil {
+			totalActiveFilters++
+			filterTag = true
+		}
+	}
+
 	filterTitle := false
 
 	var titleReg *regexp.Regexp
@@ -195,6 +300,8 @@ func (storage *Storage) GetVideos(offset int, view int, sortBy string, filter Vi
 		var videoIndex int64 = 0
 
 		for k, v := c.First(); k != nil; k, v = c.Next() {
+			var dbVideo Video
+
 			json.Unmarshal(v, &dbVideo)
 			if dbVideo.File != "" {
 				libVideo := storage.videoToLibraryVideo(dbVideo)
@@ -222,8 +329,10 @@ func (storage *Storage) GetVideos(offset int, view int, sortBy string, filter Vi
 
 				if filterCategory {
 					for _, category := range libVideo.Categories {
-						if categoryReg.Match([]byte(strings.ToLower(category.Name))) {
-							categoryPassed = true
+						for _, name := range storage.categoryPath(category.ID) {
+							if categoryReg.Match([]byte(strings.ToLower(name))) {
+								categoryPassed = true
+							}
 						}
 					}
 				}
@@ -232,6 +341,20 @@ func (storage *Storage) GetVideos(offset int, view int, sortBy string, filter Vi
 					activeFilters++
 				}
 
+				tagPassed := false
+
+				if filterTag {
+					for _, tag := range libVideo.Tags {
+						if tagReg.Match([]byte(strings.ToLower(tag.Name))) {
+							tagPassed = true
+						}
+					}
+				}
+
+				if tagPassed {
+					activeFilters++
+				}
+
 				if filterTitle {
 					if titleReg.Match([]byte(strings.ToLower(libVideo.Title))) {
 						activeFilters++
@@ -250,15 +373,19 @@ func (storage *Storage) GetVideos(offset int, view int, sortBy string, filter Vi
 	})
 
 	if sortBy == "title" {
-		sort.Sort(mpclibrary.ByTitle{videos})
+		sort.Sort(mpclibrary.ByTitle{Videos: videos})
 	} else if sortBy == "titleDesc" {
-		sort.Sort(mpclibrary.ByTitleDesc{videos})
+		sort.Sort(mpclibrary.ByTitleDesc{Videos: videos})
 	} else if sortBy == "duration" {
-		sort.Sort(mpclibrary.ByDuration{videos})
+		sort.Sort(mpclibrary.ByDuration{Videos: videos})
 	} else if sortBy == "durationDesc" {
-		sort.Sort(mpclibrary.ByTitleDesc{videos})
+		sort.Sort(mpclibrary.ByDuractionDesc{Videos: videos})
+	} else if sortBy == "natural" {
+		sort.Sort(mpclibrary.ByNaturalTitle{Videos: videos})
+	} else if sortBy == "episode" {
+		sort.Sort(mpclibrary.ByEpisode{Videos: videos})
 	} else {
-		sort.Sort(mpclibrary.ByRandom{videos})
+		sort.Sort(mpclibrary.ByRandom{Videos: videos})
 	}
 
 	var videoResults mpclibrary.Videos
@@ -281,8 +408,7 @@ func (storage *Storage) GetVideos(offset int, view int, sortBy string, filter Vi
 // GetAllVideos gets all the videos from the DB
 //
 func (storage *Storage) GetAllVideos() error {
-	storage.Videos = make(map[string]Video)
-	var dbVideo Video
+	allVideos := make(map[string]Video)
 	err := storage.Db.View(func(tx *bolt.Tx) error {
 		// Assume bucket exists and has keys
 		b := tx.Bucket([]byte("videos"))
@@ -290,9 +416,11 @@ func (storage *Storage) GetAllVideos() error {
 		c := b.Cursor()
 
 		for k, v := c.First(); k != nil; k, v = c.Next() {
+			var dbVideo Video
+
 			json.Unmarshal(v, &dbVideo)
 			if dbVideo.File != "" {
-				storage.Videos[string(k)] = dbVideo
+				allVideos[string(k)] = dbVideo
 			} else {
 				return errors.New("error getting video " + dbVideo.ID)
 			}
@@ -301,14 +429,95 @@ func (storage *Storage) GetAllVideos() error {
 		return nil
 	})
 
+	storage.mutex.Lock()
+	storage.Videos = allVideos
+	storage.mutex.Unlock()
+
 	return err
 }
 
+// CachedVideo gets a video of the cache with the IDs of its actors, categories, tags and series
+//
+func (storage *Storage) CachedVideo(videoID string) (dbVideo Video, ok bool) {
+	storage.mutex.RLock()
+	defer storage.mutex.RUnlock()
+
+	dbVideo, ok = storage.Videos[videoID]
+
+	return
+}
+
+// CachedVideos gets a copy of the videos of the cache, the copy is used without the lock
+//
+func (storage *Storage) CachedVideos() map[string]Video {
+	storage.mutex.RLock()
+	defer storage.mutex.RUnlock()
+
+	videos := make(map[string]Video, len(storage.Videos))
+
+	for videoID, dbVideo := range storage.Videos {
+		videos[videoID] = dbVideo
+	}
+
+	return videos
+}
+
+// CachedActors gets a copy of the actors of the cache, the copy is used without the lock
+//
+func (storage *Storage) CachedActors() map[int]mpclibrary.Actor {
+	storage.mutex.RLock()
+	defer storage.mutex.RUnlock()
+
+	actors := make(map[int]mpclibrary.Actor, len(storage.Actors))
+
+	for id, actor := range storage.Actors {
+		actors[id] = actor
+	}
+
+	return actors
+}
+
+// cachedCategories gets a copy of the categories of the cache, the copy is used without the lock
+//
+func (storage *Storage) cachedCategories() map[int]mpclibrary.Category {
+	storage.mutex.RLock()
+	defer storage.mutex.RUnlock()
+
+	categories := make(map[int]mpclibrary.Category, len(storage.Categories))
+
+	for id, category := range storage.Categories {
+		categories[id] = category
+	}
+
+	return categories
+}
+
+// VideoIDs gets the IDs of all the videos
+//
+func (storage *Storage) VideoIDs() (videoIDs []string) {
+	storage.mutex.RLock()
+	defer storage.mutex.RUnlock()
+
+	for videoID := range storage.Videos {
+		videoIDs = append(videoIDs, videoID)
+	}
+
+	return
+}
+
+// CountVideos gets the number of videos
+//
+func (storage *Storage) CountVideos() int {
+	storage.mutex.RLock()
+	defer storage.mutex.RUnlock()
+
+	return len(storage.Videos)
+}
+
 // getAllActors gets all the actors from the DB
 //
 func (storage *Storage) getAllActors() error {
 	allActors := make(map[int]mpclibrary.Actor)
-	var dbActor mpclibrary.Actor
 	err := storage.Db.View(func(tx *bolt.Tx) error {
 		// Assume bucket exists and has keys
 		b := tx.Bucket([]byte("actors"))
@@ -316,6 +525,8 @@ func (storage *Storage) getAllActors() error {
 		c := b.Cursor()
 
 		for k, v := c.First(); k != nil; k, v = c.Next() {
+			var dbActor mpclibrary.Actor
+
 			json.Unmarshal(v, &dbActor)
 
 			if dbActor.Name != "" {
@@ -328,7 +539,10 @@ func (storage *Storage) getAllActors() error {
 		return nil
 	})
 
+	storage.mutex.Lock()
 	storage.Actors = allActors
+	storage.mutex.Unlock()
+
 	return err
 }
 
@@ -336,7 +550,6 @@ func (storage *Storage) getAllActors() error {
 //
 func (storage *Storage) getAllCategories() error {
 	allCategories := make(map[int]mpclibrary.Category)
-	var dbCategory mpclibrary.Category
 	err := storage.Db.View(func(tx *bolt.Tx) error {
 		// Assume bucket exists and has keys
 		b := tx.Bucket([]byte("categories"))
@@ -344,6 +557,8 @@ func (storage *Storage) getAllCategories() error {
 		c := b.Cursor()
 
 		for k, v := c.First(); k != nil; k, v = c.Next() {
+			var dbCategory mpclibrary.Category
+
 			json.Unmarshal(v, &dbCategory)
 
 			if dbCategory.Name != "" {
@@ -356,7 +571,9 @@ func (storage *Storage) getAllCategories() error {
 		return nil
 	})
 
+	storage.mutex.Lock()
 	storage.Categories = allCategories
+	storage.mutex.Unlock()
 
 	return err
 }
@@ -426,7 +643,13 @@ func (storage *Storage) InsertVideos(videos []mpclibrary.Video) error {
 					}
 				}
 
+				err = storage.detectEpisode(tx.Bucket([]byte("series")), &video)
+				if err != nil {
+					return err
+				}
+
 				dbVideo := storage.storageVideoToVideo(video)
+				dbVideo.Added = time.Now()
 
 				if !dbVideo.Encrypted {
 					videoInfo, err := mpcutils.FFProbe(dbVideo.Path + dbVideo.File)
@@ -481,7 +704,9 @@ func (storage *Storage) insertActor(bucket *bolt.Bucket, actor mpclibrary.Actor)
 			return err
 		}
 
+		storage.mutex.Lock()
 		storage.Actors[int(id)] = actor
+		storage.mutex.Unlock()
 	}
 	return nil
 }
@@ -504,7 +729,9 @@ func (storage *Storage) insertCategory(bucket *bolt.Bucket, category mpclibrary.
 			return err
 		}
 
+		storage.mutex.Lock()
 		storage.Categories[int(id)] = category
+		storage.mutex.Unlock()
 	}
 	return nil
 }
@@ -521,10 +748,8 @@ func (storage *Storage) GetVideoByFileName(fileName string) (video mpclibrary.Vi
 // The Video ID can be the MD5 sum of its name for normal videos or the MD5 sum of the video file for encrypted files
 //
 func (storage *Storage) GetVideoByID(videoMd5 string) (video mpclibrary.Video, err error) {
-	for key, thisVideo := range storage.Videos {
-		if key == videoMd5 {
-			return storage.videoToLibraryVideo(thisVideo), nil
-		}
+	if thisVideo, ok := storage.CachedVideo(videoMd5); ok {
+		return storage.videoToLibraryVideo(thisVideo), nil
 	}
 
 	return
@@ -533,7 +758,7 @@ func (storage *Storage) GetVideoByID(videoMd5 string) (video mpclibrary.Video, e
 // GetVideoByOriginalName searchs a video in the DB using the file name when it was imported.
 //
 func (storage *Storage) GetVideoByOriginalName(name string) (video mpclibrary.Video, err error) {
-	for _, thisVideo := range storage.Videos {
+	for _, thisVideo := range storage.CachedVideos() {
 		if thisVideo.OrigFile == name {
 			return storage.videoToLibraryVideo(thisVideo), nil
 		}
@@ -565,6 +790,11 @@ func (storage *Storage) videoToLibraryVideo(dbVideo Video) (video mpclibrary.Vid
 	video.OrigFile = dbVideo.OrigFile
 	video.Md5Sum = dbVideo.Md5Sum
 	video.Encrypted = dbVideo.Encrypted
+	video.Added = dbVideo.Added
+	video.MetadataSources = dbVideo.MetadataSources
+	video.Series = storage.seriesName(dbVideo.Series)
+	video.Season = dbVideo.Season
+	video.Episode = dbVideo.Episode
 
 	var categories []mpclibrary.Category
 
@@ -588,6 +818,17 @@ func (storage *Storage) videoToLibraryVideo(dbVideo Video) (video mpclibrary.Vid
 
 	video.Actors = actors
 
+	var tags []mpclibrary.Tag
+
+	for _, tag := range dbVideo.Tags {
+		videoTag, err := storage.GetTagByID(tag)
+		if err == nil && videoTag.Name != "" {
+			tags = append(tags, videoTag)
+		}
+	}
+
+	video.Tags = tags
+
 	return
 }
 
@@ -613,6 +854,12 @@ func (storage *Storage) storageVideoToVideo(video mpclibrary.Video) (dbVideo Vid
 	dbVideo.OrigFile = video.OrigFile
 	dbVideo.Md5Sum = video.Md5Sum
 	dbVideo.Encrypted = video.Encrypted
+	dbVideo.Added = video.Added
+	dbVideo.MetadataSources = video.MetadataSources
+	videoSeries, _ := storage.GetSeriesByName(video.Series)
+	dbVideo.Series = videoSeries.ID
+	dbVideo.Season = video.Season
+	dbVideo.Episode = video.Episode
 
 	var videoCategories []int
 
@@ -635,34 +882,57 @@ func (storage *Storage) storageVideoToVideo(video mpclibrary.Video) (dbVideo Vid
 	}
 
 	dbVideo.Actors = videoActors
+
+	var videoTags []int
+
+	for _, tag := range video.Tags {
+		videoTag, err := storage.GetTagByName(tag.Name)
+		if err == nil && videoTag.Name != "" {
+			videoTags = append(videoTags, videoTag.ID)
+		}
+	}
+
+	dbVideo.Tags = videoTags
 	return
 }
 
-// GetActorByName gets an actor from the list by name
+// GetActorByName gets an actor from the list by name or by one of its aliases
 //
 func (storage *Storage) GetActorByName(actorName string) (actor mpclibrary.Actor, err error) {
+	storage.mutex.RLock()
+	defer storage.mutex.RUnlock()
+
 	for _, thisActor := range storage.Actors {
 		if actorName == thisActor.Name {
 			return thisActor, nil
 		}
 	}
+
+	for _, thisActor := range storage.Actors {
+		for _, alias := range thisActor.Aliases {
+			if strings.EqualFold(actorName, alias) {
+				return thisActor, nil
+			}
+		}
+	}
 	return
 }
 
 // GetActorByID gets an actor from the list by ID
 //
 func (storage *Storage) GetActorByID(actorID int) (actor mpclibrary.Actor, err error) {
-	for id, thisActor := range storage.Actors {
-		if actorID == id {
-			return thisActor, nil
-		}
-	}
-	return
+	storage.mutex.RLock()
+	defer storage.mutex.RUnlock()
+
+	return storage.Actors[actorID], nil
 }
 
 // GetCategoryByName gets category from the list by Name
 //
 func (storage *Storage) GetCategoryByName(categoryName string) (category mpclibrary.Category, err error) {
+	storage.mutex.RLock()
+	defer storage.mutex.RUnlock()
+
 	for _, thisCategory := range storage.Categories {
 		if categoryName == thisCategory.Name {
 			return thisCategory, nil
@@ -674,12 +944,10 @@ func (storage *Storage) GetCategoryByName(categoryName string) (category mpclibr
 // GetCategoryByName gets category from the list by ID
 //
 func (storage *Storage) GetCategoryByID(categoryID int) (category mpclibrary.Category, err error) {
-	for id, thisCategory := range storage.Categories {
-		if categoryID == id {
-			return thisCategory, nil
-		}
-	}
-	return
+	storage.mutex.RLock()
+	defer storage.mutex.RUnlock()
+
+	return storage.Categories[categoryID], nil
 }
 
 // GetSettings gets settings from the DB
@@ -701,6 +969,8 @@ func (storage *Storage) GetSettings() (settings mpclibrary.Settings, err error)
 				settings.InstanceID = string(v)
 			} else if key == "HMACkey" {
 				settings.HMACkey = v
+			} else if key == "mediaKeyGeneration" {
+				settings.MediaKeyGeneration, _ = strconv.Atoi(string(v))
 			}
 		}
 
@@ -725,6 +995,12 @@ func (storage *Storage) SaveSettings(settings mpclibrary.Settings) (err error) {
 
 		err = bucket.Put([]byte("HMACkey"), []byte(settings.HMACkey))
 
+		if err != nil {
+			return err
+		}
+
+		err = bucket.Put([]byte("mediaKeyGeneration"), []byte(strconv.Itoa(settings.MediaKeyGeneration)))
+
 		if err != nil {
 			return err
 		}
@@ -743,9 +1019,8 @@ func (storage *Storage) GetUsers() (users []mpcusers.User, err error) {
 
 		c := b.Cursor()
 
-		var user mpcusers.User
-
 		for k, v := c.First(); k != nil; k, v = c.Next() {
+			var user mpcusers.User
 
 			json.Unmarshal(v, &user)
 
@@ -757,6 +1032,21 @@ func (storage *Storage) GetUsers() (users []mpcusers.User, err error) {
 	return
 }
 
+// GetUserByUUID gets a user from the DB
+//
+func (storage *Storage) GetUserByUUID(userID string) (user mpcusers.User, err error) {
+	err = storage.Db.View(func(tx *bolt.Tx) error {
+		jsonUser := tx.Bucket([]byte("users")).Get([]byte(userID))
+
+		if jsonUser == nil {
+			return nil
+		}
+
+		return json.Unmarshal(jsonUser, &user)
+	})
+	return
+}
+
 // InsertUser inserts new user in the DB.
 //
 func (storage *Storage) InsertUser(user mpcusers.User) (id string, err error) {
@@ -785,6 +1075,14 @@ func (storage *Storage) InsertUser(user mpcusers.User) (id string, err error) {
 		return id, errors.New("user_short_password")
 	}
 
+	if user.Role == "" {
+		user.Role = mpcusers.RoleUser
+	}
+
+	if user.Role != mpcusers.RoleAdmin && user.Role != mpcusers.RoleUser && user.Role != mpcusers.RoleGuest {
+		return id, errors.New("user_role_invalid")
+	}
+
 	users, _ := storage.GetUsers()
 
 	for _, dbUser := range users {
@@ -814,7 +1112,7 @@ func (storage *Storage) InsertUser(user mpcusers.User) (id string, err error) {
 		return user.UUID, err
 	}
 
-	return
+	return user.UUID, nil
 }
 
 //HashPassword generates sha256 has of password
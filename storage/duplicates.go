@@ -0,0 +1,179 @@
+package mpcstorage
+
+import (
+	"github.com/jempe/mpc/duplicates"
+	"github.com/jempe/mpc/library"
+	"encoding/json"
+	"errors"
+	"github.com/boltdb/bolt"
+	"time"
+)
+
+// SaveFingerprint saves the hashes of the frames of a video
+//
+func (storage *Storage) SaveFingerprint(fingerprint mpcduplicates.Fingerprint) error {
+	if _, ok := storage.CachedVideo(fingerprint.VideoID); !ok {
+		return errors.New("video_not_exists")
+	}
+
+	if fingerprint.Created.IsZero() {
+		fingerprint.Created = time.Now()
+	}
+
+	jsonFingerprint, err := json.Marshal(fingerprint)
+	if err != nil {
+		return err
+	}
+
+	return storage.Db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket([]byte("fingerprints")).Put([]byte(fingerprint.VideoID), jsonFingerprint)
+	})
+}
+
+// GetFingerprints gets the fingerprints of the videos, the fingerprints of the deleted videos and the videos
+// whose file changed are skipped
+//
+func (storage *Storage) GetFingerprints() (fingerprints []mpcduplicates.Fingerprint, err error) {
+	err = storage.Db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket([]byte("fingerprints")).Cursor()
+
+		for k, v := c.First(); k != nil; k, v = c.Next() {
+			var fingerprint mpcduplicates.Fingerprint
+
+			err := json.Unmarshal(v, &fingerprint)
+			if err != nil {
+				return err
+			}
+
+			if video, ok := storage.CachedVideo(fingerprint.VideoID); ok && video.File == fingerprint.File {
+				fingerprints = append(fingerprints, fingerprint)
+			}
+		}
+
+		return nil
+	})
+
+	return
+}
+
+// MergeVideos adds the actors, categories and tags of the duplicates to the video that is kept, and the title,
+// description, date and URLs that the video doesn't have. The duplicates are deleted from the DB like DeleteVideos
+// in the same transaction, the video files are not deleted. When dryRun is set the changes are returned but the transaction
+// is rolled back
+//
+func (storage *Storage) MergeVideos(keepID string, duplicateIDs []string, user string, dryRun bool) (changes []BulkChange, err error) {
+	keep, _ := storage.GetVideoByID(keepID)
+	if keep.ID == "" {
+		return changes, errors.New("video_not_exists")
+	}
+
+	var duplicates []mpclibrary.Video
+
+	for _, duplicateID := range duplicateIDs {
+		if duplicateID == keepID {
+			return changes, errors.New("merge_video_duplicated")
+		}
+
+		duplicate, _ := storage.GetVideoByID(duplicateID)
+		if duplicate.ID == "" {
+			return changes, errors.New("video_not_exists")
+		}
+
+		duplicates = append(duplicates, duplicate)
+	}
+
+	if len(duplicates) == 0 {
+		return changes, errors.New("merge_videos_empty")
+	}
+
+	tx, err := storage.Db.Begin(true)
+	if err != nil {
+		return
+	}
+	defer tx.Rollback()
+
+	edit, err := storage.updateVideo(tx, keepID, mergeUpdate(keep, duplicates), user)
+	if err != nil {
+		storage.reloadAll()
+		return
+	}
+
+	if len(edit.Changes) > 0 {
+		changes = append(changes, BulkChange{VideoID: keepID, Title: keep.Title, Changes: edit.Changes})
+	}
+
+	deleted, err := deleteVideos(tx, duplicateIDs)
+	if err != nil {
+		storage.reloadAll()
+		return
+	}
+
+	changes = append(changes, deleted...)
+
+	if !dryRun {
+		err = tx.Commit()
+	}
+
+	reloadErr := storage.reloadAll()
+
+	if err == nil {
+		err = reloadErr
+	}
+
+	return
+}
+
+// mergeUpdate builds the update of the video with the metadata of its duplicates
+//
+func mergeUpdate(keep mpclibrary.Video, duplicates []mpclibrary.Video) (update VideoUpdate) {
+	var actors, categories, tags []string
+
+	for _, video := range append([]mpclibrary.Video{keep}, duplicates...) {
+		video := video
+
+		for _, actor := range video.Actors {
+			actors = append(actors, actor.Name)
+		}
+
+		for _, category := range video.Categories {
+			categories = append(categories, category.Name)
+		}
+
+		for _, tag := range video.Tags {
+			tags = append(tags, tag.Name)
+		}
+
+		if update.Description == nil && keep.Description == "" && video.Description != "" {
+			update.Description = &video.Description
+		}
+
+		if update.PubDate == nil && keep.PubDate.IsZero() && !video.PubDate.IsZero() {
+			update.PubDate = &video.PubDate
+		}
+
+		if update.ImgURL == nil && keep.ImgURL == "" && video.ImgURL != "" {
+			update.ImgURL = &video.ImgURL
+		}
+
+		if update.VideoURL == nil && keep.VideoURL == "" && video.VideoURL != "" {
+			update.VideoURL = &video.VideoURL
+		}
+
+		// the title of the scanned videos is the file name
+		if update.Title == nil && fileTitle(keep) && !fileTitle(video) {
+			update.Title = &video.Title
+		}
+	}
+
+	update.Actors = &actors
+	update.Categories = &categories
+	update.Tags = &tags
+
+	return
+}
+
+// fileTitle checks if the title of the video is the name of its file
+//
+func fileTitle(video mpclibrary.Video) bool {
+	return video.Title == video.OrigFile || video.Title == video.File
+}
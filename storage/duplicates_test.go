@@ -0,0 +1,92 @@
+package mpcstorage
+
+import (
+	"github.com/jempe/mpc/duplicates"
+	"github.com/jempe/mpc/library"
+	"testing"
+)
+
+func TestMergeVideos(t *testing.T) {
+	testStorage, _ := newTestStorage(t)
+
+	videos := []mpclibrary.Video{
+		{File: "best.mp4", OrigFile: "best.mp4", Title: "best.mp4", Encrypted: true, Actors: []mpclibrary.Actor{{Name: "Jane Doe"}}},
+		{File: "copy.mp4", OrigFile: "copy.mp4", Title: "The Movie", Description: "A movie", Encrypted: true, Actors: []mpclibrary.Actor{{Name: "John Doe"}}, Categories: []mpclibrary.Category{{Name: "Drama"}}},
+	}
+
+	err := testStorage.InsertVideos(videos)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = testStorage.GetAllVideos()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	best, _ := testStorage.GetVideoByFileName("best.mp4")
+	duplicate, _ := testStorage.GetVideoByFileName("copy.mp4")
+
+	for _, video := range []mpclibrary.Video{best, duplicate} {
+		err = testStorage.SaveFingerprint(mpcduplicates.Fingerprint{VideoID: video.ID, File: video.File, Duration: 60, Hashes: []uint64{1, 2}})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	fingerprints, err := testStorage.GetFingerprints()
+	if err != nil || len(fingerprints) != 2 || fingerprints[0].Created.IsZero() {
+		t.Error("the fingerprints should be saved", fingerprints, err)
+	}
+
+	changes, err := testStorage.MergeVideos(best.ID, []string{duplicate.ID}, "admin", true)
+	if err != nil || len(changes) != 2 {
+		t.Fatal("the dry run should return the changes", changes, err)
+	}
+
+	if _, err := testStorage.GetVideoByID(duplicate.ID); err != nil {
+		t.Error("the dry run should not delete the duplicate", err)
+	}
+
+	if _, err := testStorage.MergeVideos(best.ID, []string{best.ID}, "admin", false); err == nil {
+		t.Error("the video should not be merged with itself")
+	}
+
+	playlist, err := testStorage.SavePlaylist(Playlist{Name: "Movies", Owner: "admin", Videos: []string{duplicate.ID, best.ID}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = testStorage.MergeVideos(best.ID, []string{duplicate.ID}, "admin", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if playlist, _ = testStorage.GetPlaylist(playlist.ID); len(playlist.Videos) != 1 || playlist.Videos[0] != best.ID {
+		t.Error("the duplicate should be removed from the playlists", playlist.Videos)
+	}
+
+	merged, _ := testStorage.GetVideoByID(best.ID)
+
+	if merged.Title != "The Movie" || merged.Description != "A movie" || len(merged.Actors) != 2 || len(merged.Categories) != 1 {
+		t.Error("the metadata of the duplicate should be merged", merged)
+	}
+
+	if merged.File != "best.mp4" {
+		t.Error("the file of the best copy should be kept", merged.File)
+	}
+
+	if video, _ := testStorage.GetVideoByID(duplicate.ID); video.ID != "" {
+		t.Error("the duplicate should be deleted")
+	}
+
+	fingerprints, err = testStorage.GetFingerprints()
+	if err != nil || len(fingerprints) != 1 || fingerprints[0].VideoID != best.ID {
+		t.Error("the fingerprint of the duplicate should be deleted", fingerprints, err)
+	}
+
+	history, _ := testStorage.GetVideoHistory(best.ID)
+	if len(history) != 1 || history[0].User != "admin" {
+		t.Error("the merge should be saved in the history", history)
+	}
+}
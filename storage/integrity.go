@@ -0,0 +1,172 @@
+package mpcstorage
+
+import (
+	"github.com/jempe/mpc/utils"
+	"encoding/json"
+	"errors"
+	"github.com/boltdb/bolt"
+	"time"
+)
+
+// References are the IDs of the actors, categories, tags and series of a video
+type References struct {
+	Actors     []int `json:"actors,omitempty"`
+	Categories []int `json:"categories,omitempty"`
+	Tags       []int `json:"tags,omitempty"`
+	Series     int   `json:"series,omitempty"`
+}
+
+// Empty checks if there are no references
+//
+func (references References) Empty() bool {
+	return len(references.Actors) == 0 && len(references.Categories) == 0 && len(references.Tags) == 0 && references.Series == 0
+}
+
+// MissingReferences gets the IDs of the actors, categories, tags and series of the video that don't exist
+//
+func (storage *Storage) MissingReferences(videoID string) (missing References) {
+	storage.mutex.RLock()
+	defer storage.mutex.RUnlock()
+
+	dbVideo, ok := storage.Videos[videoID]
+	if !ok {
+		return
+	}
+
+	for _, actor := range dbVideo.Actors {
+		if _, ok := storage.Actors[actor]; !ok {
+			missing.Actors = append(missing.Actors, actor)
+		}
+	}
+
+	for _, category := range dbVideo.Categories {
+		if _, ok := storage.Categories[category]; !ok {
+			missing.Categories = append(missing.Categories, category)
+		}
+	}
+
+	for _, tag := range dbVideo.Tags {
+		if _, ok := storage.Tags[tag]; !ok {
+			missing.Tags = append(missing.Tags, tag)
+		}
+	}
+
+	if _, ok := storage.Series[dbVideo.Series]; dbVideo.Series != 0 && !ok {
+		missing.Series = dbVideo.Series
+	}
+
+	return
+}
+
+// RemoveMissingReferences removes the IDs of the actors, categories, tags and series that don't exist from the video,
+// the changes are saved in the edit history of the video
+//
+func (storage *Storage) RemoveMissingReferences(videoID string, user string) (edit VideoEdit, err error) {
+	missing := storage.MissingReferences(videoID)
+
+	if missing.Empty() {
+		return
+	}
+
+	err = storage.changeVideo(videoID, func(dbVideo *Video) {
+		edit = VideoEdit{User: user, Time: time.Now()}
+
+		if len(missing.Actors) > 0 {
+			actors := withoutInts(dbVideo.Actors, missing.Actors)
+			edit.Changes = append(edit.Changes, FieldChange{Field: "actors", Old: dbVideo.Actors, New: actors})
+			dbVideo.Actors = actors
+		}
+
+		if len(missing.Categories) > 0 {
+			categories := withoutInts(dbVideo.Categories, missing.Categories)
+			edit.Changes = append(edit.Changes, FieldChange{Field: "categories", Old: dbVideo.Categories, New: categories})
+			dbVideo.Categories = categories
+		}
+
+		if len(missing.Tags) > 0 {
+			tags := withoutInts(dbVideo.Tags, missing.Tags)
+			edit.Changes = append(edit.Changes, FieldChange{Field: "tags", Old: dbVideo.Tags, New: tags})
+			dbVideo.Tags = tags
+		}
+
+		if missing.Series != 0 {
+			edit.Changes = append(edit.Changes, FieldChange{Field: "series", Old: dbVideo.Series, New: 0})
+			dbVideo.Series = 0
+			dbVideo.Season = 0
+			dbVideo.Episode = 0
+		}
+	}, func(tx *bolt.Tx) error {
+		return appendVideoEdit(tx.Bucket([]byte("history")), videoID, edit)
+	})
+
+	return
+}
+
+// SetVideoInfo changes the size, duration and screenshots step of a video after it's probed again
+//
+func (storage *Storage) SetVideoInfo(videoID string, info mpcutils.VideoInfo) error {
+	return storage.changeVideo(videoID, func(dbVideo *Video) {
+		dbVideo.Width = info.Width
+		dbVideo.Height = info.Height
+		dbVideo.Duration = info.Duration
+		dbVideo.Step = info.Step
+	}, nil)
+}
+
+// changeVideo applies the change to the video in the DB and in memory, the after function runs in the same transaction
+//
+func (storage *Storage) changeVideo(videoID string, change func(dbVideo *Video), after func(tx *bolt.Tx) error) error {
+	var dbVideo Video
+
+	err := storage.Db.Update(func(tx *bolt.Tx) error {
+		videosBucket := tx.Bucket([]byte("videos"))
+
+		jsonVideo := videosBucket.Get([]byte(videoID))
+		if jsonVideo == nil {
+			return errors.New("video_not_exists")
+		}
+
+		err := json.Unmarshal(jsonVideo, &dbVideo)
+		if err != nil {
+			return err
+		}
+
+		change(&dbVideo)
+
+		jsonVideo, err = json.Marshal(dbVideo)
+		if err != nil {
+			return err
+		}
+
+		err = videosBucket.Put([]byte(videoID), jsonVideo)
+		if err != nil {
+			return err
+		}
+
+		if after != nil {
+			return after(tx)
+		}
+
+		return nil
+	})
+
+	if err == nil {
+		storage.mutex.Lock()
+		storage.Videos[videoID] = dbVideo
+		storage.mutex.Unlock()
+	}
+
+	return err
+}
+
+// withoutInts gets the values of the list that are not in the removed list
+//
+func withoutInts(values []int, removed []int) (kept []int) {
+	for _, value := range values {
+		if !containsInt(removed, value) {
+			kept = append(kept, value)
+		}
+	}
+
+	return
+}
@@ -0,0 +1,341 @@
+package mpcstorage
+
+import (
+	"github.com/jempe/mpc/library"
+	"encoding/json"
+	"errors"
+	"github.com/boltdb/bolt"
+	"github.com/google/uuid"
+	"sort"
+	"strings"
+	"time"
+)
+
+type Playlist struct {
+	ID         string    `json:"id"`
+	Name       string    `json:"name"`
+	Owner      string    `json:"owner"`
+	Shared     bool      `json:"shared"`
+	SharedWith []string  `json:"sharedWith"`
+	Videos     []string  `json:"videos"`
+	Created    time.Time `json:"created"`
+	Updated    time.Time `json:"updated"`
+}
+
+type Collection struct {
+	ID          int       `json:"id"`
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	Videos      []string  `json:"videos"`
+	Created     time.Time `json:"created"`
+	Updated     time.Time `json:"updated"`
+}
+
+// CanView checks if the user can play the videos of the playlist
+//
+func (playlist Playlist) CanView(userID string) bool {
+	if playlist.Owner == userID || playlist.Shared {
+		return true
+	}
+
+	for _, user := range playlist.SharedWith {
+		if user == userID {
+			return true
+		}
+	}
+
+	return false
+}
+
+// CanEdit checks if the user can change the playlist, only the owner can change it
+//
+func (playlist Playlist) CanEdit(userID string) bool {
+	return userID != "" && playlist.Owner == userID
+}
+
+// GetPlaylists gets the playlists that the user can view sorted by name
+//
+func (storage *Storage) GetPlaylists(userID string) (playlists []Playlist, err error) {
+	playlists = []Playlist{}
+
+	err = storage.Db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket([]byte("playlists")).Cursor()
+
+		for k, v := c.First(); k != nil; k, v = c.Next() {
+			var playlist Playlist
+
+			err := json.Unmarshal(v, &playlist)
+			if err != nil {
+				return err
+			}
+
+			if playlist.CanView(userID) {
+				playlists = append(playlists, playlist)
+			}
+		}
+
+		return nil
+	})
+
+	sort.Slice(playlists, func(i, j int) bool {
+		return strings.ToLower(playlists[i].Name) < strings.ToLower(playlists[j].Name)
+	})
+
+	return
+}
+
+// GetPlaylist gets a playlist from the DB
+//
+func (storage *Storage) GetPlaylist(playlistID string) (playlist Playlist, err error) {
+	err = storage.Db.View(func(tx *bolt.Tx) error {
+		jsonPlaylist := tx.Bucket([]byte("playlists")).Get([]byte(playlistID))
+
+		if jsonPlaylist == nil {
+			return errors.New("playlist_not_exists")
+		}
+
+		return json.Unmarshal(jsonPlaylist, &playlist)
+	})
+
+	return
+}
+
+// SavePlaylist inserts or updates a playlist, new playlists get a new ID
+//
+func (storage *Storage) SavePlaylist(playlist Playlist) (Playlist, error) {
+	playlist.Name = strings.TrimSpace(playlist.Name)
+
+	if playlist.Name == "" {
+		return playlist, errors.New("playlist_name_empty")
+	}
+
+	if playlist.Owner == "" {
+		return playlist, errors.New("playlist_owner_empty")
+	}
+
+	var saved Playlist
+
+	if playlist.ID != "" {
+		saved, _ = storage.GetPlaylist(playlist.ID)
+	}
+
+	err := storage.checkAddedVideos(playlist.Videos, saved.Videos)
+	if err != nil {
+		return playlist, err
+	}
+
+	if playlist.ID == "" {
+		playlist.ID = uuid.New().String()
+		playlist.Created = time.Now()
+	}
+
+	playlist.Updated = time.Now()
+
+	if playlist.Videos == nil {
+		playlist.Videos = []string{}
+	}
+
+	err = storage.Db.Update(func(tx *bolt.Tx) error {
+		jsonPlaylist, err := json.Marshal(playlist)
+		if err != nil {
+			return err
+		}
+
+		return tx.Bucket([]byte("playlists")).Put([]byte(playlist.ID), jsonPlaylist)
+	})
+
+	return playlist, err
+}
+
+// DeletePlaylist deletes a playlist from the DB
+//
+func (storage *Storage) DeletePlaylist(playlistID string) error {
+	return storage.Db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket([]byte("playlists")).Delete([]byte(playlistID))
+	})
+}
+
+// GetCollections gets all the collections sorted by name
+//
+func (storage *Storage) GetCollections() (collections []Collection, err error) {
+	collections = []Collection{}
+
+	err = storage.Db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket([]byte("collections")).Cursor()
+
+		for k, v := c.First(); k != nil; k, v = c.Next() {
+			var collection Collection
+
+			err := json.Unmarshal(v, &collection)
+			if err != nil {
+				return err
+			}
+
+			collections = append(collections, collection)
+		}
+
+		return nil
+	})
+
+	sort.Slice(collections, func(i, j int) bool {
+		return strings.ToLower(collections[i].Name) < strings.ToLower(collections[j].Name)
+	})
+
+	return
+}
+
+// GetCollection gets a collection from the DB
+//
+func (storage *Storage) GetCollection(collectionID int) (collection Collection, err error) {
+	err = storage.Db.View(func(tx *bolt.Tx) error {
+		jsonCollection := tx.Bucket([]byte("collections")).Get(itob(collectionID))
+
+		if jsonCollection == nil {
+			return errors.New("collection_not_exists")
+		}
+
+		return json.Unmarshal(jsonCollection, &collection)
+	})
+
+	return
+}
+
+// SaveCollection inserts or updates a collection, new collections get a new ID
+//
+func (storage *Storage) SaveCollection(collection Collection) (Collection, error) {
+	collection.Name = strings.TrimSpace(collection.Name)
+
+	if collection.Name == "" {
+		return collection, errors.New("collection_name_empty")
+	}
+
+	var saved Collection
+
+	if collection.ID != 0 {
+		saved, _ = storage.GetCollection(collection.ID)
+	}
+
+	err := storage.checkAddedVideos(collection.Videos, saved.Videos)
+	if err != nil {
+		return collection, err
+	}
+
+	if collection.Videos == nil {
+		collection.Videos = []string{}
+	}
+
+	err = storage.Db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte("collections"))
+
+		if collection.ID == 0 {
+			id, err := bucket.NextSequence()
+			if err != nil {
+				return err
+			}
+
+			collection.ID = int(id)
+			collection.Created = time.Now()
+		}
+
+		collection.Updated = time.Now()
+
+		jsonCollection, err := json.Marshal(collection)
+		if err != nil {
+			return err
+		}
+
+		return bucket.Put(itob(collection.ID), jsonCollection)
+	})
+
+	return collection, err
+}
+
+// checkAddedVideos checks that the videos that are not in the saved list exist, the videos that were saved
+// before are kept even when they were deleted so the lists with deleted videos can still be changed
+//
+func (storage *Storage) checkAddedVideos(videos []string, saved []string) error {
+	inSaved := make(map[string]bool)
+
+	for _, videoID := range saved {
+		inSaved[videoID] = true
+	}
+
+	for _, videoID := range videos {
+		if _, ok := storage.CachedVideo(videoID); !ok && !inSaved[videoID] {
+			return errors.New("video_not_exists")
+		}
+	}
+
+	return nil
+}
+
+// DeleteCollection deletes a collection from the DB
+//
+func (storage *Storage) DeleteCollection(collectionID int) error {
+	return storage.Db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket([]byte("collections")).Delete(itob(collectionID))
+	})
+}
+
+// GetVideosByIDs gets the videos of the list in the same order, videos that don't exist are skipped
+//
+func (storage *Storage) GetVideosByIDs(offset int, view int, videoIDs []string) VideoResults {
+	var videos mpclibrary.Videos
+
+	for index, videoID := range videoIDs {
+		if dbVideo, ok := storage.CachedVideo(videoID); ok {
+			video := storage.videoToLibraryVideo(dbVideo)
+			video.Order = index
+
+			videos = append(videos, video)
+		}
+	}
+
+	var videoResults mpclibrary.Videos
+
+	lastResult := offset + view
+
+	if lastResult > len(videos) {
+		lastResult = len(videos)
+	}
+
+	if offset < len(videos) {
+		videoResults = videos[offset:lastResult]
+	}
+
+	return VideoResults{Videos: videoResults, Offset: offset, View: view, Total: len(videos)}
+}
+
+// ReorderVideos gets the videos in the new order, the new order must have the same videos
+//
+func ReorderVideos(videos []string, order []string) ([]string, error) {
+	if !SameVideos(videos, order) {
+		return videos, errors.New("playlist_order_invalid")
+	}
+
+	return order, nil
+}
+
+// SameVideos checks if both lists have the same videos in any order
+//
+func SameVideos(a []string, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	count := make(map[string]int)
+
+	for _, videoID := range a {
+		count[videoID]++
+	}
+
+	for _, videoID := range b {
+		count[videoID]--
+
+		if count[videoID] < 0 {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,194 @@
+package mpcstorage
+
+import (
+	"encoding/json"
+	"github.com/boltdb/bolt"
+	"testing"
+)
+
+func TestPlaylistOwnership(t *testing.T) {
+	testStorage, videoIDs := newTestStorage(t, "One", "Two", "Three")
+
+	if _, err := testStorage.SavePlaylist(Playlist{Name: "Nobody"}); err == nil || err.Error() != "playlist_owner_empty" {
+		t.Error("a playlist needs an owner", err)
+	}
+
+	if _, err := testStorage.SavePlaylist(Playlist{Name: "Missing", Owner: "alice", Videos: []string{"missing"}}); err == nil || err.Error() != "video_not_exists" {
+		t.Error("a playlist can't have missing videos", err)
+	}
+
+	private, err := testStorage.SavePlaylist(Playlist{Name: "Private", Owner: "alice", Videos: videoIDs[:1]})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = testStorage.SavePlaylist(Playlist{Name: "Family", Owner: "alice", SharedWith: []string{"bob"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = testStorage.SavePlaylist(Playlist{Name: "Everyone", Owner: "carol", Shared: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string][]string{
+		"alice": {"Everyone", "Family", "Private"},
+		"bob":   {"Everyone", "Family"},
+		"dave":  {"Everyone"},
+	}
+
+	for userID, names := range expected {
+		playlists, err := testStorage.GetPlaylists(userID)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(playlists) != len(names) {
+			t.Error("wrong playlists of", userID, playlists)
+			continue
+		}
+
+		for index, playlist := range playlists {
+			if playlist.Name != names[index] {
+				t.Error("wrong playlists of", userID, playlist.Name, names[index])
+			}
+		}
+	}
+
+	saved, err := testStorage.GetPlaylist(private.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !saved.CanView("alice") || saved.CanView("bob") {
+		t.Error("only the owner can view a private playlist")
+	}
+
+	if !saved.CanEdit("alice") || saved.CanEdit("bob") || saved.CanEdit("") {
+		t.Error("only the owner can edit the playlist")
+	}
+
+	// sharing the playlist lets the other users view it but not edit it
+	saved.Shared = true
+
+	saved, err = testStorage.SavePlaylist(saved)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if saved.ID != private.ID || !saved.Created.Equal(private.Created) || !saved.CanView("bob") || saved.CanEdit("bob") {
+		t.Error("the shared playlist should keep its ID and owner", saved)
+	}
+
+	err = testStorage.DeletePlaylist(private.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = testStorage.GetPlaylist(private.ID); err == nil || err.Error() != "playlist_not_exists" {
+		t.Error("the playlist should be deleted", err)
+	}
+}
+
+func TestReorderPlaylist(t *testing.T) {
+	testStorage, videoIDs := newTestStorage(t, "One", "Two", "Three")
+
+	playlist, err := testStorage.SavePlaylist(Playlist{Name: "Queue", Owner: "alice", Videos: videoIDs})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = ReorderVideos(playlist.Videos, []string{videoIDs[2], videoIDs[0]}); err == nil || err.Error() != "playlist_order_invalid" {
+		t.Error("the new order must have all the videos", err)
+	}
+
+	if _, err = ReorderVideos(playlist.Videos, []string{videoIDs[2], videoIDs[0], videoIDs[0]}); err == nil {
+		t.Error("the new order can't repeat a video instead of another")
+	}
+
+	playlist.Videos, err = ReorderVideos(playlist.Videos, []string{videoIDs[2], videoIDs[0], videoIDs[1]})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = testStorage.SavePlaylist(playlist)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	saved, _ := testStorage.GetPlaylist(playlist.ID)
+
+	results := testStorage.GetVideosByIDs(0, 10, saved.Videos)
+	if results.Total != 3 {
+		t.Fatal("wrong number of videos", results.Total)
+	}
+
+	for index, title := range []string{"Three", "One", "Two"} {
+		if results.Videos[index].Title != title || results.Videos[index].Order != index {
+			t.Error("the videos should be in the new order", index, results.Videos[index].Title)
+		}
+	}
+
+	// the deleted videos are skipped
+	_, err = testStorage.DeleteVideos(videoIDs[:1], false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if results = testStorage.GetVideosByIDs(0, 10, saved.Videos); results.Total != 2 {
+		t.Error("the deleted video should be skipped", results.Total)
+	}
+}
+
+func TestSaveListsWithDeletedVideos(t *testing.T) {
+	testStorage, videoIDs := newTestStorage(t, "One", "Two", "Three")
+
+	playlist, err := testStorage.SavePlaylist(Playlist{Name: "Old", Owner: "alice", Videos: videoIDs[:1]})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	collection, err := testStorage.SaveCollection(Collection{Name: "Old", Videos: videoIDs[:1]})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the lists were saved before their video was deleted
+	testStorage.Db.Update(func(tx *bolt.Tx) error {
+		playlist.Videos = append(playlist.Videos, "deleted")
+		jsonPlaylist, _ := json.Marshal(playlist)
+
+		collection.Videos = append(collection.Videos, "deleted")
+		jsonCollection, _ := json.Marshal(collection)
+
+		tx.Bucket([]byte("playlists")).Put([]byte(playlist.ID), jsonPlaylist)
+
+		return tx.Bucket([]byte("collections")).Put(itob(collection.ID), jsonCollection)
+	})
+
+	playlist.Name = "Renamed"
+	playlist.Videos = append(playlist.Videos, videoIDs[1])
+
+	if _, err = testStorage.SavePlaylist(playlist); err != nil {
+		t.Error("the playlist with a deleted video should be saved", err)
+	}
+
+	collection.Name = "Renamed"
+
+	if _, err = testStorage.SaveCollection(collection); err != nil {
+		t.Error("the collection with a deleted video should be saved", err)
+	}
+
+	playlist.Videos = append(playlist.Videos, "missing")
+
+	if _, err = testStorage.SavePlaylist(playlist); err == nil || err.Error() != "video_not_exists" {
+		t.Error("the added videos should exist", err)
+	}
+
+	collection.Videos = append(collection.Videos, "missing")
+
+	if _, err = testStorage.SaveCollection(collection); err == nil || err.Error() != "video_not_exists" {
+		t.Error("the added videos should exist", err)
+	}
+}
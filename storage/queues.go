@@ -0,0 +1,65 @@
+package mpcstorage
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/boltdb/bolt"
+	"time"
+)
+
+// repeat modes of the queues
+const (
+	RepeatOff = "off"
+	RepeatAll = "all"
+	RepeatOne = "one"
+)
+
+type PlayerQueue struct {
+	Player string   `json:"player"`
+	Videos []string `json:"videos"`
+	// Current is the index of the video that is playing, -1 when the queue didn't start or finished
+	Current int       `json:"current"`
+	Repeat  string    `json:"repeat"`
+	Updated time.Time `json:"updated"`
+}
+
+// GetPlayerQueue gets the queue of the player, players without queue get an empty queue
+//
+func (storage *Storage) GetPlayerQueue(playerID string) (queue PlayerQueue, err error) {
+	queue = PlayerQueue{Player: playerID, Videos: []string{}, Current: -1, Repeat: RepeatOff}
+
+	err = storage.Db.View(func(tx *bolt.Tx) error {
+		jsonQueue := tx.Bucket([]byte("queues")).Get([]byte(playerID))
+
+		if jsonQueue == nil {
+			return nil
+		}
+
+		return json.Unmarshal(jsonQueue, &queue)
+	})
+
+	return
+}
+
+// SavePlayerQueue saves the queue of the player
+//
+func (storage *Storage) SavePlayerQueue(queue PlayerQueue) error {
+	if queue.Player == "" {
+		return errors.New("player_empty")
+	}
+
+	if queue.Videos == nil {
+		queue.Videos = []string{}
+	}
+
+	queue.Updated = time.Now()
+
+	return storage.Db.Update(func(tx *bolt.Tx) error {
+		jsonQueue, err := json.Marshal(queue)
+		if err != nil {
+			return err
+		}
+
+		return tx.Bucket([]byte("queues")).Put([]byte(queue.Player), jsonQueue)
+	})
+}
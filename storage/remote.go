@@ -0,0 +1,230 @@
+package mpcstorage
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/boltdb/bolt"
+	"github.com/google/uuid"
+	"sort"
+	"strings"
+	"time"
+)
+
+type RemoteDevice struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	Owner     string    `json:"owner"`
+	TokenHash string    `json:"tokenHash,omitempty"`
+	Players   []string  `json:"players"`
+	Created   time.Time `json:"created"`
+}
+
+// GetRemoteDevices gets the paired devices of the user sorted by name, all the devices are returned when the owner is empty
+//
+func (storage *Storage) GetRemoteDevices(owner string) (devices []RemoteDevice, err error) {
+	devices = []RemoteDevice{}
+
+	err = storage.Db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket([]byte("remotedevices")).Cursor()
+
+		for k, v := c.First(); k != nil; k, v = c.Next() {
+			var device RemoteDevice
+
+			err := json.Unmarshal(v, &device)
+			if err != nil {
+				return err
+			}
+
+			if owner == "" || device.Owner == owner {
+				device.TokenHash = ""
+				devices = append(devices, device)
+			}
+		}
+
+		return nil
+	})
+
+	sort.Slice(devices, func(i, j int) bool {
+		return strings.ToLower(devices[i].Name) < strings.ToLower(devices[j].Name)
+	})
+
+	return
+}
+
+// CreateRemoteDevice saves a new device that connects to the remote with the token, only the hash of the token is saved
+//
+func (storage *Storage) CreateRemoteDevice(device RemoteDevice, token string) (RemoteDevice, error) {
+	device.Name = strings.TrimSpace(device.Name)
+
+	if device.Name == "" {
+		return device, errors.New("device_name_empty")
+	}
+
+	if device.Owner == "" {
+		return device, errors.New("device_owner_empty")
+	}
+
+	if token == "" {
+		return device, errors.New("device_token_empty")
+	}
+
+	if device.Players == nil {
+		device.Players = []string{}
+	}
+
+	device.ID = uuid.New().String()
+	device.TokenHash = HashPassword(token)
+	device.Created = time.Now()
+
+	err := storage.Db.Update(func(tx *bolt.Tx) error {
+		jsonDevice, err := json.Marshal(device)
+		if err != nil {
+			return err
+		}
+
+		return tx.Bucket([]byte("remotedevices")).Put([]byte(device.ID), jsonDevice)
+	})
+
+	device.TokenHash = ""
+
+	return device, err
+}
+
+// GetRemoteDeviceByToken gets the device that uses the token
+//
+func (storage *Storage) GetRemoteDeviceByToken(token string) (device RemoteDevice, err error) {
+	tokenHash := HashPassword(token)
+
+	err = storage.Db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket([]byte("remotedevices")).Cursor()
+
+		for k, v := c.First(); k != nil; k, v = c.Next() {
+			var dbDevice RemoteDevice
+
+			err := json.Unmarshal(v, &dbDevice)
+			if err != nil {
+				return err
+			}
+
+			if token != "" && dbDevice.TokenHash == tokenHash {
+				device = dbDevice
+				device.TokenHash = ""
+				return nil
+			}
+		}
+
+		return errors.New("device_not_exists")
+	})
+
+	return
+}
+
+// DeleteRemoteDevice deletes a device so its token can't be used anymore
+//
+func (storage *Storage) DeleteRemoteDevice(deviceID string) error {
+	return storage.Db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket([]byte("remotedevices")).Delete([]byte(deviceID))
+	})
+}
+
+// GetRemoteDevice gets a device from the DB
+//
+func (storage *Storage) GetRemoteDevice(deviceID string) (device RemoteDevice, err error) {
+	err = storage.Db.View(func(tx *bolt.Tx) error {
+		jsonDevice := tx.Bucket([]byte("remotedevices")).Get([]byte(deviceID))
+
+		if jsonDevice == nil {
+			return errors.New("device_not_exists")
+		}
+
+		return json.Unmarshal(jsonDevice, &device)
+	})
+
+	device.TokenHash = ""
+
+	return
+}
+
+// GetPlayerInvites gets the users invited to control each player
+//
+func (storage *Storage) GetPlayerInvites() (invites map[string][]string, err error) {
+	invites = make(map[string][]string)
+
+	err = storage.Db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket([]byte("remoteinvites")).Cursor()
+
+		for k, v := c.First(); k != nil; k, v = c.Next() {
+			var users []string
+
+			err := json.Unmarshal(v, &users)
+			if err != nil {
+				return err
+			}
+
+			invites[string(k)] = users
+		}
+
+		return nil
+	})
+
+	return
+}
+
+// InvitedPlayers gets the players that the user was invited to control
+//
+func (storage *Storage) InvitedPlayers(userID string) (players []string, err error) {
+	invites, err := storage.GetPlayerInvites()
+
+	for player, users := range invites {
+		if containsString(users, userID) {
+			players = append(players, player)
+		}
+	}
+
+	sort.Strings(players)
+
+	return
+}
+
+// SetPlayerInvite invites the user to control the player or removes the invitation
+//
+func (storage *Storage) SetPlayerInvite(playerID string, userID string, invited bool) error {
+	if playerID == "" {
+		return errors.New("player_empty")
+	}
+
+	user, err := storage.GetUserByUUID(userID)
+	if err != nil || user.UUID == "" {
+		return errors.New("user_not_exists")
+	}
+
+	return storage.Db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte("remoteinvites"))
+
+		var users []string
+
+		if jsonUsers := bucket.Get([]byte(playerID)); jsonUsers != nil {
+			err := json.Unmarshal(jsonUsers, &users)
+			if err != nil {
+				return err
+			}
+		}
+
+		users = removeString(users, userID)
+
+		if invited {
+			users = append(users, userID)
+		}
+
+		if len(users) == 0 {
+			return bucket.Delete([]byte(playerID))
+		}
+
+		jsonUsers, err := json.Marshal(users)
+		if err != nil {
+			return err
+		}
+
+		return bucket.Put([]byte(playerID), jsonUsers)
+	})
+}
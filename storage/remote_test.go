@@ -0,0 +1,60 @@
+package mpcstorage
+
+import (
+	"github.com/jempe/mpc/users"
+	"testing"
+)
+
+func TestRemoteDevicesAndInvites(t *testing.T) {
+	testStorage, _ := newTestStorage(t)
+
+	guestID, err := testStorage.InsertUser(mpcusers.User{Name: "Guest", Email: "guest@example.com", Password: "guest-password", Role: mpcusers.RoleGuest})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	device, err := testStorage.CreateRemoteDevice(RemoteDevice{Name: "Phone", Owner: guestID}, "secret-token")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	found, err := testStorage.GetRemoteDeviceByToken("secret-token")
+	if err != nil || found.ID != device.ID || found.TokenHash != "" {
+		t.Error("device should be found by its token without exposing the hash", found, err)
+	}
+
+	_, err = testStorage.GetRemoteDeviceByToken("wrong-token")
+	if err == nil {
+		t.Error("wrong tokens should not match a device")
+	}
+
+	err = testStorage.SetPlayerInvite("tv1", guestID, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	players, err := testStorage.InvitedPlayers(guestID)
+	if err != nil || len(players) != 1 || players[0] != "tv1" {
+		t.Error("guest should be invited to tv1", players, err)
+	}
+
+	err = testStorage.SetPlayerInvite("tv1", guestID, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	players, _ = testStorage.InvitedPlayers(guestID)
+	if len(players) != 0 {
+		t.Error("invitation should be removed", players)
+	}
+
+	err = testStorage.DeleteRemoteDevice(device.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = testStorage.GetRemoteDeviceByToken("secret-token")
+	if err == nil {
+		t.Error("tokens of deleted devices should not be valid")
+	}
+}
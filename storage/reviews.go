@@ -0,0 +1,307 @@
+package mpcstorage
+
+import (
+	"github.com/jempe/mpc/library"
+	"encoding/json"
+	"errors"
+	"github.com/boltdb/bolt"
+	"github.com/google/uuid"
+	"sort"
+	"time"
+)
+
+// statuses of the metadata reviews
+const (
+	ReviewPending  = "pending"
+	ReviewAccepted = "accepted"
+	ReviewRejected = "rejected"
+)
+
+// MetadataReview is the metadata of a video found by a scraper, the video is updated when an admin accepts it
+type MetadataReview struct {
+	ID      string `json:"id"`
+	VideoID string `json:"videoID"`
+	Scraper string `json:"scraper"`
+	// SourceID is the ID of the video in the site of the scraper
+	SourceID string           `json:"sourceID"`
+	Metadata mpclibrary.Video `json:"metadata"`
+	Status   string           `json:"status"`
+	// Fields are the fields that were saved when the review was accepted
+	Fields   []string  `json:"fields,omitempty"`
+	Reviewer string    `json:"reviewer,omitempty"`
+	Created  time.Time `json:"created"`
+	Reviewed time.Time `json:"reviewed,omitempty"`
+}
+
+// ReviewFields are the fields of the metadata that can be accepted
+var ReviewFields = []string{"title", "description", "pubDate", "actors", "categories", "imgURL", "videoURL"}
+
+// AddMetadataReview adds the metadata of a scraper to the review queue, a pending review of the same scraper
+// and source for the video is replaced
+//
+func (storage *Storage) AddMetadataReview(review MetadataReview) (MetadataReview, error) {
+	if _, ok := storage.CachedVideo(review.VideoID); !ok {
+		return review, errors.New("video_not_exists")
+	}
+
+	if review.Scraper == "" {
+		return review, errors.New("review_scraper_empty")
+	}
+
+	review.ID = uuid.New().String()
+	review.Status = ReviewPending
+	review.Fields = nil
+	review.Created = time.Now()
+
+	err := storage.Db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte("metadatareviews"))
+
+		c := bucket.Cursor()
+
+		for k, v := c.First(); k != nil; k, v = c.Next() {
+			var existing MetadataReview
+
+			err := json.Unmarshal(v, &existing)
+			if err != nil {
+				return err
+			}
+
+			if existing.Status == ReviewPending && existing.VideoID == review.VideoID && existing.Scraper == review.Scraper && existing.SourceID == review.SourceID {
+				review.ID = existing.ID
+			}
+		}
+
+		jsonReview, err := json.Marshal(review)
+		if err != nil {
+			return err
+		}
+
+		return bucket.Put([]byte(review.ID), jsonReview)
+	})
+
+	return review, err
+}
+
+// GetMetadataReviews gets the reviews with the status from the oldest to the newest, all the reviews are returned
+// when the status is empty
+//
+func (storage *Storage) GetMetadataReviews(status string) (reviews []MetadataReview, err error) {
+	reviews = []MetadataReview{}
+
+	err = storage.Db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket([]byte("metadatareviews")).Cursor()
+
+		for k, v := c.First(); k != nil; k, v = c.Next() {
+			var review MetadataReview
+
+			err := json.Unmarshal(v, &review)
+			if err != nil {
+				return err
+			}
+
+			if status == "" || review.Status == status {
+				reviews = append(reviews, review)
+			}
+		}
+
+		return nil
+	})
+
+	sort.Slice(reviews, func(i, j int) bool {
+		return reviews[i].Created.Before(reviews[j].Created)
+	})
+
+	return
+}
+
+// GetMetadataReview gets a review by its ID
+//
+func (storage *Storage) GetMetadataReview(reviewID string) (review MetadataReview, err error) {
+	err = storage.Db.View(func(tx *bolt.Tx) error {
+		jsonReview := tx.Bucket([]byte("metadatareviews")).Get([]byte(reviewID))
+		if jsonReview == nil {
+			return errors.New("review_not_exists")
+		}
+
+		return json.Unmarshal(jsonReview, &review)
+	})
+
+	return
+}
+
+// AcceptMetadataReview saves the fields of the review in the video, all the fields that have data are saved
+// when the fields are empty. The changes are saved in the edit history of the video with the reviewer
+//
+func (storage *Storage) AcceptMetadataReview(reviewID string, fields []string, reviewer string) (review MetadataReview, err error) {
+	if len(fields) == 0 {
+		fields = ReviewFields
+	}
+
+	err = storage.Db.Update(func(tx *bolt.Tx) error {
+		review, err = pendingReview(tx, reviewID)
+		if err != nil {
+			return err
+		}
+
+		update, accepted, err := reviewUpdate(review.Metadata, fields)
+		if err != nil {
+			return err
+		}
+
+		err = validateVideoUpdate(update)
+		if err != nil {
+			return err
+		}
+
+		_, err = storage.updateVideo(tx, review.VideoID, update, reviewer)
+		if err != nil {
+			return err
+		}
+
+		review.Status = ReviewAccepted
+		review.Fields = accepted
+
+		return saveReview(tx, &review, reviewer)
+	})
+
+	reloadErr := storage.reloadAll()
+
+	if err == nil {
+		err = reloadErr
+	}
+
+	return
+}
+
+// RejectMetadataReview marks the review as rejected, the video is not changed
+//
+func (storage *Storage) RejectMetadataReview(reviewID string, reviewer string) (review MetadataReview, err error) {
+	err = storage.Db.Update(func(tx *bolt.Tx) error {
+		review, err = pendingReview(tx, reviewID)
+		if err != nil {
+			return err
+		}
+
+		review.Status = ReviewRejected
+
+		return saveReview(tx, &review, reviewer)
+	})
+
+	return
+}
+
+// pendingReview gets a review that was not reviewed yet
+//
+func pendingReview(tx *bolt.Tx, reviewID string) (review MetadataReview, err error) {
+	jsonReview := tx.Bucket([]byte("metadatareviews")).Get([]byte(reviewID))
+	if jsonReview == nil {
+		return review, errors.New("review_not_exists")
+	}
+
+	err = json.Unmarshal(jsonReview, &review)
+	if err != nil {
+		return
+	}
+
+	if review.Status != ReviewPending {
+		return review, errors.New("review_already_reviewed")
+	}
+
+	return
+}
+
+// saveReview saves the reviewer and the review time
+//
+func saveReview(tx *bolt.Tx, review *MetadataReview, reviewer string) error {
+	review.Reviewer = reviewer
+	review.Reviewed = time.Now()
+
+	jsonReview, err := json.Marshal(review)
+	if err != nil {
+		return err
+	}
+
+	return tx.Bucket([]byte("metadatareviews")).Put([]byte(review.ID), jsonReview)
+}
+
+// reviewUpdate builds the video update with the fields of the metadata that have data
+//
+func reviewUpdate(metadata mpclibrary.Video, fields []string) (update VideoUpdate, accepted []string, err error) {
+	for _, field := range fields {
+		switch field {
+		case "title":
+			if metadata.Title != "" {
+				update.Title = &metadata.Title
+			}
+		case "description":
+			if metadata.Description != "" {
+				update.Description = &metadata.Description
+			}
+		case "pubDate":
+			if !metadata.PubDate.IsZero() {
+				update.PubDate = &metadata.PubDate
+			}
+		case "actors":
+			if len(metadata.Actors) > 0 {
+				var actors []string
+
+				for _, actor := range metadata.Actors {
+					actors = append(actors, actor.Name)
+				}
+
+				update.Actors = &actors
+			}
+		case "categories":
+			if len(metadata.Categories) > 0 {
+				var categories []string
+
+				for _, category := range metadata.Categories {
+					categories = append(categories, category.Name)
+				}
+
+				update.Categories = &categories
+			}
+		case "imgURL":
+			if metadata.ImgURL != "" {
+				update.ImgURL = &metadata.ImgURL
+			}
+		case "videoURL":
+			if metadata.VideoURL != "" {
+				update.VideoURL = &metadata.VideoURL
+			}
+		default:
+			return update, nil, errors.New("review_field_invalid")
+		}
+	}
+
+	for _, field := range fields {
+		if reviewFieldSet(update, field) {
+			accepted = append(accepted, field)
+		}
+	}
+
+	return
+}
+
+// reviewFieldSet checks if the update has the field
+//
+func reviewFieldSet(update VideoUpdate, field string) bool {
+	switch field {
+	case "title":
+		return update.Title != nil
+	case "description":
+		return update.Description != nil
+	case "pubDate":
+		return update.PubDate != nil
+	case "actors":
+		return update.Actors != nil
+	case "categories":
+		return update.Categories != nil
+	case "imgURL":
+		return update.ImgURL != nil
+	case "videoURL":
+		return update.VideoURL != nil
+	}
+
+	return false
+}
@@ -0,0 +1,102 @@
+package mpcstorage
+
+import (
+	"github.com/jempe/mpc/library"
+	"testing"
+	"time"
+)
+
+func TestMetadataReviews(t *testing.T) {
+	testStorage, _ := newTestStorage(t)
+
+	err := testStorage.InsertVideos([]mpclibrary.Video{{File: "video.mp4", Title: "video.mp4"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = testStorage.GetAllVideos()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	video, _ := testStorage.GetVideoByFileName("video.mp4")
+
+	metadata := mpclibrary.Video{
+		Title:       "Scraped Title",
+		Description: "Scraped description",
+		PubDate:     time.Date(2008, 5, 20, 0, 0, 0, 0, time.UTC),
+		Actors:      []mpclibrary.Actor{{Name: "Jane Doe"}},
+	}
+
+	review, err := testStorage.AddMetadataReview(MetadataReview{VideoID: video.ID, Scraper: "example", SourceID: "1", Metadata: metadata})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	again, err := testStorage.AddMetadataReview(MetadataReview{VideoID: video.ID, Scraper: "example", SourceID: "1", Metadata: metadata})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if again.ID != review.ID {
+		t.Error("a pending review of the same source should be replaced")
+	}
+
+	other, err := testStorage.AddMetadataReview(MetadataReview{VideoID: video.ID, Scraper: "example", SourceID: "2", Metadata: mpclibrary.Video{Title: "Other"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = testStorage.AddMetadataReview(MetadataReview{VideoID: "missing", Scraper: "example"})
+	if err == nil {
+		t.Error("reviews of missing videos should be rejected")
+	}
+
+	pending, err := testStorage.GetMetadataReviews(ReviewPending)
+	if err != nil || len(pending) != 2 {
+		t.Fatal("there should be two pending reviews", pending, err)
+	}
+
+	if unchanged, _ := testStorage.GetVideoByID(video.ID); unchanged.Title != "video.mp4" {
+		t.Error("the video should not change before the review", unchanged.Title)
+	}
+
+	accepted, err := testStorage.AcceptMetadataReview(review.ID, []string{"title", "actors", "categories"}, "admin-uuid")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if accepted.Status != ReviewAccepted || accepted.Reviewer != "admin-uuid" || len(accepted.Fields) != 2 {
+		t.Error("only the fields with data should be accepted", accepted)
+	}
+
+	updated, _ := testStorage.GetVideoByID(video.ID)
+
+	if updated.Title != "Scraped Title" || updated.Description != "" || len(updated.Actors) != 1 || updated.Actors[0].Name != "Jane Doe" {
+		t.Error("the accepted fields should be saved", updated)
+	}
+
+	history, err := testStorage.GetVideoHistory(video.ID)
+	if err != nil || len(history) != 1 || history[0].User != "admin-uuid" {
+		t.Error("the review should be saved in the video history", history, err)
+	}
+
+	_, err = testStorage.AcceptMetadataReview(review.ID, nil, "admin-uuid")
+	if err == nil {
+		t.Error("reviews should be accepted only once")
+	}
+
+	_, err = testStorage.RejectMetadataReview(other.ID, "admin-uuid")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if rejected, _ := testStorage.GetVideoByID(video.ID); rejected.Title != "Scraped Title" {
+		t.Error("rejected reviews should not change the video", rejected.Title)
+	}
+
+	all, _ := testStorage.GetMetadataReviews("")
+	if len(all) != 2 || all[1].Status != ReviewRejected {
+		t.Error("all the reviews should be listed", all)
+	}
+}
@@ -0,0 +1,287 @@
+package mpcstorage
+
+import (
+	"github.com/jempe/mpc/library"
+	"encoding/json"
+	"errors"
+	"github.com/boltdb/bolt"
+	"sort"
+	"strings"
+)
+
+type SeriesInfo struct {
+	mpclibrary.Series
+	Seasons  int `json:"seasons"`
+	Episodes int `json:"episodes"`
+}
+
+type Season struct {
+	Number   int               `json:"number"`
+	Episodes mpclibrary.Videos `json:"episodes"`
+}
+
+type SeriesDetails struct {
+	mpclibrary.Series
+	Seasons []Season `json:"seasons"`
+}
+
+// getAllSeries gets all the series from the DB
+//
+func (storage *Storage) getAllSeries() error {
+	allSeries := make(map[int]mpclibrary.Series)
+
+	err := storage.Db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket([]byte("series")).Cursor()
+
+		for k, v := c.First(); k != nil; k, v = c.Next() {
+			var dbSeries mpclibrary.Series
+
+			json.Unmarshal(v, &dbSeries)
+
+			if dbSeries.Name != "" {
+				allSeries[btoi(k)] = dbSeries
+			} else {
+				return errors.New("error getting series")
+			}
+		}
+
+		return nil
+	})
+
+	storage.mutex.Lock()
+	storage.Series = allSeries
+	storage.mutex.Unlock()
+
+	return err
+}
+
+// seriesName gets the name of a series, it's empty when the series doesn't exist
+//
+func (storage *Storage) seriesName(seriesID int) string {
+	storage.mutex.RLock()
+	defer storage.mutex.RUnlock()
+
+	return storage.Series[seriesID].Name
+}
+
+// GetSeriesByName gets a series from the list by name
+//
+func (storage *Storage) GetSeriesByName(name string) (series mpclibrary.Series, err error) {
+	if name == "" {
+		return
+	}
+
+	storage.mutex.RLock()
+	defer storage.mutex.RUnlock()
+
+	for _, thisSeries := range storage.Series {
+		if strings.EqualFold(name, thisSeries.Name) {
+			return thisSeries, nil
+		}
+	}
+	return
+}
+
+// insertSeries inserts a new series in the series bucket
+//
+func (storage *Storage) insertSeries(bucket *bolt.Bucket, name string) error {
+	id, err := bucket.NextSequence()
+	if err != nil {
+		return err
+	}
+
+	series := mpclibrary.Series{ID: int(id), Name: name}
+
+	jsonSeries, err := json.Marshal(series)
+	if err != nil {
+		return err
+	}
+
+	err = bucket.Put(itob(series.ID), jsonSeries)
+	if err == nil {
+		storage.mutex.Lock()
+		storage.Series[series.ID] = series
+		storage.mutex.Unlock()
+	}
+
+	return err
+}
+
+// detectEpisode fills the series, season and episode of the video when they are not in the json file
+// by parsing its title and file names, the series is created if it doesn't exist
+//
+func (storage *Storage) detectEpisode(bucket *bolt.Bucket, video *mpclibrary.Video) error {
+	if video.Series == "" || video.Episode == 0 {
+		for _, name := range []string{video.Title, video.OrigFile, video.File} {
+			info, ok := mpclibrary.ParseEpisode(name)
+
+			if ok {
+				if video.Series == "" {
+					video.Series = info.Show
+				}
+
+				if video.Season == 0 {
+					video.Season = info.Season
+				}
+
+				if video.Episode == 0 {
+					video.Episode = info.Episode
+				}
+
+				break
+			}
+		}
+	}
+
+	if video.Series != "" {
+		series, _ := storage.GetSeriesByName(video.Series)
+
+		if series.Name == "" {
+			return storage.insertSeries(bucket, video.Series)
+		}
+	}
+
+	return nil
+}
+
+// DetectEpisodes finds the series, season and episode of the videos that are not part of a series
+// and returns the number of videos that changed
+//
+func (storage *Storage) DetectEpisodes() (total int, err error) {
+	err = storage.Db.Update(func(tx *bolt.Tx) error {
+		seriesBucket := tx.Bucket([]byte("series"))
+
+		var detectErr error
+
+		err := storage.updateVideos(tx.Bucket([]byte("videos")), func(dbVideo *Video) bool {
+			if dbVideo.Series != 0 || detectErr != nil {
+				return false
+			}
+
+			video := storage.videoToLibraryVideo(*dbVideo)
+
+			detectErr = storage.detectEpisode(seriesBucket, &video)
+
+			series, _ := storage.GetSeriesByName(video.Series)
+			if detectErr != nil || series.ID == 0 {
+				return false
+			}
+
+			dbVideo.Series = series.ID
+			dbVideo.Season = video.Season
+			dbVideo.Episode = video.Episode
+
+			total++
+			return true
+		})
+		if err != nil {
+			return err
+		}
+
+		return detectErr
+	})
+
+	reloadErr := storage.reloadAll()
+
+	if err == nil {
+		err = reloadErr
+	}
+
+	return
+}
+
+// GetSeriesList gets all the series sorted by name with their number of seasons and episodes
+//
+func (storage *Storage) GetSeriesList() (seriesList []SeriesInfo) {
+	seriesList = []SeriesInfo{}
+
+	seasons := make(map[int]map[int]bool)
+	episodes := make(map[int]int)
+
+	storage.mutex.RLock()
+	defer storage.mutex.RUnlock()
+
+	for _, video := range storage.Videos {
+		if video.Series != 0 {
+			if seasons[video.Series] == nil {
+				seasons[video.Series] = make(map[int]bool)
+			}
+
+			seasons[video.Series][video.Season] = true
+			episodes[video.Series]++
+		}
+	}
+
+	for id, series := range storage.Series {
+		seriesList = append(seriesList, SeriesInfo{Series: series, Seasons: len(seasons[id]), Episodes: episodes[id]})
+	}
+
+	sort.Slice(seriesList, func(i, j int) bool {
+		return mpclibrary.NaturalLess(seriesList[i].Name, seriesList[j].Name)
+	})
+
+	return
+}
+
+// GetSeriesDetails gets the seasons of a series with their episodes in order
+//
+func (storage *Storage) GetSeriesDetails(seriesID int) (details SeriesDetails, err error) {
+	storage.mutex.RLock()
+	series, ok := storage.Series[seriesID]
+	storage.mutex.RUnlock()
+
+	if !ok {
+		return details, errors.New("series_not_exists")
+	}
+
+	details.Series = series
+	details.Seasons = []Season{}
+
+	for _, video := range storage.seriesEpisodes(seriesID) {
+		if len(details.Seasons) == 0 || details.Seasons[len(details.Seasons)-1].Number != video.Season {
+			details.Seasons = append(details.Seasons, Season{Number: video.Season})
+		}
+
+		lastSeason := &details.Seasons[len(details.Seasons)-1]
+		lastSeason.Episodes = append(lastSeason.Episodes, video)
+	}
+
+	return
+}
+
+// NextEpisode gets the episode that follows the video in its series
+//
+func (storage *Storage) NextEpisode(videoID string) (next mpclibrary.Video, err error) {
+	dbVideo, ok := storage.CachedVideo(videoID)
+	if !ok {
+		return next, errors.New("video_not_exists")
+	}
+
+	if dbVideo.Series == 0 {
+		return next, errors.New("video_not_episode")
+	}
+
+	episodes := storage.seriesEpisodes(dbVideo.Series)
+
+	for index, episode := range episodes {
+		if episode.ID == videoID && index+1 < len(episodes) {
+			return episodes[index+1], nil
+		}
+	}
+
+	return next, errors.New("episode_last")
+}
+
+// seriesEpisodes gets the videos of a series sorted by season and episode
+//
+func (storage *Storage) seriesEpisodes(seriesID int) (episodes mpclibrary.Videos) {
+	for _, video := range storage.CachedVideos() {
+		if video.Series == seriesID {
+			episodes = append(episodes, storage.videoToLibraryVideo(video))
+		}
+	}
+
+	sort.Sort(mpclibrary.ByEpisode{Videos: episodes})
+
+	return
+}
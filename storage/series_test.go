@@ -0,0 +1,47 @@
+package mpcstorage
+
+import (
+	"github.com/jempe/mpc/library"
+	"testing"
+)
+
+func TestNextEpisode(t *testing.T) {
+	testStorage, _ := newTestStorage(t)
+
+	err := testStorage.InsertVideos([]mpclibrary.Video{
+		{File: "show.s01e10.mp4", Title: "The.Show.S01E10.mp4", Encrypted: true},
+		{File: "show.s01e02.mp4", Title: "The.Show.S01E02.mp4", Encrypted: true},
+		{File: "show.s02e01.mp4", Title: "The Show - 2x01.mp4", Encrypted: true},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = testStorage.GetAllVideos()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	seriesList := testStorage.GetSeriesList()
+
+	if len(seriesList) != 1 || seriesList[0].Name != "The Show" || seriesList[0].Seasons != 2 || seriesList[0].Episodes != 3 {
+		t.Fatal("videos should be grouped in one series", seriesList)
+	}
+
+	first, _ := testStorage.GetVideoByFileName("show.s01e02.mp4")
+
+	next, err := testStorage.NextEpisode(first.ID)
+	if err != nil || next.File != "show.s01e10.mp4" {
+		t.Error("next episode should be sorted by episode number", next, err)
+	}
+
+	next, err = testStorage.NextEpisode(next.ID)
+	if err != nil || next.File != "show.s02e01.mp4" {
+		t.Error("next episode should continue in the next season", next, err)
+	}
+
+	_, err = testStorage.NextEpisode(next.ID)
+	if err == nil {
+		t.Error("the last episode should not have a next episode")
+	}
+}
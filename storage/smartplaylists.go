@@ -0,0 +1,252 @@
+package mpcstorage
+
+import (
+	"github.com/jempe/mpc/library"
+	"encoding/json"
+	"errors"
+	"github.com/boltdb/bolt"
+	"github.com/google/uuid"
+	"sort"
+	"strings"
+	"time"
+)
+
+type SmartConditions struct {
+	Unwatched bool `json:"unwatched"`
+	AddedDays int  `json:"addedDays"`
+	// WatchedMoreThan finds the videos that the user watched more times than this, 0 finds the watched videos
+	WatchedMoreThan *int `json:"watchedMoreThan"`
+}
+
+type SmartPlaylist struct {
+	ID         string          `json:"id"`
+	Name       string          `json:"name"`
+	Owner      string          `json:"owner"`
+	Filter     VideoFilter     `json:"filter"`
+	Sort       string          `json:"sort"`
+	Limit      int             `json:"limit"`
+	Conditions SmartConditions `json:"conditions"`
+	Shared     bool            `json:"shared"`
+	SharedWith []string        `json:"sharedWith"`
+	PinnedBy   []string        `json:"pinnedBy"`
+	Created    time.Time       `json:"created"`
+	Updated    time.Time       `json:"updated"`
+}
+
+// CanView checks if the user can see the smart playlist
+//
+func (smart SmartPlaylist) CanView(userID string) bool {
+	return smart.Owner == userID || smart.Shared || containsString(smart.SharedWith, userID)
+}
+
+// IsPinned checks if the user pinned the smart playlist to the home screen
+//
+func (smart SmartPlaylist) IsPinned(userID string) bool {
+	return containsString(smart.PinnedBy, userID)
+}
+
+// GetSmartPlaylists gets the smart playlists that the user can see sorted by name
+//
+func (storage *Storage) GetSmartPlaylists(userID string) (smartPlaylists []SmartPlaylist, err error) {
+	smartPlaylists = []SmartPlaylist{}
+
+	err = storage.Db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket([]byte("smartplaylists")).Cursor()
+
+		for k, v := c.First(); k != nil; k, v = c.Next() {
+			var smart SmartPlaylist
+
+			err := json.Unmarshal(v, &smart)
+			if err != nil {
+				return err
+			}
+
+			if smart.CanView(userID) {
+				smartPlaylists = append(smartPlaylists, smart)
+			}
+		}
+
+		return nil
+	})
+
+	sort.Slice(smartPlaylists, func(i, j int) bool {
+		return strings.ToLower(smartPlaylists[i].Name) < strings.ToLower(smartPlaylists[j].Name)
+	})
+
+	return
+}
+
+// GetSmartPlaylist gets a smart playlist from the DB
+//
+func (storage *Storage) GetSmartPlaylist(smartID string) (smart SmartPlaylist, err error) {
+	err = storage.Db.View(func(tx *bolt.Tx) error {
+		jsonSmart := tx.Bucket([]byte("smartplaylists")).Get([]byte(smartID))
+
+		if jsonSmart == nil {
+			return errors.New("smart_playlist_not_exists")
+		}
+
+		return json.Unmarshal(jsonSmart, &smart)
+	})
+
+	return
+}
+
+// SaveSmartPlaylist inserts or updates a smart playlist, new smart playlists get a new ID
+//
+func (storage *Storage) SaveSmartPlaylist(smart SmartPlaylist) (SmartPlaylist, error) {
+	smart.Name = strings.TrimSpace(smart.Name)
+
+	if smart.Name == "" {
+		return smart, errors.New("smart_playlist_name_empty")
+	}
+
+	if smart.Owner == "" {
+		return smart, errors.New("smart_playlist_owner_empty")
+	}
+
+	if smart.Limit < 0 || smart.Conditions.AddedDays < 0 || (smart.Conditions.WatchedMoreThan != nil && *smart.Conditions.WatchedMoreThan < 0) {
+		return smart, errors.New("smart_playlist_invalid")
+	}
+
+	if smart.ID == "" {
+		smart.ID = uuid.New().String()
+		smart.Created = time.Now()
+	}
+
+	smart.Updated = time.Now()
+
+	err := storage.Db.Update(func(tx *bolt.Tx) error {
+		jsonSmart, err := json.Marshal(smart)
+		if err != nil {
+			return err
+		}
+
+		return tx.Bucket([]byte("smartplaylists")).Put([]byte(smart.ID), jsonSmart)
+	})
+
+	return smart, err
+}
+
+// DeleteSmartPlaylist deletes a smart playlist from the DB
+//
+func (storage *Storage) DeleteSmartPlaylist(smartID string) error {
+	return storage.Db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket([]byte("smartplaylists")).Delete([]byte(smartID))
+	})
+}
+
+// GetSmartPlaylistVideos gets the videos that meet the filter and conditions of the smart playlist for the user.
+// Videos without added date don't meet the added days condition, and the playlist filter only has videos when the
+// user can view the playlist
+//
+func (storage *Storage) GetSmartPlaylistVideos(smart SmartPlaylist, userID string, offset int, view int) (results VideoResults, err error) {
+	var members map[string]bool
+
+	if smart.Filter.Playlist != "" || smart.Filter.Collection != 0 {
+		members, err = storage.filterMembers(smart.Filter, userID)
+		if err != nil {
+			return
+		}
+	}
+
+	var watches map[string]Watch
+
+	if smart.Conditions.Unwatched || smart.Conditions.WatchedMoreThan != nil {
+		watches, err = storage.GetWatches(userID)
+		if err != nil {
+			return
+		}
+	}
+
+	allVideos := storage.GetVideos(0, storage.CountVideos(), smart.Sort, smart.Filter, 0)
+
+	var videos mpclibrary.Videos
+
+	for _, video := range allVideos.Videos {
+		if members != nil && !members[video.ID] {
+			continue
+		}
+
+		if smart.Conditions.Unwatched && watches[video.ID].Count > 0 {
+			continue
+		}
+
+		if smart.Conditions.WatchedMoreThan != nil && watches[video.ID].Count <= *smart.Conditions.WatchedMoreThan {
+			continue
+		}
+
+		if smart.Conditions.AddedDays > 0 && (video.Added.IsZero() || time.Since(video.Added) > time.Duration(smart.Conditions.AddedDays)*24*time.Hour) {
+			continue
+		}
+
+		videos = append(videos, video)
+
+		if smart.Limit > 0 && len(videos) == smart.Limit {
+			break
+		}
+	}
+
+	var videoResults mpclibrary.Videos
+
+	lastResult := offset + view
+
+	if lastResult > len(videos) {
+		lastResult = len(videos)
+	}
+
+	if offset < len(videos) {
+		videoResults = videos[offset:lastResult]
+	}
+
+	results = VideoResults{Videos: videoResults, Offset: offset, View: view, Total: len(videos)}
+
+	return
+}
+
+// filterMembers gets the IDs of the videos that are in the playlist and in the collection of the filter,
+// a missing playlist or collection and a playlist that the user can't view have no videos
+//
+func (storage *Storage) filterMembers(filter VideoFilter, userID string) (members map[string]bool, err error) {
+	var lists [][]string
+
+	if filter.Playlist != "" {
+		playlist, err := storage.GetPlaylist(filter.Playlist)
+		if err != nil && err.Error() != "playlist_not_exists" {
+			return nil, err
+		}
+
+		if err != nil || !playlist.CanView(userID) {
+			return map[string]bool{}, nil
+		}
+
+		lists = append(lists, playlist.Videos)
+	}
+
+	if filter.Collection != 0 {
+		collection, err := storage.GetCollection(filter.Collection)
+		if err != nil && err.Error() != "collection_not_exists" {
+			return nil, err
+		}
+
+		if err != nil {
+			return map[string]bool{}, nil
+		}
+
+		lists = append(lists, collection.Videos)
+	}
+
+	for index, list := range lists {
+		inList := make(map[string]bool)
+
+		for _, videoID := range list {
+			if index == 0 || members[videoID] {
+				inList[videoID] = true
+			}
+		}
+
+		members = inList
+	}
+
+	return
+}
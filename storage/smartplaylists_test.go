@@ -0,0 +1,108 @@
+package mpcstorage
+
+import (
+	"testing"
+)
+
+func TestSmartPlaylistConditions(t *testing.T) {
+	testStorage, videoIDs := newTestStorage(t, "One", "Two")
+
+	_, err := testStorage.RecordWatch("user", videoIDs[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	smart := SmartPlaylist{Name: "Unwatched", Owner: "user", Sort: "title", Conditions: SmartConditions{Unwatched: true, AddedDays: 30}}
+
+	results, err := testStorage.GetSmartPlaylistVideos(smart, "user", 0, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if results.Total != 1 || results.Videos[0].Title != "Two" {
+		t.Error("only the unwatched video should be in the smart playlist", results)
+	}
+
+	watchedMoreThan := 0
+	smart.Conditions = SmartConditions{WatchedMoreThan: &watchedMoreThan}
+
+	results, err = testStorage.GetSmartPlaylistVideos(smart, "user", 0, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if results.Total != 1 || results.Videos[0].Title != "One" {
+		t.Error("only the watched video should be in the smart playlist", results)
+	}
+
+	// the video watched once is not watched more than once
+	watchedMoreThan = 1
+
+	results, err = testStorage.GetSmartPlaylistVideos(smart, "user", 0, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if results.Total != 0 {
+		t.Error("the count of watches is exclusive", results)
+	}
+
+	_, err = testStorage.RecordWatch("user", videoIDs[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	results, err = testStorage.GetSmartPlaylistVideos(smart, "user", 0, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if results.Total != 1 || results.Videos[0].Title != "One" {
+		t.Error("the video watched twice should be in the smart playlist", results)
+	}
+}
+
+func TestSmartPlaylistPlaylistFilter(t *testing.T) {
+	testStorage, videoIDs := newTestStorage(t, "One", "Two", "Three")
+
+	playlist, err := testStorage.SavePlaylist(Playlist{Name: "Private", Owner: "owner", Videos: videoIDs[:2]})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	collection, err := testStorage.SaveCollection(Collection{Name: "Second", Videos: videoIDs[1:2]})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	smart := SmartPlaylist{Name: "From the playlist", Owner: "owner", Sort: "title", Filter: VideoFilter{Playlist: playlist.ID}}
+
+	results, err := testStorage.GetSmartPlaylistVideos(smart, "owner", 0, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if results.Total != 2 || results.Videos[0].Title != "One" || results.Videos[1].Title != "Two" {
+		t.Error("only the videos of the playlist should be in the smart playlist", results)
+	}
+
+	results, err = testStorage.GetSmartPlaylistVideos(smart, "other", 0, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if results.Total != 0 {
+		t.Error("the videos of a private playlist should be hidden from the other users", results)
+	}
+
+	smart.Filter.Collection = collection.ID
+
+	results, err = testStorage.GetSmartPlaylistVideos(smart, "owner", 0, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if results.Total != 1 || results.Videos[0].Title != "Two" {
+		t.Error("the videos should be in the playlist and in the collection", results)
+	}
+}
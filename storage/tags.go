@@ -0,0 +1,283 @@
+package mpcstorage
+
+import (
+	"github.com/jempe/mpc/library"
+	"encoding/json"
+	"errors"
+	"github.com/boltdb/bolt"
+	"sort"
+	"strings"
+)
+
+type TagInfo struct {
+	mpclibrary.Tag
+	Videos int `json:"videos"`
+}
+
+// getAllTags gets all the tags from the DB
+//
+func (storage *Storage) getAllTags() error {
+	allTags := make(map[int]mpclibrary.Tag)
+
+	err := storage.Db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte("tags"))
+
+		c := b.Cursor()
+
+		for k, v := c.First(); k != nil; k, v = c.Next() {
+			var dbTag mpclibrary.Tag
+
+			json.Unmarshal(v, &dbTag)
+
+			if dbTag.Name != "" {
+				allTags[btoi(k)] = dbTag
+			} else {
+				return errors.New("error getting tags")
+			}
+		}
+
+		return nil
+	})
+
+	storage.mutex.Lock()
+	storage.Tags = allTags
+	storage.mutex.Unlock()
+
+	return err
+}
+
+// GetTagList gets all the tags sorted by name with the number of videos of every tag
+//
+func (storage *Storage) GetTagList() (tags []TagInfo) {
+	counts := make(map[int]int)
+
+	storage.mutex.RLock()
+	defer storage.mutex.RUnlock()
+
+	for _, video := range storage.Videos {
+		for _, tag := range video.Tags {
+			counts[tag]++
+		}
+	}
+
+	for id, tag := range storage.Tags {
+		tags = append(tags, TagInfo{Tag: tag, Videos: counts[id]})
+	}
+
+	sort.Slice(tags, func(i, j int) bool {
+		return strings.ToLower(tags[i].Name) < strings.ToLower(tags[j].Name)
+	})
+
+	return
+}
+
+// GetTagByName gets a tag from the list by name
+//
+func (storage *Storage) GetTagByName(tagName string) (tag mpclibrary.Tag, err error) {
+	storage.mutex.RLock()
+	defer storage.mutex.RUnlock()
+
+	for _, thisTag := range storage.Tags {
+		if strings.EqualFold(tagName, thisTag.Name) {
+			return thisTag, nil
+		}
+	}
+	return
+}
+
+// GetTagByID gets a tag from the list by ID
+//
+func (storage *Storage) GetTagByID(tagID int) (tag mpclibrary.Tag, err error) {
+	storage.mutex.RLock()
+	defer storage.mutex.RUnlock()
+
+	return storage.Tags[tagID], nil
+}
+
+// CreateTag inserts a new tag in the DB
+//
+func (storage *Storage) CreateTag(name string) (tag mpclibrary.Tag, err error) {
+	tag.Name = strings.TrimSpace(name)
+
+	if tag.Name == "" {
+		return tag, errors.New("tag_name_empty")
+	}
+
+	sameName, _ := storage.GetTagByName(tag.Name)
+	if sameName.Name != "" {
+		return tag, errors.New("tag_name_exists")
+	}
+
+	err = storage.Db.Update(func(tx *bolt.Tx) error {
+		tag, err = storage.insertTag(tx.Bucket([]byte("tags")), tag.Name)
+		return err
+	})
+
+	return
+}
+
+// insertTag inserts a new tag in the tags bucket
+//
+func (storage *Storage) insertTag(bucket *bolt.Bucket, name string) (tag mpclibrary.Tag, err error) {
+	id, err := bucket.NextSequence()
+	if err != nil {
+		return
+	}
+
+	tag = mpclibrary.Tag{ID: int(id), Name: name}
+
+	err = saveTag(bucket, tag)
+	if err == nil {
+		storage.mutex.Lock()
+		storage.Tags[tag.ID] = tag
+		storage.mutex.Unlock()
+	}
+
+	return
+}
+
+// RenameTag changes the name of a tag
+//
+func (storage *Storage) RenameTag(tagID int, name string) (tag mpclibrary.Tag, err error) {
+	tag, _ = storage.GetTagByID(tagID)
+	if tag.Name == "" {
+		return tag, errors.New("tag_not_exists")
+	}
+
+	tag.Name = strings.TrimSpace(name)
+	if tag.Name == "" {
+		return tag, errors.New("tag_name_empty")
+	}
+
+	sameName, _ := storage.GetTagByName(tag.Name)
+	if sameName.Name != "" && sameName.ID != tagID {
+		return tag, errors.New("tag_name_exists")
+	}
+
+	err = storage.Db.Update(func(tx *bolt.Tx) error {
+		return saveTag(tx.Bucket([]byte("tags")), tag)
+	})
+
+	if err == nil {
+		storage.mutex.Lock()
+		storage.Tags[tag.ID] = tag
+		storage.mutex.Unlock()
+	}
+
+	return
+}
+
+// DeleteTag deletes a tag and removes it from all the videos
+//
+func (storage *Storage) DeleteTag(tagID int) error {
+	tag, _ := storage.GetTagByID(tagID)
+	if tag.Name == "" {
+		return errors.New("tag_not_exists")
+	}
+
+	err := storage.Db.Update(func(tx *bolt.Tx) error {
+		err := storage.updateVideos(tx.Bucket([]byte("videos")), func(video *Video) bool {
+			if !containsInt(video.Tags, tagID) {
+				return false
+			}
+
+			video.Tags = removeInt(video.Tags, tagID)
+			return true
+		})
+		if err != nil {
+			return err
+		}
+
+		return tx.Bucket([]byte("tags")).Delete(itob(tagID))
+	})
+
+	if err != nil {
+		return err
+	}
+
+	storage.mutex.Lock()
+	delete(storage.Tags, tagID)
+	storage.mutex.Unlock()
+
+	return storage.GetAllVideos()
+}
+
+// TagVideos adds the tag to a list of videos and removes it from another list of videos
+//
+func (storage *Storage) TagVideos(tagID int, add []string, remove []string) error {
+	tag, _ := storage.GetTagByID(tagID)
+	if tag.Name == "" {
+		return errors.New("tag_not_exists")
+	}
+
+	err := storage.Db.Update(func(tx *bolt.Tx) error {
+		return storage.updateVideos(tx.Bucket([]byte("videos")), func(video *Video) bool {
+			changed := false
+
+			if containsString(add, video.ID) && !containsInt(video.Tags, tagID) {
+				video.Tags = append(video.Tags, tagID)
+				changed = true
+			}
+
+			if containsString(remove, video.ID) && containsInt(video.Tags, tagID) {
+				video.Tags = removeInt(video.Tags, tagID)
+				changed = true
+			}
+
+			return changed
+		})
+	})
+
+	if err != nil {
+		return err
+	}
+
+	return storage.GetAllVideos()
+}
+
+// saveTag saves a tag in the tags bucket
+//
+func saveTag(bucket *bolt.Bucket, tag mpclibrary.Tag) error {
+	jsonTag, err := json.Marshal(tag)
+	if err != nil {
+		return err
+	}
+
+	return bucket.Put(itob(tag.ID), jsonTag)
+}
+
+// removeInt removes a value from the list
+//
+func removeInt(list []int, value int) (removed []int) {
+	for _, item := range list {
+		if item != value {
+			removed = append(removed, item)
+		}
+	}
+
+	return
+}
+
+// containsString checks if the list contains the value
+//
+func containsString(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+
+	return false
+}
+
+// removeString removes a value from the list
+//
+func removeString(list []string, value string) (removed []string) {
+	for _, item := range list {
+		if item != value {
+			removed = append(removed, item)
+		}
+	}
+
+	return
+}
@@ -0,0 +1,133 @@
+package mpcstorage
+
+import (
+	"testing"
+)
+
+func TestCreateAndRenameTag(t *testing.T) {
+	testStorage, _ := newTestStorage(t)
+
+	tag, err := testStorage.CreateTag(" favorite ")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if tag.ID == 0 || tag.Name != "favorite" {
+		t.Error("the tag should be created with a trimmed name", tag)
+	}
+
+	if _, err = testStorage.CreateTag("Favorite"); err == nil || err.Error() != "tag_name_exists" {
+		t.Error("the tag names are unique ignoring the case", err)
+	}
+
+	if _, err = testStorage.CreateTag(" "); err == nil || err.Error() != "tag_name_empty" {
+		t.Error("the tag name can't be empty", err)
+	}
+
+	other, err := testStorage.CreateTag("later")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = testStorage.RenameTag(other.ID, "FAVORITE"); err == nil || err.Error() != "tag_name_exists" {
+		t.Error("the tag can't be renamed to the name of another tag", err)
+	}
+
+	renamed, err := testStorage.RenameTag(tag.ID, "Favorite")
+	if err != nil {
+		t.Fatal("the tag can change the case of its own name", err)
+	}
+
+	if renamed.ID != tag.ID || renamed.Name != "Favorite" {
+		t.Error("wrong renamed tag", renamed)
+	}
+
+	// the tags are read again from the DB
+	err = testStorage.GetAllVideos()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	saved, _ := testStorage.GetTagByID(tag.ID)
+	if saved.Name != "Favorite" {
+		t.Error("the new name should be saved", saved)
+	}
+
+	if _, err = testStorage.RenameTag(1000, "missing"); err == nil || err.Error() != "tag_not_exists" {
+		t.Error("a missing tag can't be renamed", err)
+	}
+}
+
+func TestTagVideos(t *testing.T) {
+	testStorage, ids := newTestStorage(t, "One", "Two")
+
+	tag, err := testStorage.CreateTag("favorite")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = testStorage.TagVideos(tag.ID, ids, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	results := testStorage.GetVideos(0, 10, "title", VideoFilter{Tag: "favorite"}, 0)
+	if results.Total != 2 {
+		t.Error("both videos should have the tag", results.Total)
+	}
+
+	err = testStorage.DeleteTag(tag.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, video := range testStorage.Videos {
+		if len(video.Tags) != 0 {
+			t.Error("deleted tag should be removed from videos")
+		}
+	}
+}
+
+func TestTagVideosRemove(t *testing.T) {
+	testStorage, _ := newTestStorage(t, "One", "Two")
+
+	tag, err := testStorage.CreateTag("favorite")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	one, _ := testStorage.GetVideoByFileName("one.mp4")
+	two, _ := testStorage.GetVideoByFileName("two.mp4")
+
+	err = testStorage.TagVideos(tag.ID, []string{one.ID}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = testStorage.TagVideos(tag.ID, []string{two.ID}, []string{one.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	results := testStorage.GetVideos(0, 10, "title", VideoFilter{Tag: "favorite"}, 0)
+	if results.Total != 1 || results.Videos[0].Title != "Two" {
+		t.Error("the tag should move from one video to the other", results)
+	}
+
+	if err = testStorage.TagVideos(1000, []string{one.ID}, nil); err == nil || err.Error() != "tag_not_exists" {
+		t.Error("a missing tag can't be added to the videos", err)
+	}
+
+	if err = testStorage.DeleteTag(1000); err == nil || err.Error() != "tag_not_exists" {
+		t.Error("a missing tag can't be deleted", err)
+	}
+
+	err = testStorage.DeleteTag(tag.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(testStorage.GetTagList()) != 0 {
+		t.Error("the deleted tag should not be listed")
+	}
+}
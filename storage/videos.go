@@ -0,0 +1,319 @@
+package mpcstorage
+
+import (
+	"github.com/jempe/mpc/library"
+	"encoding/json"
+	"errors"
+	"github.com/boltdb/bolt"
+	"strings"
+	"time"
+)
+
+type VideoUpdate struct {
+	Title       *string    `json:"title"`
+	Description *string    `json:"description"`
+	PubDate     *time.Time `json:"pubDate"`
+	Actors      *[]string  `json:"actors"`
+	Categories  *[]string  `json:"categories"`
+	Tags        *[]string  `json:"tags"`
+	ImgURL      *string    `json:"imgURL"`
+	VideoURL    *string    `json:"videoURL"`
+}
+
+type VideoEdit struct {
+	User    string        `json:"user"`
+	Time    time.Time     `json:"time"`
+	Changes []FieldChange `json:"changes"`
+}
+
+type FieldChange struct {
+	Field string      `json:"field"`
+	Old   interface{} `json:"old"`
+	New   interface{} `json:"new"`
+}
+
+// UpdateVideo validates and saves the changes of a video, it creates the actors and categories that don't exist
+// and saves the changes in the edit history of the video, the anonymous edits are rejected
+//
+func (storage *Storage) UpdateVideo(videoID string, update VideoUpdate, user string) (video mpclibrary.Video, err error) {
+	if user == "" {
+		return video, errors.New("video_edit_user_empty")
+	}
+
+	err = validateVideoUpdate(update)
+	if err != nil {
+		return
+	}
+
+	err = storage.Db.Update(func(tx *bolt.Tx) error {
+		_, err := storage.updateVideo(tx, videoID, update, user)
+		return err
+	})
+
+	reloadErr := storage.reloadAll()
+
+	if err == nil {
+		err = reloadErr
+	}
+
+	if err != nil {
+		return
+	}
+
+	return storage.GetVideoByID(videoID)
+}
+
+// reloadAll loads the actors, categories, tags and videos from the DB
+//
+func (storage *Storage) reloadAll() error {
+	err := storage.getAllActors()
+	if err != nil {
+		return err
+	}
+
+	err = storage.getAllCategories()
+	if err != nil {
+		return err
+	}
+
+	err = storage.getAllTags()
+	if err != nil {
+		return err
+	}
+
+	err = storage.getAllSeries()
+	if err != nil {
+		return err
+	}
+
+	return storage.GetAllVideos()
+}
+
+// updateVideo applies the changes to a video inside a transaction and returns the saved changes
+//
+func (storage *Storage) updateVideo(tx *bolt.Tx, videoID string, update VideoUpdate, user string) (edit VideoEdit, err error) {
+	videosBucket := tx.Bucket([]byte("videos"))
+
+	jsonVideo := videosBucket.Get([]byte(videoID))
+	if jsonVideo == nil {
+		return edit, errors.New("video_not_exists")
+	}
+
+	var dbVideo Video
+
+	err = json.Unmarshal(jsonVideo, &dbVideo)
+	if err != nil {
+		return
+	}
+
+	edit = VideoEdit{User: user, Time: time.Now()}
+
+	if update.Title != nil && strings.TrimSpace(*update.Title) != dbVideo.Title {
+		edit.Changes = append(edit.Changes, FieldChange{Field: "title", Old: dbVideo.Title, New: strings.TrimSpace(*update.Title)})
+		dbVideo.Title = strings.TrimSpace(*update.Title)
+	}
+
+	if update.Description != nil && *update.Description != dbVideo.Description {
+		edit.Changes = append(edit.Changes, FieldChange{Field: "description", Old: dbVideo.Description, New: *update.Description})
+		dbVideo.Description = *update.Description
+	}
+
+	if update.PubDate != nil && !update.PubDate.Equal(dbVideo.PubDate) {
+		edit.Changes = append(edit.Changes, FieldChange{Field: "pubDate", Old: dbVideo.PubDate, New: *update.PubDate})
+		dbVideo.PubDate = *update.PubDate
+	}
+
+	if update.ImgURL != nil && *update.ImgURL != dbVideo.ImgURL {
+		edit.Changes = append(edit.Changes, FieldChange{Field: "imgURL", Old: dbVideo.ImgURL, New: *update.ImgURL})
+		dbVideo.ImgURL = *update.ImgURL
+	}
+
+	if update.VideoURL != nil && *update.VideoURL != dbVideo.VideoURL {
+		edit.Changes = append(edit.Changes, FieldChange{Field: "videoURL", Old: dbVideo.VideoURL, New: *update.VideoURL})
+		dbVideo.VideoURL = *update.VideoURL
+	}
+
+	if update.Actors != nil {
+		var actors []int
+
+		for _, name := range *update.Actors {
+			actor, _ := storage.GetActorByName(strings.TrimSpace(name))
+
+			if actor.Name == "" {
+				err = storage.insertActor(tx.Bucket([]byte("actors")), mpclibrary.Actor{Name: strings.TrimSpace(name)})
+				if err != nil {
+					return
+				}
+
+				actor, _ = storage.GetActorByName(strings.TrimSpace(name))
+			}
+
+			if !containsInt(actors, actor.ID) {
+				actors = append(actors, actor.ID)
+			}
+		}
+
+		if !equalInts(actors, dbVideo.Actors) {
+			edit.Changes = append(edit.Changes, FieldChange{Field: "actors", Old: dbVideo.Actors, New: actors})
+			dbVideo.Actors = actors
+		}
+	}
+
+	if update.Categories != nil {
+		var categories []int
+
+		for _, name := range *update.Categories {
+			category, _ := storage.GetCategoryByName(strings.TrimSpace(name))
+
+			if category.Name == "" {
+				err = storage.insertCategory(tx.Bucket([]byte("categories")), mpclibrary.Category{Name: strings.TrimSpace(name)})
+				if err != nil {
+					return
+				}
+
+				category, _ = storage.GetCategoryByName(strings.TrimSpace(name))
+			}
+
+			if !containsInt(categories, category.ID) {
+				categories = append(categories, category.ID)
+			}
+		}
+
+		if !equalInts(categories, dbVideo.Categories) {
+			edit.Changes = append(edit.Changes, FieldChange{Field: "categories", Old: dbVideo.Categories, New: categories})
+			dbVideo.Categories = categories
+		}
+	}
+
+	if update.Tags != nil {
+		var tags []int
+
+		for _, name := range *update.Tags {
+			tag, _ := storage.GetTagByName(strings.TrimSpace(name))
+
+			if tag.Name == "" {
+				tag, err = storage.insertTag(tx.Bucket([]byte("tags")), strings.TrimSpace(name))
+				if err != nil {
+					return
+				}
+			}
+
+			if !containsInt(tags, tag.ID) {
+				tags = append(tags, tag.ID)
+			}
+		}
+
+		if !equalInts(tags, dbVideo.Tags) {
+			edit.Changes = append(edit.Changes, FieldChange{Field: "tags", Old: dbVideo.Tags, New: tags})
+			dbVideo.Tags = tags
+		}
+	}
+
+	if len(edit.Changes) == 0 {
+		return
+	}
+
+	jsonVideo, err = json.Marshal(dbVideo)
+	if err != nil {
+		return
+	}
+
+	err = videosBucket.Put([]byte(videoID), jsonVideo)
+	if err != nil {
+		return
+	}
+
+	err = appendVideoEdit(tx.Bucket([]byte("history")), videoID, edit)
+
+	return
+}
+
+// GetVideoHistory gets the list of changes of a video
+//
+func (storage *Storage) GetVideoHistory(videoID string) (history []VideoEdit, err error) {
+	err = storage.Db.View(func(tx *bolt.Tx) error {
+		jsonHistory := tx.Bucket([]byte("history")).Get([]byte(videoID))
+
+		if jsonHistory == nil {
+			return nil
+		}
+
+		return json.Unmarshal(jsonHistory, &history)
+	})
+
+	return
+}
+
+// appendVideoEdit adds a change to the edit history of the video
+//
+func appendVideoEdit(bucket *bolt.Bucket, videoID string, edit VideoEdit) error {
+	var history []VideoEdit
+
+	jsonHistory := bucket.Get([]byte(videoID))
+
+	if jsonHistory != nil {
+		err := json.Unmarshal(jsonHistory, &history)
+		if err != nil {
+			return err
+		}
+	}
+
+	history = append(history, edit)
+
+	jsonHistory, err := json.Marshal(history)
+	if err != nil {
+		return err
+	}
+
+	return bucket.Put([]byte(videoID), jsonHistory)
+}
+
+// validateVideoUpdate checks the fields of the video update
+//
+func validateVideoUpdate(update VideoUpdate) error {
+	if update.Title != nil && strings.TrimSpace(*update.Title) == "" {
+		return errors.New("video_title_empty")
+	}
+
+	if update.Actors != nil {
+		for _, actor := range *update.Actors {
+			if strings.TrimSpace(actor) == "" {
+				return errors.New("video_actor_empty")
+			}
+		}
+	}
+
+	if update.Categories != nil {
+		for _, category := range *update.Categories {
+			if strings.TrimSpace(category) == "" {
+				return errors.New("video_category_empty")
+			}
+		}
+	}
+
+	if update.Tags != nil {
+		for _, tag := range *update.Tags {
+			if strings.TrimSpace(tag) == "" {
+				return errors.New("video_tag_empty")
+			}
+		}
+	}
+
+	return nil
+}
+
+// equalInts checks if both lists have the same values in the same order
+//
+func equalInts(a []int, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,55 @@
+package mpcstorage
+
+import (
+	"github.com/jempe/mpc/library"
+	"testing"
+)
+
+func TestUpdateVideo(t *testing.T) {
+	testStorage, _ := newTestStorage(t)
+
+	err := testStorage.InsertVideos([]mpclibrary.Video{{File: "video.mp4", Title: "video.mp4", Encrypted: true}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = testStorage.GetAllVideos()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	video, _ := testStorage.GetVideoByFileName("video.mp4")
+
+	title := "My Video"
+	actors := []string{"Jane Doe"}
+
+	updated, err := testStorage.UpdateVideo(video.ID, VideoUpdate{Title: &title, Actors: &actors}, "user-uuid")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if updated.Title != title || len(updated.Actors) != 1 || updated.Actors[0].Name != "Jane Doe" {
+		t.Error("video should be updated", updated)
+	}
+
+	history, err := testStorage.GetVideoHistory(video.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(history) != 1 || history[0].User != "user-uuid" || len(history[0].Changes) != 2 {
+		t.Error("edit should be saved in the video history", history)
+	}
+
+	empty := " "
+
+	_, err = testStorage.UpdateVideo(video.ID, VideoUpdate{Title: &empty}, "user-uuid")
+	if err == nil {
+		t.Error("empty titles should be rejected")
+	}
+
+	_, err = testStorage.UpdateVideo(video.ID, VideoUpdate{Title: &title}, "")
+	if err == nil || err.Error() != "video_edit_user_empty" {
+		t.Error("anonymous edits should be rejected", err)
+	}
+}
@@ -0,0 +1,99 @@
+package mpcstorage
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/boltdb/bolt"
+	"time"
+)
+
+type Watch struct {
+	Count int       `json:"count"`
+	Last  time.Time `json:"last"`
+}
+
+// RecordWatch increases the number of times that the user watched the video
+//
+func (storage *Storage) RecordWatch(userID string, videoID string) (watch Watch, err error) {
+	if _, ok := storage.CachedVideo(videoID); !ok {
+		return watch, errors.New("video_not_exists")
+	}
+
+	err = storage.Db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte("watches"))
+
+		watches, err := userWatches(bucket, userID)
+		if err != nil {
+			return err
+		}
+
+		watch = watches[videoID]
+		watch.Count++
+		watch.Last = time.Now()
+
+		watches[videoID] = watch
+
+		jsonWatches, err := json.Marshal(watches)
+		if err != nil {
+			return err
+		}
+
+		return bucket.Put([]byte(userID), jsonWatches)
+	})
+
+	return
+}
+
+// GetWatches gets the videos watched by the user
+//
+func (storage *Storage) GetWatches(userID string) (watches map[string]Watch, err error) {
+	err = storage.Db.View(func(tx *bolt.Tx) error {
+		watches, err = userWatches(tx.Bucket([]byte("watches")), userID)
+		return err
+	})
+
+	return
+}
+
+// userWatches reads the videos watched by the user from the watches bucket
+//
+func userWatches(bucket *bolt.Bucket, userID string) (watches map[string]Watch, err error) {
+	watches = make(map[string]Watch)
+
+	jsonWatches := bucket.Get([]byte(userID))
+
+	if jsonWatches != nil {
+		err = json.Unmarshal(jsonWatches, &watches)
+	}
+
+	return
+}
+
+// LastWatches gets the last time that any user watched every video
+//
+func (storage *Storage) LastWatches() (lastWatches map[string]time.Time, err error) {
+	lastWatches = make(map[string]time.Time)
+
+	err = storage.Db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket([]byte("watches")).Cursor()
+
+		for k, v := c.First(); k != nil; k, v = c.Next() {
+			var watches map[string]Watch
+
+			err := json.Unmarshal(v, &watches)
+			if err != nil {
+				return err
+			}
+
+			for videoID, watch := range watches {
+				if watch.Last.After(lastWatches[videoID]) {
+					lastWatches[videoID] = watch.Last
+				}
+			}
+		}
+
+		return nil
+	})
+
+	return
+}
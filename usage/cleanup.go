@@ -0,0 +1,219 @@
+package mpcusage
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// GB is the size of a gigabyte in bytes
+const GB = 1 << 30
+
+// names of the cleanup policies
+const (
+	PolicyCache       = "cache"
+	PolicyScreenshots = "screenshots"
+)
+
+// Policy has the limits of the cleanup, a limit is disabled when it's zero
+type Policy struct {
+	// MaxCacheGB is the max size of the image cache, the oldest files are deleted when it's larger
+	MaxCacheGB float64 `json:"maxCacheGB"`
+	// ScreenshotsUnwatchedDays deletes the screenshots of the videos that were not watched in these days,
+	// the screenshots are generated again when they are requested. The encrypted videos are skipped, and the
+	// policy is skipped until the players record the first watch
+	ScreenshotsUnwatchedDays int `json:"screenshotsUnwatchedDays"`
+	// MinFreeGB is the free space of the library disk below which there is a warning
+	MinFreeGB float64 `json:"minFreeGB"`
+}
+
+// Action is a file deleted by a cleanup policy
+type Action struct {
+	Policy  string `json:"policy"`
+	Path    string `json:"path"`
+	VideoID string `json:"videoID,omitempty"`
+	Size    int64  `json:"size"`
+	Error   string `json:"error,omitempty"`
+}
+
+// CleanupResult has the files deleted by the cleanup and the warnings of the policy
+type CleanupResult struct {
+	Actions []Action `json:"actions"`
+	// Freed is the size of the files that were deleted, or that would be deleted in a dry run
+	Freed    int64    `json:"freed"`
+	Warnings []string `json:"warnings"`
+	DryRun   bool     `json:"dryRun"`
+}
+
+// LoadPolicy reads the cleanup policy from a JSON file
+//
+func LoadPolicy(file string) (policy Policy, err error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return
+	}
+
+	err = json.Unmarshal(data, &policy)
+
+	return
+}
+
+// Enabled checks if the policy has any limit
+//
+func (policy Policy) Enabled() bool {
+	return policy.MaxCacheGB > 0 || policy.ScreenshotsUnwatchedDays > 0 || policy.MinFreeGB > 0
+}
+
+// Warnings checks the free space of the disk
+//
+func (policy Policy) Warnings(space DiskSpace) (warnings []string) {
+	warnings = []string{}
+
+	if policy.MinFreeGB > 0 && space.Error == "" && float64(space.Free) < policy.MinFreeGB*GB {
+		warnings = append(warnings, fmt.Sprintf("the free space of the library disk is %.1f GB, the minimum is %.1f GB", float64(space.Free)/GB, policy.MinFreeGB))
+	}
+
+	return
+}
+
+// Cleanup deletes the files that the policy allows, when dryRun is set the files are only listed
+//
+func (disk *Disk) Cleanup(policy Policy, dryRun bool) (result CleanupResult, err error) {
+	result = CleanupResult{Actions: []Action{}, DryRun: dryRun}
+
+	if policy.MaxCacheGB > 0 && disk.Folders[TypeCache] != "" {
+		result.Actions = append(result.Actions, evictCache(disk.Folders[TypeCache], int64(policy.MaxCacheGB*GB))...)
+	}
+
+	watched := true
+
+	if policy.ScreenshotsUnwatchedDays > 0 {
+		var actions []Action
+
+		actions, watched, err = disk.unwatchedScreenshots(time.Now().AddDate(0, 0, -policy.ScreenshotsUnwatchedDays))
+		if err != nil {
+			return
+		}
+
+		result.Actions = append(result.Actions, actions...)
+	}
+
+	for index := range result.Actions {
+		action := &result.Actions[index]
+
+		if !dryRun {
+			err := os.Remove(action.Path)
+			if err != nil && !os.IsNotExist(err) {
+				action.Error = err.Error()
+				continue
+			}
+		}
+
+		result.Freed += action.Size
+	}
+
+	var space DiskSpace
+
+	space.Free, space.Size, err = FreeSpace(disk.Library.Path)
+	if err != nil {
+		space.Error = err.Error()
+		err = nil
+	}
+
+	result.Warnings = policy.Warnings(space)
+
+	if !watched {
+		result.Warnings = append(result.Warnings, "the screenshots policy is skipped because no watches were recorded yet")
+	}
+
+	return
+}
+
+// evictCache lists the oldest files of the cache that must be deleted so the cache is not larger than the max size
+//
+func evictCache(folder string, maxSize int64) (actions []Action) {
+	type cachedFile struct {
+		path    string
+		size    int64
+		modTime time.Time
+	}
+
+	var files []cachedFile
+	var size int64
+
+	filepath.Walk(folder, func(path string, info os.FileInfo, err error) error {
+		if err == nil && !info.IsDir() {
+			files = append(files, cachedFile{path: path, size: info.Size(), modTime: info.ModTime()})
+			size += info.Size()
+		}
+
+		return nil
+	})
+
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].modTime.Before(files[j].modTime)
+	})
+
+	for _, file := range files {
+		if size <= maxSize {
+			break
+		}
+
+		actions = append(actions, Action{Policy: PolicyCache, Path: file.path, Size: file.size})
+		size -= file.size
+	}
+
+	return
+}
+
+// unwatchedScreenshots lists the screenshots of the videos that were not watched since the time, the videos that
+// were never watched are checked by the time when they were added. Nothing is listed while there are no watches,
+// because then every video looks unwatched
+//
+func (disk *Disk) unwatchedScreenshots(since time.Time) (actions []Action, watched bool, err error) {
+	lastWatches, err := disk.Storage.LastWatches()
+	if err != nil {
+		return
+	}
+
+	watched = len(lastWatches) > 0
+	if !watched {
+		return
+	}
+
+	for _, videoID := range disk.Storage.VideoIDs() {
+		video, _ := disk.Storage.GetVideoByID(videoID)
+
+		// the screenshots of the encrypted videos can't be generated again
+		if video.Encrypted {
+			continue
+		}
+
+		last := lastWatches[videoID]
+		if last.IsZero() {
+			last = video.Added
+		}
+
+		if !last.Before(since) {
+			continue
+		}
+
+		screenshots, _ := filepath.Glob(disk.Library.ScreenshotsFolder(video) + "/*.jpg")
+
+		for _, screenshot := range screenshots {
+			if info, err := os.Stat(screenshot); err == nil {
+				actions = append(actions, Action{Policy: PolicyScreenshots, Path: screenshot, VideoID: videoID, Size: info.Size()})
+			}
+		}
+	}
+
+	sort.Slice(actions, func(i, j int) bool {
+		return actions[i].Path < actions[j].Path
+	})
+
+	return
+}
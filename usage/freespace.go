@@ -0,0 +1,21 @@
+//go:build linux || darwin
+// +build linux darwin
+
+package mpcusage
+
+import (
+	"syscall"
+)
+
+// FreeSpace gets the free bytes for the users and the size of the disk of the path
+//
+func FreeSpace(path string) (free int64, size int64, err error) {
+	var stat syscall.Statfs_t
+
+	err = syscall.Statfs(path, &stat)
+	if err != nil {
+		return
+	}
+
+	return int64(stat.Bavail) * int64(stat.Bsize), int64(stat.Blocks) * int64(stat.Bsize), nil
+}
@@ -0,0 +1,14 @@
+//go:build !linux && !darwin
+// +build !linux,!darwin
+
+package mpcusage
+
+import (
+	"errors"
+)
+
+// FreeSpace is not supported in this OS, the free space warnings are skipped
+//
+func FreeSpace(path string) (free int64, size int64, err error) {
+	return 0, 0, errors.New("free_space_unsupported")
+}
@@ -0,0 +1,258 @@
+// MPC Usage reports the disk space used by the library and the caches. The bytes are attributed to the videos, their
+// actors and categories and the type of file, and the cleanup policies free the space that can be generated again
+//
+package mpcusage
+
+import (
+	"github.com/jempe/mpc/fsck"
+	"github.com/jempe/mpc/library"
+	"github.com/jempe/mpc/storage"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// types of the files
+const (
+	TypeVideo       = "video"
+	TypeThumbnail   = "thumbnail"
+	TypeScreenshots = "screenshots"
+	TypeSidecar     = "sidecar"
+	TypeArtwork     = "artwork"
+	TypeCache       = "cache"
+	TypeQuarantine  = "quarantine"
+	// TypeOther are the files of the library that don't belong to a video
+	TypeOther = "other"
+)
+
+// Report has the disk usage of the library and the caches
+type Report struct {
+	// Total is the size of all the files in bytes
+	Total int64            `json:"total"`
+	Types map[string]int64 `json:"types"`
+	// Videos are sorted from the largest to the smallest
+	Videos []VideoUsage `json:"videos"`
+	// Actors and Categories have the size of their videos, a video is counted in all its actors and categories
+	Actors     []GroupUsage `json:"actors"`
+	Categories []GroupUsage `json:"categories"`
+	Disk       DiskSpace    `json:"disk"`
+	Warnings   []string     `json:"warnings"`
+	Created    time.Time    `json:"created"`
+}
+
+// VideoUsage is the size of the files of a video
+type VideoUsage struct {
+	VideoID string           `json:"id"`
+	Title   string           `json:"title"`
+	Total   int64            `json:"total"`
+	Types   map[string]int64 `json:"types"`
+}
+
+// GroupUsage is the size of the videos of an actor or a category
+type GroupUsage struct {
+	ID     int    `json:"id"`
+	Name   string `json:"name"`
+	Videos int    `json:"videos"`
+	Total  int64  `json:"total"`
+}
+
+// DiskSpace is the space of the disk of the library
+type DiskSpace struct {
+	Free  int64  `json:"free"`
+	Size  int64  `json:"size"`
+	Error string `json:"error,omitempty"`
+}
+
+// Disk measures the files of the library and the caches
+type Disk struct {
+	Storage *mpcstorage.Storage
+	Library *mpclibrary.Library
+	// Folders are the folders outside the library by type, like the artwork and the image cache
+	Folders map[string]string
+}
+
+// Report measures the files of every video, the folders of the caches and the other files of the library
+//
+func (disk *Disk) Report() (report Report, err error) {
+	report = Report{Types: make(map[string]int64), Videos: []VideoUsage{}, Actors: []GroupUsage{}, Categories: []GroupUsage{}, Warnings: []string{}, Created: time.Now()}
+
+	attributed := make(map[string]bool)
+
+	actors := make(map[int]*GroupUsage)
+	categories := make(map[int]*GroupUsage)
+
+	for _, videoID := range disk.Storage.VideoIDs() {
+		video, _ := disk.Storage.GetVideoByID(videoID)
+
+		usage := disk.videoUsage(video, attributed)
+
+		for fileType, size := range usage.Types {
+			report.Types[fileType] += size
+		}
+
+		report.Total += usage.Total
+		report.Videos = append(report.Videos, usage)
+
+		for _, actor := range video.Actors {
+			addGroup(actors, actor.ID, actor.Name, usage.Total)
+		}
+
+		for _, category := range video.Categories {
+			addGroup(categories, category.ID, category.Name, usage.Total)
+		}
+	}
+
+	// the files of the library that don't belong to a video, the quarantine folder has its own type
+	err = filepath.Walk(disk.Library.Path, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if info.IsDir() || attributed[path] {
+			return nil
+		}
+
+		fileType := TypeOther
+
+		if relative, err := filepath.Rel(disk.Library.Path, path); err == nil && firstFolder(relative) == mpcfsck.QuarantineFolder {
+			fileType = TypeQuarantine
+		}
+
+		report.Types[fileType] += info.Size()
+		report.Total += info.Size()
+
+		return nil
+	})
+	if err != nil {
+		return
+	}
+
+	for fileType, folder := range disk.Folders {
+		size := FolderSize(folder)
+
+		report.Types[fileType] += size
+		report.Total += size
+	}
+
+	report.Disk.Free, report.Disk.Size, err = FreeSpace(disk.Library.Path)
+	if err != nil {
+		report.Disk.Error = err.Error()
+		err = nil
+	}
+
+	sort.Slice(report.Videos, func(i, j int) bool {
+		if report.Videos[i].Total != report.Videos[j].Total {
+			return report.Videos[i].Total > report.Videos[j].Total
+		}
+
+		return report.Videos[i].VideoID < report.Videos[j].VideoID
+	})
+
+	report.Actors = sortedGroups(actors)
+	report.Categories = sortedGroups(categories)
+
+	return
+}
+
+// videoUsage measures the video file, the thumbnail, the screenshots and the sidecar files of a video,
+// the paths of the measured files are added to attributed
+//
+func (disk *Disk) videoUsage(video mpclibrary.Video, attributed map[string]bool) (usage VideoUsage) {
+	usage = VideoUsage{VideoID: video.ID, Title: video.Title, Types: make(map[string]int64)}
+
+	files := map[string]string{
+		disk.Library.Path + "/" + video.File: TypeVideo,
+		disk.Library.ThumbnailPath(video):    TypeThumbnail,
+		disk.Library.JSONDataPath(video):     TypeSidecar,
+		disk.Library.NFODataPath(video):      TypeSidecar,
+	}
+
+	for path, fileType := range files {
+		if path == "" {
+			continue
+		}
+
+		path = filepath.Clean(path)
+
+		if info, err := os.Stat(path); err == nil && !info.IsDir() && !attributed[path] {
+			attributed[path] = true
+			usage.Types[fileType] += info.Size()
+		}
+	}
+
+	screenshots := filepath.Clean(disk.Library.ScreenshotsFolder(video))
+
+	filepath.Walk(screenshots, func(path string, info os.FileInfo, err error) error {
+		if err == nil && !info.IsDir() && !attributed[path] {
+			attributed[path] = true
+			usage.Types[TypeScreenshots] += info.Size()
+		}
+
+		return nil
+	})
+
+	for _, size := range usage.Types {
+		usage.Total += size
+	}
+
+	return
+}
+
+// FolderSize gets the size of the files of a folder and its subfolders
+//
+func FolderSize(folder string) (size int64) {
+	filepath.Walk(folder, func(path string, info os.FileInfo, err error) error {
+		if err == nil && !info.IsDir() {
+			size += info.Size()
+		}
+
+		return nil
+	})
+
+	return
+}
+
+// addGroup adds the size of a video to an actor or a category
+//
+func addGroup(groups map[int]*GroupUsage, id int, name string, size int64) {
+	if groups[id] == nil {
+		groups[id] = &GroupUsage{ID: id, Name: name}
+	}
+
+	groups[id].Videos++
+	groups[id].Total += size
+}
+
+// sortedGroups gets the groups from the largest to the smallest
+//
+func sortedGroups(groups map[int]*GroupUsage) (sorted []GroupUsage) {
+	sorted = []GroupUsage{}
+
+	for _, group := range groups {
+		sorted = append(sorted, *group)
+	}
+
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].Total != sorted[j].Total {
+			return sorted[i].Total > sorted[j].Total
+		}
+
+		return sorted[i].ID < sorted[j].ID
+	})
+
+	return
+}
+
+// firstFolder gets the first folder of a relative path
+//
+func firstFolder(path string) string {
+	for {
+		parent := filepath.Dir(path)
+		if parent == "." || parent == string(filepath.Separator) {
+			return path
+		}
+
+		path = parent
+	}
+}
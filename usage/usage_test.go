@@ -0,0 +1,182 @@
+package mpcusage
+
+import (
+	"github.com/jempe/mpc/library"
+	"github.com/jempe/mpc/storage"
+	"github.com/jempe/mpc/utils"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeFile(t *testing.T, path string, size int) {
+	err := os.MkdirAll(path[:strings.LastIndex(path, "/")], 0700)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = ioutil.WriteFile(path, []byte(strings.Repeat("x", size)), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func newTestDisk(t *testing.T) *Disk {
+	testStorage := &mpcstorage.Storage{Path: t.TempDir()}
+
+	err := testStorage.InitDb()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() {
+		testStorage.Db.Close()
+	})
+
+	videos := []mpclibrary.Video{
+		{File: "first.mp4", Md5Sum: "first", Title: "First", Actors: []mpclibrary.Actor{{Name: "Jane Doe"}}, Categories: []mpclibrary.Category{{Name: "Drama"}}},
+		{File: "second.enc", Title: "Second", Encrypted: true, Actors: []mpclibrary.Actor{{Name: "Jane Doe"}}},
+	}
+
+	err = testStorage.InsertVideos(videos)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = testStorage.GetAllVideos()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &Disk{Storage: testStorage, Library: &mpclibrary.Library{Path: t.TempDir()}, Folders: map[string]string{TypeCache: t.TempDir()}}
+}
+
+func TestReport(t *testing.T) {
+	disk := newTestDisk(t)
+	libraryPath := disk.Library.Path
+
+	second, _ := disk.Storage.GetVideoByFileName("second.enc")
+
+	writeFile(t, libraryPath+"/first.mp4", 1000)
+	writeFile(t, libraryPath+"/first.mp4.jpg", 100)
+	writeFile(t, libraryPath+"/first.json", 10)
+	writeFile(t, libraryPath+"/thumbs/first/0.jpg", 50)
+	writeFile(t, libraryPath+"/thumbs/first/10.jpg", 50)
+	writeFile(t, libraryPath+"/second.enc", 2000)
+	writeFile(t, libraryPath+"/second_thumb.enc", 200)
+	writeFile(t, libraryPath+"/thumbs/"+second.ID+"/0.enc", 70)
+	writeFile(t, libraryPath+"/notes.txt", 5)
+	writeFile(t, libraryPath+"/quarantine/stray.mp4", 300)
+	writeFile(t, disk.Folders[TypeCache]+"/thumbs/first_w320.jpg", 40)
+
+	report, err := disk.Report()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]int64{TypeVideo: 3000, TypeThumbnail: 300, TypeSidecar: 10, TypeScreenshots: 170, TypeOther: 5, TypeQuarantine: 300, TypeCache: 40}
+
+	for fileType, size := range expected {
+		if report.Types[fileType] != size {
+			t.Error("wrong size of", fileType, report.Types[fileType], size)
+		}
+	}
+
+	if report.Total != 3825 {
+		t.Error("wrong total", report.Total)
+	}
+
+	if len(report.Videos) != 2 || report.Videos[0].VideoID != second.ID || report.Videos[0].Total != 2270 || report.Videos[1].Total != 1210 {
+		t.Error("wrong usage of the videos", report.Videos)
+	}
+
+	if len(report.Actors) != 1 || report.Actors[0].Name != "Jane Doe" || report.Actors[0].Videos != 2 || report.Actors[0].Total != 3480 {
+		t.Error("wrong usage of the actors", report.Actors)
+	}
+
+	if len(report.Categories) != 1 || report.Categories[0].Name != "Drama" || report.Categories[0].Total != 1210 {
+		t.Error("wrong usage of the categories", report.Categories)
+	}
+}
+
+func TestCleanup(t *testing.T) {
+	disk := newTestDisk(t)
+	libraryPath := disk.Library.Path
+	cachePath := disk.Folders[TypeCache]
+
+	second, _ := disk.Storage.GetVideoByFileName("second.enc")
+
+	writeFile(t, libraryPath+"/thumbs/first/0.jpg", 50)
+	writeFile(t, libraryPath+"/thumbs/first/10.jpg", 50)
+	writeFile(t, libraryPath+"/thumbs/"+second.ID+"/0.enc", 70)
+
+	for index, name := range []string{"old.jpg", "middle.jpg", "new.jpg"} {
+		writeFile(t, cachePath+"/"+name, 1000)
+
+		modTime := time.Now().Add(time.Duration(index-3) * time.Hour)
+		os.Chtimes(cachePath+"/"+name, modTime, modTime)
+	}
+
+	// the videos were added 30 days ago and they were never watched
+	for videoID, video := range disk.Storage.Videos {
+		video.Added = time.Now().AddDate(0, 0, -30)
+		disk.Storage.Videos[videoID] = video
+	}
+
+	policy := Policy{MaxCacheGB: 1500.0 / GB, ScreenshotsUnwatchedDays: 7, MinFreeGB: 1 << 30}
+
+	result, err := disk.Cleanup(policy, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(result.Actions) != 2 || len(result.Warnings) != 2 {
+		t.Fatal("the screenshots should be kept until a watch is recorded", result.Actions, result.Warnings)
+	}
+
+	_, err = disk.Storage.RecordWatch("user", second.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result, err = disk.Cleanup(policy, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(result.Actions) != 4 || result.Freed != 2100 {
+		t.Fatal("wrong cleanup", result.Actions, result.Freed)
+	}
+
+	if result.Actions[0].Path != cachePath+"/old.jpg" || result.Actions[1].Path != cachePath+"/middle.jpg" {
+		t.Error("the oldest cached files should be evicted", result.Actions)
+	}
+
+	if !mpcutils.Exists(cachePath + "/old.jpg") {
+		t.Error("the dry run should not delete the files")
+	}
+
+	if len(result.Warnings) != 1 {
+		t.Error("there should be a free space warning", result.Warnings)
+	}
+
+	_, err = disk.Storage.RecordWatch("user", disk.Storage.Videos["first"].ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result, err = disk.Cleanup(Policy{MaxCacheGB: 1500.0 / GB, ScreenshotsUnwatchedDays: 7}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(result.Actions) != 2 || result.Freed != 2000 || len(result.Warnings) != 0 {
+		t.Error("the screenshots of the watched video should be kept", result)
+	}
+
+	if mpcutils.Exists(cachePath+"/old.jpg") || !mpcutils.Exists(cachePath+"/new.jpg") || !mpcutils.Exists(libraryPath+"/thumbs/first/0.jpg") {
+		t.Error("only the oldest cached files should be deleted")
+	}
+}
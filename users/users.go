@@ -1,8 +1,27 @@
 package mpcusers
 
+const (
+	RoleAdmin = "admin"
+	RoleUser  = "user"
+	RoleGuest = "guest"
+)
+
 type User struct {
 	UUID     string `json:"uuid"`
 	Name     string `json:"name"`
 	Email    string `json:"email"`
 	Password string `json:"password"`
+	Role     string `json:"role"`
+}
+
+// IsAdmin checks if the user can manage the library, users created before roles existed keep full access
+//
+func (user User) IsAdmin() bool {
+	return user.Role == RoleAdmin || user.Role == ""
+}
+
+// IsGuest checks if the user can only use the players that it was invited to
+//
+func (user User) IsGuest() bool {
+	return user.Role == RoleGuest
 }
@@ -5,11 +5,11 @@ package mpcutils
 import (
 	"crypto/md5"
 	"encoding/hex"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"net"
-	"net/http"
 	"os"
 	"os/exec"
 	"path/filepath"
@@ -29,6 +29,10 @@ type VideoInfo struct {
 
 var DefaultSteps int = 30 // Default number of steps to navigate through the video, this changes the total of screenshots that will be taken
 
+// FFmpegPath and FFprobePath are the binaries used to process the videos, they are found in the PATH by default
+var FFmpegPath = "ffmpeg"
+var FFprobePath = "ffprobe"
+
 // Gets MD5 sum of a string
 //
 func MD5SumString(name string) string {
@@ -44,7 +48,7 @@ func byte2string(in [16]byte) []byte {
 // FFProbe gets video information using the ffprobe binary
 //
 func FFProbe(file string) (videoInfo VideoInfo, err error) {
-	out, err := exec.Command("ffprobe", "-v", "error", "-select_streams", "v:0", "-show_entries", "stream=height,width,duration", file).Output()
+	out, err := exec.Command(FFprobePath, "-v", "error", "-select_streams", "v:0", "-show_entries", "stream=height,width,duration", file).Output()
 	if err != nil {
 		return videoInfo, err
 	}
@@ -81,10 +85,38 @@ func FFProbe(file string) (videoInfo VideoInfo, err error) {
 	return videoInfo, err
 }
 
+// FFProbeTags gets the tags of the video container using the ffprobe binary, the tag names are lower case
+//
+func FFProbeTags(file string) (tags map[string]string, err error) {
+	out, err := exec.Command(FFprobePath, "-v", "error", "-show_entries", "format_tags", "-of", "json", file).Output()
+	if err != nil {
+		return nil, err
+	}
+
+	var probe struct {
+		Format struct {
+			Tags map[string]string `json:"tags"`
+		} `json:"format"`
+	}
+
+	err = json.Unmarshal(out, &probe)
+	if err != nil {
+		return nil, err
+	}
+
+	tags = make(map[string]string)
+
+	for name, value := range probe.Format.Tags {
+		tags[strings.ToLower(name)] = value
+	}
+
+	return tags, nil
+}
+
 // SaveScreenshot saves video screenshot as jpg file using ffmpeg
 //
 func SaveScreenshot(video string, time string, target string) (err error) {
-	_, err = exec.Command("ffmpeg", "-ss", time, "-i", video, "-vframes", "1", "-q:v", "2", target).Output()
+	_, err = exec.Command(FFmpegPath, "-ss", time, "-i", video, "-vframes", "1", "-q:v", "2", target).Output()
 
 	fmt.Println(video)
 
@@ -104,39 +136,6 @@ func Exists(file string) bool {
 	return false
 }
 
-// DownloadImage downloads an image and saves it locally
-//
-func DownloadImage(url string, path string) error {
-	client := &http.Client{}
-
-	resp, err := client.Get(url)
-	defer resp.Body.Close()
-
-	if err == nil {
-		file, err := os.Create(path)
-
-		defer file.Close()
-
-		if err == nil {
-			_, err := io.Copy(file, resp.Body)
-
-			if err == nil {
-				fmt.Println("save " + path)
-			} else {
-				return err
-			}
-
-		} else {
-			return err
-		}
-
-	} else {
-		return err
-	}
-
-	return nil
-}
-
 // HomeFolder finds home Folder for every OS
 //
 func ConfigFolder() (configPath string) {